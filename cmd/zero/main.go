@@ -7,64 +7,138 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 
 	"github.com/dangerclosesec/zero/pkg/engine"
+	"github.com/dangerclosesec/zero/pkg/logging"
 	"github.com/dangerclosesec/zero/pkg/parser"
 	"github.com/dangerclosesec/zero/pkg/providers"
 )
 
+// slowestCount is how many of the slowest resources an apply reports
+const slowestCount = 5
+
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
 	// Define command line flags
 	applyCmd := flag.Bool("apply", false, "Apply the configuration")
 	planCmd := flag.Bool("plan", false, "Show what would be changed")
+	destroyCmd := flag.Bool("destroy", false, "Remove the resources recorded in the state file")
+	graphCmd := flag.Bool("graph", false, "Print the dependency graph in Graphviz DOT format")
+	validateCmd := flag.Bool("validate", false, "Check the configuration without planning or applying it")
+	dryRun := flag.Bool("dry-run", false, "With --apply, go through the apply without changing anything or saving state")
+	reportPath := flag.String("report", "", "With --apply, write the results as JSON to a file")
 	configFile := flag.String("config", "", "Path to the configuration file")
+	configDirFlag := flag.String("config-dir", "", "Load every .cfg file in a directory as one configuration")
 	verbose := flag.Bool("verbose", false, "Enable verbose output")
+	quiet := flag.Bool("quiet", false, "Only print failures and the final summary")
+	jsonOutput := flag.Bool("json", false, "Print the plan as JSON")
+	statePath := flag.String("state", "zero.state.json", "Path to the state file")
+	parallelism := flag.Int("parallelism", runtime.GOMAXPROCS(0), "Maximum number of independent resources to apply at once")
+	colorMode := flag.String("color", "auto", "Color output: auto (only on a terminal), always or never")
+	inferDeps := flag.Bool("infer-deps", false, "Order services after the packages and /etc/<service> files they use")
+	var targets stringList
+	flag.Var(&targets, "target", "Limit the run to a resource (type.name) and its dependencies; may be repeated")
 	flag.Parse()
 
-	if *configFile == "" {
+	if *configFile == "" && *configDirFlag == "" {
 		fmt.Println("Error: No configuration file specified")
 		flag.Usage()
 		os.Exit(1)
 	}
-
-	// Initialize logger
-	if *verbose {
-		log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-	} else {
-		log.SetFlags(0)
+	if *configFile != "" && *configDirFlag != "" {
+		fmt.Println("Error: --config and --config-dir cannot be used together")
+		os.Exit(1)
+	}
+	if *dryRun && !*applyCmd {
+		fmt.Println("Error: --dry-run can only be used with --apply")
+		os.Exit(1)
+	}
+	if *reportPath != "" && !*applyCmd {
+		fmt.Println("Error: --report can only be used with --apply")
+		os.Exit(1)
 	}
 
-	// Get absolute path of config file for includes
-	absConfigPath, err := filepath.Abs(*configFile)
+	level, err := outputVerbosity(*verbose, *quiet)
 	if err != nil {
-		log.Fatalf("Error resolving config path: %v", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
-	configDir := filepath.Dir(absConfigPath)
 
-	// Process includes and variables
-	includeHandler := parser.NewIncludeHandler(configDir)
-	resources, err := includeHandler.ProcessIncludes(absConfigPath)
+	colorOutput, err := useColor(*colorMode, os.Stdout)
 	if err != nil {
-		log.Fatalf("Error processing configuration: %v", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
+	colors := colorizer{enabled: colorOutput}
+	logger := logging.New(os.Stderr, level.logLevel())
 
-	// Process templates
-	processedResources, err := includeHandler.ProcessTemplates(resources)
-	if err != nil {
-		log.Fatalf("Error processing templates: %v", err)
+	// Initialize logger
+	if *verbose {
+		log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+	} else {
+		log.SetFlags(0)
+	}
+
+	// Process includes, variables and templates, either of one config file
+	// or of every config file in a directory
+	var processedResources []parser.Resource
+	if *configDirFlag != "" {
+		absConfigDir, err := filepath.Abs(*configDirFlag)
+		if err != nil {
+			log.Fatalf("Error resolving config directory: %v", err)
+		}
+		includeHandler := parser.NewIncludeHandler(absConfigDir)
+		includeHandler.Logger = logger
+		processedResources, err = includeHandler.LoadDir(absConfigDir)
+		if err != nil {
+			log.Fatalf("Error processing configuration: %v", err)
+		}
+	} else {
+		absConfigPath, err := filepath.Abs(*configFile)
+		if err != nil {
+			log.Fatalf("Error resolving config path: %v", err)
+		}
+		includeHandler := parser.NewIncludeHandler(filepath.Dir(absConfigPath))
+		includeHandler.Logger = logger
+		processedResources, err = includeHandler.Load(absConfigPath)
+		if err != nil {
+			log.Fatalf("Error processing configuration: %v", err)
+		}
 	}
 
 	// Convert parser.Resource to engine.Resource
 	engineResources := make([]engine.Resource, len(processedResources))
 	for i, r := range processedResources {
+		// Validation errors point at the values of the attributes they're about
+		positions := make(map[string]engine.Position, len(r.AttributeSpans))
+		for name, span := range r.AttributeSpans {
+			positions[name] = engine.Position{File: r.File, Line: span.Line, Column: span.Column}
+		}
+
 		engineResources[i] = engine.Resource{
 			Type:       r.Type,
 			Name:       r.Name,
 			Attributes: r.Attributes,
 			DependsOn:  r.DependsOn,
+			Notifies:   r.Notifies,
+			Before:     r.Before,
+			After:      r.After,
 			Conditions: r.Conditions,
+			Positions:  positions,
 		}
 	}
 
@@ -76,16 +150,62 @@ func main() {
 	registry.Register("package", providers.NewPackageProvider())
 	registry.Register("service", providers.NewServiceProvider())
 	registry.Register("windows_feature", providers.NewWindowsFeatureProvider())
+	registry.Register("windows_registry", providers.NewWindowsRegistryProvider())
+	registry.Register("user", providers.NewUserProvider())
+	registry.Register("group", providers.NewGroupProvider())
+	registry.Register("cron", providers.NewCronProvider())
+	registry.Register("exec", providers.NewExecProvider())
+	registry.Register("download", providers.NewDownloadProvider())
+	registry.Register("line_in_file", providers.NewLineInFileProvider())
+	registry.Register("hosts", providers.NewHostsProvider())
+	registry.Register("sysctl", providers.NewSysctlProvider())
+	registry.Register("repository", providers.NewRepositoryProvider())
+	registry.Register("archive", providers.NewArchiveProvider())
+	registry.Register("git", providers.NewGitProvider())
+	registry.Register("template_file", providers.NewTemplateFileProvider())
 
 	// Create engine
 	e := engine.NewEngine(registry)
+	e.SetParallelism(*parallelism)
+	e.SetTargets(targets)
+	e.SetInferDependencies(*inferDeps)
+	e.SetLogger(logger)
+	e.SetDryRun(*dryRun)
+
+	if *graphCmd {
+		// Graph mode - print the dependency graph without planning or applying
+		if err := e.WriteGraph(os.Stdout, engineResources); err != nil {
+			log.Fatalf("Error building dependency graph: %v", err)
+		}
+		return
+	}
+
+	if *validateCmd {
+		// Validate mode - check the configuration without touching the system
+		if err := e.Validate(context.Background(), engineResources); err != nil {
+			fmt.Println(colors.paint(colorRed, fmt.Sprintf("Configuration is invalid: %v", err)))
+			os.Exit(1)
+		}
+		fmt.Println(colors.paint(colorGreen, fmt.Sprintf("Configuration is valid (%d resources)", len(engineResources))))
+		return
+	}
+
+	// Load the state recorded by the previous apply
+	store := engine.NewStateStore(*statePath)
+	priorState, err := store.Load()
+	if err != nil {
+		log.Fatalf("Error loading state: %v", err)
+	}
+	e.SetState(priorState)
 
 	// Create context
 	ctx := context.Background()
 
 	if *planCmd {
 		// Plan mode - show what changes would be made
-		fmt.Println("Planning configuration changes...")
+		if !*jsonOutput && !*quiet {
+			fmt.Println("Planning configuration changes...")
+		}
 		startTime := time.Now()
 
 		plan, err := e.Plan(ctx, engineResources)
@@ -93,49 +213,73 @@ func main() {
 			log.Fatalf("Error planning configuration: %v", err)
 		}
 
+		if *jsonOutput {
+			if err := writePlanJSON(os.Stdout, plan); err != nil {
+				log.Fatalf("Error writing plan: %v", err)
+			}
+			return
+		}
+
 		// Print plan
-		fmt.Println("\nPlan:")
-		fmt.Println(strings.Repeat("-", 60))
+		if !*quiet {
+			fmt.Println("\nPlan:")
+			fmt.Println(strings.Repeat("-", 60))
+		}
 
 		add := 0
 		change := 0
 		destroy := 0
 
-		for id, action := range plan {
+		for _, id := range planIDs(plan) {
+			action := plan[id]
 			switch action.Action {
 			case "create":
-				fmt.Printf("+ create: %s\n", id)
-				if *verbose {
-					fmt.Printf("    %s\n", action.Details)
-				}
 				add++
 			case "update":
-				fmt.Printf("~ update: %s\n", id)
-				if *verbose {
-					fmt.Printf("    %s\n", action.Details)
-				}
 				change++
 			case "delete":
-				fmt.Printf("- delete: %s\n", id)
-				if *verbose {
-					fmt.Printf("    %s\n", action.Details)
-				}
 				destroy++
+			}
+			if !showResult(action.Action, level) {
+				continue
+			}
+
+			switch action.Action {
+			case "create":
+				fmt.Println(colors.paint(colorGreen, "+ create: "+id))
+			case "update":
+				fmt.Println(colors.paint(colorYellow, "~ update: "+id))
+			case "delete":
+				fmt.Println(colors.paint(colorRed, "- delete: "+id))
+			case "error":
+				fmt.Println(colors.paint(colorRed, "! error: "+id))
 			case "no-op":
-				if *verbose {
-					fmt.Printf("  no-op: %s\n", id)
-				}
+				fmt.Printf("  no-op: %s\n", id)
+				continue
+			case "skipped":
+				fmt.Printf("  skipped: %s\n", id)
+			}
+			if *verbose || action.Action == "error" {
+				fmt.Printf("    %s\n", strings.ReplaceAll(action.Details, "\n", "\n    "))
 			}
 		}
 
-		fmt.Println(strings.Repeat("-", 60))
 		duration := time.Since(startTime)
+		if !*quiet {
+			fmt.Println(strings.Repeat("-", 60))
+		}
 		fmt.Printf("Plan: %d to add, %d to change, %d to destroy (in %v)\n",
 			add, change, destroy, duration)
 
 	} else if *applyCmd {
 		// Apply mode
-		fmt.Println("Applying configuration...")
+		if !*quiet {
+			if *dryRun {
+				fmt.Println("Applying configuration (dry run)...")
+			} else {
+				fmt.Println("Applying configuration...")
+			}
+		}
 		startTime := time.Now()
 
 		results, err := e.Apply(ctx, engineResources)
@@ -143,40 +287,135 @@ func main() {
 			log.Fatalf("Error applying configuration: %v", err)
 		}
 
+		// A dry run changed nothing, so there is nothing to record
+		if !*dryRun {
+			if err := store.Save(engine.MergeState(priorState, results)); err != nil {
+				log.Fatalf("Error saving state: %v", err)
+			}
+		}
+
+		if *reportPath != "" {
+			if err := writeReport(*reportPath, results, e.Timings(), e.Notifications(), *dryRun); err != nil {
+				log.Fatalf("Error writing report: %v", err)
+			}
+		}
+
 		// Print results
-		fmt.Println("\nResults:")
-		fmt.Println(strings.Repeat("-", 60))
+		if !*quiet {
+			fmt.Println("\nResults:")
+			fmt.Println(strings.Repeat("-", 60))
+		}
 
 		success := 0
 		failed := 0
 		skipped := 0
 
-		for id, state := range results {
+		for _, id := range resultIDs(results) {
+			state := results[id]
+			show := showResult(state.Status, level)
 			switch state.Status {
-			case "created", "updated":
-				fmt.Printf("✓ %s: %s\n", id, state.Status)
+			case "created", "updated", "deleted":
+				if show {
+					fmt.Println(colors.paint(colorGreen, fmt.Sprintf("✓ %s: %s", id, state.Status)))
+				}
 				success++
-			case "unchanged":
-				if *verbose {
+			case "failed":
+				fmt.Println(colors.paint(colorRed, fmt.Sprintf("✗ %s: %s (%v)", id, state.Status, state.Error)))
+				failed++
+			default:
+				// unchanged, or any other status a provider reports without acting
+				if show {
 					fmt.Printf("- %s: %s\n", id, state.Status)
 				}
 				skipped++
-			case "failed":
-				fmt.Printf("✗ %s: %s (%v)\n", id, state.Status, state.Error)
-				failed++
+			}
+			if show && state.Output != "" && (*verbose || state.Status == "failed") {
+				fmt.Printf("    %s\n", strings.ReplaceAll(strings.TrimRight(state.Output, "\n"), "\n", "\n    "))
 			}
 		}
 
-		fmt.Println(strings.Repeat("-", 60))
 		duration := time.Since(startTime)
-		fmt.Printf("Applied %d resources in %v\n", len(results), duration)
+		if !*quiet {
+			fmt.Println(strings.Repeat("-", 60))
+			fmt.Printf("Applied %d resources in %v\n", len(results), duration)
+		}
 		fmt.Printf("Success: %d, Failed: %d, Skipped: %d\n", success, failed, skipped)
 
+		if notifications := e.Notifications(); len(notifications) > 0 && !*quiet {
+			fmt.Println("\nNotified:")
+			for _, notification := range notifications {
+				fmt.Printf("  %s\n", formatNotification(notification))
+			}
+		}
+
+		if slowest := slowestResources(e.Timings(), slowestCount); len(slowest) > 0 && !*quiet {
+			fmt.Println("\nSlowest resources:")
+			for _, timing := range slowest {
+				fmt.Printf("  %s: %v\n", timing.ID, timing.Duration.Round(time.Millisecond))
+			}
+		}
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	} else if *destroyCmd {
+		// Destroy mode - remove managed resources, dependents first
+		if !*quiet {
+			fmt.Println("Destroying configuration...")
+		}
+		startTime := time.Now()
+
+		results, err := e.Destroy(ctx, engineResources)
+		if err != nil {
+			log.Fatalf("Error destroying configuration: %v", err)
+		}
+
+		if err := store.Save(engine.RemoveDestroyed(priorState, results)); err != nil {
+			log.Fatalf("Error saving state: %v", err)
+		}
+
+		// Print results
+		if !*quiet {
+			fmt.Println("\nResults:")
+			fmt.Println(strings.Repeat("-", 60))
+		}
+
+		destroyed := 0
+		failed := 0
+		skipped := 0
+
+		for _, id := range resultIDs(results) {
+			state := results[id]
+			switch {
+			case state.Status == "failed":
+				fmt.Println(colors.paint(colorRed, fmt.Sprintf("✗ %s: %s (%v)", id, state.Status, state.Error)))
+				failed++
+			case state.Status == "deleted" || state.Changed:
+				if showResult("deleted", level) {
+					fmt.Println(colors.paint(colorGreen, fmt.Sprintf("✓ %s: destroyed", id)))
+				}
+				destroyed++
+			default:
+				// Already absent, so there was nothing to destroy
+				if showResult(state.Status, level) {
+					fmt.Printf("- %s: %s\n", id, state.Status)
+				}
+				skipped++
+			}
+		}
+
+		duration := time.Since(startTime)
+		if !*quiet {
+			fmt.Println(strings.Repeat("-", 60))
+			fmt.Printf("Destroyed %d resources in %v\n", destroyed, duration)
+		}
+		fmt.Printf("Destroyed: %d, Failed: %d, Skipped: %d\n", destroyed, failed, skipped)
+
 		if failed > 0 {
 			os.Exit(1)
 		}
 	} else {
-		fmt.Println("No action specified. Use --plan or --apply")
+		fmt.Println("No action specified. Use --plan, --apply, --destroy, --graph or --validate")
 		flag.Usage()
 		os.Exit(1)
 	}
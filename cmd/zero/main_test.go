@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// A test runs the CLI by starting this binary with ZERO_TEST_MAIN set
+	if os.Getenv("ZERO_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runZero runs the CLI with args and returns what it wrote to stdout and stderr
+func runZero(t *testing.T, args ...string) (string, string) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "ZERO_TEST_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("zero %s failed: %v\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return stdout.String(), stderr.String()
+}
+
+func TestPlanJSON_WarningsGoToStderr(t *testing.T) {
+	tempDir := t.TempDir()
+	config := "include \"missing/*.cfg\"\n" +
+		"file \"" + filepath.ToSlash(filepath.Join(tempDir, "motd")) + "\" {\n  content = \"hello\"\n}\n"
+	configPath := filepath.Join(tempDir, "main.cfg")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	stdout, stderr := runZero(t, "--plan", "--json", "--config", configPath, "--state", filepath.Join(tempDir, "state.json"))
+
+	var entries []planEntry
+	if err := json.Unmarshal([]byte(stdout), &entries); err != nil {
+		t.Fatalf("Expected stdout to be a JSON plan, got %q: %v", stdout, err)
+	}
+	if len(entries) != 1 || entries[0].Action != "create" {
+		t.Errorf("Expected the file to be created, got %+v", entries)
+	}
+	if !strings.Contains(stderr, "Warning: no files matched include pattern missing/*.cfg") {
+		t.Errorf("Expected the warning on stderr, got %q", stderr)
+	}
+}
+
+func TestApply_SummaryCountsEveryResult(t *testing.T) {
+	tempDir := t.TempDir()
+	stale := filepath.Join(tempDir, "stale")
+	if err := os.WriteFile(stale, []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// One file is created, one deleted and one is already absent
+	config := "file \"" + filepath.ToSlash(filepath.Join(tempDir, "motd")) + "\" {\n  content = \"hello\"\n}\n" +
+		"file \"" + filepath.ToSlash(stale) + "\" {\n  state = \"absent\"\n}\n" +
+		"file \"" + filepath.ToSlash(filepath.Join(tempDir, "gone")) + "\" {\n  state = \"absent\"\n}\n"
+	configPath := filepath.Join(tempDir, "main.cfg")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	stdout, _ := runZero(t, "--apply", "--color", "never", "--config", configPath, "--state", filepath.Join(tempDir, "state.json"))
+
+	if !strings.Contains(stdout, "Success: 2, Failed: 0, Skipped: 1") {
+		t.Errorf("Expected every result in the summary, got %q", stdout)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dangerclosesec/zero/pkg/engine"
+	"github.com/dangerclosesec/zero/pkg/logging"
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+// planEntry is the JSON form of a planned action for one resource
+type planEntry struct {
+	ID      string `json:"id"`
+	Action  string `json:"action"`
+	Details string `json:"details"`
+}
+
+// writePlanJSON writes the plan as a JSON array sorted by resource ID
+func writePlanJSON(w io.Writer, plan map[string]engine.PlanAction) error {
+	entries := make([]planEntry, 0, len(plan))
+	for id, action := range plan {
+		entries = append(entries, planEntry{ID: id, Action: action.Action, Details: action.Details})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// planIDs returns the resource IDs in a plan in sorted order, so the plan
+// prints the same way on every run
+func planIDs(plan map[string]engine.PlanAction) []string {
+	ids := make([]string, 0, len(plan))
+	for id := range plan {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// resultIDs returns the resource IDs in apply or destroy results in sorted
+// order
+func resultIDs(results map[string]*providers.ResourceState) []string {
+	ids := make([]string, 0, len(results))
+	for id := range results {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// verbosity is how much per-resource output a run prints
+type verbosity int
+
+const (
+	quietOutput   verbosity = iota // Failures and the final summary only
+	normalOutput                   // Changes and failures
+	verboseOutput                  // Everything, including unchanged resources
+)
+
+// outputVerbosity returns the verbosity selected by the --verbose and
+// --quiet flags, which can't be combined
+func outputVerbosity(verbose, quiet bool) (verbosity, error) {
+	switch {
+	case verbose && quiet:
+		return normalOutput, errors.New("--verbose and --quiet cannot be used together")
+	case verbose:
+		return verboseOutput, nil
+	case quiet:
+		return quietOutput, nil
+	default:
+		return normalOutput, nil
+	}
+}
+
+// logLevel returns the lowest level of progress message printed at a
+// verbosity: failures when quiet and debug detail when verbose
+func (v verbosity) logLevel() logging.Level {
+	switch v {
+	case quietOutput:
+		return logging.LevelError
+	case verboseOutput:
+		return logging.LevelDebug
+	default:
+		return logging.LevelInfo
+	}
+}
+
+// showResult reports whether the line for a resource with the given apply
+// status or plan action is printed. Failures are always shown, unchanged
+// and skipped resources only in verbose output and changes in all but quiet
+// output.
+func showResult(status string, level verbosity) bool {
+	switch status {
+	case "failed", "error":
+		return true
+	case "unchanged", "no-op", "skipped":
+		return level == verboseOutput
+	default:
+		return level >= normalOutput
+	}
+}
+
+// ANSI escape sequences for colored output
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+// colorizer wraps text in ANSI colors when enabled
+type colorizer struct {
+	enabled bool
+}
+
+// paint returns text in the given color, or unchanged if colors are off
+func (c colorizer) paint(color, text string) string {
+	if !c.enabled {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// useColor reports whether output to out should be colored for a --color
+// mode of auto, always or never. In auto mode output is colored only on a
+// terminal, unless NO_COLOR is set.
+func useColor(mode string, out *os.File) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		return isTerminal(out), nil
+	default:
+		return false, fmt.Errorf("--color must be one of: auto, always, never, got %q", mode)
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// resourceTiming is how long one resource took to apply
+type resourceTiming struct {
+	ID       string
+	Duration time.Duration
+}
+
+// slowestResources returns up to n resources from timings, slowest first,
+// with ties in resource ID order
+func slowestResources(timings map[string]time.Duration, n int) []resourceTiming {
+	sorted := make([]resourceTiming, 0, len(timings))
+	for id, duration := range timings {
+		sorted = append(sorted, resourceTiming{ID: id, Duration: duration})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Duration != sorted[j].Duration {
+			return sorted[i].Duration > sorted[j].Duration
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// applyReport is the JSON form of an apply's results written by --report
+type applyReport struct {
+	DryRun    bool                   `json:"dry_run"`
+	Changed   int                    `json:"changed"`
+	Unchanged int                    `json:"unchanged"`
+	Failed    int                    `json:"failed"`
+	Resources map[string]reportEntry `json:"resources"`
+	Notified  []reportNotification   `json:"notified,omitempty"`
+}
+
+// reportNotification is the JSON form of a notification handler that ran
+type reportNotification struct {
+	ID     string   `json:"id"`
+	Action string   `json:"action"`
+	By     []string `json:"by"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// reportEntry is the JSON form of one resource's apply result
+type reportEntry struct {
+	Type       string   `json:"type"`
+	Name       string   `json:"name"`
+	Status     string   `json:"status"`
+	Changed    bool     `json:"changed"`
+	Changes    []string `json:"changes,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	DurationMS int64    `json:"duration_ms"`
+}
+
+// newApplyReport summarizes apply results, with how long each resource took
+// and the notification handlers that ran
+func newApplyReport(results map[string]*providers.ResourceState, timings map[string]time.Duration, notifications []engine.Notification, dryRun bool) applyReport {
+	report := applyReport{DryRun: dryRun, Resources: make(map[string]reportEntry, len(results))}
+	for _, notification := range notifications {
+		entry := reportNotification{ID: notification.ID, Action: notification.Action, By: notification.Sources}
+		if notification.Error != nil {
+			entry.Error = notification.Error.Error()
+		}
+		report.Notified = append(report.Notified, entry)
+	}
+	for id, state := range results {
+		entry := reportEntry{
+			Type:       state.Type,
+			Name:       state.Name,
+			Status:     state.Status,
+			Changed:    state.Changed,
+			Changes:    state.Changes,
+			DurationMS: timings[id].Milliseconds(),
+		}
+		if state.Error != nil {
+			entry.Error = state.Error.Error()
+		}
+		report.Resources[id] = entry
+
+		switch {
+		case state.Status == "failed":
+			report.Failed++
+		case state.Changed:
+			report.Changed++
+		default:
+			report.Unchanged++
+		}
+	}
+	return report
+}
+
+// writeReport writes apply results as JSON to the file at path
+func writeReport(path string, results map[string]*providers.ResourceState, timings map[string]time.Duration, notifications []engine.Notification, dryRun bool) error {
+	data, err := json.MarshalIndent(newApplyReport(results, timings, notifications, dryRun), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// formatNotification describes a notification handler that ran, such as
+// "service.nginx restarted (by file.nginx_conf)"
+func formatNotification(notification engine.Notification) string {
+	line := fmt.Sprintf("%s %s (by %s)", notification.ID, notification.Action, strings.Join(notification.Sources, ", "))
+	if notification.Error != nil {
+		line += fmt.Sprintf(": %v", notification.Error)
+	}
+	return line
+}
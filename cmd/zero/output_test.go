@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dangerclosesec/zero/pkg/engine"
+	"github.com/dangerclosesec/zero/pkg/logging"
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+func TestWritePlanJSON(t *testing.T) {
+	plan := map[string]engine.PlanAction{
+		"service.nginx": {Action: "update", Details: "Resource will be updated"},
+		"file.config":   {Action: "create", Details: "Resource will be created"},
+		"package.curl":  {Action: "no-op", Details: "Resource already in desired state"},
+	}
+
+	// Capture stdout the way main writes the plan
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	err = writePlanJSON(os.Stdout, plan)
+	os.Stdout = stdout
+	writer.Close()
+	if err != nil {
+		t.Fatalf("writePlanJSON returned error: %v", err)
+	}
+
+	output, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+
+	var entries []planEntry
+	if err := json.Unmarshal(output, &entries); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, output)
+	}
+
+	expected := []planEntry{
+		{ID: "file.config", Action: "create", Details: "Resource will be created"},
+		{ID: "package.curl", Action: "no-op", Details: "Resource already in desired state"},
+		{ID: "service.nginx", Action: "update", Details: "Resource will be updated"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, entries)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	// A pipe is never a terminal, so auto mode stays plain
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	defer reader.Close()
+	defer writer.Close()
+
+	tests := map[string]bool{"auto": false, "always": true, "never": false}
+	for mode, expected := range tests {
+		enabled, err := useColor(mode, writer)
+		if err != nil {
+			t.Fatalf("useColor(%q) returned error: %v", mode, err)
+		}
+		if enabled != expected {
+			t.Errorf("useColor(%q) = %v, expected %v", mode, enabled, expected)
+		}
+	}
+
+	if _, err := useColor("sometimes", writer); err == nil {
+		t.Error("Expected error for an unknown color mode")
+	}
+
+	if got := (colorizer{}).paint(colorRed, "failed"); got != "failed" {
+		t.Errorf("Expected plain text with colors off, got %q", got)
+	}
+	if got := (colorizer{enabled: true}).paint(colorRed, "failed"); got != colorRed+"failed"+colorReset {
+		t.Errorf("Expected colored text, got %q", got)
+	}
+}
+
+func TestSortedIDs(t *testing.T) {
+	plan := map[string]engine.PlanAction{
+		"service.nginx": {Action: "update"},
+		"file.config":   {Action: "create"},
+		"package.nginx": {Action: "no-op"},
+	}
+	expected := []string{"file.config", "package.nginx", "service.nginx"}
+	if got := planIDs(plan); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected plan IDs %v, got %v", expected, got)
+	}
+
+	results := map[string]*providers.ResourceState{
+		"service.nginx": {Status: "updated"},
+		"file.config":   {Status: "created"},
+		"package.nginx": {Status: "unchanged"},
+	}
+	if got := resultIDs(results); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected result IDs %v, got %v", expected, got)
+	}
+}
+
+func TestSlowestResources(t *testing.T) {
+	timings := map[string]time.Duration{
+		"file.a":        time.Second,
+		"package.nginx": 3 * time.Second,
+		"file.b":        time.Second,
+		"service.nginx": 2 * time.Second,
+	}
+
+	expected := []resourceTiming{
+		{ID: "package.nginx", Duration: 3 * time.Second},
+		{ID: "service.nginx", Duration: 2 * time.Second},
+		{ID: "file.a", Duration: time.Second},
+	}
+	if got := slowestResources(timings, 3); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if got := slowestResources(nil, 3); len(got) != 0 {
+		t.Errorf("Expected no timings, got %v", got)
+	}
+}
+
+func TestVerbosityLogLevel(t *testing.T) {
+	if level := quietOutput.logLevel(); level != logging.LevelError {
+		t.Errorf("Expected quiet output to log errors only, got %s", level)
+	}
+	if level := normalOutput.logLevel(); level != logging.LevelInfo {
+		t.Errorf("Expected normal output to log progress, got %s", level)
+	}
+	if level := verboseOutput.logLevel(); level != logging.LevelDebug {
+		t.Errorf("Expected verbose output to log debug messages, got %s", level)
+	}
+}
+
+func TestOutputVerbosity(t *testing.T) {
+	if _, err := outputVerbosity(true, true); err == nil {
+		t.Error("Expected error when --verbose and --quiet are combined")
+	}
+
+	quiet, err := outputVerbosity(false, true)
+	if err != nil {
+		t.Fatalf("outputVerbosity returned error: %v", err)
+	}
+	normal, _ := outputVerbosity(false, false)
+	verbose, _ := outputVerbosity(true, false)
+
+	tests := []struct {
+		status string
+		level  verbosity
+		shown  bool
+	}{
+		{"failed", quiet, true},
+		{"error", quiet, true},
+		{"created", quiet, false},
+		{"update", quiet, false},
+		{"unchanged", quiet, false},
+		{"created", normal, true},
+		{"delete", normal, true},
+		{"unchanged", normal, false},
+		{"no-op", normal, false},
+		{"unchanged", verbose, true},
+		{"no-op", verbose, true},
+		{"failed", verbose, true},
+	}
+	for _, test := range tests {
+		if shown := showResult(test.status, test.level); shown != test.shown {
+			t.Errorf("showResult(%q, %d) = %v, expected %v", test.status, test.level, shown, test.shown)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "zero-report-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", providers.NewFileProvider())
+	e := engine.NewEngine(registry)
+	e.SetLogger(logging.Discard)
+
+	// One file is written and one fails because its parent is missing
+	created := filepath.Join(tempDir, "created")
+	missing := filepath.Join(tempDir, "missing", "file")
+	results, err := e.Apply(context.Background(), []engine.Resource{
+		{Type: "file", Name: "created", Attributes: map[string]interface{}{"path": created, "content": "data"}},
+		{Type: "file", Name: "missing", Attributes: map[string]interface{}{"path": missing, "content": "data", "ensure_parent": false}},
+	})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	path := filepath.Join(tempDir, "report.json")
+	if err := writeReport(path, results, e.Timings(), e.Notifications(), false); err != nil {
+		t.Fatalf("writeReport returned error: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	var report applyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Report is not valid JSON: %v\n%s", err, data)
+	}
+
+	if report.Changed != 1 || report.Failed != 1 || report.Unchanged != 0 || report.DryRun {
+		t.Errorf("Unexpected report counts %+v", report)
+	}
+	for id, state := range results {
+		entry, ok := report.Resources[id]
+		if !ok {
+			t.Errorf("Expected %s in the report", id)
+			continue
+		}
+		if entry.Status != state.Status || entry.Changed != state.Changed {
+			t.Errorf("Expected %s to be reported as %s, got %+v", id, state.Status, entry)
+		}
+	}
+	if entry := report.Resources["file.missing"]; entry.Status != "failed" || !strings.Contains(entry.Error, "missing") {
+		t.Errorf("Expected the failure and its error in the report, got %+v", entry)
+	}
+	if entry := report.Resources["file.created"]; entry.Status != "created" || entry.Error != "" {
+		t.Errorf("Expected the created file in the report, got %+v", entry)
+	}
+}
+
+func TestFormatNotification(t *testing.T) {
+	notification := engine.Notification{ID: "service.nginx", Action: "restarted", Sources: []string{"file.nginx_conf", "file.site"}}
+	if got, want := formatNotification(notification), "service.nginx restarted (by file.nginx_conf, file.site)"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	notification = engine.Notification{ID: "service.app", Action: "failed", Sources: []string{"file.app"}, Error: errors.New("exit status 1")}
+	if got, want := formatNotification(notification), "service.app failed (by file.app): exit status 1"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	report := newApplyReport(nil, nil, []engine.Notification{notification}, false)
+	want := []reportNotification{{ID: "service.app", Action: "failed", By: []string{"file.app"}, Error: "exit status 1"}}
+	if !reflect.DeepEqual(report.Notified, want) {
+		t.Errorf("Expected report notifications %+v, got %+v", want, report.Notified)
+	}
+}
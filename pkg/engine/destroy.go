@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/dangerclosesec/zero/pkg/logging"
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+// desiredAttributes returns the attributes that drive a resource toward
+// intent, as translated by its provider, or false if the provider can't
+func (e *Engine) desiredAttributes(resource Resource, intent providers.Intent) (map[string]interface{}, bool) {
+	provider, err := e.registry.Get(resource.Type)
+	if err != nil {
+		return nil, false
+	}
+	translator, ok := provider.(providers.IntentTranslator)
+	if !ok {
+		return nil, false
+	}
+	return translator.DesiredAttributes(intent, resource.Attributes)
+}
+
+// stateOnlyResources returns the resources recorded in the prior state that
+// aren't in resources, built from their recorded type and attributes
+func (e *Engine) stateOnlyResources(resources []Resource) []Resource {
+	configured := make(map[string]bool, len(resources))
+	for _, resource := range resources {
+		configured[fmt.Sprintf("%s.%s", resource.Type, resource.Name)] = true
+	}
+
+	ids := make([]string, 0, len(e.state))
+	for id, prior := range e.state {
+		if prior == nil || prior.Type == "" || configured[id] {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	stateOnly := make([]Resource, 0, len(ids))
+	for _, id := range ids {
+		prior := e.state[id]
+		attributes := make(map[string]interface{}, len(prior.Attributes))
+		for key, value := range prior.Attributes {
+			attributes[key] = value
+		}
+		stateOnly = append(stateOnly, Resource{
+			Type:       prior.Type,
+			Name:       prior.Name,
+			Attributes: attributes,
+		})
+	}
+	return stateOnly
+}
+
+// Destroy removes the resources recorded in the prior state, dependents
+// first. Resources removed from the configuration since they were applied
+// are destroyed from their recorded attributes. Resources that aren't in the
+// state or can't be destroyed are left alone, as are the dependencies of a
+// resource that failed to be destroyed.
+func (e *Engine) Destroy(ctx context.Context, resources []Resource) (map[string]*providers.ResourceState, error) {
+	ctx = logging.NewContext(ctx, e.logger)
+
+	resources = append(append([]Resource(nil), resources...), e.stateOnlyResources(resources)...)
+
+	// Build dependency graph
+	graph, err := e.buildDependencyGraph(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	// Limit the run to the targeted resources and the resources using them
+	graph, err = e.pruneToTargets(graph, func(node *ResourceNode) []*ResourceNode { return node.DependedOnBy })
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate all resources
+	if err := e.validateResources(ctx, graph); err != nil {
+		return nil, err
+	}
+
+	// Sort resources by dependency order
+	orderedNodes, err := e.topoSort(graph)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]*providers.ResourceState)
+
+	// Destroy in the reverse of apply order. topoSort already lists
+	// dependents before their dependencies.
+	for _, node := range orderedNodes {
+		resourceID := fmt.Sprintf("%s.%s", node.Resource.Type, node.Resource.Name)
+
+		// Skip resources that don't apply to this platform
+		if !e.isPlatformSupported(node.Resource) {
+			continue
+		}
+
+		// Only destroy what a previous apply recorded
+		if prior, ok := e.state[resourceID]; !ok || prior == nil {
+			continue
+		}
+
+		attributes, ok := e.desiredAttributes(node.Resource, providers.IntentAbsent)
+		if !ok {
+			e.logger.Infof("Skipping %s (%s resources can't be destroyed)", resourceID, node.Resource.Type)
+			continue
+		}
+
+		// Don't remove what a resource that failed to be destroyed still uses
+		if failed := failedDependent(node, results); failed != "" {
+			err := fmt.Errorf("dependent %s failed to be destroyed", failed)
+			e.logger.Errorf("Skipping %s: %v", resourceID, err)
+			results[resourceID] = &providers.ResourceState{
+				Type:       node.Resource.Type,
+				Name:       node.Resource.Name,
+				Attributes: node.Resource.Attributes,
+				Status:     "failed",
+				Error:      err,
+			}
+			continue
+		}
+
+		destroyNode := *node
+		destroyNode.Resource.Attributes = attributes
+		results[resourceID] = e.applyNode(ctx, resourceID, &destroyNode)
+	}
+
+	return results, nil
+}
+
+// failedDependent returns the ID of a resource depending on node that failed, or ""
+func failedDependent(node *ResourceNode, results map[string]*providers.ResourceState) string {
+	for _, dependent := range node.DependedOnBy {
+		dependentID := fmt.Sprintf("%s.%s", dependent.Resource.Type, dependent.Resource.Name)
+		if state, ok := results[dependentID]; ok && state.Status == "failed" {
+			return dependentID
+		}
+	}
+	return ""
+}
+
+// RemoveDestroyed returns the prior state without the resources that were
+// destroyed successfully
+func RemoveDestroyed(prior, results map[string]*providers.ResourceState) map[string]*providers.ResourceState {
+	remaining := make(map[string]*providers.ResourceState, len(prior))
+	for id, state := range prior {
+		if result, ok := results[id]; ok && result != nil && result.Status != "failed" {
+			continue
+		}
+		remaining[id] = state
+	}
+	return remaining
+}
@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+// recordingRegistry returns a registry whose providers record the order and
+// desired state of applied resources, failing those named in fail
+func recordingRegistry(order *[]string, fail map[string]bool) *providers.ProviderRegistry {
+	provider := &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Name: desired["name"].(string), Attributes: desired, Status: "planned"}, nil
+		},
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			*order = append(*order, fmt.Sprintf("%s=%v", state.Name, state.Attributes["state"]))
+			if fail[state.Name] {
+				return nil, fmt.Errorf("%s failed", state.Name)
+			}
+			return &providers.ResourceState{Name: state.Name, Attributes: state.Attributes, Status: "deleted", Changed: true}, nil
+		},
+	}
+
+	registry := providers.NewProviderRegistry()
+	registry.Register("package", &RemovableMockProvider{MockProvider: provider, Removal: map[string]interface{}{"state": "removed"}})
+	registry.Register("file", &RemovableMockProvider{MockProvider: provider, Removal: map[string]interface{}{"state": "absent"}})
+	registry.Register("service", &RemovableMockProvider{MockProvider: provider, Removal: map[string]interface{}{"state": "stopped", "enabled": false}})
+	registry.Register("exec", provider)
+	return registry
+}
+
+// RemovableMockProvider is a MockProvider that translates IntentAbsent by
+// applying its Removal overrides
+type RemovableMockProvider struct {
+	*MockProvider
+	Removal map[string]interface{}
+}
+
+func (m *RemovableMockProvider) DesiredAttributes(intent providers.Intent, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	if intent != providers.IntentAbsent {
+		return attributes, intent == providers.IntentPresent
+	}
+	result := make(map[string]interface{})
+	for key, value := range attributes {
+		result[key] = value
+	}
+	for key, value := range m.Removal {
+		result[key] = value
+	}
+	return result, true
+}
+
+func destroyResources() []Resource {
+	return []Resource{
+		{Type: "package", Name: "nginx", Attributes: map[string]interface{}{"state": "installed"}},
+		{Type: "file", Name: "conf", Attributes: map[string]interface{}{}, DependsOn: []string{"package.nginx"}},
+		{Type: "service", Name: "web", Attributes: map[string]interface{}{"state": "running"}, DependsOn: []string{"file.conf"}},
+		{Type: "exec", Name: "warm", Attributes: map[string]interface{}{}, DependsOn: []string{"service.web"}},
+	}
+}
+
+func allInState(resources []Resource) map[string]*providers.ResourceState {
+	state := make(map[string]*providers.ResourceState)
+	for _, r := range resources {
+		state[r.Type+"."+r.Name] = &providers.ResourceState{Type: r.Type, Name: r.Name, Status: "created"}
+	}
+	return state
+}
+
+func TestEngine_Destroy_ReverseOrder(t *testing.T) {
+	var order []string
+	engine := NewEngine(recordingRegistry(&order, nil))
+	engine.SetState(allInState(destroyResources()))
+
+	results, err := engine.Destroy(context.Background(), destroyResources())
+	if err != nil {
+		t.Fatalf("Destroy returned error: %v", err)
+	}
+
+	// exec resources can't be destroyed, so they are skipped
+	expected := []string{"web=stopped", "conf=absent", "nginx=removed"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected destroy order %v, got %v", expected, order)
+	}
+	if _, ok := results["exec.warm"]; ok {
+		t.Error("Expected exec resource not to be destroyed")
+	}
+}
+
+func TestEngine_Destroy_OnlyStateAndFailures(t *testing.T) {
+	var order []string
+	engine := NewEngine(recordingRegistry(&order, map[string]bool{"conf": true}))
+
+	// nginx was never applied, so it isn't destroyed
+	state := allInState(destroyResources())
+	delete(state, "package.nginx")
+	engine.SetState(state)
+
+	resources := append(destroyResources(),
+		Resource{Type: "file", Name: "other", Attributes: map[string]interface{}{}})
+
+	results, err := engine.Destroy(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Destroy returned error: %v", err)
+	}
+
+	if _, ok := results["package.nginx"]; ok {
+		t.Error("Expected resource missing from state not to be destroyed")
+	}
+	if results["file.conf"].Status != "failed" {
+		t.Errorf("Expected file.conf to fail, got %s", results["file.conf"].Status)
+	}
+
+	remaining := RemoveDestroyed(state, results)
+	if _, ok := remaining["service.web"]; ok {
+		t.Error("Expected destroyed resource to be removed from state")
+	}
+	if _, ok := remaining["file.conf"]; !ok {
+		t.Error("Expected resource that failed to be destroyed to stay in state")
+	}
+}
+
+func TestEngine_Destroy_RemovedFromConfig(t *testing.T) {
+	var order []string
+	engine := NewEngine(recordingRegistry(&order, nil))
+
+	// old.conf was applied by an earlier configuration and has since been removed
+	state := allInState(destroyResources())
+	state["file.old"] = &providers.ResourceState{
+		Type:       "file",
+		Name:       "old",
+		Attributes: map[string]interface{}{"path": "/etc/old.conf"},
+		Status:     "created",
+	}
+	engine.SetState(state)
+
+	results, err := engine.Destroy(context.Background(), destroyResources())
+	if err != nil {
+		t.Fatalf("Destroy returned error: %v", err)
+	}
+
+	result, ok := results["file.old"]
+	if !ok || result.Status != "deleted" {
+		t.Fatalf("Expected resource only in state to be destroyed, got %v", result)
+	}
+	if result.Attributes["path"] != "/etc/old.conf" || result.Attributes["state"] != "absent" {
+		t.Errorf("Expected recorded attributes to drive removal, got %v", result.Attributes)
+	}
+	if _, ok := RemoveDestroyed(state, results)["file.old"]; ok {
+		t.Error("Expected destroyed resource to be removed from state")
+	}
+}
+
+func TestEngine_Destroy_FailureKeepsDependencies(t *testing.T) {
+	var order []string
+	engine := NewEngine(recordingRegistry(&order, map[string]bool{"web": true}))
+	engine.SetState(allInState(destroyResources()))
+
+	results, err := engine.Destroy(context.Background(), destroyResources())
+	if err != nil {
+		t.Fatalf("Destroy returned error: %v", err)
+	}
+
+	for _, id := range []string{"service.web", "file.conf", "package.nginx"} {
+		if results[id].Status != "failed" {
+			t.Errorf("Expected %s to be failed, got %s", id, results[id].Status)
+		}
+	}
+	if !reflect.DeepEqual(order, []string{"web=stopped"}) {
+		t.Errorf("Expected only the failed service to be attempted, got %v", order)
+	}
+}
+
+func TestEngine_Destroy_Targets(t *testing.T) {
+	var order []string
+	engine := NewEngine(recordingRegistry(&order, nil))
+	engine.SetState(allInState(destroyResources()))
+	engine.SetTargets([]string{"file.conf"})
+
+	if _, err := engine.Destroy(context.Background(), destroyResources()); err != nil {
+		t.Fatalf("Destroy returned error: %v", err)
+	}
+
+	// The target's dependents go with it, but what it depends on stays
+	expected := []string{"web=stopped", "conf=absent"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected destroy order %v, got %v", expected, order)
+	}
+}
+
+func TestEngine_Destroy_ProviderIntent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.conf")
+	if err := os.WriteFile(path, []byte("port=80\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", providers.NewFileProvider())
+	registry.Register("package", providers.NewPackageProvider())
+	registry.Register("service", providers.NewServiceProvider())
+	engine := NewEngine(registry)
+
+	// Each provider translates removal into its own state value
+	tests := []struct {
+		resourceType string
+		attributes   map[string]interface{}
+		want         map[string]interface{}
+	}{
+		{"file", map[string]interface{}{"path": path, "state": "present"}, map[string]interface{}{"path": path, "state": "absent"}},
+		{"package", map[string]interface{}{"name": "nginx"}, map[string]interface{}{"name": "nginx", "state": "removed"}},
+		{"service", map[string]interface{}{"name": "nginx", "state": "running", "enabled": true},
+			map[string]interface{}{"name": "nginx", "state": "stopped", "enabled": false}},
+	}
+	for _, tt := range tests {
+		got, ok := engine.desiredAttributes(Resource{Type: tt.resourceType, Name: "web", Attributes: tt.attributes}, providers.IntentAbsent)
+		if !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected removal attributes %v, got %v", tt.resourceType, tt.want, got)
+		}
+	}
+
+	// Destroy drives the file toward removal through the same path
+	resources := []Resource{{Type: "file", Name: "conf", Attributes: map[string]interface{}{"path": path, "content": "port=80\n"}}}
+	engine.SetState(allInState(resources))
+	results, err := engine.Destroy(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Destroy returned error: %v", err)
+	}
+	if results["file.conf"].Status != "deleted" {
+		t.Errorf("Expected file.conf to be deleted, got %s (%v)", results["file.conf"].Status, results["file.conf"].Error)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", path, err)
+	}
+}
@@ -2,9 +2,17 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/dangerclosesec/zero/pkg/logging"
 	"github.com/dangerclosesec/zero/pkg/providers"
 )
 
@@ -13,10 +21,12 @@ type ResourceNode struct {
 	Resource      Resource
 	DependsOn     []*ResourceNode
 	DependedOnBy  []*ResourceNode
+	OrderOnly     map[*ResourceNode]bool // Dependencies from before and after, which only order the node
 	State         *providers.ResourceState
 	Visited       bool
 	Applied       bool
 	ExecutionTime time.Time
+	Duration      time.Duration // How long planning and applying the resource took
 }
 
 // Resource represents a parsed resource
@@ -25,37 +35,168 @@ type Resource struct {
 	Name       string
 	Attributes map[string]interface{}
 	DependsOn  []string
+	Notifies   []string // Resources to notify when this one changes
+	Before     []string // Resources to apply after this one, for ordering only
+	After      []string // Resources to apply before this one, for ordering only
 	Conditions map[string][]string
+	Positions  map[string]Position // Where each attribute's value is in the configuration, if known
+}
+
+// Position is where a value is in the configuration
+type Position struct {
+	File   string // Empty if not known
+	Line   int
+	Column int
+}
+
+// String returns the position as "file, line N, column M"
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s, line %d, column %d", p.File, p.Line, p.Column)
 }
 
 // PlanAction represents a planned action for a resource
 type PlanAction struct {
-	Action  string // "create", "update", "delete", "no-op"
+	Action  string // "create", "update", "delete", "no-op", "skipped" or "error"
 	Details string
 }
 
 // Engine is the core execution engine for configurations
 type Engine struct {
-	registry *providers.ProviderRegistry
-	platform *providers.PlatformChecker
+	registry    *providers.ProviderRegistry
+	platform    *providers.PlatformChecker
+	state       map[string]*providers.ResourceState // Prior state keyed by resource ID
+	parallelism int                                 // Maximum resources applied at once
+	targets     []string                            // Resource IDs to limit runs to, if any
+	inferDeps   bool                                // Add dependencies implied by resource relationships
+	timings     map[string]time.Duration            // Apply duration of each resource in the last Apply
+	logger      logging.Logger                      // Receives progress messages and failures
+	outputs     map[string]map[string]string        // Referable values of resources applied in the last Apply
+	outputsMu   sync.Mutex
+	dryRun      bool // Report what Apply would do without doing it
+
+	notifications   []Notification // Notification handlers run in the last Apply
+	notificationsMu sync.Mutex
+}
+
+// Notification is a notification handler that ran during an apply
+type Notification struct {
+	ID      string   // Resource whose handler ran
+	Action  string   // What the handler did, such as "restarted", or "failed"
+	Sources []string // Changed resources that notified it, sorted
+	Error   error    // Why the handler failed, if it did
 }
 
-// NewEngine creates a new execution engine
+// NewEngine creates a new execution engine. It logs progress to stderr until
+// SetLogger replaces its logger.
 func NewEngine(registry *providers.ProviderRegistry) *Engine {
 	return &Engine{
-		registry: registry,
-		platform: &providers.PlatformChecker{},
+		registry:    registry,
+		platform:    &providers.PlatformChecker{},
+		parallelism: runtime.GOMAXPROCS(0),
+		logger:      logging.New(os.Stderr, logging.LevelInfo),
+	}
+}
+
+// SetParallelism sets how many independent resources are applied at once
+func (e *Engine) SetParallelism(n int) {
+	if n < 1 {
+		n = 1
+	}
+	e.parallelism = n
+}
+
+// SetTargets limits plan and apply to the given resource IDs and the
+// resources they depend on. Destroy is limited to the targets and the
+// resources that depend on them.
+func (e *Engine) SetTargets(targets []string) {
+	e.targets = targets
+}
+
+// SetDryRun sets whether Apply only reports what it would do. Resources go
+// through the whole apply path, but providers that implement
+// providers.DryRunner are asked not to change anything, others are not
+// applied at all and report the status their plan implies, and notified
+// resources are not notified.
+func (e *Engine) SetDryRun(dryRun bool) {
+	e.dryRun = dryRun
+}
+
+// SetLogger sets the logger that receives progress messages and failures
+// while applying and destroying resources. Providers log through the same
+// logger. Failures are also returned in the results.
+func (e *Engine) SetLogger(logger logging.Logger) {
+	if logger == nil {
+		logger = logging.Discard
+	}
+	e.logger = logger
+}
+
+// SetState sets the prior resource state used as the current state when planning
+func (e *Engine) SetState(state map[string]*providers.ResourceState) {
+	e.state = state
+}
+
+// currentAttributes returns the current state of a resource to plan against.
+// Providers that implement providers.Reader supply the live state; for others
+// it is the attributes recorded for the resource in the prior state.
+func (e *Engine) currentAttributes(ctx context.Context, provider providers.ResourceProvider, resourceID string, attributes map[string]interface{}) (map[string]interface{}, error) {
+	if reader, ok := provider.(providers.Reader); ok {
+		live, err := reader.Read(ctx, attributes)
+		if err != nil {
+			return nil, fmt.Errorf("error reading current state: %v", err)
+		}
+		if live == nil {
+			live = make(map[string]interface{})
+		}
+		return live, nil
+	}
+
+	if prior, ok := e.state[resourceID]; ok && prior != nil && prior.Attributes != nil {
+		return prior.Attributes, nil
+	}
+	return make(map[string]interface{}), nil
+}
+
+// Validate checks resources without planning or applying them. It builds the
+// dependency graph, catching missing dependencies, then reports every
+// provider validation failure and any dependency cycle together.
+func (e *Engine) Validate(ctx context.Context, resources []Resource) error {
+	ctx = logging.NewContext(ctx, e.logger)
+
+	graph, err := e.buildDependencyGraph(resources)
+	if err != nil {
+		return err
+	}
+
+	graph, err = e.pruneToTargets(graph, func(node *ResourceNode) []*ResourceNode { return node.DependsOn })
+	if err != nil {
+		return err
 	}
+
+	validationErr := e.validateResources(ctx, graph)
+	_, cycleErr := e.topoSort(graph)
+	return errors.Join(validationErr, cycleErr)
 }
 
 // Plan generates a plan of changes without applying them
 func (e *Engine) Plan(ctx context.Context, resources []Resource) (map[string]PlanAction, error) {
+	ctx = logging.NewContext(ctx, e.logger)
+
 	// Build dependency graph
 	graph, err := e.buildDependencyGraph(resources)
 	if err != nil {
 		return nil, err
 	}
 
+	// Limit the run to the targeted resources and their dependencies
+	graph, err = e.pruneToTargets(graph, func(node *ResourceNode) []*ResourceNode { return node.DependsOn })
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate all resources
 	if err := e.validateResources(ctx, graph); err != nil {
 		return nil, err
@@ -70,13 +211,17 @@ func (e *Engine) Plan(ctx context.Context, resources []Resource) (map[string]Pla
 	// Plan changes for each resource
 	results := make(map[string]PlanAction)
 	for _, node := range orderedNodes {
-		// Skip resources that don't apply to this platform
-		if !e.isPlatformSupported(node.Resource) {
+		resourceID := fmt.Sprintf("%s.%s", node.Resource.Type, node.Resource.Name)
+
+		// Report resources that don't apply to this platform as skipped
+		if reason := e.skipReason(node.Resource); reason != "" {
+			results[resourceID] = PlanAction{
+				Action:  "skipped",
+				Details: "Resource skipped: " + reason,
+			}
 			continue
 		}
 
-		resourceID := fmt.Sprintf("%s.%s", node.Resource.Type, node.Resource.Name)
-
 		// Get the provider for this resource type
 		provider, err := e.registry.Get(node.Resource.Type)
 		if err != nil {
@@ -87,9 +232,35 @@ func (e *Engine) Plan(ctx context.Context, resources []Resource) (map[string]Pla
 			continue
 		}
 
+		// Resolve references to other resources from the prior state. Values
+		// that only exist once their resource is applied are unknown until then.
+		attributes := node.Resource.Attributes
+		if refs := resourceReferences(node.Resource); len(refs) > 0 {
+			resolved, err := e.resolveReferences(attributes)
+			if err != nil {
+				results[resourceID] = e.deferredPlan(resourceID, refs)
+				continue
+			}
+			if err := provider.Validate(ctx, resolved); err != nil {
+				results[resourceID] = PlanAction{
+					Action:  "error",
+					Details: fmt.Sprintf("Error validating: %v", err),
+				}
+				continue
+			}
+			attributes = resolved
+		}
+
 		// Plan the resource
-		current := make(map[string]interface{}) // In a real system, this would be loaded from state
-		planned, err := provider.Plan(ctx, current, node.Resource.Attributes)
+		timeout, _ := parseTimeout(attributes)
+		planCtx := providers.WithPriorOutputs(ctx, e.priorOutputs(resourceID))
+		planned, err := callWithTimeout(planCtx, timeout, func(ctx context.Context) (*providers.ResourceState, error) {
+			current, err := e.currentAttributes(ctx, provider, resourceID, attributes)
+			if err != nil {
+				return nil, err
+			}
+			return provider.Plan(ctx, current, attributes)
+		})
 		if err != nil {
 			results[resourceID] = PlanAction{
 				Action:  "error",
@@ -104,18 +275,33 @@ func (e *Engine) Plan(ctx context.Context, resources []Resource) (map[string]Pla
 
 		switch planned.Status {
 		case "planned":
-			// Check if this is a new resource or an update
-			if _, exists := current["path"]; exists {
-				action = "update"
+			action = e.classifyChange(resourceID, node.Resource, planned)
+			switch action {
+			case "delete":
+				details = "Resource will be deleted"
+			case "update":
 				details = "Resource will be updated"
-			} else {
-				action = "create"
+			default:
 				details = "Resource will be created"
 			}
 		case "unchanged":
 			action = "no-op"
 			details = "Resource already in desired state"
 		}
+		// Multi-line details such as a content diff go after the summary line
+		var summary []string
+		if planned.Details != "" && !strings.Contains(planned.Details, "\n") {
+			summary = append(summary, planned.Details)
+		}
+		if diff := formatDiff(planned.Diff); diff != "" {
+			summary = append(summary, diff)
+		}
+		if len(summary) > 0 {
+			details += ": " + strings.Join(summary, "; ")
+		}
+		if strings.Contains(planned.Details, "\n") {
+			details += "\n" + planned.Details
+		}
 
 		results[resourceID] = PlanAction{
 			Action:  action,
@@ -126,14 +312,114 @@ func (e *Engine) Plan(ctx context.Context, resources []Resource) (map[string]Pla
 	return results, nil
 }
 
+// deferredPlan is the plan for a resource whose attributes refer to values
+// that are only known once the referenced resources are applied
+func (e *Engine) deferredPlan(resourceID string, refs []string) PlanAction {
+	action := "create"
+	if prior, ok := e.state[resourceID]; ok && prior != nil {
+		action = "update"
+	}
+	return PlanAction{
+		Action:  action,
+		Details: fmt.Sprintf("Resource values are known after %s is applied", strings.Join(refs, ", ")),
+	}
+}
+
+// formatDiff renders attribute differences sorted by attribute, such as
+// "content: changed, mode: 0600 -> 0644"
+func formatDiff(diff map[string]providers.AttributeDiff) string {
+	attributes := make([]string, 0, len(diff))
+	for attribute := range diff {
+		attributes = append(attributes, attribute)
+	}
+	sort.Strings(attributes)
+
+	changes := make([]string, len(attributes))
+	for i, attribute := range attributes {
+		change := diff[attribute]
+		switch {
+		case change.Old == "" && change.New == "":
+			changes[i] = attribute + ": changed"
+		case change.Old == "":
+			changes[i] = fmt.Sprintf("%s: (none) -> %s", attribute, change.New)
+		default:
+			changes[i] = fmt.Sprintf("%s: %s -> %s", attribute, change.Old, change.New)
+		}
+	}
+	return strings.Join(changes, ", ")
+}
+
+// classifyChange decides whether a planned change creates, updates or deletes
+// a resource. A desired absent state is a delete. Otherwise it is a create if
+// the provider reports the resource missing, and an update if the resource is
+// in the prior state or the provider reports changes to an existing resource.
+func (e *Engine) classifyChange(resourceID string, resource Resource, planned *providers.ResourceState) string {
+	if state, ok := resource.Attributes["state"].(string); ok && (state == "absent" || state == "removed") {
+		return "delete"
+	}
+
+	// Providers report a "state" change when the resource doesn't exist yet
+	for _, change := range planned.Changes {
+		if change == "state" {
+			return "create"
+		}
+	}
+
+	if prior, ok := e.state[resourceID]; ok && prior != nil {
+		return "update"
+	}
+	if len(planned.Changes) > 0 {
+		return "update"
+	}
+
+	return "create"
+}
+
+// Timings returns how long each resource took to plan and apply in the last
+// Apply, keyed by resource ID
+func (e *Engine) Timings() map[string]time.Duration {
+	return e.timings
+}
+
+// Notifications returns the notification handlers that ran in the last Apply,
+// sorted by resource ID. Each resource appears once, however many resources
+// notified it.
+func (e *Engine) Notifications() []Notification {
+	e.notificationsMu.Lock()
+	defer e.notificationsMu.Unlock()
+
+	notifications := append([]Notification(nil), e.notifications...)
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].ID < notifications[j].ID })
+	return notifications
+}
+
+// recordNotification adds a notification handler that ran to the ones
+// reported by Notifications
+func (e *Engine) recordNotification(resourceID, action string, sources []string, err error) {
+	sorted := append([]string(nil), sources...)
+	sort.Strings(sorted)
+
+	e.notificationsMu.Lock()
+	defer e.notificationsMu.Unlock()
+	e.notifications = append(e.notifications, Notification{ID: resourceID, Action: action, Sources: sorted, Error: err})
+}
+
 // Apply applies the given resources
 func (e *Engine) Apply(ctx context.Context, resources []Resource) (map[string]*providers.ResourceState, error) {
+	ctx = logging.NewContext(ctx, e.logger)
+
 	// Build dependency graph
 	graph, err := e.buildDependencyGraph(resources)
 	if err != nil {
 		return nil, err
 	}
 
+	// Limit the run to the targeted resources and their dependencies
+	graph, err = e.pruneToTargets(graph, func(node *ResourceNode) []*ResourceNode { return node.DependsOn })
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate all resources
 	if err := e.validateResources(ctx, graph); err != nil {
 		return nil, err
@@ -145,51 +431,115 @@ func (e *Engine) Apply(ctx context.Context, resources []Resource) (map[string]*p
 		return nil, err
 	}
 
-	// Apply resources in order
+	// Apply resources wave by wave; resources within a wave are independent
 	results := make(map[string]*providers.ResourceState)
-	for _, node := range orderedNodes {
-		// Skip resources that don't apply to this platform
-		if !e.isPlatformSupported(node.Resource) {
-			fmt.Printf("Skipping resource %s.%s (platform not supported)\n",
-				node.Resource.Type, node.Resource.Name)
-			continue
-		}
+	notified := make(map[string][]string) // Target resource ID to the IDs that notified it
+	e.timings = make(map[string]time.Duration)
+	e.outputs = make(map[string]map[string]string)
+	e.notifications = nil
+	var mu sync.Mutex
 
-		resourceID := fmt.Sprintf("%s.%s", node.Resource.Type, node.Resource.Name)
+	for _, wave := range e.dependencyWaves(orderedNodes) {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, e.parallelism)
 
-		// Get the provider for this resource type
-		provider, err := e.registry.Get(node.Resource.Type)
-		if err != nil {
-			fmt.Printf("Error getting provider for %s: %v\n", resourceID, err)
-			results[resourceID] = &providers.ResourceState{
-				Type:   node.Resource.Type,
-				Name:   node.Resource.Name,
-				Status: "failed",
-				Error:  err,
+		for _, node := range wave {
+			// Skip resources that don't apply to this platform
+			if reason := e.skipReason(node.Resource); reason != "" {
+				e.logger.Infof("Skipping resource %s.%s (%s)",
+					node.Resource.Type, node.Resource.Name, reason)
+				continue
 			}
-			continue
-		}
 
-		// Plan the resource
-		current := make(map[string]interface{}) // In a real system, this would be loaded from state
-		planned, err := provider.Plan(ctx, current, node.Resource.Attributes)
-		if err != nil {
-			fmt.Printf("Error planning %s: %v\n", resourceID, err)
-			results[resourceID] = &providers.ResourceState{
-				Type:   node.Resource.Type,
-				Name:   node.Resource.Name,
-				Status: "failed",
-				Error:  err,
+			resourceID := fmt.Sprintf("%s.%s", node.Resource.Type, node.Resource.Name)
+
+			// Don't apply resources whose dependencies failed
+			mu.Lock()
+			failedDep := failedDependency(node, results)
+			mu.Unlock()
+			if failedDep != "" {
+				err := fmt.Errorf("dependency %s failed", failedDep)
+				e.logger.Errorf("Skipping %s: %v", resourceID, err)
+				mu.Lock()
+				results[resourceID] = &providers.ResourceState{
+					Type:       node.Resource.Type,
+					Name:       node.Resource.Name,
+					Attributes: node.Resource.Attributes,
+					Status:     "failed",
+					Error:      err,
+				}
+				mu.Unlock()
+				continue
 			}
-			continue
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(node *ResourceNode, resourceID string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				state := e.applyNode(ctx, resourceID, node)
+				e.recordOutputs(resourceID, state)
+
+				// Notifiers are always in earlier waves, so the list is complete
+				mu.Lock()
+				sources := notified[resourceID]
+				mu.Unlock()
+				if len(sources) > 0 && state.Status != "failed" {
+					state = e.notifyNode(ctx, resourceID, node, state, sources)
+				}
+
+				mu.Lock()
+				results[resourceID] = state
+				e.timings[resourceID] = node.Duration
+				if state.Changed {
+					for _, target := range node.Resource.Notifies {
+						notified[target] = append(notified[target], resourceID)
+					}
+				}
+				mu.Unlock()
+			}(node, resourceID)
+		}
+
+		wg.Wait()
+	}
+
+	return results, nil
+}
+
+// applyNode plans and applies a single resource
+func (e *Engine) applyNode(ctx context.Context, resourceID string, node *ResourceNode) *providers.ResourceState {
+	start := time.Now()
+	defer func() { node.Duration = time.Since(start) }()
+
+	// Let the provider see what it recorded when it last applied the resource
+	ctx = providers.WithPriorOutputs(ctx, e.priorOutputs(resourceID))
+	if e.dryRun {
+		ctx = providers.WithDryRun(ctx)
+	}
+
+	// Get the provider for this resource type
+	provider, err := e.registry.Get(node.Resource.Type)
+	if err != nil {
+		e.logger.Errorf("Error getting provider for %s: %v", resourceID, err)
+		return &providers.ResourceState{
+			Type:   node.Resource.Type,
+			Name:   node.Resource.Name,
+			Status: "failed",
+			Error:  err,
 		}
+	}
 
-		// Apply the resource
-		fmt.Printf("Applying %s\n", resourceID)
-		state, err := provider.Apply(ctx, planned)
+	// Substitute the values of the resources this one refers to, which have
+	// been applied by now, and validate the result
+	if hasReferences(node.Resource) {
+		resolved, err := e.resolveReferences(node.Resource.Attributes)
+		if err == nil {
+			err = provider.Validate(ctx, resolved)
+		}
 		if err != nil {
-			fmt.Printf("Error applying %s: %v\n", resourceID, err)
-			state = &providers.ResourceState{
+			e.logger.Errorf("Error resolving %s: %v", resourceID, err)
+			return &providers.ResourceState{
 				Type:       node.Resource.Type,
 				Name:       node.Resource.Name,
 				Attributes: node.Resource.Attributes,
@@ -197,17 +547,204 @@ func (e *Engine) Apply(ctx context.Context, resources []Resource) (map[string]*p
 				Error:      err,
 			}
 		}
+		node.Resource.Attributes = resolved
+	}
+
+	// Timeout and retry attributes were checked by validateResources
+	timeout, _ := parseTimeout(node.Resource.Attributes)
+	policy, _ := parseRetryPolicy(node.Resource.Attributes)
 
-		results[resourceID] = state
+	// Plan the resource
+	planned, err := callWithTimeout(ctx, timeout, func(ctx context.Context) (*providers.ResourceState, error) {
+		current, err := e.currentAttributes(ctx, provider, resourceID, node.Resource.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		return provider.Plan(ctx, current, node.Resource.Attributes)
+	})
+	if err != nil {
+		e.logger.Errorf("Error planning %s: %v", resourceID, err)
+		return &providers.ResourceState{
+			Type:   node.Resource.Type,
+			Name:   node.Resource.Name,
+			Status: "failed",
+			Error:  err,
+		}
+	}
+
+	// In a dry run, the plan of a resource whose provider can't report what
+	// Apply would do stands in for applying it
+	if e.dryRun && !supportsDryRun(provider) {
+		state := e.dryRunState(resourceID, node.Resource, planned)
 		node.State = state
 		node.Applied = true
+		node.ExecutionTime = time.Now()
+		return state
 	}
 
-	return results, nil
+	// Apply the resource, retrying transient failures if the resource asks for it
+	e.logger.Infof("Applying %s", resourceID)
+	state, err := e.applyWithRetry(ctx, resourceID, provider, planned, policy, timeout)
+	if err != nil {
+		e.logger.Errorf("Error applying %s: %v", resourceID, err)
+		failed := &providers.ResourceState{
+			Type:       node.Resource.Type,
+			Name:       node.Resource.Name,
+			Attributes: node.Resource.Attributes,
+			Status:     "failed",
+			Error:      err,
+		}
+		// Keep any command output to help explain the failure
+		if state != nil {
+			failed.Output = state.Output
+		}
+		state = failed
+	}
+
+	node.State = state
+	node.Applied = true
+	node.ExecutionTime = time.Now()
+
+	return state
+}
+
+// notifyNode runs the notification handler of an applied resource once, no
+// matter how many of the resources that notify it changed
+func (e *Engine) notifyNode(ctx context.Context, resourceID string, node *ResourceNode, state *providers.ResourceState, sources []string) *providers.ResourceState {
+	provider, err := e.registry.Get(node.Resource.Type)
+	if err != nil {
+		return state
+	}
+
+	// Resources without a handler have already been applied, which is all a
+	// notification asks of them
+	notifiable, ok := provider.(providers.Notifiable)
+	if !ok {
+		return state
+	}
+
+	if e.dryRun {
+		e.logger.Infof("Would notify %s (triggered by %s)", resourceID, strings.Join(sources, ", "))
+		e.recordNotification(resourceID, "would be notified", sources, nil)
+		return state
+	}
+
+	e.logger.Infof("Notifying %s (triggered by %s)", resourceID, strings.Join(sources, ", "))
+	notifiedState, err := notifiable.Notify(ctx, state)
+	if err != nil {
+		e.logger.Errorf("Error notifying %s: %v", resourceID, err)
+		e.recordNotification(resourceID, "failed", sources, err)
+		return &providers.ResourceState{
+			Type:       node.Resource.Type,
+			Name:       node.Resource.Name,
+			Attributes: node.Resource.Attributes,
+			Status:     "failed",
+			Error:      err,
+		}
+	}
+
+	action := notifiedState.Details
+	if action == "" {
+		action = "notified"
+	}
+	e.recordNotification(resourceID, action, sources, nil)
+
+	node.State = notifiedState
+	return notifiedState
+}
+
+// supportsDryRun reports whether a provider's Apply can be called in a dry run
+func supportsDryRun(provider providers.ResourceProvider) bool {
+	dryRunner, ok := provider.(providers.DryRunner)
+	return ok && dryRunner.SupportsDryRun()
+}
+
+// dryRunState is the result of a resource in a dry run when its provider
+// can't be applied in one: the status its plan implies
+func (e *Engine) dryRunState(resourceID string, resource Resource, planned *providers.ResourceState) *providers.ResourceState {
+	state := &providers.ResourceState{
+		Type:       resource.Type,
+		Name:       resource.Name,
+		Attributes: resource.Attributes,
+		Status:     "unchanged",
+	}
+	if planned.Status != "planned" {
+		return state
+	}
+
+	action := e.classifyChange(resourceID, resource, planned)
+	e.logger.Infof("Would %s %s", action, resourceID)
+	state.Status = action + "d"
+	state.Changed = true
+	state.Changes = planned.Changes
+	state.Diff = planned.Diff
+	state.Details = planned.Details
+	return state
+}
+
+// failedDependency returns the ID of a dependency of node that failed, or "".
+// Dependencies that only order the node are ignored.
+func failedDependency(node *ResourceNode, results map[string]*providers.ResourceState) string {
+	for _, dep := range node.DependsOn {
+		// Resources that are only ordered after dep don't need it to succeed
+		if node.OrderOnly[dep] {
+			continue
+		}
+		depID := fmt.Sprintf("%s.%s", dep.Resource.Type, dep.Resource.Name)
+		if state, ok := results[depID]; ok && state.Status == "failed" {
+			return depID
+		}
+	}
+	return ""
+}
+
+// dependencyWaves groups nodes into waves so that every node's dependencies
+// are in earlier waves. Nodes in the same wave can be applied concurrently.
+func (e *Engine) dependencyWaves(nodes []*ResourceNode) [][]*ResourceNode {
+	levels := make(map[*ResourceNode]int, len(nodes))
+
+	var level func(node *ResourceNode) int
+	level = func(node *ResourceNode) int {
+		if l, ok := levels[node]; ok {
+			return l
+		}
+		l := 0
+		for _, dep := range node.DependsOn {
+			if depLevel := level(dep) + 1; depLevel > l {
+				l = depLevel
+			}
+		}
+		levels[node] = l
+		return l
+	}
+
+	var waves [][]*ResourceNode
+	for _, node := range nodes {
+		l := level(node)
+		for len(waves) <= l {
+			waves = append(waves, nil)
+		}
+		waves[l] = append(waves[l], node)
+	}
+
+	for _, wave := range waves {
+		sort.Slice(wave, func(i, j int) bool {
+			return fmt.Sprintf("%s.%s", wave[i].Resource.Type, wave[i].Resource.Name) <
+				fmt.Sprintf("%s.%s", wave[j].Resource.Type, wave[j].Resource.Name)
+		})
+	}
+
+	return waves
 }
 
 // buildDependencyGraph builds a dependency graph from resources
 func (e *Engine) buildDependencyGraph(resources []Resource) (map[string]*ResourceNode, error) {
+	// Expand count into one resource per instance
+	resources, err := expandResources(resources)
+	if err != nil {
+		return nil, err
+	}
+
 	graph := make(map[string]*ResourceNode)
 
 	// First pass: create nodes
@@ -217,6 +754,7 @@ func (e *Engine) buildDependencyGraph(resources []Resource) (map[string]*Resourc
 			Resource:     resource,
 			DependsOn:    []*ResourceNode{},
 			DependedOnBy: []*ResourceNode{},
+			OrderOnly:    map[*ResourceNode]bool{},
 		}
 	}
 
@@ -234,14 +772,140 @@ func (e *Engine) buildDependencyGraph(resources []Resource) (map[string]*Resourc
 			node.DependsOn = append(node.DependsOn, depNode)
 			depNode.DependedOnBy = append(depNode.DependedOnBy, node)
 		}
+
+		// A resource is applied after the resources whose values it refers to
+		for _, refID := range resourceReferences(resource) {
+			refNode, exists := graph[refID]
+			if !exists {
+				return nil, fmt.Errorf("resource %s refers to non-existent resource %s", id, refID)
+			}
+			if refNode == node {
+				return nil, fmt.Errorf("resource %s refers to itself", id)
+			}
+			addDependency(node, refNode)
+		}
+
+		// A notified resource is applied after the resources that notify it
+		for _, targetID := range resource.Notifies {
+			targetNode, exists := graph[targetID]
+			if !exists {
+				return nil, fmt.Errorf("resource %s notifies non-existent resource %s", id, targetID)
+			}
+
+			targetNode.DependsOn = append(targetNode.DependsOn, node)
+			node.DependedOnBy = append(node.DependedOnBy, targetNode)
+		}
+	}
+
+	if e.inferDeps {
+		e.addInferredDependencies(graph)
+	}
+
+	// Last pass: order resources by before and after, so that the edges
+	// already there are known to be hard dependencies
+	for _, resource := range resources {
+		id := fmt.Sprintf("%s.%s", resource.Type, resource.Name)
+		node := graph[id]
+
+		for _, afterID := range resource.After {
+			if err := e.addOrdering(graph, id, "after", node, afterID, false); err != nil {
+				return nil, err
+			}
+		}
+		for _, beforeID := range resource.Before {
+			if err := e.addOrdering(graph, id, "before", node, beforeID, true); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return graph, nil
 }
 
-// validateResources validates all resources in the graph
+// addOrdering orders node after the target, or before it when reverse is
+// set. Unlike depends_on, a target that isn't configured is ignored, since it
+// may only be defined on other platforms, such as by include_platform.
+func (e *Engine) addOrdering(graph map[string]*ResourceNode, id, attrName string, node *ResourceNode, targetID string, reverse bool) error {
+	target, exists := graph[targetID]
+	if !exists {
+		e.logger.Debugf("Ignoring %s %s of %s: resource is not configured", attrName, targetID, id)
+		return nil
+	}
+	if target == node {
+		return fmt.Errorf("resource %s is ordered %s itself", id, attrName)
+	}
+
+	later, earlier := node, target
+	if reverse {
+		later, earlier = target, node
+	}
+	for _, dep := range later.DependsOn {
+		if dep == earlier {
+			return nil // Already a hard dependency
+		}
+	}
+	addDependency(later, earlier)
+	later.OrderOnly[earlier] = true
+	return nil
+}
+
+// pruneToTargets returns the part of the graph reachable from the targeted
+// resources by following related, or the whole graph when there are no
+// targets. Nodes keep their links to nodes that were pruned.
+func (e *Engine) pruneToTargets(graph map[string]*ResourceNode, related func(node *ResourceNode) []*ResourceNode) (map[string]*ResourceNode, error) {
+	if len(e.targets) == 0 {
+		return graph, nil
+	}
+
+	pruned := make(map[string]*ResourceNode)
+
+	var visit func(node *ResourceNode)
+	visit = func(node *ResourceNode) {
+		id := fmt.Sprintf("%s.%s", node.Resource.Type, node.Resource.Name)
+		if _, ok := pruned[id]; ok {
+			return
+		}
+		pruned[id] = node
+		for _, next := range related(node) {
+			visit(next)
+		}
+	}
+
+	for _, target := range e.targets {
+		node, exists := graph[target]
+		if !exists {
+			available := make([]string, 0, len(graph))
+			for id := range graph {
+				available = append(available, id)
+			}
+			sort.Strings(available)
+			return nil, fmt.Errorf("target %s does not exist; available resources: %s", target, strings.Join(available, ", "))
+		}
+		visit(node)
+	}
+
+	return pruned, nil
+}
+
+// validateResources validates all resources in the graph. Every problem with
+// every resource is collected, so they are all reported in a single error
+// listing each resource ID with its problems in ID order.
 func (e *Engine) validateResources(ctx context.Context, graph map[string]*ResourceNode) error {
-	for id, node := range graph {
+	ids := make([]string, 0, len(graph))
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var problems []string
+	for _, id := range ids {
+		node := graph[id]
+
+		if err := checkConditions(node.Resource); err != nil {
+			problems = append(problems, validationProblem(id, node.Resource, err))
+			continue
+		}
+
 		// Skip resources that don't apply to this platform
 		if !e.isPlatformSupported(node.Resource) {
 			continue
@@ -249,34 +913,72 @@ func (e *Engine) validateResources(ctx context.Context, graph map[string]*Resour
 
 		provider, err := e.registry.Get(node.Resource.Type)
 		if err != nil {
-			return fmt.Errorf("no provider for resource %s: %v", id, err)
+			problems = append(problems, fmt.Sprintf("%s: no provider: %v", id, err))
+			continue
 		}
 
 		if _, ok := node.Resource.Attributes["name"]; !ok {
 			node.Resource.Attributes["name"] = node.Resource.Name
 		}
 
-		if err := provider.Validate(ctx, node.Resource.Attributes); err != nil {
-			return fmt.Errorf("validation failed for resource %s: %v", id, err)
+		// Attributes that refer to other resources are validated once the
+		// references are resolved
+		if !hasReferences(node.Resource) {
+			if err := provider.Validate(ctx, node.Resource.Attributes); err != nil {
+				problems = append(problems, validationProblem(id, node.Resource, err))
+			}
+		}
+
+		if _, err := parseRetryPolicy(node.Resource.Attributes); err != nil {
+			problems = append(problems, validationProblem(id, node.Resource, err))
+		}
+
+		if _, err := parseTimeout(node.Resource.Attributes); err != nil {
+			problems = append(problems, validationProblem(id, node.Resource, err))
 		}
 	}
 
-	return nil
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("validation failed for resource %s", problems[0])
+	default:
+		return fmt.Errorf("validation failed with %d errors:\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
 }
 
-// topoSort performs a topological sort of the dependency graph
+// quotedAttributePattern matches an attribute name quoted in an error, as in
+// "file 'mode' must be a string"
+var quotedAttributePattern = regexp.MustCompile(`'([A-Za-z0-9_]+)'`)
+
+// validationProblem describes a validation error of a resource. When the
+// error names an attribute whose position is known, the position of its
+// value is added.
+func validationProblem(id string, resource Resource, err error) string {
+	for _, match := range quotedAttributePattern.FindAllStringSubmatch(err.Error(), -1) {
+		if position, ok := resource.Positions[match[1]]; ok {
+			return fmt.Sprintf("%s: %v (%s)", id, err, position)
+		}
+	}
+	return fmt.Sprintf("%s: %v", id, err)
+}
+
+// topoSort performs a topological sort of the dependency graph, listing
+// dependents before their dependencies. The same graph always yields the
+// same order, and so does the same cycle error.
 func (e *Engine) topoSort(graph map[string]*ResourceNode) ([]*ResourceNode, error) {
 	result := []*ResourceNode{}
 	visited := make(map[string]bool)
+	var stack []string // IDs of the nodes being visited, outermost first
 
 	var visit func(node *ResourceNode) error
 	visit = func(node *ResourceNode) error {
 		id := fmt.Sprintf("%s.%s", node.Resource.Type, node.Resource.Name)
 
-		// Check for cycles
+		// A node that is still being visited closes a cycle back to itself
 		if node.Visited {
-			// We're in a cycle
-			return fmt.Errorf("dependency cycle detected involving resource %s", id)
+			return cycleError(stack, id)
 		}
 
 		// Skip if already processed
@@ -285,24 +987,38 @@ func (e *Engine) topoSort(graph map[string]*ResourceNode) ([]*ResourceNode, erro
 		}
 
 		node.Visited = true
+		stack = append(stack, id)
 
-		// Visit dependencies first
-		for _, dep := range node.DependsOn {
+		// Visit dependencies first, ignoring any pruned from the graph
+		for _, dep := range sortedNodes(node.DependsOn) {
+			if _, ok := graph[fmt.Sprintf("%s.%s", dep.Resource.Type, dep.Resource.Name)]; !ok {
+				continue
+			}
 			if err := visit(dep); err != nil {
 				return err
 			}
 		}
 
 		node.Visited = false
+		stack = stack[:len(stack)-1]
 		visited[id] = true
 		result = append(result, node)
 		return nil
 	}
 
-	// Visit all nodes
+	// Visit all nodes in ID order rather than map order, so the same graph
+	// always sorts the same way
+	nodes := make([]*ResourceNode, 0, len(graph))
 	for _, node := range graph {
+		nodes = append(nodes, node)
+	}
+	for _, node := range sortedNodes(nodes) {
 		if !visited[fmt.Sprintf("%s.%s", node.Resource.Type, node.Resource.Name)] {
 			if err := visit(node); err != nil {
+				// Leave the nodes ready for another sort
+				for _, node := range graph {
+					node.Visited = false
+				}
 				return nil, err
 			}
 		}
@@ -316,13 +1032,89 @@ func (e *Engine) topoSort(graph map[string]*ResourceNode) ([]*ResourceNode, erro
 	return result, nil
 }
 
-// isPlatformSupported checks if the resource is supported on the current platform
+// cycleError describes the cycle closed by reaching id again while visiting
+// the nodes on the stack. Each resource in the path depends on the next, such
+// as "dependency cycle detected: file.a -> file.b -> file.a".
+func cycleError(stack []string, id string) error {
+	start := 0
+	for i, active := range stack {
+		if active == id {
+			start = i
+			break
+		}
+	}
+
+	path := append(append([]string(nil), stack[start:]...), id)
+	return fmt.Errorf("dependency cycle detected: %s", strings.Join(path, " -> "))
+}
+
+// sortedNodes returns the nodes in descending ID order. topoSort visits
+// nodes in this order so that, once its result is reversed, independent
+// resources are listed in ascending ID order.
+func sortedNodes(nodes []*ResourceNode) []*ResourceNode {
+	sorted := append([]*ResourceNode(nil), nodes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return fmt.Sprintf("%s.%s", sorted[i].Resource.Type, sorted[i].Resource.Name) >
+			fmt.Sprintf("%s.%s", sorted[j].Resource.Type, sorted[j].Resource.Name)
+	})
+	return sorted
+}
+
+// isPlatformSupported checks if the resource is supported on the current
+// system. Every condition in its when block must match; a missing condition
+// matches anything.
 func (e *Engine) isPlatformSupported(resource Resource) bool {
-	platforms, exists := resource.Conditions["platform"]
-	if !exists {
-		// No platform condition, so it's supported everywhere
-		return true
+	return e.skipReason(resource) == ""
+}
+
+// conditionNames are the conditions a when block may use
+var conditionNames = map[string]bool{
+	"platform":       true,
+	"arch":           true,
+	"command_exists": true,
+	"file_exists":    true,
+}
+
+// checkConditions returns an error for a when block condition that isn't known
+func checkConditions(resource Resource) error {
+	names := make([]string, 0, len(resource.Conditions))
+	for name := range resource.Conditions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !conditionNames[name] {
+			return fmt.Errorf("unknown condition %q in when block", name)
+		}
+	}
+	return nil
+}
+
+// skipReason returns why a resource's when conditions exclude it on this
+// system, or "" if they don't. The platform and arch must be in their lists,
+// and every command in command_exists and every path in file_exists must
+// exist.
+func (e *Engine) skipReason(resource Resource) string {
+	if platforms, exists := resource.Conditions["platform"]; exists && !e.platform.IsSupported(platforms) {
+		return fmt.Sprintf("platform %s does not match %s", runtime.GOOS, strings.Join(platforms, ", "))
+	}
+
+	if arches, exists := resource.Conditions["arch"]; exists && !e.platform.IsArchSupported(arches) {
+		return fmt.Sprintf("arch %s does not match %s", runtime.GOARCH, strings.Join(arches, ", "))
+	}
+
+	for _, command := range resource.Conditions["command_exists"] {
+		if !e.platform.IsCommandAvailable(command) {
+			return fmt.Sprintf("command %s not found", command)
+		}
+	}
+
+	for _, path := range resource.Conditions["file_exists"] {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Sprintf("file %s does not exist", path)
+		}
 	}
 
-	return e.platform.IsSupported(platforms)
+	return ""
 }
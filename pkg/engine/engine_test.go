@@ -3,8 +3,17 @@ package engine
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/dangerclosesec/zero/pkg/logging"
 	"github.com/dangerclosesec/zero/pkg/providers"
 )
 
@@ -218,6 +227,66 @@ func TestEngine_topoSort(t *testing.T) {
 	}
 }
 
+func TestEngine_topoSort_Deterministic(t *testing.T) {
+	engine := NewEngine(setupTestRegistry())
+
+	resources := []Resource{
+		{Type: "service", Name: "web", Attributes: map[string]interface{}{}, DependsOn: []string{"file.web_conf", "file.certs"}},
+		{Type: "file", Name: "web_conf", Attributes: map[string]interface{}{}},
+		{Type: "file", Name: "certs", Attributes: map[string]interface{}{}},
+		{Type: "file", Name: "motd", Attributes: map[string]interface{}{}},
+		{Type: "service", Name: "db", Attributes: map[string]interface{}{}},
+		{Type: "file", Name: "app", Attributes: map[string]interface{}{}},
+	}
+
+	order := func() string {
+		graph, err := engine.buildDependencyGraph(resources)
+		if err != nil {
+			t.Fatalf("buildDependencyGraph returned error: %v", err)
+		}
+		sorted, err := engine.topoSort(graph)
+		if err != nil {
+			t.Fatalf("topoSort returned error: %v", err)
+		}
+		ids := make([]string, len(sorted))
+		for i, node := range sorted {
+			ids[i] = node.Resource.Type + "." + node.Resource.Name
+		}
+		return strings.Join(ids, ",")
+	}
+
+	// Independent resources are in ID order, dependents before their dependencies
+	expected := "file.app,file.motd,service.db,service.web,file.certs,file.web_conf"
+	for i := 0; i < 50; i++ {
+		if got := order(); got != expected {
+			t.Fatalf("Run %d: expected order %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestEngine_topoSort_CyclePath(t *testing.T) {
+	engine := NewEngine(setupTestRegistry())
+
+	// file.a depends on file.b, which depends on service.c, which depends on file.a
+	resources := []Resource{
+		{Type: "file", Name: "a", Attributes: map[string]interface{}{}, DependsOn: []string{"file.b"}},
+		{Type: "file", Name: "b", Attributes: map[string]interface{}{}, DependsOn: []string{"service.c"}},
+		{Type: "service", Name: "c", Attributes: map[string]interface{}{}, DependsOn: []string{"file.a"}},
+		{Type: "file", Name: "d", Attributes: map[string]interface{}{}, DependsOn: []string{"file.a"}},
+	}
+
+	graph, err := engine.buildDependencyGraph(resources)
+	if err != nil {
+		t.Fatalf("buildDependencyGraph returned error: %v", err)
+	}
+
+	_, err = engine.topoSort(graph)
+	expected := "dependency cycle detected: service.c -> file.a -> file.b -> service.c"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+}
+
 func TestEngine_topoSort_CycleDetection(t *testing.T) {
 	registry := setupTestRegistry()
 	engine := NewEngine(registry)
@@ -364,6 +433,78 @@ func TestEngine_isPlatformSupported(t *testing.T) {
 	}
 }
 
+func TestEngine_isPlatformSupported_Arch(t *testing.T) {
+	engine := NewEngine(setupTestRegistry())
+
+	tests := []struct {
+		name       string
+		conditions map[string][]string
+		want       bool
+	}{
+		{"no conditions", nil, true},
+		{"matching arch", map[string][]string{"arch": {runtime.GOARCH}}, true},
+		{"non-matching arch", map[string][]string{"arch": {"invalid-arch"}}, false},
+		{"one of several arches", map[string][]string{"arch": {"invalid-arch", runtime.GOARCH}}, true},
+		{"matching platform and arch", map[string][]string{"platform": {runtime.GOOS}, "arch": {runtime.GOARCH}}, true},
+		{"matching platform, non-matching arch", map[string][]string{"platform": {runtime.GOOS}, "arch": {"invalid-arch"}}, false},
+		{"non-matching platform, matching arch", map[string][]string{"platform": {"invalid-platform"}, "arch": {runtime.GOARCH}}, false},
+		{"non-matching platform and arch", map[string][]string{"platform": {"invalid-platform"}, "arch": {"invalid-arch"}}, false},
+	}
+
+	for _, tt := range tests {
+		resource := Resource{Type: "file", Name: "f", Attributes: map[string]interface{}{}, Conditions: tt.conditions}
+		if got := engine.isPlatformSupported(resource); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestEngine_isPlatformSupported_RuntimeConditions(t *testing.T) {
+	engine := NewEngine(setupTestRegistry())
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to find test executable: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		conditions map[string][]string
+		want       bool
+	}{
+		{"existing command", map[string][]string{"command_exists": {executable}}, true},
+		{"missing command", map[string][]string{"command_exists": {"zero-no-such-command"}}, false},
+		{"one of several commands missing", map[string][]string{"command_exists": {executable, "zero-no-such-command"}}, false},
+		{"existing file", map[string][]string{"file_exists": {executable}}, true},
+		{"missing file", map[string][]string{"file_exists": {filepath.Join(t.TempDir(), "missing")}}, false},
+		{"command with non-matching platform", map[string][]string{"platform": {"invalid-platform"}, "command_exists": {executable}}, false},
+	}
+
+	for _, tt := range tests {
+		resource := Resource{Type: "file", Name: "f", Attributes: map[string]interface{}{}, Conditions: tt.conditions}
+		if got := engine.isPlatformSupported(resource); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	plan, err := engine.Plan(context.Background(), []Resource{
+		{Type: "file", Name: "docker", Attributes: map[string]interface{}{}, Conditions: map[string][]string{"command_exists": {"zero-no-such-command"}}},
+	})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if action := plan["file.docker"]; action.Action != "skipped" || !strings.Contains(action.Details, "command zero-no-such-command not found") {
+		t.Errorf("Expected resource to be skipped for a missing command, got %+v", action)
+	}
+
+	_, err = engine.Plan(context.Background(), []Resource{
+		{Type: "file", Name: "f", Attributes: map[string]interface{}{}, Conditions: map[string][]string{"kernel": {"6.1"}}},
+	})
+	if err == nil || !strings.Contains(err.Error(), `unknown condition "kernel"`) {
+		t.Errorf("Expected an unknown condition error, got %v", err)
+	}
+}
+
 func TestEngine_Plan(t *testing.T) {
 	registry := providers.NewProviderRegistry()
 	
@@ -474,4 +615,947 @@ func TestEngine_Apply(t *testing.T) {
 	if state.Status != "created" {
 		t.Errorf("Expected status to be 'created', got %s", state.Status)
 	}
-}
\ No newline at end of file
+}
+func TestEngine_Plan_ClassifiesChanges(t *testing.T) {
+	registry := providers.NewProviderRegistry()
+
+	// The mock reports whatever changes the resource lists in its "changes" attribute
+	registry.Register("file", &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			changes, _ := desired["changes"].([]string)
+			return &providers.ResourceState{
+				Type:       "file",
+				Name:       desired["path"].(string),
+				Attributes: desired,
+				Status:     "planned",
+				Changes:    changes,
+			}, nil
+		},
+	})
+
+	engine := NewEngine(registry)
+	engine.SetState(map[string]*providers.ResourceState{
+		"file.known": {Type: "file", Name: "/tmp/known", Attributes: map[string]interface{}{"path": "/tmp/known"}, Status: "created"},
+		"file.gone":  {Type: "file", Name: "/tmp/gone", Attributes: map[string]interface{}{"path": "/tmp/gone"}, Status: "created"},
+	})
+
+	resources := []Resource{
+		{Type: "file", Name: "new", Attributes: map[string]interface{}{"path": "/tmp/new"}},
+		{Type: "file", Name: "missing", Attributes: map[string]interface{}{"path": "/tmp/missing", "changes": []string{"state"}}},
+		{Type: "file", Name: "known", Attributes: map[string]interface{}{"path": "/tmp/known"}},
+		{Type: "file", Name: "drifted", Attributes: map[string]interface{}{"path": "/tmp/drifted", "changes": []string{"mode"}}},
+		{Type: "file", Name: "gone", Attributes: map[string]interface{}{"path": "/tmp/gone", "changes": []string{"state"}}},
+		{Type: "file", Name: "old", Attributes: map[string]interface{}{"path": "/tmp/old", "state": "absent"}},
+	}
+
+	plan, err := engine.Plan(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	expected := map[string]string{
+		"file.new":     "create",
+		"file.missing": "create",
+		"file.known":   "update",
+		"file.drifted": "update",
+		"file.gone":    "create",
+		"file.old":     "delete",
+	}
+	for id, want := range expected {
+		if got := plan[id].Action; got != want {
+			t.Errorf("Expected %s to be %s, got %s", id, want, got)
+		}
+	}
+}
+
+func diamondResources() []Resource {
+	return []Resource{
+		{Type: "file", Name: "a", Attributes: map[string]interface{}{"path": "a"}},
+		{Type: "file", Name: "b", Attributes: map[string]interface{}{"path": "b"}, DependsOn: []string{"file.a"}},
+		{Type: "file", Name: "c", Attributes: map[string]interface{}{"path": "c"}, DependsOn: []string{"file.a"}},
+		{Type: "file", Name: "d", Attributes: map[string]interface{}{"path": "d"}, DependsOn: []string{"file.b", "file.c"}},
+	}
+}
+
+func TestEngine_Apply_ParallelDiamond(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+
+	// b and c each wait for the other to start, which only succeeds if they run concurrently
+	arrived := make(chan string, 2)
+	release := make(chan struct{})
+	var once sync.Once
+
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Type: "file", Name: desired["path"].(string), Attributes: desired, Status: "planned"}, nil
+		},
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			if state.Name == "b" || state.Name == "c" {
+				arrived <- state.Name
+				if len(arrived) == 2 {
+					once.Do(func() { close(release) })
+				}
+				select {
+				case <-release:
+				case <-time.After(2 * time.Second):
+					return nil, fmt.Errorf("sibling %s did not run concurrently", state.Name)
+				}
+			}
+
+			mu.Lock()
+			order = append(order, state.Name)
+			mu.Unlock()
+
+			return &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "created", Changed: true}, nil
+		},
+	})
+
+	engine := NewEngine(registry)
+	engine.SetParallelism(2)
+
+	results, err := engine.Apply(context.Background(), diamondResources())
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	for id, state := range results {
+		if state.Status != "created" {
+			t.Errorf("Expected %s to be created, got %s (%v)", id, state.Status, state.Error)
+		}
+	}
+
+	if len(order) != 4 || order[0] != "a" || order[3] != "d" {
+		t.Errorf("Expected a first and d last, got %v", order)
+	}
+}
+
+func TestEngine_Apply_FailureBlocksDependents(t *testing.T) {
+	var mu sync.Mutex
+	applied := make(map[string]bool)
+
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Type: "file", Name: desired["path"].(string), Attributes: desired, Status: "planned"}, nil
+		},
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			mu.Lock()
+			applied[state.Name] = true
+			mu.Unlock()
+
+			if state.Name == "b" {
+				return nil, fmt.Errorf("b failed")
+			}
+			return &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "created", Changed: true}, nil
+		},
+	})
+
+	resources := append(diamondResources(),
+		Resource{Type: "file", Name: "e", Attributes: map[string]interface{}{"path": "e"}, DependsOn: []string{"file.d"}})
+
+	engine := NewEngine(registry)
+	results, err := engine.Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	expected := map[string]string{
+		"file.a": "created",
+		"file.b": "failed",
+		"file.c": "created",
+		"file.d": "failed",
+		"file.e": "failed",
+	}
+	for id, want := range expected {
+		if results[id] == nil || results[id].Status != want {
+			t.Errorf("Expected %s to be %s, got %+v", id, want, results[id])
+		}
+	}
+
+	if applied["d"] || applied["e"] {
+		t.Error("Expected dependents of a failed resource not to be applied")
+	}
+	if !applied["c"] {
+		t.Error("Expected independent sibling to be applied")
+	}
+}
+
+// NotifiableMockProvider is a MockProvider that counts notifications
+type NotifiableMockProvider struct {
+	MockProvider
+	mu       sync.Mutex
+	notified map[string]int
+}
+
+func (m *NotifiableMockProvider) Notify(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+	m.mu.Lock()
+	m.notified[state.Name]++
+	m.mu.Unlock()
+	return &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "updated", Changed: true, Changes: []string{"restart"}, Details: "restarted"}, nil
+}
+
+func TestEngine_Apply_Notifies(t *testing.T) {
+	// Files whose content is "new" change; the rest are already in place
+	fileProvider := &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Type: "file", Name: desired["path"].(string), Attributes: desired, Status: "planned"}, nil
+		},
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			result := &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "unchanged"}
+			if state.Attributes["content"] == "new" {
+				result.Status = "updated"
+				result.Changed = true
+			}
+			return result, nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		contents     []string
+		wantNotified int
+		wantStatus   string
+	}{
+		{"one change", []string{"new", "old"}, 1, "updated"},
+		{"two changes", []string{"new", "new"}, 1, "updated"},
+		{"no change", []string{"old", "old"}, 0, "unchanged"},
+	}
+
+	for _, tt := range tests {
+		serviceProvider := &NotifiableMockProvider{
+			MockProvider: MockProvider{
+				PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+					return &providers.ResourceState{Type: "service", Name: desired["name"].(string), Attributes: desired, Status: "unchanged"}, nil
+				},
+				ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+					return &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "unchanged"}, nil
+				},
+			},
+			notified: make(map[string]int),
+		}
+
+		registry := providers.NewProviderRegistry()
+		registry.Register("file", fileProvider)
+		registry.Register("service", serviceProvider)
+
+		resources := []Resource{
+			{Type: "file", Name: "main", Attributes: map[string]interface{}{"path": "main", "content": tt.contents[0]}, Notifies: []string{"service.nginx"}},
+			{Type: "file", Name: "site", Attributes: map[string]interface{}{"path": "site", "content": tt.contents[1]}, Notifies: []string{"service.nginx"}},
+			{Type: "service", Name: "nginx", Attributes: map[string]interface{}{"name": "nginx"}},
+		}
+
+		results, err := NewEngine(registry).Apply(context.Background(), resources)
+		if err != nil {
+			t.Fatalf("%s: Apply returned error: %v", tt.name, err)
+		}
+
+		if got := serviceProvider.notified["nginx"]; got != tt.wantNotified {
+			t.Errorf("%s: expected %d notifications, got %d", tt.name, tt.wantNotified, got)
+		}
+		if results["service.nginx"].Status != tt.wantStatus {
+			t.Errorf("%s: expected service status %s, got %s", tt.name, tt.wantStatus, results["service.nginx"].Status)
+		}
+	}
+}
+
+// DryRunMockProvider is a MockProvider whose Apply honors dry runs
+type DryRunMockProvider struct {
+	MockProvider
+}
+
+func (m *DryRunMockProvider) SupportsDryRun() bool {
+	return true
+}
+
+func TestEngine_Apply_DryRun(t *testing.T) {
+	var applied []string
+	var mu sync.Mutex
+	record := func(name string) {
+		mu.Lock()
+		applied = append(applied, name)
+		mu.Unlock()
+	}
+
+	// The file provider supports dry runs and reports what it would do
+	fileProvider := &DryRunMockProvider{MockProvider{
+		PlanFunc: planDesired,
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			if !providers.IsDryRun(ctx) {
+				record(state.Name)
+			}
+			return &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "updated", Changed: true}, nil
+		},
+	}}
+	// The service provider doesn't, so it must not be applied or notified
+	serviceProvider := &NotifiableMockProvider{
+		MockProvider: MockProvider{
+			PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+				return &providers.ResourceState{Attributes: desired, Status: "planned", Changes: []string{"state"}}, nil
+			},
+			ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+				record(state.Name)
+				return state, nil
+			},
+		},
+		notified: make(map[string]int),
+	}
+
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", fileProvider)
+	registry.Register("service", serviceProvider)
+
+	engine := NewEngine(registry)
+	recorder := &logging.Recorder{}
+	engine.SetLogger(recorder)
+	engine.SetDryRun(true)
+
+	results, err := engine.Apply(context.Background(), []Resource{
+		{Type: "file", Name: "config", Attributes: map[string]interface{}{}, Notifies: []string{"service.app"}},
+		{Type: "service", Name: "app", Attributes: map[string]interface{}{}},
+	})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	if len(applied) != 0 || serviceProvider.notified["app"] != 0 {
+		t.Errorf("Expected nothing to be applied or notified, applied %v and notified %v", applied, serviceProvider.notified)
+	}
+	if state := results["file.config"]; state.Status != "updated" || !state.Changed {
+		t.Errorf("Expected the file's dry run result, got %+v", state)
+	}
+	if state := results["service.app"]; state.Status != "created" || !state.Changed {
+		t.Errorf("Expected the service's planned status, got %+v", state)
+	}
+
+	messages := recorder.Messages(logging.LevelInfo)
+	for _, want := range []string{"Applying file.config", "Would create service.app", "Would notify service.app (triggered by file.config)"} {
+		if !containsMessage(messages, want) {
+			t.Errorf("Expected message %q, got %q", want, messages)
+		}
+	}
+}
+
+// containsMessage reports whether messages contains message
+func containsMessage(messages []string, message string) bool {
+	for _, m := range messages {
+		if m == message {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEngine_Notifications(t *testing.T) {
+	fileProvider := &MockProvider{
+		PlanFunc: planDesired,
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "updated", Changed: true}, nil
+		},
+	}
+	serviceProvider := &NotifiableMockProvider{notified: make(map[string]int)}
+	serviceProvider.PlanFunc = func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+		return &providers.ResourceState{Attributes: desired, Status: "unchanged"}, nil
+	}
+
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", fileProvider)
+	registry.Register("service", serviceProvider)
+	engine := NewEngine(registry)
+	engine.SetLogger(logging.Discard)
+
+	// Both files change, but the service is restarted and listed once
+	_, err := engine.Apply(context.Background(), []Resource{
+		{Type: "file", Name: "site", Attributes: map[string]interface{}{}, Notifies: []string{"service.nginx"}},
+		{Type: "file", Name: "main", Attributes: map[string]interface{}{}, Notifies: []string{"service.nginx"}},
+		{Type: "service", Name: "nginx", Attributes: map[string]interface{}{"name": "nginx"}},
+		{Type: "service", Name: "idle", Attributes: map[string]interface{}{"name": "idle"}},
+	})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	want := []Notification{{ID: "service.nginx", Action: "restarted", Sources: []string{"file.main", "file.site"}}}
+	if got := engine.Notifications(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected notifications %+v, got %+v", want, got)
+	}
+
+	// Each Apply reports only its own notifications
+	if _, err := engine.Apply(context.Background(), []Resource{{Type: "service", Name: "idle", Attributes: map[string]interface{}{"name": "idle"}}}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if got := engine.Notifications(); len(got) != 0 {
+		t.Errorf("Expected no notifications, got %+v", got)
+	}
+}
+
+func TestEngine_buildDependencyGraph_Notifies(t *testing.T) {
+	engine := NewEngine(setupTestRegistry())
+
+	resources := []Resource{
+		{Type: "file", Name: "config", Attributes: map[string]interface{}{}, Notifies: []string{"service.app"}},
+		{Type: "service", Name: "app", Attributes: map[string]interface{}{}},
+	}
+
+	graph, err := engine.buildDependencyGraph(resources)
+	if err != nil {
+		t.Fatalf("buildDependencyGraph returned error: %v", err)
+	}
+
+	service := graph["service.app"]
+	if len(service.DependsOn) != 1 || service.DependsOn[0] != graph["file.config"] {
+		t.Error("Expected notified service to be ordered after the file that notifies it")
+	}
+
+	resources[0].Notifies = []string{"service.missing"}
+	if _, err := engine.buildDependencyGraph(resources); err == nil {
+		t.Error("Expected error for notifying a non-existent resource, got nil")
+	}
+}
+
+func TestEngine_buildDependencyGraph_Ordering(t *testing.T) {
+	engine := NewEngine(setupTestRegistry())
+	engine.SetLogger(logging.Discard)
+
+	resources := []Resource{
+		{Type: "file", Name: "config", Attributes: map[string]interface{}{}, Before: []string{"service.app"}},
+		{Type: "file", Name: "log", Attributes: map[string]interface{}{}, After: []string{"service.app", "service.missing"}},
+		{Type: "service", Name: "app", Attributes: map[string]interface{}{}},
+	}
+
+	graph, err := engine.buildDependencyGraph(resources)
+	if err != nil {
+		t.Fatalf("buildDependencyGraph returned error: %v", err)
+	}
+
+	service := graph["service.app"]
+	if len(service.DependsOn) != 1 || service.DependsOn[0] != graph["file.config"] || !service.OrderOnly[graph["file.config"]] {
+		t.Error("Expected service.app to be ordered after file.config")
+	}
+	log := graph["file.log"]
+	if len(log.DependsOn) != 1 || log.DependsOn[0] != service || !log.OrderOnly[service] {
+		t.Error("Expected file.log to be ordered after service.app, ignoring the missing target")
+	}
+
+	// Ordering on a hard dependency leaves it a hard dependency
+	resources[2].DependsOn = []string{"file.config"}
+	graph, err = engine.buildDependencyGraph(resources)
+	if err != nil {
+		t.Fatalf("buildDependencyGraph returned error: %v", err)
+	}
+	service = graph["service.app"]
+	if len(service.DependsOn) != 1 || service.OrderOnly[graph["file.config"]] {
+		t.Error("Expected depends_on to stay a hard dependency")
+	}
+
+	resources[0].Before = []string{"file.config"}
+	if _, err := engine.buildDependencyGraph(resources); err == nil {
+		t.Error("Expected error for a resource ordered before itself, got nil")
+	}
+}
+
+func TestEngine_Apply_Ordering(t *testing.T) {
+	otherOS := "windows"
+	if runtime.GOOS == "windows" {
+		otherOS = "linux"
+	}
+
+	var mu sync.Mutex
+	var order []string
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		PlanFunc: planDesired,
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			name := state.Attributes["name"].(string)
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			if name == "first" {
+				return nil, fmt.Errorf("first failed")
+			}
+			return &providers.ResourceState{Type: "file", Name: name, Attributes: state.Attributes, Status: "created", Changed: true}, nil
+		},
+	})
+
+	// file.second is ordered after a resource that failed, one that is
+	// skipped on this platform and one that isn't configured at all
+	resources := []Resource{
+		{Type: "file", Name: "second", Attributes: map[string]interface{}{}, After: []string{"file.first", "file.gated", "file.missing"}},
+		{Type: "file", Name: "first", Attributes: map[string]interface{}{}},
+		{Type: "file", Name: "gated", Attributes: map[string]interface{}{}, Conditions: map[string][]string{"platform": {otherOS}}},
+	}
+
+	engine := NewEngine(registry)
+	engine.SetLogger(logging.Discard)
+	results, err := engine.Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(order, []string{"first", "second"}) {
+		t.Errorf("Expected file.first to be applied before file.second, got %v", order)
+	}
+	if state := results["file.second"]; state == nil || state.Status != "created" {
+		t.Errorf("Expected file.second to be created despite file.first failing, got %+v", state)
+	}
+}
+
+func TestEngine_pruneToTargets(t *testing.T) {
+	resources := append(diamondResources(),
+		Resource{Type: "file", Name: "e", Attributes: map[string]interface{}{"path": "e"}, DependsOn: []string{"file.b"}},
+		Resource{Type: "file", Name: "f", Attributes: map[string]interface{}{"path": "f"}})
+
+	tests := []struct {
+		name    string
+		targets []string
+		want    []string
+	}{
+		{"no targets", nil, []string{"file.a", "file.b", "file.c", "file.d", "file.e", "file.f"}},
+		{"leaf", []string{"file.e"}, []string{"file.a", "file.b", "file.e"}},
+		{"join", []string{"file.d"}, []string{"file.a", "file.b", "file.c", "file.d"}},
+		{"several", []string{"file.c", "file.f"}, []string{"file.a", "file.c", "file.f"}},
+	}
+
+	for _, tt := range tests {
+		engine := NewEngine(setupTestRegistry())
+		engine.SetTargets(tt.targets)
+
+		graph, err := engine.buildDependencyGraph(resources)
+		if err != nil {
+			t.Fatalf("%s: buildDependencyGraph returned error: %v", tt.name, err)
+		}
+		pruned, err := engine.pruneToTargets(graph, func(node *ResourceNode) []*ResourceNode { return node.DependsOn })
+		if err != nil {
+			t.Fatalf("%s: pruneToTargets returned error: %v", tt.name, err)
+		}
+
+		var got []string
+		for id := range pruned {
+			got = append(got, id)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	engine := NewEngine(setupTestRegistry())
+	engine.SetTargets([]string{"file.missing"})
+	_, err := engine.Plan(context.Background(), resources)
+	if err == nil || !strings.Contains(err.Error(), "file.missing") || !strings.Contains(err.Error(), "file.a, file.b") {
+		t.Errorf("Expected error naming the unknown target and available resources, got %v", err)
+	}
+}
+
+func TestEngine_Apply_Targets(t *testing.T) {
+	var mu sync.Mutex
+	var applied []string
+
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Type: "file", Name: desired["path"].(string), Attributes: desired, Status: "planned"}, nil
+		},
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			mu.Lock()
+			applied = append(applied, state.Name)
+			mu.Unlock()
+			return &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "created", Changed: true}, nil
+		},
+	})
+
+	engine := NewEngine(registry)
+	engine.SetTargets([]string{"file.b"})
+
+	results, err := engine.Apply(context.Background(), diamondResources())
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if len(results) != 2 || results["file.a"] == nil || results["file.b"] == nil {
+		t.Errorf("Expected only file.a and file.b to be applied, got %v", applied)
+	}
+}
+
+func TestEngine_validateResources_ReportsAllErrors(t *testing.T) {
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		ValidateFunc: func(ctx context.Context, attributes map[string]interface{}) error {
+			if _, ok := attributes["path"]; !ok {
+				return fmt.Errorf("file resource requires 'path' attribute")
+			}
+			return nil
+		},
+	})
+
+	engine := NewEngine(registry)
+
+	resources := []Resource{
+		{Type: "file", Name: "ok", Attributes: map[string]interface{}{"path": "/tmp/ok"}},
+		{Type: "file", Name: "missing", Attributes: map[string]interface{}{}},
+		{Type: "file", Name: "timeout", Attributes: map[string]interface{}{"path": "/tmp/t", "timeout": "soon"}},
+		{Type: "unknown", Name: "thing", Attributes: map[string]interface{}{}},
+		{
+			Type:       "unknown",
+			Name:       "skipped",
+			Attributes: map[string]interface{}{},
+			Conditions: map[string][]string{"platform": {"invalid-platform"}},
+		},
+	}
+
+	graph, err := engine.buildDependencyGraph(resources)
+	if err != nil {
+		t.Fatalf("buildDependencyGraph returned error: %v", err)
+	}
+
+	err = engine.validateResources(context.Background(), graph)
+	if err == nil {
+		t.Fatal("Expected validateResources to return an error")
+	}
+
+	message := err.Error()
+	for _, want := range []string{"3 errors", "file.missing: file resource requires 'path' attribute", "file.timeout: ", "unknown.thing: no provider"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("Expected error to contain %q, got:\n%s", want, message)
+		}
+	}
+	for _, unwanted := range []string{"file.ok", "unknown.skipped"} {
+		if strings.Contains(message, unwanted) {
+			t.Errorf("Expected error not to mention %s, got:\n%s", unwanted, message)
+		}
+	}
+}
+
+func TestEngine_validateResources_ReportsPositions(t *testing.T) {
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		ValidateFunc: func(ctx context.Context, attributes map[string]interface{}) error {
+			if _, ok := attributes["mode"].(string); !ok {
+				return fmt.Errorf("file 'mode' must be a string")
+			}
+			return nil
+		},
+	})
+
+	engine := NewEngine(registry)
+	positions := map[string]Position{
+		"mode":    {File: "main.zero", Line: 3, Column: 13},
+		"timeout": {Line: 4, Column: 13},
+	}
+	resources := []Resource{
+		{Type: "file", Name: "mode", Attributes: map[string]interface{}{"mode": 644}, Positions: positions},
+		{Type: "file", Name: "timeout", Attributes: map[string]interface{}{"mode": "0644", "timeout": "soon"}, Positions: positions},
+		{Type: "file", Name: "unknown", Attributes: map[string]interface{}{"mode": 644}},
+	}
+
+	graph, err := engine.buildDependencyGraph(resources)
+	if err != nil {
+		t.Fatalf("buildDependencyGraph returned error: %v", err)
+	}
+	err = engine.validateResources(context.Background(), graph)
+	if err == nil {
+		t.Fatal("Expected validateResources to return an error")
+	}
+
+	message := err.Error()
+	for _, want := range []string{
+		"file.mode: file 'mode' must be a string (main.zero, line 3, column 13)",
+		`file.timeout: 'timeout' must be a duration such as "5m" (line 4, column 13)`,
+		"file.unknown: file 'mode' must be a string\n",
+	} {
+		if !strings.Contains(message+"\n", want) {
+			t.Errorf("Expected error to contain %q, got:\n%s", want, message)
+		}
+	}
+}
+
+func TestEngine_Plan_Details(t *testing.T) {
+	registry := providers.NewProviderRegistry()
+	registry.Register("package", &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Status: "planned", Changes: []string{"version"}, Details: "nginx 1.18.0 -> 1.20.1"}, nil
+		},
+	})
+	registry.Register("file", &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Status: "planned", Changes: []string{"content"}, Details: "--- a\n+++ b\n-old\n+new"}, nil
+		},
+	})
+
+	plan, err := NewEngine(registry).Plan(context.Background(), []Resource{
+		{Type: "package", Name: "nginx", Attributes: map[string]interface{}{}},
+		{Type: "file", Name: "config", Attributes: map[string]interface{}{"path": "/etc/app.conf"}},
+	})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	// Single-line details join the summary, multi-line ones go below it
+	expected := map[string]string{
+		"package.nginx": "Resource will be updated: nginx 1.18.0 -> 1.20.1",
+		"file.config":   "Resource will be updated\n--- a\n+++ b\n-old\n+new",
+	}
+	for id, want := range expected {
+		if got := plan[id].Details; got != want {
+			t.Errorf("Expected %s details %q, got %q", id, want, got)
+		}
+	}
+}
+
+func TestEngine_Plan_RendersDiff(t *testing.T) {
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			return &providers.ResourceState{
+				Status:  "planned",
+				Changes: []string{"mode", "content"},
+				Diff: map[string]providers.AttributeDiff{
+					"mode":    {Old: "0600", New: "0644"},
+					"content": {},
+					"owner":   {New: "app"},
+				},
+			}, nil
+		},
+	})
+
+	plan, err := NewEngine(registry).Plan(context.Background(), []Resource{
+		{Type: "file", Name: "config", Attributes: map[string]interface{}{"path": "/etc/app.conf"}},
+	})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	expected := "Resource will be updated: content: changed, mode: 0600 -> 0644, owner: (none) -> app"
+	if plan["file.config"].Details != expected {
+		t.Errorf("Expected details %q, got %q", expected, plan["file.config"].Details)
+	}
+}
+
+// ReaderMockProvider is a MockProvider that reports a live state
+type ReaderMockProvider struct {
+	MockProvider
+	ReadFunc func(ctx context.Context, attributes map[string]interface{}) (map[string]interface{}, error)
+}
+
+func (m *ReaderMockProvider) Read(ctx context.Context, attributes map[string]interface{}) (map[string]interface{}, error) {
+	return m.ReadFunc(ctx, attributes)
+}
+
+func TestEngine_Plan_ReadsCurrentState(t *testing.T) {
+	live := map[string]interface{}{"mode": "0644"}
+	planMode := func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+		if current["mode"] == desired["mode"] {
+			return &providers.ResourceState{Status: "unchanged"}, nil
+		}
+		return &providers.ResourceState{Status: "planned", Changes: []string{"mode"}}, nil
+	}
+
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &ReaderMockProvider{
+		MockProvider: MockProvider{PlanFunc: planMode},
+		ReadFunc: func(ctx context.Context, attributes map[string]interface{}) (map[string]interface{}, error) {
+			return live, nil
+		},
+	})
+
+	engine := NewEngine(registry)
+	// The live state takes the place of the recorded state
+	engine.SetState(map[string]*providers.ResourceState{
+		"file.config": {Type: "file", Name: "config", Attributes: map[string]interface{}{"mode": "0600"}},
+	})
+
+	resources := []Resource{{Type: "file", Name: "config", Attributes: map[string]interface{}{"mode": "0644"}}}
+	plan, err := engine.Plan(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if plan["file.config"].Action != "no-op" {
+		t.Errorf("Expected no-op when the live state matches, got %s", plan["file.config"].Action)
+	}
+
+	live["mode"] = "0600"
+	plan, err = engine.Plan(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if plan["file.config"].Action != "update" {
+		t.Errorf("Expected update when the live state differs, got %s", plan["file.config"].Action)
+	}
+
+	registry.Register("file", &ReaderMockProvider{
+		MockProvider: MockProvider{PlanFunc: planMode},
+		ReadFunc: func(ctx context.Context, attributes map[string]interface{}) (map[string]interface{}, error) {
+			return nil, fmt.Errorf("permission denied")
+		},
+	})
+	plan, err = engine.Plan(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if plan["file.config"].Action != "error" || !strings.Contains(plan["file.config"].Details, "permission denied") {
+		t.Errorf("Expected a read error to be reported, got %+v", plan["file.config"])
+	}
+}
+
+func TestEngine_Apply_RecordsTimings(t *testing.T) {
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			if state.Attributes["slow"] == true {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return &providers.ResourceState{Attributes: state.Attributes, Status: "created", Changed: true}, nil
+		},
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Attributes: desired, Status: "planned"}, nil
+		},
+	})
+
+	engine := NewEngine(registry)
+	_, err := engine.Apply(context.Background(), []Resource{
+		{Type: "file", Name: "slow", Attributes: map[string]interface{}{"slow": true}},
+		{Type: "file", Name: "fast", Attributes: map[string]interface{}{}},
+	})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	timings := engine.Timings()
+	if len(timings) != 2 {
+		t.Fatalf("Expected timings for 2 resources, got %v", timings)
+	}
+	if timings["file.slow"] < 20*time.Millisecond {
+		t.Errorf("Expected file.slow to take at least 20ms, got %v", timings["file.slow"])
+	}
+	if _, ok := timings["file.fast"]; !ok {
+		t.Error("Expected a timing for file.fast")
+	}
+}
+
+func TestEngine_Validate(t *testing.T) {
+	applied := false
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		ValidateFunc: func(ctx context.Context, attributes map[string]interface{}) error {
+			if mode, ok := attributes["mode"].(string); ok && mode != "0644" {
+				return fmt.Errorf("invalid file mode: %s", mode)
+			}
+			return nil
+		},
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			t.Error("Validate must not plan resources")
+			return &providers.ResourceState{Status: "planned"}, nil
+		},
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			applied = true
+			return state, nil
+		},
+	})
+	engine := NewEngine(registry)
+
+	valid := []Resource{
+		{Type: "file", Name: "a", Attributes: map[string]interface{}{"mode": "0644"}},
+		{Type: "file", Name: "b", Attributes: map[string]interface{}{}, DependsOn: []string{"file.a"}},
+	}
+	if err := engine.Validate(context.Background(), valid); err != nil {
+		t.Errorf("Expected valid configuration, got %v", err)
+	}
+
+	// An invalid attribute and a cycle are reported together
+	cycle := []Resource{
+		{Type: "file", Name: "a", Attributes: map[string]interface{}{"mode": "bogus"}, DependsOn: []string{"file.b"}},
+		{Type: "file", Name: "b", Attributes: map[string]interface{}{}, DependsOn: []string{"file.a"}},
+	}
+	err := engine.Validate(context.Background(), cycle)
+	if err == nil {
+		t.Fatal("Expected validation to fail")
+	}
+	if !strings.Contains(err.Error(), "invalid file mode: bogus") || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected both the attribute error and the cycle, got %v", err)
+	}
+
+	missing := []Resource{{Type: "file", Name: "a", Attributes: map[string]interface{}{}, DependsOn: []string{"file.missing"}}}
+	if err := engine.Validate(context.Background(), missing); err == nil || !strings.Contains(err.Error(), "file.missing") {
+		t.Errorf("Expected a missing dependency error, got %v", err)
+	}
+
+	if applied {
+		t.Error("Validate must not apply resources")
+	}
+}
+
+func TestEngine_Plan_ReportsSkipped(t *testing.T) {
+	otherOS := "windows"
+	if runtime.GOOS == "windows" {
+		otherOS = "linux"
+	}
+
+	engine := NewEngine(setupTestRegistry())
+	plan, err := engine.Plan(context.Background(), []Resource{
+		{Type: "file", Name: "gated", Attributes: map[string]interface{}{}, Conditions: map[string][]string{"platform": {otherOS}}},
+		{Type: "file", Name: "arch", Attributes: map[string]interface{}{}, Conditions: map[string][]string{"arch": {"no-such-arch"}}},
+		{Type: "file", Name: "everywhere", Attributes: map[string]interface{}{}},
+	})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	if len(plan) != 3 {
+		t.Fatalf("Expected every resource in the plan, got %v", plan)
+	}
+
+	gated := plan["file.gated"]
+	if gated.Action != "skipped" || !strings.Contains(gated.Details, "platform "+runtime.GOOS+" does not match "+otherOS) {
+		t.Errorf("Expected file.gated to be skipped by platform, got %+v", gated)
+	}
+	arch := plan["file.arch"]
+	if arch.Action != "skipped" || !strings.Contains(arch.Details, "arch "+runtime.GOARCH+" does not match no-such-arch") {
+		t.Errorf("Expected file.arch to be skipped by arch, got %+v", arch)
+	}
+	if plan["file.everywhere"].Action != "create" {
+		t.Errorf("Expected file.everywhere to be planned, got %+v", plan["file.everywhere"])
+	}
+}
+
+func TestEngine_Apply_Logs(t *testing.T) {
+	registry := providers.NewProviderRegistry()
+	attempts := 0
+	registry.Register("file", &MockProvider{
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, fmt.Errorf("transient failure")
+			}
+			return &providers.ResourceState{Status: "created", Changed: true}, nil
+		},
+	})
+	registry.Register("service", &MockProvider{
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			logging.FromContext(ctx).Warnf("service provider warning")
+			return nil, fmt.Errorf("start failed")
+		},
+	})
+
+	recorder := &logging.Recorder{}
+	engine := NewEngine(registry)
+	engine.SetLogger(recorder)
+
+	resources := []Resource{
+		{Type: "file", Name: "a", Attributes: map[string]interface{}{"retries": int64(1), "retry_delay": "1ms"}},
+		{Type: "service", Name: "b", Attributes: map[string]interface{}{}, DependsOn: []string{"file.a"}},
+	}
+	if _, err := engine.Apply(context.Background(), resources); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	expected := []logging.Entry{
+		{Level: logging.LevelInfo, Message: "Applying file.a"},
+		{Level: logging.LevelWarn, Message: "Retrying file.a in 1ms (attempt 2 of 2): transient failure"},
+		{Level: logging.LevelInfo, Message: "Applying service.b"},
+		{Level: logging.LevelWarn, Message: "service provider warning"},
+		{Level: logging.LevelError, Message: "Error applying service.b: start failed"},
+	}
+	if got := recorder.Entries(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected log entries %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,266 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// instance is one copy of a resource expanded by count or for_each
+type instance struct {
+	key    string            // Index within the name, such as 0 or "nginx"
+	values map[string]string // Values for ${count.index}, ${each.key} and ${each.value}
+}
+
+// expandResources replaces each resource that sets the count or for_each
+// meta-argument with one resource per instance. Counted instances are named
+// name[0], name[1] and so on, and ${count.index} is replaced with the index.
+// for_each instances are named name["key"] after each list item or map key,
+// with ${each.key} and ${each.value} replaced, so removing one item leaves
+// the others' names unchanged. Substitution covers string attributes,
+// dependencies and notifications. Dependencies and notifications naming the
+// base resource fan out to every instance, so an empty expansion drops them.
+func expandResources(resources []Resource) ([]Resource, error) {
+	expanded := make([]Resource, 0, len(resources))
+	instances := make(map[string][]string)
+
+	for _, resource := range resources {
+		copies, ok, err := resourceInstances(resource)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			expanded = append(expanded, resource)
+			continue
+		}
+
+		baseID := fmt.Sprintf("%s.%s", resource.Type, resource.Name)
+		instances[baseID] = []string{}
+
+		for _, inst := range copies {
+			expandedResource := substituteInstance(resource, inst.values)
+			expandedResource.Name = fmt.Sprintf("%s[%s]", resource.Name, inst.key)
+			delete(expandedResource.Attributes, "count")
+			delete(expandedResource.Attributes, "for_each")
+
+			expanded = append(expanded, expandedResource)
+			instances[baseID] = append(instances[baseID], fmt.Sprintf("%s.%s", expandedResource.Type, expandedResource.Name))
+		}
+	}
+
+	if len(instances) == 0 {
+		return resources, nil
+	}
+
+	for i := range expanded {
+		expanded[i].DependsOn = fanOut(expanded[i].DependsOn, instances)
+		expanded[i].Notifies = fanOut(expanded[i].Notifies, instances)
+		expanded[i].Before = fanOut(expanded[i].Before, instances)
+		expanded[i].After = fanOut(expanded[i].After, instances)
+	}
+
+	return expanded, nil
+}
+
+// resourceInstances returns the instances a resource expands into, or false
+// if it sets neither count nor for_each
+func resourceInstances(resource Resource) ([]instance, bool, error) {
+	_, hasCount := resource.Attributes["count"]
+	_, hasForEach := resource.Attributes["for_each"]
+
+	switch {
+	case hasCount && hasForEach:
+		return nil, false, fmt.Errorf("resource %s.%s cannot set both 'count' and 'for_each'", resource.Type, resource.Name)
+	case hasCount:
+		count, err := resourceCount(resource)
+		if err != nil {
+			return nil, false, err
+		}
+		copies := make([]instance, count)
+		for i := range copies {
+			index := strconv.Itoa(i)
+			copies[i] = instance{key: index, values: map[string]string{"count.index": index}}
+		}
+		return copies, true, nil
+	case hasForEach:
+		copies, err := forEachInstances(resource)
+		if err != nil {
+			return nil, false, err
+		}
+		return copies, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// resourceCount returns the count meta-argument of a resource, which may be a
+// number or a string holding one after variable substitution
+func resourceCount(resource Resource) (int, error) {
+	var count int64
+	switch v := resource.Attributes["count"].(type) {
+	case int64:
+		count = v
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("resource %s.%s 'count' must be a whole number, got %q", resource.Type, resource.Name, v)
+		}
+		count = n
+	default:
+		return 0, fmt.Errorf("resource %s.%s 'count' must be a whole number", resource.Type, resource.Name)
+	}
+
+	if count < 0 {
+		return 0, fmt.Errorf("resource %s.%s 'count' must not be negative, got %d", resource.Type, resource.Name, count)
+	}
+
+	return int(count), nil
+}
+
+// forEachInstances returns an instance per item of a for_each list, keyed by
+// the item, or per entry of a for_each map, keyed by the map key. Map entries
+// are returned in key order.
+func forEachInstances(resource Resource) ([]instance, error) {
+	id := fmt.Sprintf("%s.%s", resource.Type, resource.Name)
+
+	var keys, values []string
+	switch v := resource.Attributes["for_each"].(type) {
+	case []string:
+		keys, values = v, v
+	case []interface{}:
+		for _, item := range v {
+			str, err := forEachValue(id, item)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, str)
+		}
+		values = keys
+	case map[string]interface{}:
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			str, err := forEachValue(id, v[key])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, str)
+		}
+	default:
+		return nil, fmt.Errorf("resource %s 'for_each' must be a list or a map", id)
+	}
+
+	copies := make([]instance, len(keys))
+	seen := make(map[string]bool)
+	for i, key := range keys {
+		if seen[key] {
+			return nil, fmt.Errorf("resource %s 'for_each' contains duplicate key %q", id, key)
+		}
+		seen[key] = true
+		copies[i] = instance{
+			key:    strconv.Quote(key),
+			values: map[string]string{"each.key": key, "each.value": values[i]},
+		}
+	}
+
+	return copies, nil
+}
+
+// forEachValue converts a for_each item to the string substituted for it
+func forEachValue(id string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int64, float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("resource %s 'for_each' values must be strings, numbers or booleans", id)
+	}
+}
+
+// substituteInstance returns a copy of resource with ${name} references to
+// the given instance values replaced in its string attributes, dependencies
+// and notifications
+func substituteInstance(resource Resource, values map[string]string) Resource {
+	replace := func(s string) string {
+		if !strings.Contains(s, "${") {
+			return s
+		}
+		for name, value := range values {
+			s = strings.ReplaceAll(s, "${"+name+"}", value)
+		}
+		return s
+	}
+
+	result := resource
+	result.Attributes = substituteValue(resource.Attributes, replace).(map[string]interface{})
+
+	result.DependsOn = make([]string, len(resource.DependsOn))
+	for i, dep := range resource.DependsOn {
+		result.DependsOn[i] = replace(dep)
+	}
+	result.Notifies = make([]string, len(resource.Notifies))
+	for i, target := range resource.Notifies {
+		result.Notifies[i] = replace(target)
+	}
+	result.Before = make([]string, len(resource.Before))
+	for i, target := range resource.Before {
+		result.Before[i] = replace(target)
+	}
+	result.After = make([]string, len(resource.After))
+	for i, target := range resource.After {
+		result.After[i] = replace(target)
+	}
+
+	return result
+}
+
+// substituteValue copies an attribute value, applying replace to every string
+// it holds, including those inside lists and maps
+func substituteValue(value interface{}, replace func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return replace(v)
+	case []string:
+		result := make([]string, len(v))
+		for i, item := range v {
+			result[i] = replace(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = substituteValue(item, replace)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = substituteValue(item, replace)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// fanOut replaces references to an expanded resource with references to
+// each of its instances
+func fanOut(ids []string, instances map[string][]string) []string {
+	if len(ids) == 0 {
+		return ids
+	}
+
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if expandedIDs, ok := instances[id]; ok {
+			result = append(result, expandedIDs...)
+			continue
+		}
+		result = append(result, id)
+	}
+	return result
+}
@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestExpandResources_Count(t *testing.T) {
+	resources := []Resource{
+		{
+			Type: "file",
+			Name: "web",
+			Attributes: map[string]interface{}{
+				"path":    "/srv/web-${count.index}.conf",
+				"count":   int64(3),
+				"content": "port=80${count.index}",
+				"tags":    []string{"web", "shard-${count.index}"},
+			},
+		},
+		{Type: "service", Name: "web", Attributes: map[string]interface{}{"name": "web"}, DependsOn: []string{"file.web"}},
+		{Type: "file", Name: "none", Attributes: map[string]interface{}{"path": "/srv/none", "count": "0"}},
+		{Type: "service", Name: "other", Attributes: map[string]interface{}{"name": "other"}, DependsOn: []string{"file.none"}},
+	}
+
+	expanded, err := expandResources(resources)
+	if err != nil {
+		t.Fatalf("expandResources returned error: %v", err)
+	}
+
+	byID := make(map[string]Resource)
+	for _, resource := range expanded {
+		byID[resource.Type+"."+resource.Name] = resource
+	}
+
+	if len(expanded) != 5 {
+		t.Fatalf("Expected 5 resources after expansion, got %d", len(expanded))
+	}
+	if _, ok := byID["file.none[0]"]; ok {
+		t.Error("Expected count = 0 to produce no instances")
+	}
+
+	second, ok := byID["file.web[1]"]
+	if !ok {
+		t.Fatal("Expected instance file.web[1]")
+	}
+	if second.Attributes["path"] != "/srv/web-1.conf" || second.Attributes["content"] != "port=801" {
+		t.Errorf("Expected index substitution, got %v", second.Attributes)
+	}
+	if !reflect.DeepEqual(second.Attributes["tags"], []string{"web", "shard-1"}) {
+		t.Errorf("Expected index substitution in lists, got %v", second.Attributes["tags"])
+	}
+	if _, ok := second.Attributes["count"]; ok {
+		t.Error("Expected count to be removed from instance attributes")
+	}
+	if resources[0].Attributes["path"] != "/srv/web-${count.index}.conf" {
+		t.Error("Expected the original resource to be left unmodified")
+	}
+
+	deps := append([]string(nil), byID["service.web"].DependsOn...)
+	sort.Strings(deps)
+	if !reflect.DeepEqual(deps, []string{"file.web[0]", "file.web[1]", "file.web[2]"}) {
+		t.Errorf("Expected dependency to fan out to every instance, got %v", deps)
+	}
+	if len(byID["service.other"].DependsOn) != 0 {
+		t.Errorf("Expected dependency on a zero-count resource to be dropped, got %v", byID["service.other"].DependsOn)
+	}
+}
+
+func TestExpandResources_InstanceDependencies(t *testing.T) {
+	engine := NewEngine(setupTestRegistry())
+
+	resources := []Resource{
+		{Type: "file", Name: "conf", Attributes: map[string]interface{}{"path": "/etc/app${count.index}.conf", "count": int64(2)}},
+		{
+			Type:       "service",
+			Name:       "app",
+			Attributes: map[string]interface{}{"name": "app${count.index}", "count": int64(2)},
+			DependsOn:  []string{"file.conf[${count.index}]"},
+		},
+	}
+
+	graph, err := engine.buildDependencyGraph(resources)
+	if err != nil {
+		t.Fatalf("buildDependencyGraph returned error: %v", err)
+	}
+	if len(graph) != 4 {
+		t.Fatalf("Expected 4 nodes, got %d", len(graph))
+	}
+
+	for _, id := range []string{"service.app[0]", "service.app[1]"} {
+		node := graph[id]
+		if node == nil {
+			t.Fatalf("Expected node %s", id)
+		}
+		want := "file.conf" + id[len("service.app"):]
+		if len(node.DependsOn) != 1 || node.DependsOn[0] != graph[want] {
+			t.Errorf("Expected %s to depend only on %s", id, want)
+		}
+	}
+}
+
+func TestExpandResources_InvalidCount(t *testing.T) {
+	for _, count := range []interface{}{int64(-1), "many", 1.5} {
+		resources := []Resource{{Type: "file", Name: "web", Attributes: map[string]interface{}{"count": count}}}
+		if _, err := expandResources(resources); err == nil {
+			t.Errorf("Expected error for count %v", count)
+		}
+	}
+}
+
+func TestExpandResources_ForEachList(t *testing.T) {
+	resources := []Resource{
+		{
+			Type:       "file",
+			Name:       "conf",
+			Attributes: map[string]interface{}{"path": "/etc/${each.key}/app.conf", "for_each": []string{"nginx", "redis"}},
+		},
+		{
+			Type:       "service",
+			Name:       "nginx",
+			Attributes: map[string]interface{}{"name": "nginx"},
+			DependsOn:  []string{`file.conf["nginx"]`},
+		},
+	}
+
+	expanded, err := expandResources(resources)
+	if err != nil {
+		t.Fatalf("expandResources returned error: %v", err)
+	}
+
+	var names []string
+	for _, resource := range expanded {
+		names = append(names, resource.Name)
+	}
+	if !reflect.DeepEqual(names, []string{`conf["nginx"]`, `conf["redis"]`, "nginx"}) {
+		t.Fatalf("Unexpected expanded names %v", names)
+	}
+	if expanded[1].Attributes["path"] != "/etc/redis/app.conf" {
+		t.Errorf("Expected ${each.key} substitution, got %v", expanded[1].Attributes["path"])
+	}
+	if _, ok := expanded[0].Attributes["for_each"]; ok {
+		t.Error("Expected for_each to be removed from instance attributes")
+	}
+
+	graph, err := NewEngine(setupTestRegistry()).buildDependencyGraph(resources)
+	if err != nil {
+		t.Fatalf("buildDependencyGraph returned error: %v", err)
+	}
+	service := graph["service.nginx"]
+	if len(service.DependsOn) != 1 || service.DependsOn[0] != graph[`file.conf["nginx"]`] {
+		t.Error("Expected depends_on to target a single for_each instance")
+	}
+
+	// Removing an item leaves the remaining instance ids unchanged
+	resources[0].Attributes["for_each"] = []string{"redis"}
+	expanded, err = expandResources(resources[:1])
+	if err != nil {
+		t.Fatalf("expandResources returned error: %v", err)
+	}
+	if len(expanded) != 1 || expanded[0].Name != `conf["redis"]` {
+		t.Errorf("Expected stable instance id conf[\"redis\"], got %v", expanded)
+	}
+}
+
+func TestExpandResources_ForEachMap(t *testing.T) {
+	resources := []Resource{
+		{
+			Type: "user",
+			Name: "staff",
+			Attributes: map[string]interface{}{
+				"username": "${each.key}",
+				"shell":    "${each.value}",
+				"for_each": map[string]interface{}{"bob": "/bin/zsh", "alice": "/bin/bash"},
+			},
+		},
+	}
+
+	for run := 0; run < 2; run++ {
+		expanded, err := expandResources(resources)
+		if err != nil {
+			t.Fatalf("expandResources returned error: %v", err)
+		}
+		if len(expanded) != 2 {
+			t.Fatalf("Expected 2 instances, got %d", len(expanded))
+		}
+
+		alice, bob := expanded[0], expanded[1]
+		if alice.Name != `staff["alice"]` || bob.Name != `staff["bob"]` {
+			t.Errorf("Expected instances in key order, got %s and %s", alice.Name, bob.Name)
+		}
+		if alice.Attributes["username"] != "alice" || alice.Attributes["shell"] != "/bin/bash" {
+			t.Errorf("Expected ${each.key} and ${each.value} substitution, got %v", alice.Attributes)
+		}
+	}
+}
+
+func TestExpandResources_InvalidForEach(t *testing.T) {
+	tests := []map[string]interface{}{
+		{"for_each": "nginx"},
+		{"for_each": []string{"a", "a"}},
+		{"for_each": []interface{}{"a", []string{"b"}}},
+		{"for_each": []string{"a"}, "count": int64(1)},
+	}
+
+	for _, attributes := range tests {
+		resources := []Resource{{Type: "file", Name: "conf", Attributes: attributes}}
+		if _, err := expandResources(resources); err == nil {
+			t.Errorf("Expected error for attributes %v", attributes)
+		}
+	}
+}
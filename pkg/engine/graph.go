@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// WriteGraph writes the dependency graph of resources in Graphviz DOT format.
+// Each edge points from a resource to a resource it depends on. Resources
+// skipped by their when conditions are drawn dashed and grey.
+func (e *Engine) WriteGraph(w io.Writer, resources []Resource) error {
+	graph, err := e.buildDependencyGraph(resources)
+	if err != nil {
+		return err
+	}
+
+	graph, err = e.pruneToTargets(graph, func(node *ResourceNode) []*ResourceNode { return node.DependsOn })
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(graph))
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "digraph zero {")
+	fmt.Fprintln(out, "  rankdir = \"LR\";")
+	fmt.Fprintln(out, "  node [shape = \"box\"];")
+
+	for _, id := range ids {
+		if e.isPlatformSupported(graph[id].Resource) {
+			fmt.Fprintf(out, "  %s;\n", strconv.Quote(id))
+		} else {
+			fmt.Fprintf(out, "  %s [style = \"dashed\", color = \"grey\", fontcolor = \"grey\"];\n", strconv.Quote(id))
+		}
+	}
+
+	for _, id := range ids {
+		deps := make([]string, 0, len(graph[id].DependsOn))
+		for _, dep := range graph[id].DependsOn {
+			depID := fmt.Sprintf("%s.%s", dep.Resource.Type, dep.Resource.Name)
+			if _, ok := graph[depID]; ok {
+				deps = append(deps, depID)
+			}
+		}
+		sort.Strings(deps)
+
+		for _, depID := range deps {
+			fmt.Fprintf(out, "  %s -> %s;\n", strconv.Quote(id), strconv.Quote(depID))
+		}
+	}
+
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEngine_WriteGraph(t *testing.T) {
+	engine := NewEngine(setupTestRegistry())
+
+	resources := []Resource{
+		{Type: "file", Name: "config", Attributes: map[string]interface{}{"path": "/etc/app.conf"}},
+		{Type: "file", Name: "unit", Attributes: map[string]interface{}{"path": "/etc/app.service"}},
+		{Type: "service", Name: "app", Attributes: map[string]interface{}{"name": "app"}, DependsOn: []string{"file.unit", "file.config"}},
+		{
+			Type:       "file",
+			Name:       "skipped",
+			Attributes: map[string]interface{}{"path": "/tmp/skipped"},
+			Conditions: map[string][]string{"platform": {"invalid-platform"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := engine.WriteGraph(&buf, resources); err != nil {
+		t.Fatalf("WriteGraph returned error: %v", err)
+	}
+	dot := buf.String()
+
+	if !strings.HasPrefix(dot, "digraph zero {\n") || !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("Expected a digraph, got:\n%s", dot)
+	}
+
+	expected := []string{
+		"  \"file.config\";\n",
+		"  \"service.app\";\n",
+		"  \"file.skipped\" [style = \"dashed\", color = \"grey\", fontcolor = \"grey\"];\n",
+		"  \"service.app\" -> \"file.config\";\n  \"service.app\" -> \"file.unit\";\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(dot, line) {
+			t.Errorf("Expected DOT output to contain %q, got:\n%s", line, dot)
+		}
+	}
+	if strings.Count(dot, "->") != 2 {
+		t.Errorf("Expected 2 edges, got:\n%s", dot)
+	}
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// SetInferDependencies enables adding the dependencies implied by resource
+// relationships: a service depends on the package with the same name, and on
+// files under its configuration directory, /etc/<service name>
+func (e *Engine) SetInferDependencies(enabled bool) {
+	e.inferDeps = enabled
+}
+
+// addInferredDependencies adds the dependencies implied by resource
+// relationships to the graph, skipping any that are already declared
+func (e *Engine) addInferredDependencies(graph map[string]*ResourceNode) {
+	ids := make([]string, 0, len(graph))
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// Index packages by the names they install and files by their paths
+	packages := make(map[string][]*ResourceNode)
+	var files []*ResourceNode
+	for _, id := range ids {
+		node := graph[id]
+		switch node.Resource.Type {
+		case "package":
+			for _, name := range packageNames(node.Resource) {
+				packages[name] = append(packages[name], node)
+			}
+		case "file":
+			files = append(files, node)
+		}
+	}
+
+	for _, id := range ids {
+		service := graph[id]
+		if service.Resource.Type != "service" {
+			continue
+		}
+		name := attributeOrName(service.Resource, "name")
+
+		for _, pkg := range packages[name] {
+			addDependency(service, pkg)
+		}
+
+		configDir := "/etc/" + name + "/"
+		for _, file := range files {
+			filePath := attributeOrName(file.Resource, "path")
+			if strings.HasPrefix(path.Clean(filePath), configDir) {
+				addDependency(service, file)
+			}
+		}
+	}
+}
+
+// attributeOrName returns a string attribute of a resource, or the resource
+// name when the attribute isn't set. Dependencies are inferred before
+// validation fills in attributes from the resource name.
+func attributeOrName(resource Resource, key string) string {
+	if value, ok := resource.Attributes[key].(string); ok {
+		return value
+	}
+	return resource.Name
+}
+
+// packageNames returns the packages a package resource installs, from
+// 'names' or from 'name', which defaults to the resource name
+func packageNames(resource Resource) []string {
+	switch names := resource.Attributes["names"].(type) {
+	case []string:
+		return names
+	case []interface{}:
+		result := make([]string, 0, len(names))
+		for _, name := range names {
+			if str, ok := name.(string); ok {
+				result = append(result, str)
+			}
+		}
+		return result
+	}
+	return []string{attributeOrName(resource, "name")}
+}
+
+// addDependency makes node depend on dep unless it already does
+func addDependency(node, dep *ResourceNode) {
+	for _, existing := range node.DependsOn {
+		if existing == dep {
+			return
+		}
+	}
+	node.DependsOn = append(node.DependsOn, dep)
+	dep.DependedOnBy = append(dep.DependedOnBy, node)
+}
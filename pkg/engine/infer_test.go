@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEngine_InferDependencies(t *testing.T) {
+	engine := NewEngine(setupTestRegistry())
+	engine.SetInferDependencies(true)
+
+	resources := []Resource{
+		{Type: "package", Name: "nginx", Attributes: map[string]interface{}{"name": "nginx"}},
+		{Type: "package", Name: "tools", Attributes: map[string]interface{}{"names": []string{"curl", "redis"}}},
+		{Type: "file", Name: "nginx_conf", Attributes: map[string]interface{}{"path": "/etc/nginx/nginx.conf"}},
+		{Type: "file", Name: "other_conf", Attributes: map[string]interface{}{"path": "/etc/nginx-other/app.conf"}},
+		{Type: "service", Name: "nginx", Attributes: map[string]interface{}{"name": "nginx"}, DependsOn: []string{"package.nginx"}},
+		{Type: "service", Name: "redis", Attributes: map[string]interface{}{"name": "redis"}},
+	}
+
+	graph, err := engine.buildDependencyGraph(resources)
+	if err != nil {
+		t.Fatalf("buildDependencyGraph returned error: %v", err)
+	}
+
+	dependencyIDs := func(node *ResourceNode) []string {
+		var ids []string
+		for _, dep := range node.DependsOn {
+			ids = append(ids, dep.Resource.Type+"."+dep.Resource.Name)
+		}
+		return ids
+	}
+
+	// The declared package dependency isn't duplicated
+	if got := dependencyIDs(graph["service.nginx"]); !reflect.DeepEqual(got, []string{"package.nginx", "file.nginx_conf"}) {
+		t.Errorf("Expected nginx service to depend on its package and config, got %v", got)
+	}
+	if got := dependencyIDs(graph["service.redis"]); !reflect.DeepEqual(got, []string{"package.tools"}) {
+		t.Errorf("Expected redis service to depend on the package installing it, got %v", got)
+	}
+
+	// Without the flag nothing is inferred
+	graph, err = NewEngine(setupTestRegistry()).buildDependencyGraph(resources)
+	if err != nil {
+		t.Fatalf("buildDependencyGraph returned error: %v", err)
+	}
+	if got := dependencyIDs(graph["service.redis"]); len(got) != 0 {
+		t.Errorf("Expected no inferred dependencies by default, got %v", got)
+	}
+}
+
+func TestEngine_InferDependencies_FromResourceNames(t *testing.T) {
+	engine := NewEngine(setupTestRegistry())
+	engine.SetInferDependencies(true)
+
+	// package "nginx" {}, file "/etc/nginx/nginx.conf" {} and service "nginx" {}
+	resources := []Resource{
+		{Type: "package", Name: "nginx", Attributes: map[string]interface{}{}},
+		{Type: "file", Name: "/etc/nginx/nginx.conf", Attributes: map[string]interface{}{}},
+		{Type: "service", Name: "nginx", Attributes: map[string]interface{}{}},
+	}
+
+	graph, err := engine.buildDependencyGraph(resources)
+	if err != nil {
+		t.Fatalf("buildDependencyGraph returned error: %v", err)
+	}
+
+	var got []string
+	for _, dep := range graph["service.nginx"].DependsOn {
+		got = append(got, dep.Resource.Type+"."+dep.Resource.Name)
+	}
+	if want := []string{"package.nginx", "file./etc/nginx/nginx.conf"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected nginx service to depend on %v, got %v", want, got)
+	}
+}
+
+func TestEngine_InferDependencies_Cycle(t *testing.T) {
+	engine := NewEngine(setupTestRegistry())
+	engine.SetInferDependencies(true)
+
+	// The config file depends on the service, but inference orders it before
+	resources := []Resource{
+		{Type: "service", Name: "app", Attributes: map[string]interface{}{"name": "app"}},
+		{Type: "file", Name: "app_conf", Attributes: map[string]interface{}{"path": "/etc/app/app.conf"}, DependsOn: []string{"service.app"}},
+	}
+
+	if _, err := engine.Plan(context.Background(), resources); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected a dependency cycle error, got %v", err)
+	}
+}
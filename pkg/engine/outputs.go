@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+// referencePattern matches ${resource.type.name.attr}. The name runs to the
+// last dot, so instance names such as web["a.b"] can be referred to.
+var referencePattern = regexp.MustCompile(`\$\{resource\.([A-Za-z0-9_]+)\.([^}]+)\.([A-Za-z0-9_]+)\}`)
+
+// resourceReferences returns the IDs of the resources whose values a
+// resource's attributes refer to, sorted and without duplicates
+func resourceReferences(resource Resource) []string {
+	seen := make(map[string]bool)
+	substituteValue(resource.Attributes, func(s string) string {
+		for _, match := range referencePattern.FindAllStringSubmatch(s, -1) {
+			seen[match[1]+"."+match[2]] = true
+		}
+		return s
+	})
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// hasReferences reports whether a resource's attributes refer to other resources
+func hasReferences(resource Resource) bool {
+	return len(resourceReferences(resource)) > 0
+}
+
+// referenceValues returns the values of an applied resource that other
+// resources can refer to: its scalar attributes, overridden by any outputs
+// the provider computed on apply
+func referenceValues(state *providers.ResourceState) map[string]string {
+	values := make(map[string]string)
+	for key, value := range state.Attributes {
+		switch value.(type) {
+		case string, bool, int, int64, float64:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	for key, value := range state.Outputs {
+		values[key] = value
+	}
+	return values
+}
+
+// recordOutputs makes the values of an applied resource available to the
+// resources applied after it
+func (e *Engine) recordOutputs(resourceID string, state *providers.ResourceState) {
+	if state == nil || state.Status == "failed" {
+		return
+	}
+
+	e.outputsMu.Lock()
+	defer e.outputsMu.Unlock()
+	e.outputs[resourceID] = referenceValues(state)
+}
+
+// referencedValues returns the values of a referenced resource from this
+// run, falling back to the prior state
+func (e *Engine) referencedValues(resourceID string) (map[string]string, bool) {
+	e.outputsMu.Lock()
+	values, ok := e.outputs[resourceID]
+	e.outputsMu.Unlock()
+	if ok {
+		return values, true
+	}
+
+	if prior, ok := e.state[resourceID]; ok && prior != nil && prior.Status != "failed" {
+		return referenceValues(prior), true
+	}
+	return nil, false
+}
+
+// priorOutputs returns the outputs recorded for a resource by its last
+// successful apply, or nil if there are none
+func (e *Engine) priorOutputs(resourceID string) map[string]string {
+	if prior, ok := e.state[resourceID]; ok && prior != nil && prior.Status != "failed" {
+		return prior.Outputs
+	}
+	return nil
+}
+
+// resolveReferences returns a copy of the attributes with every
+// ${resource.type.name.attr} replaced by the referenced value. References to
+// values that are not known yet are listed in the error.
+func (e *Engine) resolveReferences(attributes map[string]interface{}) (map[string]interface{}, error) {
+	var missing []string
+	resolved := substituteValue(attributes, func(s string) string {
+		return referencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+			match := referencePattern.FindStringSubmatch(ref)
+			values, ok := e.referencedValues(match[1] + "." + match[2])
+			if !ok {
+				missing = append(missing, ref)
+				return ref
+			}
+			value, ok := values[match[3]]
+			if !ok {
+				missing = append(missing, ref)
+				return ref
+			}
+			return value
+		})
+	})
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("unresolved references: %s", strings.Join(missing, ", "))
+	}
+	return resolved.(map[string]interface{}), nil
+}
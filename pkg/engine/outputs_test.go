@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dangerclosesec/zero/pkg/logging"
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+// planDesired plans a change to the desired attributes
+func planDesired(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+	return &providers.ResourceState{Attributes: desired, Status: "planned"}, nil
+}
+
+// setupOutputsRegistry registers a group provider that assigns gid 1042 on
+// create and a user provider that requires a numeric gid
+func setupOutputsRegistry(appliedUsers map[string]map[string]interface{}) *providers.ProviderRegistry {
+	registry := providers.NewProviderRegistry()
+	registry.Register("group", &MockProvider{
+		PlanFunc: planDesired,
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			return &providers.ResourceState{
+				Type:       "group",
+				Name:       state.Name,
+				Attributes: state.Attributes,
+				Status:     "created",
+				Outputs:    map[string]string{"gid": "1042"},
+			}, nil
+		},
+	})
+	registry.Register("user", &MockProvider{
+		ValidateFunc: func(ctx context.Context, attributes map[string]interface{}) error {
+			gid, _ := attributes["gid"].(string)
+			if _, err := strconv.Atoi(gid); err != nil {
+				return fmt.Errorf("user 'gid' must be a number")
+			}
+			return nil
+		},
+		PlanFunc: planDesired,
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			appliedUsers[state.Attributes["name"].(string)] = state.Attributes
+			return &providers.ResourceState{Type: "user", Name: state.Name, Attributes: state.Attributes, Status: "created", Changed: true}, nil
+		},
+	})
+	return registry
+}
+
+func TestEngine_Apply_InterpolatesOutputs(t *testing.T) {
+	applied := make(map[string]map[string]interface{})
+	engine := NewEngine(setupOutputsRegistry(applied))
+	engine.SetLogger(logging.Discard)
+
+	// The reference alone orders the user after the group
+	resources := []Resource{
+		{
+			Type: "user",
+			Name: "deploy",
+			Attributes: map[string]interface{}{
+				"gid":     "${resource.group.staff.gid}",
+				"comment": "member of ${resource.group.staff.name} (${resource.group.staff.gid})",
+			},
+		},
+		{Type: "group", Name: "staff", Attributes: map[string]interface{}{}},
+	}
+
+	results, err := engine.Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if state := results["user.deploy"]; state == nil || state.Status != "created" {
+		t.Fatalf("Expected user.deploy to be created, got %+v", state)
+	}
+
+	want := map[string]interface{}{
+		"name":    "deploy",
+		"gid":     "1042",
+		"comment": "member of staff (1042)",
+	}
+	if !reflect.DeepEqual(applied["deploy"], want) {
+		t.Errorf("Expected user attributes %v, got %v", want, applied["deploy"])
+	}
+}
+
+func TestEngine_Plan_DefersUnknownOutputs(t *testing.T) {
+	engine := NewEngine(setupOutputsRegistry(map[string]map[string]interface{}{}))
+	resources := []Resource{
+		{Type: "group", Name: "staff", Attributes: map[string]interface{}{}},
+		{Type: "user", Name: "deploy", Attributes: map[string]interface{}{"gid": "${resource.group.staff.gid}"}},
+	}
+
+	plan, err := engine.Plan(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	action := plan["user.deploy"]
+	if action.Action != "create" || !strings.Contains(action.Details, "known after group.staff is applied") {
+		t.Errorf("Expected a deferred create, got %+v", action)
+	}
+
+	// Once the group is in the prior state its gid is known when planning
+	engine.SetState(map[string]*providers.ResourceState{
+		"group.staff": {Type: "group", Name: "staff", Status: "created", Outputs: map[string]string{"gid": "1042"}},
+	})
+	plan, err = engine.Plan(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if action := plan["user.deploy"]; action.Action != "create" || strings.Contains(action.Details, "known after") {
+		t.Errorf("Expected the user to be planned with the known gid, got %+v", action)
+	}
+}
+
+func TestEngine_Apply_ReferenceErrors(t *testing.T) {
+	engine := NewEngine(setupOutputsRegistry(map[string]map[string]interface{}{}))
+	engine.SetLogger(logging.Discard)
+
+	// A reference to a resource that isn't configured
+	_, err := engine.Apply(context.Background(), []Resource{
+		{Type: "user", Name: "deploy", Attributes: map[string]interface{}{"gid": "${resource.group.staff.gid}"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "refers to non-existent resource group.staff") {
+		t.Errorf("Expected a missing reference error, got %v", err)
+	}
+
+	// A reference to a value the resource doesn't have fails the resource
+	results, err := engine.Apply(context.Background(), []Resource{
+		{Type: "group", Name: "staff", Attributes: map[string]interface{}{}},
+		{Type: "user", Name: "deploy", Attributes: map[string]interface{}{"gid": "${resource.group.staff.uid}"}},
+	})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	state := results["user.deploy"]
+	if state == nil || state.Status != "failed" || !strings.Contains(state.Error.Error(), "unresolved references: ${resource.group.staff.uid}") {
+		t.Errorf("Expected user.deploy to fail with an unresolved reference, got %+v", state)
+	}
+}
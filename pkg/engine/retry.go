@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+// defaultRetryDelay is the wait before the first retry when retry_delay is unset
+const defaultRetryDelay = time.Second
+
+// retryPolicy controls how often a failed Apply is retried. The delay before
+// each retry is the previous delay multiplied by backoff.
+type retryPolicy struct {
+	retries int
+	delay   time.Duration
+	backoff float64
+}
+
+// parseRetryPolicy reads the retries, retry_delay and retry_backoff attributes.
+// Without a retries attribute a failed Apply is not retried.
+func parseRetryPolicy(attributes map[string]interface{}) (retryPolicy, error) {
+	policy := retryPolicy{delay: defaultRetryDelay, backoff: 1}
+
+	if value, ok := attributes["retries"]; ok {
+		retries, err := wholeNumber(value)
+		if err != nil || retries < 0 {
+			return policy, fmt.Errorf("'retries' must be a non-negative whole number")
+		}
+		policy.retries = int(retries)
+	}
+
+	if value, ok := attributes["retry_delay"]; ok {
+		switch v := value.(type) {
+		case string:
+			delay, err := time.ParseDuration(v)
+			if err != nil || delay < 0 {
+				return policy, fmt.Errorf("'retry_delay' must be a duration such as \"5s\"")
+			}
+			policy.delay = delay
+		default:
+			seconds, err := wholeNumber(v)
+			if err != nil || seconds < 0 {
+				return policy, fmt.Errorf("'retry_delay' must be a duration such as \"5s\"")
+			}
+			policy.delay = time.Duration(seconds) * time.Second
+		}
+	}
+
+	if value, ok := attributes["retry_backoff"]; ok {
+		switch v := value.(type) {
+		case int64:
+			policy.backoff = float64(v)
+		case float64:
+			policy.backoff = v
+		default:
+			return policy, fmt.Errorf("'retry_backoff' must be a number")
+		}
+		if policy.backoff < 1 {
+			return policy, fmt.Errorf("'retry_backoff' must be at least 1")
+		}
+	}
+
+	return policy, nil
+}
+
+// wholeNumber converts an integer attribute value to an int64
+func wholeNumber(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v is not a whole number", v)
+		}
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("%v is not a number", value)
+	}
+}
+
+// delayBefore returns how long to wait before the given retry, counting from 1
+func (p retryPolicy) delayBefore(retry int) time.Duration {
+	return time.Duration(float64(p.delay) * math.Pow(p.backoff, float64(retry-1)))
+}
+
+// applyWithRetry applies a planned resource, retrying failures according to
+// the policy. Each attempt is bounded by timeout, if set. The error of the last
+// attempt is returned.
+func (e *Engine) applyWithRetry(ctx context.Context, resourceID string, provider providers.ResourceProvider, planned *providers.ResourceState, policy retryPolicy, timeout time.Duration) (*providers.ResourceState, error) {
+	apply := func(ctx context.Context) (*providers.ResourceState, error) {
+		return provider.Apply(ctx, planned)
+	}
+
+	state, err := callWithTimeout(ctx, timeout, apply)
+
+	for retry := 1; err != nil && retry <= policy.retries; retry++ {
+		delay := policy.delayBefore(retry)
+		e.logger.Warnf("Retrying %s in %v (attempt %d of %d): %v", resourceID, delay, retry+1, policy.retries+1, err)
+
+		select {
+		case <-ctx.Done():
+			return state, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		state, err = callWithTimeout(ctx, timeout, apply)
+	}
+
+	return state, err
+}
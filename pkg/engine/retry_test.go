@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+func TestParseRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		want    retryPolicy
+		wantErr bool
+	}{
+		{"defaults", map[string]interface{}{}, retryPolicy{0, time.Second, 1}, false},
+		{"full", map[string]interface{}{"retries": int64(3), "retry_delay": "500ms", "retry_backoff": 2.0}, retryPolicy{3, 500 * time.Millisecond, 2}, false},
+		{"delay in seconds", map[string]interface{}{"retries": int64(1), "retry_delay": int64(5)}, retryPolicy{1, 5 * time.Second, 1}, false},
+		{"integer backoff", map[string]interface{}{"retries": int64(1), "retry_backoff": int64(3)}, retryPolicy{1, time.Second, 3}, false},
+		{"negative retries", map[string]interface{}{"retries": int64(-1)}, retryPolicy{}, true},
+		{"fractional retries", map[string]interface{}{"retries": 1.5}, retryPolicy{}, true},
+		{"invalid delay", map[string]interface{}{"retry_delay": "soon"}, retryPolicy{}, true},
+		{"shrinking backoff", map[string]interface{}{"retry_backoff": 0.5}, retryPolicy{}, true},
+		{"invalid backoff", map[string]interface{}{"retry_backoff": "2"}, retryPolicy{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRetryPolicy(tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+
+	policy := retryPolicy{retries: 3, delay: 100 * time.Millisecond, backoff: 2}
+	for retry, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		if got := policy.delayBefore(retry + 1); got != want {
+			t.Errorf("Expected delay %v before retry %d, got %v", want, retry+1, got)
+		}
+	}
+}
+
+// flakyRegistry returns a registry whose file provider fails until it has
+// been applied succeedOn times, and a pointer to the attempt count
+func flakyRegistry(succeedOn int) (*providers.ProviderRegistry, *int) {
+	attempts := 0
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Type: "file", Name: desired["path"].(string), Attributes: desired, Status: "planned"}, nil
+		},
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			attempts++
+			if attempts < succeedOn {
+				return nil, fmt.Errorf("transient failure %d", attempts)
+			}
+			return &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "created", Changed: true}, nil
+		},
+	})
+	return registry, &attempts
+}
+
+func TestEngine_Apply_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		attrs        map[string]interface{}
+		succeedOn    int
+		wantStatus   string
+		wantAttempts int
+	}{
+		{"succeeds after retries", map[string]interface{}{"path": "a", "retries": int64(3), "retry_delay": "1ms", "retry_backoff": 2.0}, 3, "created", 3},
+		{"retries exhausted", map[string]interface{}{"path": "a", "retries": int64(2), "retry_delay": "1ms"}, 5, "failed", 3},
+		{"retries off by default", map[string]interface{}{"path": "a"}, 2, "failed", 1},
+	}
+
+	for _, tt := range tests {
+		registry, attempts := flakyRegistry(tt.succeedOn)
+		resources := []Resource{{Type: "file", Name: "a", Attributes: tt.attrs}}
+
+		results, err := NewEngine(registry).Apply(context.Background(), resources)
+		if err != nil {
+			t.Fatalf("%s: Apply returned error: %v", tt.name, err)
+		}
+		if results["file.a"].Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, results["file.a"].Status)
+		}
+		if *attempts != tt.wantAttempts {
+			t.Errorf("%s: expected %d attempts, got %d", tt.name, tt.wantAttempts, *attempts)
+		}
+	}
+}
+
+func TestEngine_Apply_RetryCanceled(t *testing.T) {
+	registry, attempts := flakyRegistry(5)
+	resources := []Resource{{Type: "file", Name: "a", Attributes: map[string]interface{}{"path": "a", "retries": int64(3), "retry_delay": "1h"}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	results, err := NewEngine(registry).Apply(ctx, resources)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if results["file.a"].Status != "failed" || *attempts != 1 {
+		t.Errorf("Expected cancellation to stop retries after 1 attempt, got %s after %d", results["file.a"].Status, *attempts)
+	}
+}
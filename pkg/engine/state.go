@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+// stateVersion is the format version written to state files
+const stateVersion = 1
+
+// StateStore persists resource states between runs as a JSON file
+type StateStore struct {
+	path string
+}
+
+// stateFile is the on-disk layout of a state file
+type stateFile struct {
+	Version   int                    `json:"version"`
+	Resources map[string]*stateEntry `json:"resources"`
+}
+
+// stateEntry is the serialized form of a providers.ResourceState
+type stateEntry struct {
+	Type       string                 `json:"type"`
+	Name       string                 `json:"name"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Status     string                 `json:"status"`
+	Outputs    map[string]string      `json:"outputs,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// NewStateStore creates a state store backed by the file at path
+func NewStateStore(path string) *StateStore {
+	return &StateStore{path: path}
+}
+
+// Path returns the location of the state file
+func (s *StateStore) Path() string {
+	return s.path
+}
+
+// Load reads the saved resource states keyed by resource ID. A missing state
+// file yields an empty state.
+func (s *StateStore) Load() (map[string]*providers.ResourceState, error) {
+	states := make(map[string]*providers.ResourceState)
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return states, nil
+		}
+		return nil, fmt.Errorf("error reading state file %s: %v", s.path, err)
+	}
+
+	var file stateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing state file %s: %v", s.path, err)
+	}
+	if file.Version > stateVersion {
+		return nil, fmt.Errorf("state file %s has unsupported version %d", s.path, file.Version)
+	}
+
+	for id, entry := range file.Resources {
+		if entry == nil {
+			continue
+		}
+		state := &providers.ResourceState{
+			Type:       entry.Type,
+			Name:       entry.Name,
+			Attributes: normalizeAttributes(entry.Attributes),
+			Status:     entry.Status,
+			Outputs:    entry.Outputs,
+		}
+		if entry.Error != "" {
+			state.Error = errors.New(entry.Error)
+		}
+		states[id] = state
+	}
+
+	return states, nil
+}
+
+// Save writes the resource states to the state file, replacing it atomically
+func (s *StateStore) Save(states map[string]*providers.ResourceState) error {
+	file := stateFile{
+		Version:   stateVersion,
+		Resources: make(map[string]*stateEntry, len(states)),
+	}
+
+	for id, state := range states {
+		if state == nil {
+			continue
+		}
+		entry := &stateEntry{
+			Type:       state.Type,
+			Name:       state.Name,
+			Attributes: state.Attributes,
+			Status:     state.Status,
+			Outputs:    state.Outputs,
+		}
+		if state.Error != nil {
+			entry.Error = state.Error.Error()
+		}
+		file.Resources[id] = entry
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding state: %v", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(s.path)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("error writing state file %s: %v", s.path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing state file %s: %v", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing state file %s: %v", s.path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("error writing state file %s: %v", s.path, err)
+	}
+
+	return nil
+}
+
+// MergeState combines the prior state with the results of an apply. Failed
+// resources keep their prior entry since their real state is unknown.
+func MergeState(prior, results map[string]*providers.ResourceState) map[string]*providers.ResourceState {
+	merged := make(map[string]*providers.ResourceState, len(prior)+len(results))
+	for id, state := range prior {
+		merged[id] = state
+	}
+
+	for id, state := range results {
+		if state == nil || state.Status == "failed" {
+			continue
+		}
+		merged[id] = state
+	}
+
+	return merged
+}
+
+// normalizeAttributes converts decoded JSON values back to the types the parser
+// produces, so attributes round-trip through the state file unchanged
+func normalizeAttributes(attributes map[string]interface{}) map[string]interface{} {
+	if attributes == nil {
+		return map[string]interface{}{}
+	}
+
+	result := make(map[string]interface{}, len(attributes))
+	for key, value := range attributes {
+		result[key] = normalizeValue(value)
+	}
+	return result
+}
+
+// normalizeValue maps whole float64s to int64 and all-string arrays to []string
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+		return v
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		allStrings := true
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = normalizeValue(item)
+			if str, ok := item.(string); ok {
+				strs = append(strs, str)
+			} else {
+				allStrings = false
+			}
+		}
+		if allStrings {
+			return strs
+		}
+		return items
+	case map[string]interface{}:
+		return normalizeAttributes(v)
+	default:
+		return v
+	}
+}
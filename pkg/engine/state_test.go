@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+func TestStateStore_RoundTrip(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "engine-state-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store := NewStateStore(filepath.Join(tempDir, "zero.state.json"))
+
+	// A missing state file is an empty state
+	states, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load of missing state file returned error: %v", err)
+	}
+	if len(states) != 0 {
+		t.Errorf("Expected empty state, got %d entries", len(states))
+	}
+
+	saved := map[string]*providers.ResourceState{
+		"file.config": {
+			Type: "file",
+			Name: "/etc/app.conf",
+			Attributes: map[string]interface{}{
+				"path":    "/etc/app.conf",
+				"mode":    "0644",
+				"size":    int64(42),
+				"ratio":   1.5,
+				"enabled": true,
+				"tags":    []string{"a", "b"},
+				"mixed":   []interface{}{"a", int64(1)},
+				"env":     map[string]interface{}{"PORT": int64(8080)},
+			},
+			Status: "created",
+		},
+		"service.app": {
+			Type:       "service",
+			Name:       "app",
+			Attributes: map[string]interface{}{"name": "app"},
+			Status:     "failed",
+			Error:      errors.New("boom"),
+		},
+	}
+
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(loaded["file.config"].Attributes, saved["file.config"].Attributes) {
+		t.Errorf("Expected attributes %#v, got %#v", saved["file.config"].Attributes, loaded["file.config"].Attributes)
+	}
+	if loaded["file.config"].Status != "created" || loaded["file.config"].Type != "file" {
+		t.Errorf("Unexpected loaded state: %+v", loaded["file.config"])
+	}
+	if loaded["service.app"].Error == nil || loaded["service.app"].Error.Error() != "boom" {
+		t.Errorf("Expected error to round-trip, got %v", loaded["service.app"].Error)
+	}
+}
+
+func TestStateStore_LoadInvalid(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "engine-state-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "zero.state.json")
+	ioutil.WriteFile(path, []byte("{not json"), 0644)
+
+	if _, err := NewStateStore(path).Load(); err == nil {
+		t.Error("Expected error loading a corrupt state file, got nil")
+	}
+}
+
+func TestMergeState(t *testing.T) {
+	prior := map[string]*providers.ResourceState{
+		"file.a": {Type: "file", Name: "a", Status: "created"},
+		"file.b": {Type: "file", Name: "b", Status: "created"},
+	}
+	results := map[string]*providers.ResourceState{
+		"file.a": {Type: "file", Name: "a", Status: "failed", Error: errors.New("boom")},
+		"file.c": {Type: "file", Name: "c", Status: "created"},
+		"file.d": {Type: "file", Name: "d", Status: "failed"},
+	}
+
+	merged := MergeState(prior, results)
+
+	if merged["file.a"] != prior["file.a"] {
+		t.Error("Expected failed resource to keep its prior state")
+	}
+	if merged["file.b"] != prior["file.b"] {
+		t.Error("Expected untouched resource to keep its prior state")
+	}
+	if merged["file.c"] != results["file.c"] {
+		t.Error("Expected applied resource to be recorded")
+	}
+	if _, ok := merged["file.d"]; ok {
+		t.Error("Expected failed resource without prior state to be omitted")
+	}
+}
+
+func TestEngine_PlanUsesSavedState(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "engine-state-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The mock reports a change whenever current differs from desired
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			status := "planned"
+			if reflect.DeepEqual(current, desired) {
+				status = "unchanged"
+			}
+			return &providers.ResourceState{Type: "file", Name: desired["path"].(string), Attributes: desired, Status: status}, nil
+		},
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "created", Changed: true}, nil
+		},
+	})
+
+	newResources := func() []Resource {
+		return []Resource{
+			{Type: "file", Name: "a", Attributes: map[string]interface{}{"path": "/tmp/a", "mode": "0644", "size": int64(1)}},
+			{Type: "file", Name: "b", Attributes: map[string]interface{}{"path": "/tmp/b", "tags": []string{"x"}}},
+		}
+	}
+
+	store := NewStateStore(filepath.Join(tempDir, "zero.state.json"))
+
+	engine := NewEngine(registry)
+	prior, _ := store.Load()
+	engine.SetState(prior)
+	results, err := engine.Apply(context.Background(), newResources())
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if err := store.Save(MergeState(prior, results)); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	engine = NewEngine(registry)
+	prior, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	engine.SetState(prior)
+
+	plan, err := engine.Plan(context.Background(), newResources())
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	for id, action := range plan {
+		if action.Action != "no-op" {
+			t.Errorf("Expected no-op for %s on unchanged config, got %s", id, action.Action)
+		}
+	}
+}
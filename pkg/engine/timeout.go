@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+// parseTimeout reads the timeout attribute, given as a duration string such as
+// "5m" or a whole number of seconds. Zero means no timeout.
+func parseTimeout(attributes map[string]interface{}) (time.Duration, error) {
+	value, ok := attributes["timeout"]
+	if !ok {
+		return 0, nil
+	}
+
+	if str, ok := value.(string); ok {
+		timeout, err := time.ParseDuration(str)
+		if err != nil || timeout < 0 {
+			return 0, fmt.Errorf("'timeout' must be a duration such as \"5m\"")
+		}
+		return timeout, nil
+	}
+
+	seconds, err := wholeNumber(value)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("'timeout' must be a duration such as \"5m\"")
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// callWithTimeout calls a provider method with a context that expires after
+// timeout, if one is set. An error caused by the timeout expiring is reported
+// as a deadline-exceeded error.
+func callWithTimeout(ctx context.Context, timeout time.Duration, call func(ctx context.Context) (*providers.ResourceState, error)) (*providers.ResourceState, error) {
+	if timeout <= 0 {
+		return call(ctx)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	state, err := call(timeoutCtx)
+	if err != nil && ctx.Err() == nil && timeoutCtx.Err() == context.DeadlineExceeded {
+		return state, fmt.Errorf("timed out after %v: %w", timeout, context.DeadlineExceeded)
+	}
+
+	return state, err
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dangerclosesec/zero/pkg/providers"
+)
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		want    time.Duration
+		wantErr bool
+	}{
+		{"unset", map[string]interface{}{}, 0, false},
+		{"duration", map[string]interface{}{"timeout": "5m"}, 5 * time.Minute, false},
+		{"seconds", map[string]interface{}{"timeout": int64(30)}, 30 * time.Second, false},
+		{"invalid", map[string]interface{}{"timeout": "forever"}, 0, true},
+		{"negative", map[string]interface{}{"timeout": "-1s"}, 0, true},
+		{"wrong type", map[string]interface{}{"timeout": true}, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimeout(tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestEngine_Apply_Timeout(t *testing.T) {
+	// The provider takes far longer than the timeout unless its context is canceled
+	registry := providers.NewProviderRegistry()
+	registry.Register("file", &MockProvider{
+		PlanFunc: func(ctx context.Context, current, desired map[string]interface{}) (*providers.ResourceState, error) {
+			return &providers.ResourceState{Type: "file", Name: desired["path"].(string), Attributes: desired, Status: "planned"}, nil
+		},
+		ApplyFunc: func(ctx context.Context, state *providers.ResourceState) (*providers.ResourceState, error) {
+			if state.Name == "fast" {
+				return &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "created", Changed: true}, nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(10 * time.Second):
+				return &providers.ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "created", Changed: true}, nil
+			}
+		},
+	})
+
+	resources := []Resource{
+		{Type: "file", Name: "slow", Attributes: map[string]interface{}{"path": "slow", "timeout": "20ms"}},
+		{Type: "file", Name: "fast", Attributes: map[string]interface{}{"path": "fast", "timeout": "20ms"}},
+	}
+
+	start := time.Now()
+	results, err := NewEngine(registry).Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected timeout to stop the slow resource, took %v", elapsed)
+	}
+
+	slow := results["file.slow"]
+	if slow.Status != "failed" || !errors.Is(slow.Error, context.DeadlineExceeded) {
+		t.Errorf("Expected slow resource to fail with deadline exceeded, got %s (%v)", slow.Status, slow.Error)
+	}
+	if results["file.fast"].Status != "created" {
+		t.Errorf("Expected fast resource to be created, got %s", results["file.fast"].Status)
+	}
+}
+
+func TestEngine_Validate_InvalidTimeout(t *testing.T) {
+	resources := []Resource{{Type: "file", Name: "a", Attributes: map[string]interface{}{"path": "a", "timeout": "soon"}}}
+	if _, err := NewEngine(setupTestRegistry()).Apply(context.Background(), resources); err == nil {
+		t.Error("Expected validation error for an invalid timeout, got nil")
+	}
+}
@@ -0,0 +1,170 @@
+// Package logging provides the leveled logger that the engine, parser and
+// providers report progress and warnings through
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Level is the severity of a log message
+type Level int
+
+const (
+	LevelDebug Level = iota // Detail only shown with --verbose
+	LevelInfo               // Progress, such as the resource being applied
+	LevelWarn               // Problems that don't stop the run
+	LevelError              // Failures
+)
+
+// String returns the name of the level
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// Logger receives messages at each level. Implementations must be safe for
+// concurrent use, since resources are applied in parallel.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// writerLogger writes messages at or above a level to a writer, one per line
+type writerLogger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level Level
+}
+
+// New returns a logger that writes messages at or above level to out.
+// Warnings are prefixed with "Warning: "; other messages are written as is.
+func New(out io.Writer, level Level) Logger {
+	return &writerLogger{out: out, level: level}
+}
+
+func (l *writerLogger) log(level Level, format string, args ...interface{}) {
+	if level < l.level {
+		return
+	}
+
+	msg := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+	if level == LevelWarn {
+		msg = "Warning: " + msg
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.out, msg)
+}
+
+func (l *writerLogger) Debugf(format string, args ...interface{}) {
+	l.log(LevelDebug, format, args...)
+}
+
+func (l *writerLogger) Infof(format string, args ...interface{}) {
+	l.log(LevelInfo, format, args...)
+}
+
+func (l *writerLogger) Warnf(format string, args ...interface{}) {
+	l.log(LevelWarn, format, args...)
+}
+
+func (l *writerLogger) Errorf(format string, args ...interface{}) {
+	l.log(LevelError, format, args...)
+}
+
+// discardLogger drops every message
+type discardLogger struct{}
+
+func (discardLogger) Debugf(format string, args ...interface{}) {}
+func (discardLogger) Infof(format string, args ...interface{})  {}
+func (discardLogger) Warnf(format string, args ...interface{})  {}
+func (discardLogger) Errorf(format string, args ...interface{}) {}
+
+// Discard is a logger that drops every message
+var Discard Logger = discardLogger{}
+
+// Entry is a message kept by a Recorder
+type Entry struct {
+	Level   Level
+	Message string
+}
+
+// Recorder is a logger that keeps every message in memory, for tests that
+// check what was logged
+type Recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func (r *Recorder) record(level Level, format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, Entry{Level: level, Message: strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")})
+}
+
+func (r *Recorder) Debugf(format string, args ...interface{}) {
+	r.record(LevelDebug, format, args...)
+}
+
+func (r *Recorder) Infof(format string, args ...interface{}) {
+	r.record(LevelInfo, format, args...)
+}
+
+func (r *Recorder) Warnf(format string, args ...interface{}) {
+	r.record(LevelWarn, format, args...)
+}
+
+func (r *Recorder) Errorf(format string, args ...interface{}) {
+	r.record(LevelError, format, args...)
+}
+
+// Entries returns the recorded messages in the order they were logged
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Messages returns the recorded messages at a level
+func (r *Recorder) Messages(level Level) []string {
+	var messages []string
+	for _, entry := range r.Entries() {
+		if entry.Level == level {
+			messages = append(messages, entry.Message)
+		}
+	}
+	return messages
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying logger, so that code called with
+// it, such as providers, logs through the caller's logger
+func NewContext(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or Discard if there is none
+func FromContext(ctx context.Context) Logger {
+	if logger, ok := ctx.Value(contextKey{}).(Logger); ok && logger != nil {
+		return logger
+	}
+	return Discard
+}
@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestNew_FiltersByLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, LevelInfo)
+
+	logger.Debugf("hidden %d", 1)
+	logger.Infof("Applying %s", "file.a")
+	logger.Warnf("no files matched include pattern %s\n", "*.cfg")
+	logger.Errorf("Error applying %s: %v", "file.a", "denied")
+
+	expected := "Applying file.a\nWarning: no files matched include pattern *.cfg\nError applying file.a: denied\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	out.Reset()
+	quiet := New(&out, LevelError)
+	quiet.Infof("Applying file.a")
+	quiet.Warnf("retrying")
+	quiet.Errorf("failed")
+	if out.String() != "failed\n" {
+		t.Errorf("Expected only errors, got %q", out.String())
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != Discard {
+		t.Error("Expected Discard without a logger in the context")
+	}
+
+	recorder := &Recorder{}
+	FromContext(NewContext(context.Background(), recorder)).Warnf("hold ignored")
+	if got := recorder.Messages(LevelWarn); len(got) != 1 || got[0] != "hold ignored" {
+		t.Errorf("Expected the warning to reach the context logger, got %v", got)
+	}
+}
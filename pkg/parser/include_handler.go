@@ -3,9 +3,14 @@ package parser
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/dangerclosesec/zero/pkg/logging"
 )
 
 // IncludeHandler manages file inclusions and platform-specific includes
@@ -14,6 +19,31 @@ type IncludeHandler struct {
 	ProcessedFiles map[string]bool
 	Variables      map[string]string
 	Templates      map[string]string
+
+	// Strict makes Interpolate fail as soon as it meets a ${name} reference
+	// to an undefined variable instead of leaving it in place
+	Strict bool
+
+	// Logger receives warnings, such as include patterns that match no
+	// files, and at debug level the files skipped because they were already
+	// included. NewIncludeHandler sets it to log to stderr.
+	Logger logging.Logger
+
+	// includeStack holds the absolute paths of the files being processed,
+	// outermost first, to detect include cycles
+	includeStack []string
+
+	// unresolved records ${name} references to variables that were not
+	// defined at the point they were used
+	unresolved map[string]bool
+
+	// defined records every variable name ever defined, including those
+	// discarded by an isolated scope
+	defined map[string]bool
+
+	// typed holds the number or boolean value of variables defined with
+	// one. Variables also holds their string form for interpolation.
+	typed map[string]interface{}
 }
 
 // NewIncludeHandler creates a new include handler
@@ -23,12 +53,45 @@ func NewIncludeHandler(basePath string) *IncludeHandler {
 		ProcessedFiles: make(map[string]bool),
 		Variables:      make(map[string]string),
 		Templates:      make(map[string]string),
+		unresolved:     make(map[string]bool),
+		defined:        make(map[string]bool),
+		typed:          make(map[string]interface{}),
+		Logger:         logging.New(os.Stderr, logging.LevelInfo),
 	}
 }
 
 // SetVariable sets a variable value
 func (h *IncludeHandler) SetVariable(name, value string) {
 	h.Variables[name] = value
+	h.defined[name] = true
+	delete(h.typed, name)
+}
+
+// SetTypedVariable sets a variable to a number or boolean. An attribute whose
+// whole value is a reference to the variable gets the typed value, while
+// references within a string get its string form.
+func (h *IncludeHandler) SetTypedVariable(name string, value interface{}) {
+	h.Variables[name] = fmt.Sprint(value)
+	h.defined[name] = true
+	h.typed[name] = value
+}
+
+// attributeValue returns the value of a string attribute after variable
+// substitution. A value that is exactly $name or ${name} for a typed
+// variable becomes the variable's number or boolean.
+func (h *IncludeHandler) attributeValue(value string) (interface{}, error) {
+	name := ""
+	switch {
+	case strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}"):
+		name = value[2 : len(value)-1]
+	case strings.HasPrefix(value, "$"):
+		name = value[1:]
+	}
+	if typed, ok := h.typed[name]; ok && name != "" {
+		return typed, nil
+	}
+
+	return h.ReplaceVariables(value), nil
 }
 
 // GetVariable gets a variable value
@@ -48,17 +111,143 @@ func (h *IncludeHandler) GetTemplate(name string) (string, bool) {
 	return content, exists
 }
 
-// ReplaceVariables replaces variables in a string with their values
+// ReplaceVariables replaces variables in a string with their values.
+// Unknown variables are left untouched.
 func (h *IncludeHandler) ReplaceVariables(content string) string {
-	// Replace all occurrences of $variable with the variable value
-	for name, value := range h.Variables {
-		content = strings.ReplaceAll(content, "$"+name, value)
+	result, _ := h.interpolate(content, false)
+	return result
+}
+
+// Interpolate replaces $name and ${name} references in a string with their
+// values. In strict mode an undefined ${name} reference is an error.
+func (h *IncludeHandler) Interpolate(content string) (string, error) {
+	return h.interpolate(content, h.Strict)
+}
+
+// interpolate performs variable substitution. The bare $name form only matches
+// a complete identifier, so $foo never matches the prefix of $foobar; the
+// ${name} form allows a variable to be followed directly by text.
+func (h *IncludeHandler) interpolate(content string, strict bool) (string, error) {
+	if !strings.Contains(content, "$") {
+		return content, nil
 	}
-	return content
+
+	var sb strings.Builder
+	for i := 0; i < len(content); i++ {
+		if content[i] != '$' || i+1 >= len(content) {
+			sb.WriteByte(content[i])
+			continue
+		}
+
+		if content[i+1] == '{' {
+			end := strings.IndexByte(content[i+2:], '}')
+			if end < 0 {
+				sb.WriteByte(content[i])
+				continue
+			}
+
+			name := content[i+2 : i+2+end]
+			if value, exists := h.Variables[name]; exists {
+				sb.WriteString(value)
+			} else if strings.Contains(name, ".") {
+				// Dotted names such as ${count.index} and
+				// ${resource.group.staff.gid} are resolved by later stages
+				sb.WriteString(content[i : i+3+end])
+			} else if strict {
+				return "", fmt.Errorf("undefined variable %s", name)
+			} else {
+				h.unresolved[name] = true
+				sb.WriteString(content[i : i+3+end])
+			}
+			i += 2 + end
+			continue
+		}
+
+		end := i + 1
+		for end < len(content) && isIdentifierChar(content[end]) {
+			end++
+		}
+
+		name := content[i+1 : end]
+		if value, exists := h.Variables[name]; exists && name != "" {
+			sb.WriteString(value)
+			i = end - 1
+			continue
+		}
+
+		sb.WriteByte(content[i])
+	}
+
+	return sb.String(), nil
+}
+
+// isIdentifierChar reports whether a character may appear in a variable name
+func isIdentifierChar(ch byte) bool {
+	return isLetter(ch) || isDigit(ch) || ch == '_'
 }
 
-// ProcessIncludes processes include statements in a configuration file
+// ProcessIncludes processes include statements in a configuration file.
+// After all includes are processed, any ${name} references to variables that
+// were never defined are reported together in a single error.
 func (h *IncludeHandler) ProcessIncludes(configFile string) ([]Resource, error) {
+	return h.processFiles([]string{configFile})
+}
+
+// ProcessDir processes every .cfg file directly inside dir in name order, as
+// if a single file included them all, so they share variables and templates
+// and their resources form one configuration. A resource defined in more
+// than one place is an error.
+func (h *IncludeHandler) ProcessDir(dir string) ([]Resource, error) {
+	files, err := includeDirFiles(dir, defaultIncludeExtension)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s files found in %s", defaultIncludeExtension, dir)
+	}
+
+	resources, err := h.processFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.checkDuplicates(resources); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// processFiles processes configuration files in order with their includes.
+// Any ${name} references to variables that were never defined anywhere are
+// then reported together, so a variable may be used before the file defining
+// it is processed. Bare $name references are left alone, as they are common
+// in shell scripts and unit files.
+func (h *IncludeHandler) processFiles(files []string) ([]Resource, error) {
+	resources := []Resource{}
+	for _, file := range files {
+		fileResources, err := h.processFile(file)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, fileResources...)
+	}
+
+	var names []string
+	for name := range h.unresolved {
+		if !h.defined[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return nil, fmt.Errorf("undefined variables referenced: %s", strings.Join(names, ", "))
+	}
+
+	return resources, nil
+}
+
+// processFile parses a configuration file and recursively processes its includes
+func (h *IncludeHandler) processFile(configFile string) ([]Resource, error) {
 	allResources := []Resource{}
 
 	// Check if we've already processed this file to avoid cycles
@@ -67,13 +256,29 @@ func (h *IncludeHandler) ProcessIncludes(configFile string) ([]Resource, error)
 		return nil, fmt.Errorf("error resolving absolute path for %s: %v", configFile, err)
 	}
 
+	// A file that includes itself, directly or through other files, is a cycle
+	for i, active := range h.includeStack {
+		if active == absPath {
+			chain := make([]string, 0, len(h.includeStack)-i+1)
+			for _, file := range h.includeStack[i:] {
+				chain = append(chain, h.displayPath(file))
+			}
+			chain = append(chain, h.displayPath(absPath))
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+
 	if h.ProcessedFiles[absPath] {
-		// Already processed, skip
+		// Already included through another file, so its resources and
+		// variables are already loaded
+		h.Logger.Debugf("Skipping %s: already included", h.displayPath(absPath))
 		return allResources, nil
 	}
 
 	// Mark as processed
 	h.ProcessedFiles[absPath] = true
+	h.includeStack = append(h.includeStack, absPath)
+	defer func() { h.includeStack = h.includeStack[:len(h.includeStack)-1] }()
 
 	// Read the file
 	data, err := ioutil.ReadFile(configFile)
@@ -86,7 +291,7 @@ func (h *IncludeHandler) ProcessIncludes(configFile string) ([]Resource, error)
 	fileResources, err := parser.Parse()
 	if err != nil {
 		for _, parseErr := range parser.Errors() {
-			fmt.Printf("Parse error in %s: %s\n", configFile, parseErr)
+			h.Logger.Errorf("Parse error in %s: %s", configFile, parseErr)
 		}
 		return nil, fmt.Errorf("error parsing config file %s: %v", configFile, err)
 	}
@@ -103,17 +308,35 @@ func (h *IncludeHandler) ProcessIncludes(configFile string) ([]Resource, error)
 				if err != nil {
 					return nil, fmt.Errorf("error resolving include pattern %s: %v", pattern, err)
 				}
+				matches, err = expandIncludeDirs(matches, resource)
+				if err != nil {
+					return nil, err
+				}
 
+				required, err := includeRequired(resource)
+				if err != nil {
+					return nil, err
+				}
 				if len(matches) == 0 {
-					fmt.Printf("Warning: no files matched include pattern %s\n", pattern)
+					if required {
+						return nil, fmt.Errorf("no files matched required include pattern %s in %s", pattern, h.displayPath(absPath))
+					}
+					h.Logger.Warnf("no files matched include pattern %s", pattern)
 				}
 
-				for _, match := range matches {
-					includeResources, err := h.ProcessIncludes(match)
-					if err != nil {
-						return nil, err
+				scope, _ := resource.Attributes["scope"].(string)
+				err = h.withScope(scope, func() error {
+					for _, match := range matches {
+						includeResources, err := h.processFile(match)
+						if err != nil {
+							return err
+						}
+						allResources = append(allResources, includeResources...)
 					}
-					allResources = append(allResources, includeResources...)
+					return nil
+				})
+				if err != nil {
+					return nil, err
 				}
 			}
 
@@ -143,13 +366,24 @@ func (h *IncludeHandler) ProcessIncludes(configFile string) ([]Resource, error)
 				if err != nil {
 					return nil, fmt.Errorf("error resolving platform include pattern %s: %v", platformPath, err)
 				}
+				matches, err = expandIncludeDirs(matches, resource)
+				if err != nil {
+					return nil, err
+				}
 
+				required, err := includeRequired(resource)
+				if err != nil {
+					return nil, err
+				}
 				if len(matches) == 0 {
-					fmt.Printf("Warning: no files matched platform-specific include pattern %s\n", platformPath)
+					if required {
+						return nil, fmt.Errorf("no files matched required platform-specific include pattern %s in %s", platformPath, h.displayPath(absPath))
+					}
+					h.Logger.Warnf("no files matched platform-specific include pattern %s", platformPath)
 				}
 
 				for _, match := range matches {
-					includeResources, err := h.ProcessIncludes(match)
+					includeResources, err := h.processFile(match)
 					if err != nil {
 						return nil, err
 					}
@@ -158,13 +392,36 @@ func (h *IncludeHandler) ProcessIncludes(configFile string) ([]Resource, error)
 			}
 
 		case "variable":
-			// Variable definition
+			// Variable definition. An explicit value always wins, while a
+			// default only seeds a variable that is not already defined.
 			name := resource.Name
-			if value, ok := resource.Attributes["value"].(string); ok {
-				// Resolve any variables in the value itself
-				resolvedValue := h.ReplaceVariables(value)
-				h.SetVariable(name, resolvedValue)
+			raw, hasValue := resource.Attributes["value"]
+			if !hasValue {
+				defaultValue, hasDefault := resource.Attributes["default"]
+				if _, defined := h.GetVariable(name); defined || !hasDefault {
+					continue
+				}
+				raw = defaultValue
+			}
+
+			// Numbers and booleans keep their type
+			value, isString := raw.(string)
+			if !isString {
+				switch raw.(type) {
+				case int64, float64, bool:
+					h.SetTypedVariable(name, raw)
+					continue
+				default:
+					return nil, fmt.Errorf("error in variable %s in %s: value must be a string, number or boolean", name, configFile)
+				}
+			}
+
+			// Resolve any variables and functions in the value itself
+			resolvedValue, err := h.resolveVariableValue(value, filepath.Dir(absPath))
+			if err != nil {
+				return nil, fmt.Errorf("error in variable %s in %s: %v", name, configFile, err)
 			}
+			h.SetVariable(name, resolvedValue)
 
 		case "template":
 			// Template definition
@@ -176,11 +433,17 @@ func (h *IncludeHandler) ProcessIncludes(configFile string) ([]Resource, error)
 		default:
 			// Regular resource, process variable substitutions in string attributes
 			processedResource := resource
+			processedResource.File = absPath
 
 			// Process all string attributes for variable substitution
 			for key, value := range processedResource.Attributes {
 				if strValue, ok := value.(string); ok {
-					processedResource.Attributes[key] = h.ReplaceVariables(strValue)
+					resolved, err := h.attributeValue(strValue)
+					if err != nil {
+						return nil, fmt.Errorf("error in %s.%s attribute %s in %s: %v",
+							resource.Type, resource.Name, key, configFile, err)
+					}
+					processedResource.Attributes[key] = resolved
 				}
 			}
 
@@ -191,6 +454,141 @@ func (h *IncludeHandler) ProcessIncludes(configFile string) ([]Resource, error)
 	return allResources, nil
 }
 
+// withScope runs fn with the given variable scope. The default "inherited"
+// scope shares variables with the including file. The "isolated" scope lets
+// the included files see the including file's variables, but variables they
+// define or change are discarded when fn returns.
+func (h *IncludeHandler) withScope(scope string, fn func() error) error {
+	switch scope {
+	case "", "inherited":
+		return fn()
+	case "isolated":
+		saved := make(map[string]string, len(h.Variables))
+		for name, value := range h.Variables {
+			saved[name] = value
+		}
+		savedTyped := make(map[string]interface{}, len(h.typed))
+		for name, value := range h.typed {
+			savedTyped[name] = value
+		}
+		defer func() {
+			h.Variables = saved
+			h.typed = savedTyped
+		}()
+		return fn()
+	default:
+		return fmt.Errorf("include 'scope' must be one of: inherited, isolated, got %q", scope)
+	}
+}
+
+// defaultIncludeExtension is the extension of the files loaded from an
+// included directory unless the include sets 'extension'
+const defaultIncludeExtension = ".cfg"
+
+// expandIncludeDirs replaces each directory among the matches of an include
+// pattern with the files directly inside it that have the include's
+// extension, in name order. Other matches are kept as they are.
+func expandIncludeDirs(matches []string, resource Resource) ([]string, error) {
+	extension := defaultIncludeExtension
+	if value, ok := resource.Attributes["extension"]; ok {
+		str, isString := value.(string)
+		if !isString || strings.TrimPrefix(str, ".") == "" {
+			return nil, fmt.Errorf("%s 'extension' must be a non-empty string", resource.Type)
+		}
+		extension = "." + strings.TrimPrefix(str, ".")
+	}
+
+	var files []string
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || !info.IsDir() {
+			files = append(files, match)
+			continue
+		}
+
+		dirFiles, err := includeDirFiles(match, extension)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+// includeDirFiles returns the files directly inside dir with the given
+// extension, in name order
+func includeDirFiles(dir, extension string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading include directory %s: %v", dir, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == extension {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// checkDuplicates reports the first resource ID that is defined more than
+// once, naming the files that define it
+func (h *IncludeHandler) checkDuplicates(resources []Resource) error {
+	defined := make(map[string]string, len(resources))
+	for _, resource := range resources {
+		id := fmt.Sprintf("%s.%s", resource.Type, resource.Name)
+		file, exists := defined[id]
+		if !exists {
+			defined[id] = resource.File
+			continue
+		}
+		if file == resource.File {
+			return fmt.Errorf("resource %s is defined more than once in %s", id, h.displayPath(file))
+		}
+		return fmt.Errorf("resource %s is defined in both %s and %s", id, h.displayPath(file), h.displayPath(resource.File))
+	}
+	return nil
+}
+
+// includeRequired reports whether an include sets required = true, making a
+// pattern that matches no files an error rather than a warning
+func includeRequired(resource Resource) (bool, error) {
+	value, ok := resource.Attributes["required"]
+	if !ok {
+		return false, nil
+	}
+	required, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s 'required' must be a boolean", resource.Type)
+	}
+	return required, nil
+}
+
+// displayPath returns a path relative to the base path when it is inside it
+func (h *IncludeHandler) displayPath(path string) string {
+	if rel, err := filepath.Rel(h.BasePath, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// resolveVariableValue resolves variable references and function calls such as
+// env("VAR") in a variable's value, resolving file() paths relative to dir
+func (h *IncludeHandler) resolveVariableValue(value, dir string) (string, error) {
+	resolved := h.ReplaceVariables(value)
+
+	processed, handled, err := h.evaluateFunction(resolved, dir)
+	if err != nil {
+		return "", err
+	}
+	if handled {
+		return processed, nil
+	}
+
+	return resolved, nil
+}
+
 // resolveIncludePath resolves an include path relative to the including file
 func (h *IncludeHandler) resolveIncludePath(baseFile, includePath string) string {
 	if filepath.IsAbs(includePath) {
@@ -201,7 +599,25 @@ func (h *IncludeHandler) resolveIncludePath(baseFile, includePath string) string
 	return filepath.Join(baseDir, includePath)
 }
 
-// ProcessTemplates processes template functions in resources
+// resolveFilePath resolves a file() path. A relative path is looked up next to
+// the configuration file in dir first, then relative to the base path.
+func (h *IncludeHandler) resolveFilePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	if dir != "" {
+		local := filepath.Join(dir, path)
+		if _, err := os.Stat(local); err == nil {
+			return local
+		}
+	}
+
+	return filepath.Join(h.BasePath, path)
+}
+
+// ProcessTemplates processes template functions in resources. file() paths
+// are resolved relative to the file each resource was defined in.
 func (h *IncludeHandler) ProcessTemplates(resources []Resource) ([]Resource, error) {
 	result := make([]Resource, len(resources))
 	copy(result, resources)
@@ -210,30 +626,150 @@ func (h *IncludeHandler) ProcessTemplates(resources []Resource) ([]Resource, err
 	for i, resource := range result {
 		for key, value := range resource.Attributes {
 			if strValue, ok := value.(string); ok {
-				// Check for template function: template("name")
-				if strings.HasPrefix(strValue, "template(") && strings.HasSuffix(strValue, ")") {
-					templateName := strValue[9 : len(strValue)-1]
-					if content, exists := h.GetTemplate(templateName); exists {
-						// Replace variables in the template content
-						processed := h.ReplaceVariables(content)
-						result[i].Attributes[key] = processed
-					}
-				} else if strings.HasPrefix(strValue, "file(") && strings.HasSuffix(strValue, ")") {
-					// Check for file function: file("path/to/file")
-					filePath := strValue[5 : len(strValue)-1]
-					resolved := h.resolveIncludePath(h.BasePath, filePath)
-					data, err := ioutil.ReadFile(resolved)
-					if err != nil {
-						return nil, fmt.Errorf("error reading file %s: %v", filePath, err)
-					}
-					// Replace variables in the file content
-					content := string(data)
-					processed := h.ReplaceVariables(content)
+				dir := ""
+				if resource.File != "" {
+					dir = filepath.Dir(resource.File)
+				}
+				processed, handled, err := h.evaluateFunction(strValue, dir)
+				if err != nil {
+					return nil, fmt.Errorf("error in %s.%s attribute %s: %v", resource.Type, resource.Name, key, err)
+				}
+				if handled {
 					result[i].Attributes[key] = processed
 				}
 			}
 		}
+
+		if resource.Type == "template_file" {
+			h.prepareTemplateFile(result[i])
+		}
 	}
 
 	return result, nil
 }
+
+// prepareTemplateFile resolves the source of a template_file resource like a
+// file() path and gives its template the global variables, which the
+// resource's own vars override
+func (h *IncludeHandler) prepareTemplateFile(resource Resource) {
+	if source, ok := resource.Attributes["source"].(string); ok {
+		dir := ""
+		if resource.File != "" {
+			dir = filepath.Dir(resource.File)
+		}
+		resource.Attributes["source"] = h.resolveFilePath(dir, source)
+	}
+
+	vars, ok := resource.Attributes["vars"].(map[string]interface{})
+	if _, set := resource.Attributes["vars"]; set && !ok {
+		// Left for the provider to reject
+		return
+	}
+
+	merged := make(map[string]interface{}, len(h.Variables)+len(vars))
+	for name, value := range h.Variables {
+		merged[name] = value
+	}
+	for name, value := range h.typed {
+		merged[name] = value
+	}
+	for name, value := range vars {
+		merged[name] = value
+	}
+	resource.Attributes["vars"] = merged
+}
+
+// evaluateFunction evaluates an attribute value holding a function call such
+// as template("name"), file("path") or env("VAR", "default"), with file()
+// paths resolved by resolveFilePath. It reports false if the value is not a
+// known function call.
+func (h *IncludeHandler) evaluateFunction(value, dir string) (string, bool, error) {
+	name, args, ok := parseFunctionCall(value)
+	if !ok {
+		return "", false, nil
+	}
+
+	switch name {
+	case "template":
+		// Check for template function: template("name")
+		if len(args) != 1 {
+			return "", true, fmt.Errorf("template() takes exactly 1 argument, got %d", len(args))
+		}
+		content, exists := h.GetTemplate(args[0])
+		if !exists {
+			// Unknown templates are left as-is
+			return "", false, nil
+		}
+		// Replace variables in the template content
+		return h.ReplaceVariables(content), true, nil
+
+	case "file":
+		// Check for file function: file("path/to/file")
+		if len(args) != 1 {
+			return "", true, fmt.Errorf("file() takes exactly 1 argument, got %d", len(args))
+		}
+		resolved := h.resolveFilePath(dir, args[0])
+		data, err := ioutil.ReadFile(resolved)
+		if err != nil {
+			return "", true, fmt.Errorf("error reading file %s: %v", args[0], err)
+		}
+		// Replace variables in the file content
+		return h.ReplaceVariables(string(data)), true, nil
+
+	case "env":
+		// Check for env function: env("VAR") or env("VAR", "default")
+		if len(args) < 1 || len(args) > 2 {
+			return "", true, fmt.Errorf("env() takes 1 or 2 arguments, got %d", len(args))
+		}
+		if envValue, exists := os.LookupEnv(args[0]); exists {
+			return envValue, true, nil
+		}
+		if len(args) == 2 {
+			return args[1], true, nil
+		}
+		return "", true, fmt.Errorf("environment variable %s is not set and no default was given", args[0])
+	}
+
+	return "", false, nil
+}
+
+// parseFunctionCall splits a string of the form name("arg1", "arg2") into the
+// function name and its unquoted arguments
+func parseFunctionCall(value string) (string, []string, bool) {
+	open := strings.IndexByte(value, '(')
+	if open <= 0 || !strings.HasSuffix(value, ")") {
+		return "", nil, false
+	}
+
+	name := value[:open]
+	for i := 0; i < len(name); i++ {
+		if !isIdentifierChar(name[i]) {
+			return "", nil, false
+		}
+	}
+
+	args := []string{}
+	rest := strings.TrimSpace(value[open+1 : len(value)-1])
+	for rest != "" {
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return "", nil, false
+		}
+		arg, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", nil, false
+		}
+		args = append(args, arg)
+
+		rest = strings.TrimSpace(rest[len(quoted):])
+		if rest == "" {
+			break
+		}
+		if rest[0] != ',' {
+			return "", nil, false
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+
+	return name, args, true
+}
@@ -3,7 +3,10 @@ package parser
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/dangerclosesec/zero/pkg/logging"
 )
 
 func TestIncludeHandler_VariableOperations(t *testing.T) {
@@ -35,6 +38,39 @@ func TestIncludeHandler_VariableOperations(t *testing.T) {
 	if result != expected {
 		t.Errorf("Variable replacement failed.\nExpected: %s\nGot: %s", expected, result)
 	}
+
+	// Bare references only match complete identifiers
+	handler.SetVariable("foo", "short")
+	handler.SetVariable("foobar", "long")
+	cases := map[string]string{
+		"$foo $foobar":          "short long",
+		"$foo-suffix":           "short-suffix",
+		"$foo_suffix":           "$foo_suffix",
+		"${foo}_suffix":         "short_suffix",
+		"${key1}${key2}":        "value1value2",
+		"prefix${foobar}suffix": "prefixlongsuffix",
+		"${missing} stays":      "${missing} stays",
+		"unterminated ${foo":    "unterminated ${foo",
+		"cost: 5$":              "cost: 5$",
+	}
+	for input, want := range cases {
+		if got := handler.ReplaceVariables(input); got != want {
+			t.Errorf("ReplaceVariables(%q): expected %q, got %q", input, want, got)
+		}
+	}
+
+	// Strict mode rejects undefined ${name} references
+	handler.Strict = true
+	if _, err := handler.Interpolate("${missing}"); err == nil {
+		t.Error("Expected error for undefined variable in strict mode, got nil")
+	}
+	if got, err := handler.Interpolate("${foo}"); err != nil || got != "short" {
+		t.Errorf("Expected strict interpolation of defined variable to succeed, got %q, %v", got, err)
+	}
+	// Resource references are left for the engine even in strict mode
+	if got, err := handler.Interpolate("${resource.group.staff.gid}"); err != nil || got != "${resource.group.staff.gid}" {
+		t.Errorf("Expected resource reference to be left in place, got %q, %v", got, err)
+	}
 }
 
 func TestIncludeHandler_TemplateOperations(t *testing.T) {
@@ -242,7 +278,6 @@ func TestIncludeHandler_ProcessIncludes_InvalidFile(t *testing.T) {
 }
 
 func TestIncludeHandler_ProcessTemplates_Direct(t *testing.T) {
-	t.Skip("Skipping failing test")
 	// Create a handler
 	handler := NewIncludeHandler("/base/path")
 	
@@ -282,8 +317,6 @@ func TestIncludeHandler_ProcessTemplates_Direct(t *testing.T) {
 }
 
 func TestIncludeHandler_ProcessTemplates(t *testing.T) {
-	t.Skip("Skipping test due to issues with template processing")
-	
 	// Create a new temporary directory
 	tempDir, err := os.MkdirTemp("", "include_handler_test_templates")
 	if err != nil {
@@ -335,8 +368,6 @@ func TestIncludeHandler_ProcessTemplates(t *testing.T) {
 }
 
 func TestIncludeHandler_ProcessTemplates_Error(t *testing.T) {
-	t.Skip("Skipping test due to issues with template processing")
-	
 	handler := NewIncludeHandler("/base/path")
 	
 	// Test with an invalid file path
@@ -354,4 +385,638 @@ func TestIncludeHandler_ProcessTemplates_Error(t *testing.T) {
 	if err == nil {
 		t.Errorf("Expected error when processing nonexistent file in file() function")
 	}
-}
\ No newline at end of file
+}
+func TestIncludeHandler_ProcessIncludes_Booleans(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "include_handler_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mainContent := `
+variable "svc" {
+	value = "nginx"
+}
+service "$svc" {
+	name    = "$svc"
+	enabled = true
+}
+`
+	if err := os.WriteFile(filepath.Join(tempDir, "main.cfg"), []byte(mainContent), 0644); err != nil {
+		t.Fatalf("Failed to write main config file: %v", err)
+	}
+
+	handler := NewIncludeHandler(tempDir)
+	resources, err := handler.ProcessIncludes(filepath.Join(tempDir, "main.cfg"))
+	if err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+
+	if len(resources) != 1 {
+		t.Fatalf("Expected 1 resource, got %d", len(resources))
+	}
+
+	if resources[0].Attributes["name"] != "nginx" {
+		t.Errorf("Expected string attribute to be substituted, got %v", resources[0].Attributes["name"])
+	}
+
+	if enabled, ok := resources[0].Attributes["enabled"].(bool); !ok || !enabled {
+		t.Errorf("Expected 'enabled' to remain bool true, got %T %v", resources[0].Attributes["enabled"], resources[0].Attributes["enabled"])
+	}
+}
+
+func TestIncludeHandler_ProcessTemplates_Env(t *testing.T) {
+	t.Setenv("ZERO_TEST_WEB_ROOT", "/srv/www")
+	os.Unsetenv("ZERO_TEST_UNSET")
+
+	handler := NewIncludeHandler("/base/path")
+
+	resources := []Resource{
+		{
+			Type: "file",
+			Name: "env_test",
+			Attributes: map[string]interface{}{
+				"path":    `env("ZERO_TEST_WEB_ROOT")`,
+				"owner":   `env("ZERO_TEST_UNSET", "www-data")`,
+				"content": `env("ZERO_TEST_WEB_ROOT", "ignored")`,
+			},
+		},
+	}
+
+	result, err := handler.ProcessTemplates(resources)
+	if err != nil {
+		t.Fatalf("ProcessTemplates returned error: %v", err)
+	}
+
+	attrs := result[0].Attributes
+	if attrs["path"] != "/srv/www" {
+		t.Errorf("Expected path to come from the environment, got %v", attrs["path"])
+	}
+	if attrs["owner"] != "www-data" {
+		t.Errorf("Expected owner to fall back to the default, got %v", attrs["owner"])
+	}
+	if attrs["content"] != "/srv/www" {
+		t.Errorf("Expected set variable to win over the default, got %v", attrs["content"])
+	}
+
+	// A missing variable without a default is an error
+	missing := []Resource{
+		{
+			Type:       "file",
+			Name:       "missing",
+			Attributes: map[string]interface{}{"path": `env("ZERO_TEST_UNSET")`},
+		},
+	}
+	_, err = handler.ProcessTemplates(missing)
+	if err == nil || !strings.Contains(err.Error(), "ZERO_TEST_UNSET") {
+		t.Errorf("Expected error naming the missing variable, got %v", err)
+	}
+}
+
+func TestIncludeHandler_VariableDefaults(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "include_handler_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	t.Setenv("ZERO_TEST_PORT", "8080")
+
+	mainContent := `
+variable "user" {
+	default = "www-data"
+}
+variable "root" {
+	default = "/var/www"
+}
+variable "root" {
+	value = "/srv/www"
+}
+variable "port" {
+	default = env("ZERO_TEST_PORT", "80")
+}
+variable "user" {
+	default = "nobody"
+}
+file "${root}/index.html" {
+	owner   = "$user"
+	content = "port ${port}"
+}
+`
+	if err := os.WriteFile(filepath.Join(tempDir, "main.cfg"), []byte(mainContent), 0644); err != nil {
+		t.Fatalf("Failed to write main config file: %v", err)
+	}
+
+	handler := NewIncludeHandler(tempDir)
+	resources, err := handler.ProcessIncludes(filepath.Join(tempDir, "main.cfg"))
+	if err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+
+	expected := map[string]string{
+		"user": "www-data", // first default wins over a later default
+		"root": "/srv/www", // explicit value supersedes the default
+		"port": "8080",     // defaults may come from the environment
+	}
+	for name, want := range expected {
+		if got, _ := handler.GetVariable(name); got != want {
+			t.Errorf("Expected variable %s to be %q, got %q", name, want, got)
+		}
+	}
+
+	if resources[0].Attributes["content"] != "port 8080" {
+		t.Errorf("Expected content 'port 8080', got %v", resources[0].Attributes["content"])
+	}
+}
+
+func TestIncludeHandler_UnresolvedVariables(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "include_handler_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mainContent := `
+include "other.cfg"
+file "/tmp/a" {
+	content = "${missing_one}"
+}
+`
+	otherContent := `
+file "/tmp/b" {
+	content = "${missing_two} and ${missing_one}"
+}
+`
+	if err := os.WriteFile(filepath.Join(tempDir, "main.cfg"), []byte(mainContent), 0644); err != nil {
+		t.Fatalf("Failed to write main config file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "other.cfg"), []byte(otherContent), 0644); err != nil {
+		t.Fatalf("Failed to write include file: %v", err)
+	}
+
+	handler := NewIncludeHandler(tempDir)
+	_, err = handler.ProcessIncludes(filepath.Join(tempDir, "main.cfg"))
+	if err == nil {
+		t.Fatal("Expected error for unresolved variables, got nil")
+	}
+
+	if !strings.Contains(err.Error(), "missing_one, missing_two") {
+		t.Errorf("Expected a single error listing both variables, got %v", err)
+	}
+}
+
+func TestIncludeHandler_BareShellVariables(t *testing.T) {
+	tempDir := t.TempDir()
+	content := "file \"/etc/profile.d/path.sh\" {\n  content = <<EOF\necho $HOME\nexport PATH=$HOME/bin:$PATH\nEOF\n}\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "main.cfg"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write main config file: %v", err)
+	}
+
+	recorder := &logging.Recorder{}
+	handler := NewIncludeHandler(tempDir)
+	handler.Logger = recorder
+	resources, err := handler.ProcessIncludes(filepath.Join(tempDir, "main.cfg"))
+	if err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+	if len(resources) != 1 || resources[0].Attributes["content"] != "echo $HOME\nexport PATH=$HOME/bin:$PATH\n" {
+		t.Errorf("Expected the shell variables to be left in place, got %v", resources)
+	}
+	if got := recorder.Messages(logging.LevelWarn); len(got) != 0 {
+		t.Errorf("Expected no report for shell variables, got %v", got)
+	}
+}
+
+func TestIncludeHandler_ForwardVariableReference(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"main.cfg":      "include \"app.cfg\"\ninclude \"variables.cfg\"\n",
+		"app.cfg":       "file \"/tmp/app\" {\n  content = \"${port} $port\"\n}\n",
+		"variables.cfg": "variable \"port\" {\n  value = \"8080\"\n}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	handler := NewIncludeHandler(tempDir)
+	if _, err := handler.ProcessIncludes(filepath.Join(tempDir, "main.cfg")); err != nil {
+		t.Fatalf("Expected a variable defined later not to be an error, got %v", err)
+	}
+}
+
+func TestIncludeHandler_IncludeCycle(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "include_handler_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"main.cfg": "include \"a.cfg\"\nfile \"main\" {}\n",
+		"a.cfg":    "include \"b.cfg\"\nfile \"a\" {}\n",
+		"b.cfg":    "include \"a.cfg\"\nfile \"b\" {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	_, err = NewIncludeHandler(tempDir).ProcessIncludes(filepath.Join(tempDir, "main.cfg"))
+	if err == nil {
+		t.Fatal("Expected an include cycle error, got nil")
+	}
+	if !strings.Contains(err.Error(), "include cycle: a.cfg -> b.cfg -> a.cfg") {
+		t.Errorf("Expected the error to name the include chain, got: %v", err)
+	}
+}
+
+func TestIncludeHandler_DiamondInclude(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "include_handler_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"main.cfg":   "include \"left.cfg\"\ninclude \"right.cfg\"\n",
+		"left.cfg":   "include \"common.cfg\"\nfile \"left\" {}\n",
+		"right.cfg":  "include \"common.cfg\"\nfile \"right\" {}\n",
+		"common.cfg": "file \"common\" {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	resources, err := NewIncludeHandler(tempDir).ProcessIncludes(filepath.Join(tempDir, "main.cfg"))
+	if err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+
+	var names []string
+	for _, resource := range resources {
+		names = append(names, resource.Name)
+	}
+	if strings.Join(names, ",") != "common,left,right" {
+		t.Errorf("Expected a file included twice to be loaded once, got %v", names)
+	}
+}
+
+func TestIncludeHandler_IncludeScope(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "include_handler_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	child := `
+variable "port" {
+	value = "9090"
+}
+variable "child_only" {
+	value = "yes"
+}
+file "child" {
+	content = "${port} ${env}"
+}
+`
+	files := map[string]string{
+		"inherited.cfg": "variable \"env\" {\n\tvalue = \"prod\"\n}\nvariable \"port\" {\n\tvalue = \"8080\"\n}\ninclude \"child.cfg\"\nfile \"parent\" {\n\tcontent = \"${port}\"\n}\n",
+		"isolated.cfg":  "variable \"env\" {\n\tvalue = \"prod\"\n}\nvariable \"port\" {\n\tvalue = \"8080\"\n}\ninclude \"child.cfg\" {\n\tscope = \"isolated\"\n}\nfile \"parent\" {\n\tcontent = \"${port}\"\n}\n",
+		"invalid.cfg":   "include \"child.cfg\" {\n\tscope = \"global\"\n}\n",
+		"child.cfg":     child,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	contents := func(resources []Resource) map[string]string {
+		result := make(map[string]string)
+		for _, resource := range resources {
+			result[resource.Name], _ = resource.Attributes["content"].(string)
+		}
+		return result
+	}
+
+	// By default the included file's variables replace the parent's
+	handler := NewIncludeHandler(tempDir)
+	resources, err := handler.ProcessIncludes(filepath.Join(tempDir, "inherited.cfg"))
+	if err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+	if got := contents(resources); got["child"] != "9090 prod" || got["parent"] != "9090" {
+		t.Errorf("Expected inherited variables to be shared, got %v", got)
+	}
+	if _, ok := handler.GetVariable("child_only"); !ok {
+		t.Error("Expected the included file's variable to be visible to the parent")
+	}
+
+	// An isolated include sees the parent's variables but doesn't change them
+	handler = NewIncludeHandler(tempDir)
+	resources, err = handler.ProcessIncludes(filepath.Join(tempDir, "isolated.cfg"))
+	if err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+	if got := contents(resources); got["child"] != "9090 prod" || got["parent"] != "8080" {
+		t.Errorf("Expected isolated variables not to leak to the parent, got %v", got)
+	}
+	if _, ok := handler.GetVariable("child_only"); ok {
+		t.Error("Expected the isolated file's variable to be discarded")
+	}
+
+	if _, err := NewIncludeHandler(tempDir).ProcessIncludes(filepath.Join(tempDir, "invalid.cfg")); err == nil {
+		t.Error("Expected error for an unknown scope, got nil")
+	}
+}
+
+func TestIncludeHandler_FileRelativeToInclude(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "include_handler_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"main.cfg":                  "include \"services/*/service.cfg\"\nfile \"root\" {\n\tcontent = file(\"shared.txt\")\n}\n",
+		"shared.txt":                "shared",
+		"services/web/service.cfg":  "file \"web\" {\n\tcontent = file(\"web.conf\")\n}\nfile \"web_shared\" {\n\tcontent = file(\"shared.txt\")\n}\n",
+		"services/web/web.conf":     "listen 80",
+		"services/db/service.cfg":   "variable \"db_conf\" {\n\tvalue = file(\"../db.conf\")\n}\nfile \"db\" {\n\tcontent = \"${db_conf}\"\n}\n",
+		"services/db.conf":          "port 5432",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	handler := NewIncludeHandler(tempDir)
+	resources, err := handler.ProcessIncludes(filepath.Join(tempDir, "main.cfg"))
+	if err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+	resources, err = handler.ProcessTemplates(resources)
+	if err != nil {
+		t.Fatalf("ProcessTemplates returned error: %v", err)
+	}
+
+	contents := make(map[string]string)
+	for _, resource := range resources {
+		contents[resource.Name], _ = resource.Attributes["content"].(string)
+	}
+
+	expected := map[string]string{
+		"web":        "listen 80", // Next to the including file
+		"web_shared": "shared",    // Falls back to the base path
+		"db":         "port 5432", // Relative path in a variable
+		"root":       "shared",
+	}
+	for name, want := range expected {
+		if contents[name] != want {
+			t.Errorf("Expected %s content %q, got %q", name, want, contents[name])
+		}
+	}
+
+	for _, resource := range resources {
+		if resource.Name == "web" && resource.File != filepath.Join(tempDir, "services", "web", "service.cfg") {
+			t.Errorf("Expected web to record its originating file, got %q", resource.File)
+		}
+	}
+}
+
+func TestIncludeHandler_TemplateFileVariables(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"main.cfg": "variable \"port\" {\n\tvalue = \"80\"\n}\nvariable \"host\" {\n\tvalue = \"web\"\n}\n" +
+			"template_file \"nginx\" {\n\tpath = \"/etc/nginx/nginx.conf\"\n\tsource = \"nginx.conf.tmpl\"\n" +
+			"\tvars = {\n\t\tport = \"8080\",\n\t\tworkers = [\"a\", \"b\"]\n\t}\n}\n",
+		"nginx.conf.tmpl": "listen {{ .port }};\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	resources, err := Load(filepath.Join(tempDir, "main.cfg"))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(resources) != 1 {
+		t.Fatalf("Expected 1 resource, got %d", len(resources))
+	}
+
+	attributes := resources[0].Attributes
+	if attributes["source"] != filepath.Join(tempDir, "nginx.conf.tmpl") {
+		t.Errorf("Expected source relative to the configuration file, got %v", attributes["source"])
+	}
+
+	vars, ok := attributes["vars"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected vars to be a map, got %T", attributes["vars"])
+	}
+	if vars["port"] != "8080" {
+		t.Errorf("Expected the resource's vars to override globals, got port %v", vars["port"])
+	}
+	if vars["host"] != "web" {
+		t.Errorf("Expected global variables in vars, got host %v", vars["host"])
+	}
+	if _, ok := vars["workers"]; !ok {
+		t.Error("Expected the resource's own vars to be kept")
+	}
+}
+
+func TestIncludeHandler_Logging(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"main.cfg":   "include \"common.cfg\"\ninclude \"common.cfg\"\ninclude \"missing/*.cfg\"\n",
+		"common.cfg": "file \"common\" {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	recorder := &logging.Recorder{}
+	handler := NewIncludeHandler(tempDir)
+	handler.Logger = recorder
+	if _, err := handler.ProcessIncludes(filepath.Join(tempDir, "main.cfg")); err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+
+	if got := recorder.Messages(logging.LevelWarn); len(got) != 1 || got[0] != "no files matched include pattern missing/*.cfg" {
+		t.Errorf("Expected a warning for the empty pattern, got %v", got)
+	}
+	if got := recorder.Messages(logging.LevelDebug); len(got) != 1 || got[0] != "Skipping common.cfg: already included" {
+		t.Errorf("Expected a debug message for the repeated include, got %v", got)
+	}
+}
+
+func TestIncludeHandler_RequiredInclude(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"optional.cfg": "include \"missing/*.cfg\"\nfile \"main\" {}\n",
+		"required.cfg": "include \"missing/*.cfg\" {\n  required = true\n}\nfile \"main\" {}\n",
+		"present.cfg":  "include \"common.cfg\" {\n  required = true\n}\n",
+		"invalid.cfg":  "include \"common.cfg\" {\n  required = \"yes\"\n}\n",
+		"common.cfg":   "file \"common\" {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// By default an empty pattern is a warning and the rest of the file loads
+	recorder := &logging.Recorder{}
+	handler := NewIncludeHandler(tempDir)
+	handler.Logger = recorder
+	resources, err := handler.ProcessIncludes(filepath.Join(tempDir, "optional.cfg"))
+	if err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+	if len(resources) != 1 || resources[0].Name != "main" {
+		t.Errorf("Expected the file resource to load, got %v", resources)
+	}
+	if got := recorder.Messages(logging.LevelWarn); len(got) != 1 {
+		t.Errorf("Expected a warning for the empty pattern, got %v", got)
+	}
+
+	// A required include that matches nothing is an error
+	_, err = NewIncludeHandler(tempDir).ProcessIncludes(filepath.Join(tempDir, "required.cfg"))
+	if err == nil || !strings.Contains(err.Error(), "no files matched required include pattern missing/*.cfg in required.cfg") {
+		t.Errorf("Expected a required include error, got %v", err)
+	}
+
+	// A required include that matches loads normally
+	resources, err = NewIncludeHandler(tempDir).ProcessIncludes(filepath.Join(tempDir, "present.cfg"))
+	if err != nil || len(resources) != 1 || resources[0].Name != "common" {
+		t.Errorf("Expected the required include to load, got %v, %v", resources, err)
+	}
+
+	// required must be a boolean
+	_, err = NewIncludeHandler(tempDir).ProcessIncludes(filepath.Join(tempDir, "invalid.cfg"))
+	if err == nil || !strings.Contains(err.Error(), "include 'required' must be a boolean") {
+		t.Errorf("Expected a boolean error, got %v", err)
+	}
+}
+
+func TestIncludeHandler_IncludeDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"main.cfg":             "include \"conf.d\"\n",
+		"custom.cfg":           "include \"conf.d\" {\n  extension = \"zero\"\n}\n",
+		"conf.d/20-web.cfg":    "file \"web\" {}\n",
+		"conf.d/10-base.cfg":   "file \"base\" {}\n",
+		"conf.d/30-db.cfg":     "file \"db\" {}\n",
+		"conf.d/README.md":     "not a config file\n",
+		"conf.d/extra.zero":    "file \"extra\" {}\n",
+		"conf.d/nested/99.cfg": "file \"nested\" {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	names := func(resources []Resource) string {
+		var result []string
+		for _, resource := range resources {
+			result = append(result, resource.Name)
+		}
+		return strings.Join(result, ",")
+	}
+
+	// The directory's .cfg files load in name order; subdirectories don't
+	resources, err := NewIncludeHandler(tempDir).ProcessIncludes(filepath.Join(tempDir, "main.cfg"))
+	if err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+	if got := names(resources); got != "base,web,db" {
+		t.Errorf("Expected base,web,db, got %s", got)
+	}
+
+	// The extension can be changed
+	resources, err = NewIncludeHandler(tempDir).ProcessIncludes(filepath.Join(tempDir, "custom.cfg"))
+	if err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+	if got := names(resources); got != "extra" {
+		t.Errorf("Expected extra, got %s", got)
+	}
+}
+
+func TestIncludeHandler_TypedVariables(t *testing.T) {
+	tempDir := t.TempDir()
+	content := `
+variable "port" {
+  value = 8080
+}
+variable "enabled" {
+  default = true
+}
+variable "ratio" {
+  value = 0.5
+}
+service "app" {
+  port    = "$port"
+  enabled = "${enabled}"
+  ratio   = "${ratio}"
+  url     = "http://localhost:${port}/"
+}
+`
+	if err := os.WriteFile(filepath.Join(tempDir, "main.cfg"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	resources, err := NewIncludeHandler(tempDir).ProcessIncludes(filepath.Join(tempDir, "main.cfg"))
+	if err != nil {
+		t.Fatalf("ProcessIncludes returned error: %v", err)
+	}
+	if len(resources) != 1 {
+		t.Fatalf("Expected 1 resource, got %d", len(resources))
+	}
+
+	attrs := resources[0].Attributes
+	if port, ok := attrs["port"].(int64); !ok || port != 8080 {
+		t.Errorf("Expected port to be the integer 8080, got %#v", attrs["port"])
+	}
+	if enabled, ok := attrs["enabled"].(bool); !ok || !enabled {
+		t.Errorf("Expected enabled to be the boolean true, got %#v", attrs["enabled"])
+	}
+	if ratio, ok := attrs["ratio"].(float64); !ok || ratio != 0.5 {
+		t.Errorf("Expected ratio to be the number 0.5, got %#v", attrs["ratio"])
+	}
+	// Within a longer string the variable is replaced by its string form
+	if attrs["url"] != "http://localhost:8080/" {
+		t.Errorf("Expected url to interpolate the port, got %#v", attrs["url"])
+	}
+}
+
+func TestIncludeHandler_SetVariableReplacesTyped(t *testing.T) {
+	handler := NewIncludeHandler("/base/path")
+	handler.SetTypedVariable("port", int64(8080))
+	if value, err := handler.attributeValue("$port"); err != nil || value != int64(8080) {
+		t.Errorf("Expected the typed value, got %#v, %v", value, err)
+	}
+
+	handler.SetVariable("port", "9090")
+	if value, err := handler.attributeValue("$port"); err != nil || value != "9090" {
+		t.Errorf("Expected the string value after SetVariable, got %#v, %v", value, err)
+	}
+}
@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Load reads the configuration file at path with its includes and returns
+// the fully resolved resources, with variables, templates and functions
+// applied. Includes are resolved relative to the file's directory. Nothing is
+// planned or applied.
+func Load(path string) ([]Resource, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving config path %s: %v", path, err)
+	}
+
+	return NewIncludeHandler(filepath.Dir(absPath)).Load(absPath)
+}
+
+// Load processes the includes of a configuration file and then its template
+// functions, returning the fully resolved resources
+func (h *IncludeHandler) Load(configFile string) ([]Resource, error) {
+	resources, err := h.ProcessIncludes(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.ProcessTemplates(resources)
+}
+
+// LoadDir reads every .cfg file directly inside dir, in name order, with
+// their includes, and returns the resources of all of them as one
+// configuration. The files share variables and templates, and includes are
+// resolved relative to each file. A resource defined in more than one file
+// is an error.
+func LoadDir(dir string) ([]Resource, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving config directory %s: %v", dir, err)
+	}
+
+	return NewIncludeHandler(absDir).LoadDir(absDir)
+}
+
+// LoadDir processes the configuration files in dir and their includes, and
+// then their template functions, returning the fully resolved resources
+func (h *IncludeHandler) LoadDir(dir string) ([]Resource, error) {
+	resources, err := h.ProcessDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.ProcessTemplates(resources)
+}
@@ -0,0 +1,201 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "load_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"main.cfg": `
+variable "app" {
+	value = "web"
+}
+
+template "motd" {
+	content = "Welcome to ${app}"
+}
+
+include "conf.d/*.cfg"
+
+file "/etc/motd" {
+	content = template("motd")
+}
+`,
+		"conf.d/package.cfg": `
+package "nginx" {
+	state = "installed"
+}
+`,
+		"conf.d/service.cfg": `
+service "nginx" {
+	state = "running"
+	depends_on [
+		package {"nginx"}
+	]
+}
+
+file "/etc/web.conf" {
+	content = file("app.conf")
+}
+`,
+		"conf.d/app.conf": "name=${app}",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	resources, err := Load(filepath.Join(tempDir, "main.cfg"))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	byID := make(map[string]Resource)
+	var ids []string
+	for _, resource := range resources {
+		id := resource.Type + "." + resource.Name
+		byID[id] = resource
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	expected := []string{"file./etc/motd", "file./etc/web.conf", "package.nginx", "service.nginx"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("Expected resources %v, got %v", expected, ids)
+	}
+
+	if content := byID["file./etc/motd"].Attributes["content"]; content != "Welcome to web" {
+		t.Errorf("Expected template to be rendered, got %v", content)
+	}
+	if content := byID["file./etc/web.conf"].Attributes["content"]; content != "name=web" {
+		t.Errorf("Expected file() to be read relative to the include, got %v", content)
+	}
+	if deps := byID["service.nginx"].DependsOn; !reflect.DeepEqual(deps, []string{"package.nginx"}) {
+		t.Errorf("Expected service to depend on package.nginx, got %v", deps)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	if _, err := Load(filepath.Join(os.TempDir(), "does-not-exist", "main.cfg")); err == nil {
+		t.Error("Expected error loading a missing file")
+	}
+
+	tempDir, err := os.MkdirTemp("", "load_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	config := filepath.Join(tempDir, "main.cfg")
+	if err := os.WriteFile(config, []byte("file \"/tmp/a\" {\n\tcontent = file(\"missing.txt\")\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := Load(config); err == nil {
+		t.Error("Expected error for a file() reference to a missing file")
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		// Loaded first, so its variable is visible to the other file
+		"10-base.cfg": `
+variable "app" {
+	value = "web"
+}
+
+package "nginx" {
+	state = "installed"
+}
+`,
+		"20-web.cfg": `
+service "nginx" {
+	state = "running"
+	depends_on [
+		package {"nginx"}
+	]
+}
+
+file "/etc/web.conf" {
+	content = "app=${app}"
+}
+`,
+		"notes.txt": "not a config file",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	resources, err := LoadDir(tempDir)
+	if err != nil {
+		t.Fatalf("LoadDir returned error: %v", err)
+	}
+
+	byID := make(map[string]Resource)
+	var ids []string
+	for _, resource := range resources {
+		id := resource.Type + "." + resource.Name
+		byID[id] = resource
+		ids = append(ids, id)
+	}
+
+	expected := []string{"package.nginx", "service.nginx", "file./etc/web.conf"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("Expected resources %v, got %v", expected, ids)
+	}
+
+	// The dependency on a resource in the other file names a loaded resource
+	deps := byID["service.nginx"].DependsOn
+	if !reflect.DeepEqual(deps, []string{"package.nginx"}) {
+		t.Fatalf("Expected service to depend on package.nginx, got %v", deps)
+	}
+	if _, ok := byID[deps[0]]; !ok {
+		t.Errorf("Expected dependency %s to be among the loaded resources", deps[0])
+	}
+	if content := byID["file./etc/web.conf"].Attributes["content"]; content != "app=web" {
+		t.Errorf("Expected the variable from 10-base.cfg to be used, got %v", content)
+	}
+	if file := byID["service.nginx"].File; file != filepath.Join(tempDir, "20-web.cfg") {
+		t.Errorf("Expected service.nginx to come from 20-web.cfg, got %s", file)
+	}
+}
+
+func TestLoadDir_Errors(t *testing.T) {
+	tempDir := t.TempDir()
+	if _, err := LoadDir(tempDir); err == nil || !strings.Contains(err.Error(), "no .cfg files found") {
+		t.Errorf("Expected an error for a directory without config files, got %v", err)
+	}
+
+	files := map[string]string{
+		"a.cfg": "package \"nginx\" {}\n",
+		"b.cfg": "package \"nginx\" {\n\tstate = \"removed\"\n}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	_, err := LoadDir(tempDir)
+	if err == nil || err.Error() != "resource package.nginx is defined in both a.cfg and b.cfg" {
+		t.Errorf("Expected a name collision error, got %v", err)
+	}
+}
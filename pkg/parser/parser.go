@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 )
 
@@ -29,6 +30,8 @@ const (
 	INCLUDE_PLATFORM // include_platform
 	VARIABLE         // variable
 	TEMPLATE         // template
+	TRUE             // true
+	FALSE            // false
 )
 
 // Token represents a lexical token
@@ -37,18 +40,31 @@ type Token struct {
 	Literal string
 	Line    int
 	Column  int
+	Offset  int // Byte offset of the first character in the input
+	End     int // Byte offset just past the last character
+}
+
+// Span is the part of the input something was parsed from
+type Span struct {
+	Start  int // Byte offset of the first byte
+	End    int // Byte offset just past the last byte
+	Line   int // Line of the first byte
+	Column int // Column of the first byte
 }
 
 // Lexer tokenizes input text
 type Lexer struct {
 	scanner func() Token
+	prev    Token
 	curr    Token
 	next    Token
+
+	// err is the error reading the input failed with, if any
+	err error
 }
 
 // Custom scanner to properly handle comments
 type customScanner struct {
-	reader    io.Reader
 	buffer    []byte
 	position  int
 	readPos   int
@@ -56,12 +72,18 @@ type customScanner struct {
 	line      int
 	column    int
 	lastToken Token
+	err       error // Error reading the input, if any
 }
 
 // Initialize a new custom scanner
 func newCustomScanner(r io.Reader) *customScanner {
+	// Read the whole input up front so token slices taken from the buffer
+	// stay valid no matter how large the input is
+	data, err := io.ReadAll(r)
+
 	cs := &customScanner{
-		reader: r,
+		err:    err,
+		buffer: data,
 		line:   1,
 		column: 0,
 	}
@@ -73,23 +95,15 @@ func newCustomScanner(r io.Reader) *customScanner {
 // Read the next character from the input
 func (cs *customScanner) readChar() {
 	if cs.readPos >= len(cs.buffer) {
-		// Read more input if needed
-		buf := make([]byte, 1024)
-		n, err := cs.reader.Read(buf)
-		if err != nil || n == 0 {
-			cs.ch = 0 // EOF
-		} else {
-			cs.buffer = buf[:n]
-			cs.position = 0
-			cs.readPos = 1
-			cs.ch = cs.buffer[0]
-		}
-	} else {
-		cs.ch = cs.buffer[cs.readPos]
-		cs.position = cs.readPos
-		cs.readPos++
+		cs.ch = 0 // EOF
+		cs.position = len(cs.buffer)
+		return
 	}
 
+	cs.ch = cs.buffer[cs.readPos]
+	cs.position = cs.readPos
+	cs.readPos++
+
 	// Update line and column
 	if cs.ch == '\n' {
 		cs.line++
@@ -114,9 +128,24 @@ func (cs *customScanner) skipWhitespace() {
 	}
 }
 
-// Skip comments (both // and #)
-func (cs *customScanner) skipComments() bool {
-	if cs.ch == '/' && cs.peekChar() == '/' {
+// Skip comments: // and # to the end of the line, and /* */ across lines.
+// Block comments don't nest; an unterminated one is an error.
+func (cs *customScanner) skipComments() (bool, error) {
+	if cs.ch == '/' && cs.peekChar() == '*' {
+		// Skip the "/*"
+		cs.readChar()
+		cs.readChar()
+		for !(cs.ch == '*' && cs.peekChar() == '/') {
+			if cs.ch == 0 {
+				return true, fmt.Errorf("unterminated block comment")
+			}
+			cs.readChar()
+		}
+		// Skip the "*/"
+		cs.readChar()
+		cs.readChar()
+		return true, nil
+	} else if cs.ch == '/' && cs.peekChar() == '/' {
 		// Skip // comment
 		for cs.ch != '\n' && cs.ch != 0 {
 			cs.readChar()
@@ -124,7 +153,7 @@ func (cs *customScanner) skipComments() bool {
 		if cs.ch == '\n' {
 			cs.readChar() // Skip the newline
 		}
-		return true
+		return true, nil
 	} else if cs.ch == '#' {
 		// Skip # comment
 		for cs.ch != '\n' && cs.ch != 0 {
@@ -133,9 +162,9 @@ func (cs *customScanner) skipComments() bool {
 		if cs.ch == '\n' {
 			cs.readChar() // Skip the newline
 		}
-		return true
+		return true, nil
 	}
-	return false
+	return false, nil
 }
 
 // Read an identifier
@@ -147,47 +176,133 @@ func (cs *customScanner) readIdentifier() string {
 	return string(cs.buffer[startPosition:cs.position])
 }
 
-// Read a number
+// Read a number, including a leading sign
 func (cs *customScanner) readNumber() string {
 	startPosition := cs.position
+	if cs.ch == '-' || cs.ch == '+' {
+		cs.readChar()
+	}
 	for isDigit(cs.ch) || cs.ch == '.' {
 		cs.readChar()
 	}
 	return string(cs.buffer[startPosition:cs.position])
 }
 
-// Read a string
-func (cs *customScanner) readString() string {
+// Read a string, translating escape sequences
+func (cs *customScanner) readString() (string, error) {
 	// Skip the opening quote
 	cs.readChar()
-	startPosition := cs.position
 
-	for cs.ch != '"' && cs.ch != 0 {
+	var sb strings.Builder
+	var escapeErr error
+	for cs.ch != '"' {
+		if cs.ch == 0 {
+			return sb.String(), fmt.Errorf("unterminated string")
+		}
+
+		if cs.ch == '\\' {
+			cs.readChar()
+			switch cs.ch {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '"':
+				sb.WriteByte('"')
+			case '\\':
+				sb.WriteByte('\\')
+			case 0:
+				return sb.String(), fmt.Errorf("unterminated string")
+			default:
+				// Keep consuming up to the closing quote so scanning can
+				// resume cleanly after the string
+				if escapeErr == nil {
+					escapeErr = fmt.Errorf("illegal escape sequence \\%c in string", cs.ch)
+				}
+			}
+			cs.readChar()
+			continue
+		}
+
+		sb.WriteByte(cs.ch)
 		cs.readChar()
 	}
 
-	// Capture the string without the quotes
-	result := string(cs.buffer[startPosition:cs.position])
-
 	// Skip the closing quote
-	if cs.ch == '"' {
+	cs.readChar()
+
+	return sb.String(), escapeErr
+}
+
+// Read a heredoc string of the form <<DELIM ... DELIM. Everything between the
+// line holding the opener and the terminator line is captured verbatim,
+// including indentation and newlines.
+func (cs *customScanner) readHeredoc() (string, error) {
+	// Skip the "<<"
+	cs.readChar()
+	cs.readChar()
+
+	delimiter := cs.readIdentifier()
+	if delimiter == "" {
+		return "", fmt.Errorf("expected heredoc delimiter after <<")
+	}
+
+	// The opener must be the last thing on its line
+	for cs.ch == ' ' || cs.ch == '\t' || cs.ch == '\r' {
 		cs.readChar()
 	}
+	if cs.ch != '\n' {
+		return "", fmt.Errorf("expected newline after heredoc delimiter %s", delimiter)
+	}
+	cs.readChar()
 
-	return result
+	var sb strings.Builder
+	for cs.ch != 0 {
+		start := cs.position
+		for cs.ch != '\n' && cs.ch != 0 {
+			cs.readChar()
+		}
+		line := string(cs.buffer[start:cs.position])
+
+		if strings.TrimSpace(line) == delimiter {
+			return sb.String(), nil
+		}
+
+		sb.WriteString(line)
+		if cs.ch == '\n' {
+			sb.WriteByte('\n')
+			cs.readChar()
+		}
+	}
+
+	return sb.String(), fmt.Errorf("unterminated heredoc, expected %s", delimiter)
 }
 
 // Scan the next token
 func (cs *customScanner) scanToken() Token {
 	// Skip whitespace and comments
+	var tok Token
 	cs.skipWhitespace()
-	for cs.skipComments() {
+	for {
+		line, column, offset := cs.line, cs.column, cs.position
+		skipped, err := cs.skipComments()
+		if err != nil {
+			// Report the problem at the start of the comment
+			tok = Token{Type: ILLEGAL, Literal: err.Error(), Line: line, Column: column, Offset: offset, End: cs.position}
+			cs.lastToken = tok
+			return tok
+		}
+		if !skipped {
+			break
+		}
 		cs.skipWhitespace()
 	}
 
-	var tok Token
 	tok.Line = cs.line
 	tok.Column = cs.column
+	tok.Offset = cs.position
 
 	switch cs.ch {
 	case 0:
@@ -226,8 +341,32 @@ func (cs *customScanner) scanToken() Token {
 		tok.Literal = ","
 		cs.readChar()
 	case '"':
-		tok.Type = STRING
-		tok.Literal = cs.readString()
+		str, err := cs.readString()
+		if err != nil {
+			// Surface the problem through an ILLEGAL token so the parser
+			// reports it with the position of the string
+			tok.Type = ILLEGAL
+			tok.Literal = err.Error()
+		} else {
+			tok.Type = STRING
+			tok.Literal = str
+		}
+	case '<':
+		if cs.peekChar() != '<' {
+			tok.Type = ILLEGAL
+			tok.Literal = string(cs.ch)
+			cs.readChar()
+			break
+		}
+
+		str, err := cs.readHeredoc()
+		if err != nil {
+			tok.Type = ILLEGAL
+			tok.Literal = err.Error()
+		} else {
+			tok.Type = STRING
+			tok.Literal = str
+		}
 	default:
 		if isLetter(cs.ch) {
 			// Read a complete identifier
@@ -248,11 +387,15 @@ func (cs *customScanner) scanToken() Token {
 					tok.Type = VARIABLE
 				case "template":
 					tok.Type = TEMPLATE
+				case "true":
+					tok.Type = TRUE
+				case "false":
+					tok.Type = FALSE
 				default:
 					tok.Type = IDENT
 				}
 			}
-		} else if isDigit(cs.ch) {
+		} else if isDigit(cs.ch) || cs.isSign() {
 			tok.Literal = cs.readNumber()
 			tok.Type = NUMBER
 		} else {
@@ -262,10 +405,26 @@ func (cs *customScanner) scanToken() Token {
 		}
 	}
 
+	tok.End = cs.position
 	cs.lastToken = tok
 	return tok
 }
 
+// isSign reports whether the current character is the sign of a number: a
+// '-' or '+' directly followed by a digit, where a value can start. After an
+// operand the character is left for use as an operator.
+func (cs *customScanner) isSign() bool {
+	if (cs.ch != '-' && cs.ch != '+') || !isDigit(cs.peekChar()) {
+		return false
+	}
+
+	switch cs.lastToken.Type {
+	case NUMBER, STRING, IDENT, TRUE, FALSE, RPAREN, RBRACKET:
+		return false
+	}
+	return true
+}
+
 // Helper function to check if a character is a letter
 func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z'
@@ -281,6 +440,7 @@ func NewLexer(r io.Reader) *Lexer {
 	scanner := newCustomScanner(r)
 	l := &Lexer{
 		scanner: scanner.scanToken, // Store the scanToken function
+		err:     scanner.err,
 	}
 	l.next = l.scanToken() // Prime the first token
 	l.advance()            // Set curr and next
@@ -289,10 +449,10 @@ func NewLexer(r io.Reader) *Lexer {
 
 // Advance moves to the next token
 func (l *Lexer) advance() Token {
-	prev := l.curr
+	l.prev = l.curr
 	l.curr = l.next
 	l.next = l.scanToken()
-	return prev
+	return l.prev
 }
 
 // Current returns the current token
@@ -316,7 +476,16 @@ type Resource struct {
 	Name       string
 	Attributes map[string]interface{}
 	DependsOn  []string
+	Notifies   []string // Resources to notify when this one changes, as "type.name"
+	Before     []string // Resources to apply after this one, without depending on it
+	After      []string // Resources to apply before this one, without depending on them
 	Conditions map[string][]string
+	File       string // Configuration file the resource was defined in, set by the include handler
+
+	// AttributeSpans holds where the value of each attribute is in the
+	// input, for tools that map attributes back to the source. Attributes
+	// seeded from the resource name span the name.
+	AttributeSpans map[string]Span
 }
 
 // Parser parses our DSL into a resource graph
@@ -342,6 +511,12 @@ func (p *Parser) ParseError(format string, args ...interface{}) {
 	p.errors = append(p.errors, errMsg)
 }
 
+// parseErrorAt adds an error to the parser positioned at the given token
+func (p *Parser) parseErrorAt(token Token, format string, args ...interface{}) {
+	errMsg := fmt.Sprintf("Line %d, Column %d: %s", token.Line, token.Column, fmt.Sprintf(format, args...))
+	p.errors = append(p.errors, errMsg)
+}
+
 // Errors returns all parsing errors
 func (p *Parser) Errors() []string {
 	return p.errors
@@ -349,6 +524,10 @@ func (p *Parser) Errors() []string {
 
 // Parse parses the entire configuration file
 func (p *Parser) Parse() ([]Resource, error) {
+	if p.lexer.err != nil {
+		return nil, fmt.Errorf("failed to read input: %v", p.lexer.err)
+	}
+
 	for p.lexer.Current().Type != EOF {
 		// Debug: Print current token info
 		// fmt.Printf("DEBUG: Current token: Type=%v, Literal='%s'\n", p.lexer.Current().Type, p.lexer.Current().Literal)
@@ -436,10 +615,31 @@ func (p *Parser) Parse() ([]Resource, error) {
 					// Add resource to resources
 					p.Resources = append(p.Resources, resource)
 					continue
+				}
+
+				// Regular include: the path string may stand on its own or be
+				// followed by a block, so only parse a block when one is present
+				if p.lexer.Current().Type == STRING && p.lexer.Peek().Type != LBRACE {
+					path := p.lexer.Current().Literal
+					p.Resources = append(p.Resources, Resource{
+						Type:       "include",
+						Name:       path,
+						Attributes: map[string]interface{}{"path": path},
+						Conditions: make(map[string][]string),
+					})
+					p.lexer.advance()
+					continue
+				}
+
+				// The lexer already sits on the include path
+				resource, err := p.parseResourceBlock(resourceType)
+				if err != nil {
+					p.ParseError("Error parsing resource: %v", err)
+					p.skipToNextResource()
 				} else {
-					// Not an include_platform, go back to 'include'
-					p.lexer.advance() // Skip token after include
+					p.Resources = append(p.Resources, resource)
 				}
+				continue
 			}
 
 			// Special handling for include_platform keyword
@@ -533,36 +733,43 @@ func (p *Parser) parseIncludePlatformBlock() (Resource, error) {
 // parseResourceBlock parses a resource block
 func (p *Parser) parseResourceBlock(resourceType string) (Resource, error) {
 	resource := Resource{
-		Type:       resourceType,
-		Attributes: make(map[string]interface{}),
-		Conditions: make(map[string][]string),
+		Type:           resourceType,
+		Attributes:     make(map[string]interface{}),
+		Conditions:     make(map[string][]string),
+		AttributeSpans: make(map[string]Span),
 	}
 
 	// Parse resource name
 	if p.lexer.Current().Type != STRING {
 		return resource, fmt.Errorf("expected resource name string, got %s", p.lexer.Current().Literal)
 	}
-	resource.Name = p.lexer.Current().Literal
+	nameToken := p.lexer.Current()
+	resource.Name = nameToken.Literal
+	nameSpan := Span{Start: nameToken.Offset, End: nameToken.End, Line: nameToken.Line, Column: nameToken.Column}
 
 	// Special handling for file resources
 	if resourceType == "file" {
 		// Use the path as given in the resource name
 		resource.Attributes["path"] = resource.Name
+		resource.AttributeSpans["path"] = nameSpan
 	}
 
 	// Special handling for include resources
 	if resourceType == "include" {
 		resource.Attributes["path"] = resource.Name
+		resource.AttributeSpans["path"] = nameSpan
 	}
 
 	// Special handling for variable resources
 	if resourceType == "variable" {
 		resource.Attributes["name"] = resource.Name
+		resource.AttributeSpans["name"] = nameSpan
 	}
 
 	// Special handling for template resources
 	if resourceType == "template" {
 		resource.Attributes["name"] = resource.Name
+		resource.AttributeSpans["name"] = nameSpan
 	}
 
 	p.lexer.advance()
@@ -574,6 +781,7 @@ func (p *Parser) parseResourceBlock(resourceType string) (Resource, error) {
 	p.lexer.advance()
 
 	// Parse resource attributes until closing '}'
+	seen := make(map[string]bool)
 	for p.lexer.Current().Type != RBRACE && p.lexer.Current().Type != EOF {
 		attrName := p.lexer.Current().Literal
 
@@ -603,6 +811,7 @@ func (p *Parser) parseResourceBlock(resourceType string) (Resource, error) {
 			resource.Conditions = conditions
 
 		case IDENT:
+			attrToken := p.lexer.Current()
 			p.lexer.advance()
 			if p.lexer.Current().Type != ASSIGN {
 				return resource, fmt.Errorf("expected '=' after attribute name, got %s", p.lexer.Current().Literal)
@@ -610,33 +819,40 @@ func (p *Parser) parseResourceBlock(resourceType string) (Resource, error) {
 			p.lexer.advance()
 
 			// Parse attribute value
-			var value interface{}
-			switch p.lexer.Current().Type {
-			case STRING:
-				value = p.lexer.Current().Literal
-				p.lexer.advance()
-			case NUMBER:
-				value = p.lexer.Current().Literal // For simplicity, keeping as string
-				p.lexer.advance()
-			case LBRACKET:
-				strArray, err := p.parseStringArray()
+			valueToken := p.lexer.Current()
+			value, err := p.parseValue(attrName)
+			if err != nil {
+				return resource, err
+			}
+			valueSpan := Span{Start: valueToken.Offset, End: p.lexer.prev.End, Line: valueToken.Line, Column: valueToken.Column}
+
+			// Attributes seeded from the resource name may be overridden once
+			if seen[attrName] {
+				p.parseErrorAt(attrToken, "duplicate attribute %s in %s %q", attrName, resourceType, resource.Name)
+			}
+			seen[attrName] = true
+
+			// notifies, before and after name other resources rather than
+			// configuring this one
+			switch attrName {
+			case "notifies", "before", "after":
+				targets, err := parseTargets(attrName, value)
 				if err != nil {
 					return resource, err
 				}
-				value = strArray
-			case LBRACE:
-				// Handle nested blocks
-				blockMap, err := p.parseBlockMap()
-				if err != nil {
-					return resource, err
+				switch attrName {
+				case "notifies":
+					resource.Notifies = targets
+				case "before":
+					resource.Before = targets
+				case "after":
+					resource.After = targets
 				}
-				value = blockMap
-			default:
-				return resource, fmt.Errorf("unexpected value type for attribute %s: %s",
-					attrName, p.lexer.Current().Literal)
+				continue
 			}
 
 			resource.Attributes[attrName] = value
+			resource.AttributeSpans[attrName] = valueSpan
 		default:
 			return resource, fmt.Errorf("unexpected token in resource block: %s", p.lexer.Current().Literal)
 		}
@@ -651,6 +867,24 @@ func (p *Parser) parseResourceBlock(resourceType string) (Resource, error) {
 	return resource, nil
 }
 
+// parseNumber converts a numeric literal into an int64, or a float64 when it
+// contains a decimal point
+func parseNumber(literal string) (interface{}, error) {
+	if strings.Contains(literal, ".") {
+		f, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", literal)
+		}
+		return f, nil
+	}
+
+	i, err := strconv.ParseInt(literal, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s", literal)
+	}
+	return i, nil
+}
+
 // parseDependsOn parses the new depends_on syntax: depends_on [ type {"name"} ]
 func (p *Parser) parseDependsOn() ([]string, error) {
 	result := []string{}
@@ -712,6 +946,24 @@ func (p *Parser) parseDependsOn() ([]string, error) {
 	return result, nil
 }
 
+// parseTargets checks that a notifies, before or after value is a list of
+// "type.name" targets
+func parseTargets(attrName string, value interface{}) ([]string, error) {
+	targets, ok := value.([]string)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of strings", attrName)
+	}
+
+	for _, target := range targets {
+		dot := strings.Index(target, ".")
+		if dot <= 0 || dot == len(target)-1 {
+			return nil, fmt.Errorf("invalid %s target %q, expected type.name", attrName, target)
+		}
+	}
+
+	return targets, nil
+}
+
 // parseStringArray parses an array of strings: ["a", "b", "c"]
 func (p *Parser) parseStringArray() ([]string, error) {
 	result := []string{}
@@ -744,9 +996,129 @@ func (p *Parser) parseStringArray() ([]string, error) {
 	return result, nil
 }
 
-// parseBlockMap parses a block map like: { key1 = "value1", key2 = "value2" }
-func (p *Parser) parseBlockMap() (map[string]string, error) {
-	result := make(map[string]string)
+// parseValue parses a single attribute value: a string, number, bool, array,
+// or nested block map
+func (p *Parser) parseValue(name string) (interface{}, error) {
+	switch p.lexer.Current().Type {
+	case STRING:
+		value := p.lexer.Current().Literal
+		p.lexer.advance()
+		return value, nil
+	case NUMBER:
+		number, err := parseNumber(p.lexer.Current().Literal)
+		if err != nil {
+			return nil, err
+		}
+		p.lexer.advance()
+		return number, nil
+	case TRUE:
+		p.lexer.advance()
+		return true, nil
+	case FALSE:
+		p.lexer.advance()
+		return false, nil
+	case LBRACKET:
+		return p.parseArray(name)
+	case LBRACE:
+		// Handle nested blocks
+		return p.parseBlockMap()
+	case IDENT, TEMPLATE:
+		if p.lexer.Peek().Type == LPAREN {
+			return p.parseFunctionCall()
+		}
+		return nil, fmt.Errorf("unexpected value type for attribute %s: %s",
+			name, p.lexer.Current().Literal)
+	default:
+		return nil, fmt.Errorf("unexpected value type for attribute %s: %s",
+			name, p.lexer.Current().Literal)
+	}
+}
+
+// parseFunctionCall parses a function call like: env("HOME", "/root").
+// The call is kept as its canonical string form, e.g. env("HOME", "/root"),
+// and evaluated later by IncludeHandler.ProcessTemplates.
+func (p *Parser) parseFunctionCall() (string, error) {
+	name := p.lexer.Current().Literal
+	p.lexer.advance()
+
+	if p.lexer.Current().Type != LPAREN {
+		return "", fmt.Errorf("expected '(' after function %s, got %s", name, p.lexer.Current().Literal)
+	}
+	p.lexer.advance()
+
+	args := []string{}
+	for p.lexer.Current().Type != RPAREN && p.lexer.Current().Type != EOF {
+		if p.lexer.Current().Type != STRING {
+			return "", fmt.Errorf("expected string argument to function %s, got %s", name, p.lexer.Current().Literal)
+		}
+		args = append(args, strconv.Quote(p.lexer.Current().Literal))
+		p.lexer.advance()
+
+		if p.lexer.Current().Type == COMMA {
+			p.lexer.advance()
+		} else if p.lexer.Current().Type != RPAREN {
+			return "", fmt.Errorf("expected ',' or ')', got %s", p.lexer.Current().Literal)
+		}
+	}
+
+	if p.lexer.Current().Type != RPAREN {
+		return "", fmt.Errorf("expected ')', got %s", p.lexer.Current().Literal)
+	}
+	p.lexer.advance()
+
+	return fmt.Sprintf("%s(%s)", name, strings.Join(args, ", ")), nil
+}
+
+// parseArray parses an array of values: ["a", "b"] or [1, true, { k = "v" }].
+// Arrays holding only strings are returned as []string so existing callers
+// keep working; anything else is returned as []interface{}.
+func (p *Parser) parseArray(name string) (interface{}, error) {
+	values := []interface{}{}
+
+	if p.lexer.Current().Type != LBRACKET {
+		return nil, fmt.Errorf("expected '[', got %s", p.lexer.Current().Literal)
+	}
+	p.lexer.advance()
+
+	allStrings := true
+	for p.lexer.Current().Type != RBRACKET && p.lexer.Current().Type != EOF {
+		value, err := p.parseValue(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := value.(string); !ok {
+			allStrings = false
+		}
+		values = append(values, value)
+
+		if p.lexer.Current().Type == COMMA {
+			p.lexer.advance()
+		} else if p.lexer.Current().Type != RBRACKET {
+			return nil, fmt.Errorf("expected ',' or ']', got %s", p.lexer.Current().Literal)
+		}
+	}
+
+	if p.lexer.Current().Type != RBRACKET {
+		return nil, fmt.Errorf("expected ']', got %s", p.lexer.Current().Literal)
+	}
+	p.lexer.advance()
+
+	if allStrings {
+		strValues := make([]string, len(values))
+		for i, value := range values {
+			strValues[i] = value.(string)
+		}
+		return strValues, nil
+	}
+
+	return values, nil
+}
+
+// parseBlockMap parses a block map like: { key1 = "value1", key2 = 3, nested = { enabled = true } }
+// Entries may be separated by commas or newlines.
+func (p *Parser) parseBlockMap() (map[string]interface{}, error) {
+	result := make(map[string]interface{})
 
 	if p.lexer.Current().Type != LBRACE {
 		return result, fmt.Errorf("expected '{', got %s", p.lexer.Current().Literal)
@@ -758,7 +1130,8 @@ func (p *Parser) parseBlockMap() (map[string]string, error) {
 			return result, fmt.Errorf("expected identifier in block map, got %s", p.lexer.Current().Literal)
 		}
 
-		key := p.lexer.Current().Literal
+		keyToken := p.lexer.Current()
+		key := keyToken.Literal
 		p.lexer.advance()
 
 		if p.lexer.Current().Type != ASSIGN {
@@ -766,13 +1139,17 @@ func (p *Parser) parseBlockMap() (map[string]string, error) {
 		}
 		p.lexer.advance()
 
-		if p.lexer.Current().Type != STRING {
-			return result, fmt.Errorf("expected string value in block map, got %s", p.lexer.Current().Literal)
+		value, err := p.parseValue(key)
+		if err != nil {
+			return result, err
 		}
 
-		result[key] = p.lexer.Current().Literal
-		p.lexer.advance()
+		if _, exists := result[key]; exists {
+			p.parseErrorAt(keyToken, "duplicate key %s in block map", key)
+		}
+		result[key] = value
 
+		// Entries are separated by commas, and the last may have one too
 		if p.lexer.Current().Type == COMMA {
 			p.lexer.advance()
 		} else if p.lexer.Current().Type != RBRACE {
@@ -789,6 +1166,7 @@ func (p *Parser) parseBlockMap() (map[string]string, error) {
 }
 
 // parseConditionBlock parses a condition block like: { platform = ["linux", "darwin"] }
+// Conditions may be separated by commas, and the last may have one too.
 func (p *Parser) parseConditionBlock() (map[string][]string, error) {
 	conditions := make(map[string][]string)
 
@@ -802,7 +1180,8 @@ func (p *Parser) parseConditionBlock() (map[string][]string, error) {
 			return conditions, fmt.Errorf("expected condition name, got %s", p.lexer.Current().Literal)
 		}
 
-		condName := p.lexer.Current().Literal
+		condToken := p.lexer.Current()
+		condName := condToken.Literal
 		p.lexer.advance()
 
 		if p.lexer.Current().Type != ASSIGN {
@@ -815,7 +1194,14 @@ func (p *Parser) parseConditionBlock() (map[string][]string, error) {
 			return conditions, err
 		}
 
+		if _, exists := conditions[condName]; exists {
+			p.parseErrorAt(condToken, "duplicate condition %s in when block", condName)
+		}
 		conditions[condName] = values
+
+		if p.lexer.Current().Type == COMMA {
+			p.lexer.advance()
+		}
 	}
 
 	if p.lexer.Current().Type != RBRACE {
@@ -1,8 +1,12 @@
 package parser
 
 import (
+	"errors"
+	"io"
+	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestLexer_Basic(t *testing.T) {
@@ -137,6 +141,84 @@ resource "test" { # This is also a comment
 	}
 }
 
+func TestBlockCommentSkipping(t *testing.T) {
+	input := `/* file "/old" {
+  content = "disabled"
+} */
+resource /* inline */ "test" {
+	attr1 = /* before the value */ "value1" /**/
+	attr2 = 123 /* spans
+	two lines */ attr3 = true // line comment /* is not a block comment
+}`
+
+	scanner := newCustomScanner(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: IDENT, Literal: "resource", Line: 4, Column: 1},
+		{Type: STRING, Literal: "test", Line: 4, Column: 23},
+		{Type: LBRACE, Literal: "{", Line: 4, Column: 30},
+		{Type: IDENT, Literal: "attr1", Line: 5, Column: 2},
+		{Type: ASSIGN, Literal: "=", Line: 5, Column: 8},
+		{Type: STRING, Literal: "value1", Line: 5, Column: 33},
+		{Type: IDENT, Literal: "attr2", Line: 6, Column: 2},
+		{Type: ASSIGN, Literal: "=", Line: 6, Column: 8},
+		{Type: NUMBER, Literal: "123", Line: 6, Column: 10},
+		{Type: IDENT, Literal: "attr3", Line: 7, Column: 15},
+		{Type: ASSIGN, Literal: "=", Line: 7, Column: 21},
+		{Type: TRUE, Literal: "true", Line: 7, Column: 23},
+		{Type: RBRACE, Literal: "}", Line: 8, Column: 1},
+		{Type: EOF, Literal: "", Line: 8, Column: 1},
+	}
+
+	for i, expected := range expectedTokens {
+		token := scanner.scanToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Fatalf("Token %d: expected %v %q, got %v %q", i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+		if token.Line != expected.Line || token.Column != expected.Column {
+			t.Errorf("Token %d (%s): expected line %d column %d, got line %d column %d",
+				i, token.Literal, expected.Line, expected.Column, token.Line, token.Column)
+		}
+	}
+
+	// An unterminated block comment is reported where it starts
+	scanner = newCustomScanner(strings.NewReader("attr = 1\n  /* never closed\n"))
+	for i := 0; i < 3; i++ {
+		scanner.scanToken()
+	}
+	token := scanner.scanToken()
+	if token.Type != ILLEGAL || token.Literal != "unterminated block comment" || token.Line != 2 || token.Column != 3 {
+		t.Errorf("Expected unterminated block comment at line 2 column 3, got %+v", token)
+	}
+	if token := scanner.scanToken(); token.Type != EOF {
+		t.Errorf("Expected EOF after an unterminated block comment, got %+v", token)
+	}
+
+	parser := NewParser(strings.NewReader(`file "/tmp/x" { /* content = "x" }`))
+	if _, err := parser.Parse(); err == nil {
+		t.Error("Expected parse error for unterminated block comment, got none")
+	}
+}
+
+func TestLexer_Offsets(t *testing.T) {
+	input := "file \"/etc/motd\" {\n  mode = 0644 /* octal */\n  content = <<EOF\nhi\nEOF\n}"
+	scanner := newCustomScanner(strings.NewReader(input))
+
+	expected := []string{"file", `"/etc/motd"`, "{", "mode", "=", "0644", "content", "=", "<<EOF\nhi\nEOF", "}"}
+	for i, text := range expected {
+		token := scanner.scanToken()
+		if got := input[token.Offset:token.End]; got != text {
+			t.Errorf("Token %d (%v %q): expected offsets %d-%d to span %q, got %q",
+				i, token.Type, token.Literal, token.Offset, token.End, text, got)
+		}
+	}
+
+	token := scanner.scanToken()
+	if token.Type != EOF || token.Offset != len(input) || token.End != len(input) {
+		t.Errorf("Expected EOF at offset %d, got %+v", len(input), token)
+	}
+}
+
 func TestLexer_AllTokenTypes(t *testing.T) {
 	t.Skip("Skipping token type test")
 	input := `
@@ -213,7 +295,6 @@ func TestParser_ParseError(t *testing.T) {
 }
 
 func TestParser_Parse_Basic(t *testing.T) {
-	t.Skip("Skipping test due to parser issues")
 	input := `resource "test" {
 attr1 = "value1"
 attr2 = 123
@@ -249,8 +330,8 @@ attr2 = 123
 	val, ok = res.Attributes["attr2"]
 	if !ok {
 		t.Errorf("Expected attribute 'attr2' but it doesn't exist")
-	} else if val != "123" {
-		t.Errorf("Expected attribute 'attr2' value '123', got %v", val)
+	} else if val != int64(123) {
+		t.Errorf("Expected attribute 'attr2' value int64(123), got %T %v", val, val)
 	}
 }
 
@@ -363,6 +444,111 @@ resource {"dep2"}
 	}
 }
 
+func TestParser_Parse_AttributeSpans(t *testing.T) {
+	input := `file "/etc/app.conf" {
+  mode    = "0644"
+  content = env("APP_CONF", "none")
+  owners  = ["root", "app"]
+  notifies = ["service.app"]
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	spans := resources[0].AttributeSpans
+	expected := map[string]string{
+		"path":    `"/etc/app.conf"`,
+		"mode":    `"0644"`,
+		"content": `env("APP_CONF", "none")`,
+		"owners":  `["root", "app"]`,
+	}
+	for name, text := range expected {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("Expected a span for %s", name)
+			continue
+		}
+		if got := input[span.Start:span.End]; got != text {
+			t.Errorf("Expected the span of %s to be %q, got %q", name, text, got)
+		}
+	}
+	if _, ok := spans["notifies"]; ok {
+		t.Error("Expected no span for notifies, which isn't an attribute")
+	}
+	if mode := spans["mode"]; mode.Line != 2 || mode.Column != 13 {
+		t.Errorf("Expected mode's value at line 2, column 13, got line %d, column %d", mode.Line, mode.Column)
+	}
+}
+
+func TestParser_Parse_Notifies(t *testing.T) {
+	input := `file "/etc/nginx/nginx.conf" {
+  content = "worker_processes 1;"
+  notifies = ["service.nginx", "exec.reload-cache"]
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if len(resources) != 1 {
+		t.Fatalf("Expected 1 resource, got %d", len(resources))
+	}
+
+	res := resources[0]
+	if !reflect.DeepEqual(res.Notifies, []string{"service.nginx", "exec.reload-cache"}) {
+		t.Errorf("Expected notifies [service.nginx exec.reload-cache], got %v", res.Notifies)
+	}
+	if _, ok := res.Attributes["notifies"]; ok {
+		t.Error("Expected notifies not to be stored as an attribute")
+	}
+
+	invalid := []string{
+		`file "/a" { notifies = "service.nginx" }`,
+		`file "/a" { notifies = ["nginx"] }`,
+		`file "/a" { notifies = ["service."] }`,
+	}
+	for _, input := range invalid {
+		parser := NewParser(strings.NewReader(input))
+		if _, err := parser.Parse(); err == nil && len(parser.Errors()) == 0 {
+			t.Errorf("Expected error for %s, got nil", input)
+		}
+	}
+}
+
+func TestParser_Parse_Ordering(t *testing.T) {
+	input := `service "app" {
+  before = ["service.proxy"]
+  after  = ["file.config", "package.app"]
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	res := resources[0]
+	if !reflect.DeepEqual(res.Before, []string{"service.proxy"}) {
+		t.Errorf("Expected before [service.proxy], got %v", res.Before)
+	}
+	if !reflect.DeepEqual(res.After, []string{"file.config", "package.app"}) {
+		t.Errorf("Expected after [file.config package.app], got %v", res.After)
+	}
+	if _, ok := res.Attributes["after"]; ok {
+		t.Error("Expected after not to be stored as an attribute")
+	}
+
+	parser = NewParser(strings.NewReader(`service "app" { after = ["config"] }`))
+	if _, err := parser.Parse(); err == nil || !strings.Contains(strings.Join(parser.Errors(), "\n"), `invalid after target "config"`) {
+		t.Errorf("Expected invalid after target error, got %v", parser.Errors())
+	}
+}
+
 func TestParser_Parse_When(t *testing.T) {
 	input := `resource "test" {
 when = {
@@ -463,11 +649,13 @@ array = ["value1", "value2", "value3"]
 }
 
 func TestParser_Parse_BlockMap(t *testing.T) {
-	t.Skip("Skipping test due to parser issues")
 	input := `resource "test" {
 map = {
-key1 = "value1"
-key2 = "value2"
+key1 = "value1",
+key2 = "value2",
+retries = 3,
+force = true,
+nested = { ratio = 0.5, tags = ["a", "b"] }
 }
 }`
 	
@@ -488,21 +676,95 @@ key2 = "value2"
 		t.Fatalf("Expected attribute 'map' but it doesn't exist")
 	}
 	
-	mapVal, ok := blockMap.(map[string]string)
+	mapVal, ok := blockMap.(map[string]interface{})
 	if !ok {
-		t.Fatalf("Expected map to be map[string]string, got %T", blockMap)
+		t.Fatalf("Expected map to be map[string]interface{}, got %T", blockMap)
 	}
 	
-	if len(mapVal) != 2 {
-		t.Fatalf("Expected map length 2, got %d", len(mapVal))
+	if len(mapVal) != 5 {
+		t.Fatalf("Expected map length 5, got %d", len(mapVal))
 	}
 	
 	if mapVal["key1"] != "value1" {
-		t.Errorf("Expected map['key1'] to be 'value1', got %s", mapVal["key1"])
+		t.Errorf("Expected map['key1'] to be 'value1', got %v", mapVal["key1"])
 	}
 	
 	if mapVal["key2"] != "value2" {
-		t.Errorf("Expected map['key2'] to be 'value2', got %s", mapVal["key2"])
+		t.Errorf("Expected map['key2'] to be 'value2', got %v", mapVal["key2"])
+	}
+
+	if mapVal["retries"] != int64(3) {
+		t.Errorf("Expected map['retries'] to be int64(3), got %T %v", mapVal["retries"], mapVal["retries"])
+	}
+
+	if mapVal["force"] != true {
+		t.Errorf("Expected map['force'] to be true, got %T %v", mapVal["force"], mapVal["force"])
+	}
+
+	nested, ok := mapVal["nested"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected map['nested'] to be a map, got %T", mapVal["nested"])
+	}
+
+	if nested["ratio"] != 0.5 {
+		t.Errorf("Expected nested['ratio'] to be 0.5, got %v", nested["ratio"])
+	}
+
+	tags, ok := nested["tags"].([]string)
+	if !ok || len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Errorf("Expected nested['tags'] to be [a b], got %v", nested["tags"])
+	}
+}
+
+func TestParser_Parse_TrailingCommas(t *testing.T) {
+	input := `resource "test" {
+array = ["a", "b",]
+map = { k = "v", }
+when = { platform = ["linux", "darwin",], arch = ["amd64"], }
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	res := resources[0]
+	if !reflect.DeepEqual(res.Attributes["array"], []string{"a", "b"}) {
+		t.Errorf("Expected array [a b], got %v", res.Attributes["array"])
+	}
+	if !reflect.DeepEqual(res.Attributes["map"], map[string]interface{}{"k": "v"}) {
+		t.Errorf("Expected map {k = v}, got %v", res.Attributes["map"])
+	}
+	wantConditions := map[string][]string{"platform": {"linux", "darwin"}, "arch": {"amd64"}}
+	if !reflect.DeepEqual(res.Conditions, wantConditions) {
+		t.Errorf("Expected conditions %v, got %v", wantConditions, res.Conditions)
+	}
+
+	// A comma still has to follow a value
+	invalid := []string{
+		`resource "test" { array = ["a",,] }`,
+		`resource "test" { array = [,] }`,
+		`resource "test" { map = { k = "v",, } }`,
+		`resource "test" { map = { , } }`,
+		`resource "test" { when = { platform = ["linux"],, } }`,
+	}
+	for _, input := range invalid {
+		parser := NewParser(strings.NewReader(input))
+		if _, err := parser.Parse(); err == nil {
+			t.Errorf("Expected error for %s, got nil", input)
+		}
+	}
+}
+
+func TestParser_Parse_BlockMapSeparator(t *testing.T) {
+	// Entries on one line need a comma between them
+	parser := NewParser(strings.NewReader(`resource "test" { m = { a = "x" b = "y" } }`))
+	if _, err := parser.Parse(); err == nil {
+		t.Fatal("Expected error for block map entries without a comma, got nil")
+	}
+	if problems := parser.Errors(); len(problems) == 0 || !strings.Contains(problems[0], "expected ',' or '}', got b") {
+		t.Errorf("Expected a missing separator error, got %v", problems)
 	}
 }
 
@@ -550,15 +812,13 @@ func TestParser_Parse_Error(t *testing.T) {
 }
 
 func TestParser_ParseBlockMap_Direct(t *testing.T) {
-	t.Skip("Skipping failing test")
 	// Test directly rather than through full parser
 	parser := &Parser{}
 	input := strings.NewReader(`{
-		key1 = "value1"
+		key1 = "value1",
 		key2 = "value2"
 	}`)
-	parser.lexer = NewLexer(input)
-	parser.lexer.advance() // Move to the first token (LBRACE)
+	parser.lexer = NewLexer(input) // The lexer starts on the first token (LBRACE)
 	
 	result, err := parser.parseBlockMap()
 	if err != nil {
@@ -570,11 +830,28 @@ func TestParser_ParseBlockMap_Direct(t *testing.T) {
 	}
 	
 	if result["key1"] != "value1" {
-		t.Errorf("Expected result[\"key1\"] to be \"value1\", got %s", result["key1"])
+		t.Errorf("Expected result[\"key1\"] to be \"value1\", got %v", result["key1"])
 	}
 	
 	if result["key2"] != "value2" {
-		t.Errorf("Expected result[\"key2\"] to be \"value2\", got %s", result["key2"])
+		t.Errorf("Expected result[\"key2\"] to be \"value2\", got %v", result["key2"])
+	}
+}
+
+func TestParser_Parse_MixedArray(t *testing.T) {
+	input := `resource "test" {
+ports = [80, 443]
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	ports, ok := resources[0].Attributes["ports"].([]interface{})
+	if !ok || len(ports) != 2 || ports[0] != int64(80) || ports[1] != int64(443) {
+		t.Errorf("Expected ports to be [80 443], got %T %v", resources[0].Attributes["ports"], resources[0].Attributes["ports"])
 	}
 }
 
@@ -635,4 +912,408 @@ func TestParser_IncludePlatform(t *testing.T) {
 	if windows, ok := resources[0].Attributes["windows"].(string); !ok || windows != "windows/config.cfg" {
 		t.Errorf("Expected windows path to be 'windows/config.cfg', got '%v'", resources[0].Attributes["windows"])
 	}
-}
\ No newline at end of file
+}
+func TestParser_Parse_LargeInput(t *testing.T) {
+	// Pad the block with comments so that tokens land on and past the
+	// boundaries of any fixed-size read buffer
+	var sb strings.Builder
+	sb.WriteString("file \"/tmp/large\" {\n")
+	for sb.Len() < 4096 {
+		sb.WriteString("  // padding comment to push attributes past the 4KB mark\n")
+	}
+	longValue := strings.Repeat("abcdefghij", 150)
+	sb.WriteString("  owner_attribute_with_a_long_name = \"root\"\n")
+	sb.WriteString("  content = \"" + longValue + "\"\n")
+	sb.WriteString("  mode = \"0644\"\n")
+	sb.WriteString("}\n")
+
+	if sb.Len() <= 4096 {
+		t.Fatalf("Expected input larger than 4KB, got %d bytes", sb.Len())
+	}
+
+	parser := NewParser(strings.NewReader(sb.String()))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if len(resources) != 1 {
+		t.Fatalf("Expected 1 resource, got %d", len(resources))
+	}
+
+	res := resources[0]
+	if res.Attributes["owner_attribute_with_a_long_name"] != "root" {
+		t.Errorf("Expected long attribute name to map to 'root', got %v", res.Attributes["owner_attribute_with_a_long_name"])
+	}
+
+	if res.Attributes["content"] != longValue {
+		t.Errorf("Expected content of length %d to survive intact, got %v", len(longValue), res.Attributes["content"])
+	}
+
+	if res.Attributes["mode"] != "0644" {
+		t.Errorf("Expected mode '0644', got %v", res.Attributes["mode"])
+	}
+}
+
+func TestParser_Parse_ReadError(t *testing.T) {
+	input := io.MultiReader(strings.NewReader("file \"/tmp/a\" {}\n"), iotest.ErrReader(errors.New("disk failure")))
+
+	parser := NewParser(input)
+	_, err := parser.Parse()
+	if err == nil || !strings.Contains(err.Error(), "disk failure") {
+		t.Errorf("Expected the read error, got %v", err)
+	}
+}
+
+func TestParser_Parse_BareInclude(t *testing.T) {
+	input := `include "config/default/*.cfg"
+include_platform {
+  linux = "config/linux/*.cfg"
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if len(resources) != 2 {
+		t.Fatalf("Expected 2 resources, got %d", len(resources))
+	}
+
+	if resources[0].Type != "include" || resources[0].Attributes["path"] != "config/default/*.cfg" {
+		t.Errorf("Expected include of 'config/default/*.cfg', got %s %v", resources[0].Type, resources[0].Attributes["path"])
+	}
+
+	if resources[1].Type != "include_platform" {
+		t.Errorf("Expected include_platform resource, got %s", resources[1].Type)
+	}
+}
+
+func TestParser_Parse_Booleans(t *testing.T) {
+	input := `service "nginx" {
+  enabled = true
+  managed = false
+  state   = "true"
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if len(resources) != 1 {
+		t.Fatalf("Expected 1 resource, got %d", len(resources))
+	}
+
+	res := resources[0]
+	if enabled, ok := res.Attributes["enabled"].(bool); !ok || !enabled {
+		t.Errorf("Expected 'enabled' to be bool true, got %T %v", res.Attributes["enabled"], res.Attributes["enabled"])
+	}
+
+	if managed, ok := res.Attributes["managed"].(bool); !ok || managed {
+		t.Errorf("Expected 'managed' to be bool false, got %T %v", res.Attributes["managed"], res.Attributes["managed"])
+	}
+
+	// Quoted booleans remain strings
+	if state, ok := res.Attributes["state"].(string); !ok || state != "true" {
+		t.Errorf("Expected 'state' to be string \"true\", got %T %v", res.Attributes["state"], res.Attributes["state"])
+	}
+}
+
+func TestParser_Parse_Numbers(t *testing.T) {
+	input := `resource "test" {
+  port    = 8080
+  ratio   = 0.75
+  big     = 9223372036854775807
+  timeout = 1.5
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if len(resources) != 1 {
+		t.Fatalf("Expected 1 resource, got %d", len(resources))
+	}
+
+	expected := map[string]interface{}{
+		"port":    int64(8080),
+		"ratio":   0.75,
+		"big":     int64(9223372036854775807),
+		"timeout": 1.5,
+	}
+
+	for key, want := range expected {
+		got := resources[0].Attributes[key]
+		if got != want {
+			t.Errorf("Expected %s to be %T %v, got %T %v", key, want, want, got, got)
+		}
+	}
+}
+
+func TestParser_Parse_MalformedNumber(t *testing.T) {
+	tests := []string{
+		`resource "test" {
+  version = 1.2.3
+}`,
+		`resource "test" {
+  huge = 99999999999999999999
+}`,
+	}
+
+	for _, input := range tests {
+		parser := NewParser(strings.NewReader(input))
+		_, err := parser.Parse()
+		if err == nil {
+			t.Errorf("Expected error for input %q, got none", input)
+			continue
+		}
+
+		errors := parser.Errors()
+		if len(errors) == 0 || !strings.Contains(errors[0], "Line 2") {
+			t.Errorf("Expected error to point at line 2, got %v", errors)
+		}
+	}
+}
+
+func TestLexer_StringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"line1\nline2"`, "line1\nline2"},
+		{`"col1\tcol2"`, "col1\tcol2"},
+		{`"crlf\r\n"`, "crlf\r\n"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"C:\\Logs\\app"`, `C:\Logs\app`},
+		{`"plain"`, "plain"},
+	}
+
+	for _, tt := range tests {
+		scanner := newCustomScanner(strings.NewReader(tt.input))
+		token := scanner.scanToken()
+
+		if token.Type != STRING {
+			t.Errorf("Input %s: expected STRING token, got %v (%s)", tt.input, token.Type, token.Literal)
+			continue
+		}
+
+		if token.Literal != tt.expected {
+			t.Errorf("Input %s: expected %q, got %q", tt.input, tt.expected, token.Literal)
+		}
+	}
+}
+
+func TestLexer_StringEscapeErrors(t *testing.T) {
+	// Unknown escape sequences are reported but the string is still consumed
+	scanner := newCustomScanner(strings.NewReader(`"bad \q escape" next`))
+	token := scanner.scanToken()
+	if token.Type != ILLEGAL || !strings.Contains(token.Literal, "illegal escape") {
+		t.Errorf("Expected illegal escape error token, got %v %q", token.Type, token.Literal)
+	}
+
+	token = scanner.scanToken()
+	if token.Type != IDENT || token.Literal != "next" {
+		t.Errorf("Expected scanning to resume after the string, got %v %q", token.Type, token.Literal)
+	}
+
+	// Unterminated strings are reported at EOF
+	scanner = newCustomScanner(strings.NewReader(`"never closed`))
+	token = scanner.scanToken()
+	if token.Type != ILLEGAL || !strings.Contains(token.Literal, "unterminated string") {
+		t.Errorf("Expected unterminated string error token, got %v %q", token.Type, token.Literal)
+	}
+
+	parser := NewParser(strings.NewReader(`file "/tmp/x" {
+  content = "unterminated
+}`))
+	if _, err := parser.Parse(); err == nil {
+		t.Error("Expected parse error for unterminated string, got none")
+	}
+}
+
+func TestLexer_SignedNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Token
+	}{
+		{"-5", []Token{{Type: NUMBER, Literal: "-5"}}},
+		{"-0.5", []Token{{Type: NUMBER, Literal: "-0.5"}}},
+		{"+3", []Token{{Type: NUMBER, Literal: "+3"}}},
+		{"-", []Token{{Type: ILLEGAL, Literal: "-"}}},
+		{"- 5", []Token{{Type: ILLEGAL, Literal: "-"}, {Type: NUMBER, Literal: "5"}}},
+		{"[1, -2]", []Token{{Type: LBRACKET, Literal: "["}, {Type: NUMBER, Literal: "1"}, {Type: COMMA, Literal: ","}, {Type: NUMBER, Literal: "-2"}, {Type: RBRACKET, Literal: "]"}}},
+		// After an operand a sign is left for use as an operator
+		{"4-1", []Token{{Type: NUMBER, Literal: "4"}, {Type: ILLEGAL, Literal: "-"}, {Type: NUMBER, Literal: "1"}}},
+	}
+
+	for _, tt := range tests {
+		scanner := newCustomScanner(strings.NewReader(tt.input))
+		for i, expected := range tt.expected {
+			token := scanner.scanToken()
+			if token.Type != expected.Type || token.Literal != expected.Literal {
+				t.Errorf("Input %q token %d: expected %v %q, got %v %q", tt.input, i, expected.Type, expected.Literal, token.Type, token.Literal)
+			}
+		}
+		if token := scanner.scanToken(); token.Type != EOF {
+			t.Errorf("Input %q: expected EOF, got %v %q", tt.input, token.Type, token.Literal)
+		}
+	}
+
+	parser := NewParser(strings.NewReader(`sysctl "vm.swappiness" {
+  offset = -5
+  ratio  = -0.5
+  limits = [-1, +2]
+}`))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	attributes := resources[0].Attributes
+	if attributes["offset"] != int64(-5) || attributes["ratio"] != -0.5 {
+		t.Errorf("Expected negative numbers, got %v", attributes)
+	}
+	if !reflect.DeepEqual(attributes["limits"], []interface{}{int64(-1), int64(2)}) {
+		t.Errorf("Expected signed numbers in a list, got %#v", attributes["limits"])
+	}
+}
+
+func TestParser_Parse_Heredoc(t *testing.T) {
+	input := `file "/etc/motd" {
+  content = <<EOF
+Welcome to $host
+  indented line
+last line
+EOF
+  mode = "0644"
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if len(resources) != 1 {
+		t.Fatalf("Expected 1 resource, got %d", len(resources))
+	}
+
+	expected := "Welcome to $host\n  indented line\nlast line\n"
+	if content := resources[0].Attributes["content"]; content != expected {
+		t.Errorf("Expected heredoc content %q, got %q", expected, content)
+	}
+
+	if mode := resources[0].Attributes["mode"]; mode != "0644" {
+		t.Errorf("Expected parsing to continue after heredoc, got mode %v", mode)
+	}
+}
+
+func TestParser_Parse_HeredocUnterminated(t *testing.T) {
+	input := `file "/etc/motd" {
+  content = <<EOF
+never closed
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	if _, err := parser.Parse(); err == nil {
+		t.Error("Expected error for unterminated heredoc, got none")
+	}
+}
+
+func TestParser_Parse_DuplicateAttributes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "Resource attribute",
+			input: `file "/tmp/x" {
+  mode = "0644"
+  owner = "root"
+  mode = "0600"
+}`,
+			expected: "Line 4, Column 3: duplicate attribute mode",
+		},
+		{
+			name: "Block map key",
+			input: `file "/tmp/x" {
+  options = {
+    force = true,
+    force = false
+  }
+}`,
+			expected: "Line 4, Column 5: duplicate key force",
+		},
+		{
+			name: "Condition name",
+			input: `file "/tmp/x" {
+  when = {
+    platform = ["linux"]
+    platform = ["darwin"]
+  }
+}`,
+			expected: "Line 4, Column 5: duplicate condition platform",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser(strings.NewReader(tt.input))
+			resources, err := parser.Parse()
+			if err == nil {
+				t.Fatal("Expected duplicate error, got none")
+			}
+
+			// Parsing continues past the duplicate
+			if len(resources) != 1 {
+				t.Errorf("Expected the resource to still be parsed, got %d resources", len(resources))
+			}
+
+			errors := parser.Errors()
+			if len(errors) != 1 || !strings.Contains(errors[0], tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, errors)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_ExplicitPathIsNotDuplicate(t *testing.T) {
+	input := `file "web_root" {
+  path = "/var/www/html"
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if resources[0].Attributes["path"] != "/var/www/html" {
+		t.Errorf("Expected explicit path to win, got %v", resources[0].Attributes["path"])
+	}
+}
+
+func TestParser_FunctionCallValues(t *testing.T) {
+	input := `file "/tmp/x" {
+  content = template("motd")
+  owner   = env("OWNER", "root")
+}`
+
+	parser := NewParser(strings.NewReader(input))
+	resources, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if resources[0].Attributes["content"] != `template("motd")` {
+		t.Errorf("Expected template call to be kept for evaluation, got %v", resources[0].Attributes["content"])
+	}
+	if resources[0].Attributes["owner"] != `env("OWNER", "root")` {
+		t.Errorf("Expected env call to be kept for evaluation, got %v", resources[0].Attributes["owner"])
+	}
+}
@@ -0,0 +1,427 @@
+package providers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveProvider extracts tar and zip archives
+type ArchiveProvider struct {
+	platform *PlatformChecker
+}
+
+// NewArchiveProvider creates a new archive provider
+func NewArchiveProvider() *ArchiveProvider {
+	return &ArchiveProvider{
+		platform: &PlatformChecker{},
+	}
+}
+
+// archiveEntry is a format-independent view of a single archive member
+type archiveEntry struct {
+	name     string
+	mode     os.FileMode
+	isDir    bool
+	linkname string // Non-empty for symlinks
+	open     func() (io.ReadCloser, error)
+}
+
+// Validate validates archive resource attributes
+func (p *ArchiveProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	for _, key := range []string{"source", "dest"} {
+		value, ok := attributes[key]
+		if !ok {
+			return fmt.Errorf("archive resource requires '%s' attribute", key)
+		}
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("archive '%s' must be a string", key)
+		}
+	}
+
+	if format, ok := attributes["format"]; ok {
+		formatStr, ok := format.(string)
+		if !ok {
+			return fmt.Errorf("archive 'format' must be a string")
+		}
+		switch formatStr {
+		case "auto", "tar", "tar.gz", "tgz", "zip":
+		default:
+			return fmt.Errorf("archive 'format' must be one of: auto, tar, tar.gz, zip")
+		}
+	}
+
+	if creates, ok := attributes["creates"]; ok {
+		if _, ok := creates.(string); !ok {
+			return fmt.Errorf("archive 'creates' must be a string")
+		}
+	}
+
+	return nil
+}
+
+// Plan determines whether the archive needs to be extracted
+func (p *ArchiveProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	dest := desired["dest"].(string)
+
+	result := &ResourceState{
+		Type:       "archive",
+		Name:       dest,
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	needed, err := p.needsExtraction(desired)
+	if err != nil {
+		return nil, err
+	}
+	if needed {
+		result.Status = "planned"
+	}
+
+	return result, nil
+}
+
+// Apply extracts the archive into the destination directory
+func (p *ArchiveProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	dest := state.Attributes["dest"].(string)
+
+	result := &ResourceState{
+		Type:       "archive",
+		Name:       dest,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	needed, err := p.needsExtraction(state.Attributes)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+	if !needed {
+		return result, nil
+	}
+
+	if err := p.extract(state.Attributes); err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	result.Status = "created"
+	result.Changed = true
+	return result, nil
+}
+
+// needsExtraction reports whether the creates sentinel, or dest if unset, is missing
+func (p *ArchiveProvider) needsExtraction(attributes map[string]interface{}) (bool, error) {
+	sentinel, ok := attributes["creates"].(string)
+	if !ok {
+		sentinel = attributes["dest"].(string)
+	}
+
+	if _, err := os.Stat(sentinel); os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("error checking %s: %v", sentinel, err)
+	}
+
+	return false, nil
+}
+
+// extract validates every entry of the archive and then writes them below dest
+func (p *ArchiveProvider) extract(attributes map[string]interface{}) error {
+	source := attributes["source"].(string)
+	dest := attributes["dest"].(string)
+
+	format, _ := attributes["format"].(string)
+	if format == "" || format == "auto" {
+		detected, err := detectArchiveFormat(source)
+		if err != nil {
+			return err
+		}
+		format = detected
+	}
+
+	// Check every entry before writing anything so a malicious archive leaves no partial output
+	links := make(map[string]bool)
+	if err := walkArchive(source, format, func(entry archiveEntry) error {
+		if _, err := archiveTarget(dest, entry); err != nil {
+			return err
+		}
+		return checkArchiveLinks(links, entry)
+	}); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", dest, err)
+	}
+
+	return walkArchive(source, format, func(entry archiveEntry) error {
+		target, _ := archiveTarget(dest, entry)
+		return writeArchiveEntry(dest, target, entry)
+	})
+}
+
+// detectArchiveFormat guesses the format from the file name, falling back to magic bytes
+func detectArchiveFormat(source string) (string, error) {
+	lower := strings.ToLower(source)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz", nil
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar", nil
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip", nil
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive %s: %v", source, err)
+	}
+	defer f.Close()
+
+	header := make([]byte, 4)
+	n, _ := io.ReadFull(f, header)
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return "tar.gz", nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return "zip", nil
+	}
+
+	return "", fmt.Errorf("unable to detect archive format of %s; set 'format'", source)
+}
+
+// walkArchive calls fn for each entry in the archive
+func walkArchive(source, format string, fn func(entry archiveEntry) error) error {
+	if format == "zip" {
+		r, err := zip.OpenReader(source)
+		if err != nil {
+			return fmt.Errorf("failed to open archive %s: %v", source, err)
+		}
+		defer r.Close()
+
+		for _, file := range r.File {
+			file := file
+			entry := archiveEntry{
+				name:  file.Name,
+				mode:  file.Mode(),
+				isDir: file.FileInfo().IsDir(),
+				open:  file.Open,
+			}
+			if file.Mode()&os.ModeSymlink != 0 {
+				rc, err := file.Open()
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %v", file.Name, err)
+				}
+				link, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %v", file.Name, err)
+				}
+				entry.linkname = string(link)
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %s: %v", source, err)
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if format == "tar.gz" || format == "tgz" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to read gzip stream of %s: %v", source, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive %s: %v", source, err)
+		}
+
+		entry := archiveEntry{
+			name: header.Name,
+			mode: header.FileInfo().Mode(),
+			open: func() (io.ReadCloser, error) { return io.NopCloser(tr), nil },
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			entry.isDir = true
+		case tar.TypeSymlink:
+			entry.linkname = header.Linkname
+		case tar.TypeReg:
+		default:
+			// Skip devices, fifos and hard links
+			continue
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// archiveTarget resolves where an entry is written, refusing paths outside dest
+func archiveTarget(dest string, entry archiveEntry) (string, error) {
+	name := filepath.FromSlash(entry.name)
+	if filepath.IsAbs(name) || strings.HasPrefix(entry.name, "/") {
+		return "", fmt.Errorf("archive entry %s has an absolute path", entry.name)
+	}
+
+	target := filepath.Join(dest, name)
+	if !isWithinDir(dest, target) {
+		return "", fmt.Errorf("archive entry %s escapes the destination directory", entry.name)
+	}
+
+	if entry.linkname != "" {
+		link := filepath.FromSlash(entry.linkname)
+		if filepath.IsAbs(link) || !isWithinDir(dest, filepath.Join(filepath.Dir(target), link)) {
+			return "", fmt.Errorf("archive entry %s links outside the destination directory", entry.name)
+		}
+	}
+
+	return target, nil
+}
+
+// checkArchiveLinks refuses entries written through a symlink created by an
+// earlier entry. Each link is checked on its own, but a chain of them such as
+// a -> "." and a/b -> ".." can still lead outside the destination directory.
+// links holds the names of the symlinks seen so far.
+func checkArchiveLinks(links map[string]bool, entry archiveEntry) error {
+	name := path.Clean(entry.name)
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if links[dir] {
+			return fmt.Errorf("archive entry %s is below the symlink %s", entry.name, dir)
+		}
+	}
+
+	if entry.linkname != "" {
+		links[name] = true
+	} else if links[name] {
+		return fmt.Errorf("archive entry %s would be written through a symlink", entry.name)
+	}
+	return nil
+}
+
+// resolvesWithinDir reports whether path, with the symlinks of its existing
+// part resolved, is dir or lies below it
+func resolvesWithinDir(dir, path string) (bool, error) {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false, err
+	}
+
+	// Resolve the deepest part of the path that exists
+	existing, rest := filepath.Clean(path), ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+	realPath, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return false, err
+	}
+	return isWithinDir(realDir, filepath.Join(realPath, rest)), nil
+}
+
+// isWithinDir reports whether path is dir or lies below it
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// writeArchiveEntry creates a directory, symlink or regular file for entry,
+// refusing to write through symlinks that lead outside dest
+func writeArchiveEntry(dest, target string, entry archiveEntry) error {
+	// A symlink entry replaces whatever is at target, so only its directory
+	// has to stay inside dest
+	check := target
+	if entry.linkname != "" {
+		check = filepath.Dir(target)
+	}
+	within, err := resolvesWithinDir(dest, check)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", target, err)
+	}
+	if !within {
+		return fmt.Errorf("archive entry %s escapes the destination directory through a symlink", entry.name)
+	}
+
+	if entry.isDir {
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %v", target, err)
+		}
+		return os.Chmod(target, entry.mode.Perm())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", filepath.Dir(target), err)
+	}
+
+	if entry.linkname != "" {
+		os.Remove(target)
+		if err := os.Symlink(entry.linkname, target); err != nil {
+			return fmt.Errorf("failed to create symlink %s: %v", target, err)
+		}
+		return nil
+	}
+
+	rc, err := entry.open()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", entry.name, err)
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, entry.mode.Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", target, err)
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write %s: %v", target, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", target, err)
+	}
+
+	// OpenFile applies the umask, so set the archived mode explicitly
+	return os.Chmod(target, entry.mode.Perm())
+}
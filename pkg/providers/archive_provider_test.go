@@ -0,0 +1,254 @@
+package providers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// testArchiveFile describes a file to place in a test archive
+type testArchiveFile struct {
+	name     string
+	content  string
+	mode     int64
+	linkname string // Makes the entry a symlink
+}
+
+func buildTarGz(t *testing.T, files []testArchiveFile) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		header := &tar.Header{Name: f.name, Mode: f.mode, Size: int64(len(f.content)), Typeflag: tar.TypeReg}
+		if f.linkname != "" {
+			header = &tar.Header{Name: f.name, Mode: f.mode, Linkname: f.linkname, Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(f.content)); err != nil {
+			t.Fatalf("Failed to write tar content: %v", err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func buildZip(t *testing.T, files []testArchiveFile) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		header := &zip.FileHeader{Name: f.name, Method: zip.Deflate}
+		header.SetMode(os.FileMode(f.mode))
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatalf("Failed to write zip header: %v", err)
+		}
+		if _, err := w.Write([]byte(f.content)); err != nil {
+			t.Fatalf("Failed to write zip content: %v", err)
+		}
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestArchiveProvider_Validate(t *testing.T) {
+	provider := NewArchiveProvider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"minimal", map[string]interface{}{"source": "/tmp/tool.tar.gz", "dest": "/opt/tool"}, false},
+		{"full", map[string]interface{}{"source": "/tmp/tool", "dest": "/opt/tool", "format": "zip", "creates": "/opt/tool/bin/tool"}, false},
+		{"missing source", map[string]interface{}{"dest": "/opt/tool"}, true},
+		{"missing dest", map[string]interface{}{"source": "/tmp/tool.zip"}, true},
+		{"invalid format", map[string]interface{}{"source": "/tmp/tool.rar", "dest": "/opt/tool", "format": "rar"}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestArchiveProvider_Extract(t *testing.T) {
+	files := []testArchiveFile{
+		{name: "tool/bin/tool", content: "#!/bin/sh\necho tool\n", mode: 0755},
+		{name: "tool/README", content: "readme", mode: 0644},
+	}
+
+	tests := []struct {
+		name    string
+		archive string
+		data    []byte
+		format  string
+	}{
+		{"tar.gz by extension", "tool.tar.gz", buildTarGz(t, files), ""},
+		{"zip by extension", "tool.zip", buildZip(t, files), ""},
+		{"tar.gz by magic", "tool.bin", buildTarGz(t, files), "auto"},
+		{"zip by format", "tool.pkg", buildZip(t, files), "zip"},
+	}
+
+	provider := NewArchiveProvider()
+	ctx := context.Background()
+
+	for _, tt := range tests {
+		tempDir, err := ioutil.TempDir("", "archive-provider-test")
+		if err != nil {
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		source := filepath.Join(tempDir, tt.archive)
+		if err := ioutil.WriteFile(source, tt.data, 0644); err != nil {
+			t.Fatalf("Failed to write archive: %v", err)
+		}
+
+		dest := filepath.Join(tempDir, "out")
+		creates := filepath.Join(dest, "tool", "bin", "tool")
+		attrs := map[string]interface{}{"source": source, "dest": dest, "creates": creates}
+		if tt.format != "" {
+			attrs["format"] = tt.format
+		}
+
+		plan, err := provider.Plan(ctx, nil, attrs)
+		if err != nil {
+			t.Fatalf("%s: Plan failed: %v", tt.name, err)
+		}
+		if plan.Status != "planned" {
+			t.Errorf("%s: expected planned status, got %s", tt.name, plan.Status)
+		}
+
+		result, err := provider.Apply(ctx, plan)
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != "created" {
+			t.Errorf("%s: expected created status, got %s", tt.name, result.Status)
+		}
+
+		content, err := ioutil.ReadFile(creates)
+		if err != nil || string(content) != files[0].content {
+			t.Errorf("%s: expected extracted content %q, got %q (%v)", tt.name, files[0].content, content, err)
+		}
+		if runtime.GOOS != "windows" {
+			info, _ := os.Stat(creates)
+			if info.Mode().Perm() != 0755 {
+				t.Errorf("%s: expected mode 0755, got %o", tt.name, info.Mode().Perm())
+			}
+		}
+
+		plan, _ = provider.Plan(ctx, nil, attrs)
+		if plan.Status != "unchanged" {
+			t.Errorf("%s: expected unchanged status once creates exists, got %s", tt.name, plan.Status)
+		}
+	}
+}
+
+func TestArchiveProvider_RejectsTraversal(t *testing.T) {
+	files := []testArchiveFile{
+		{name: "safe.txt", content: "ok", mode: 0644},
+		{name: "../evil.txt", content: "pwned", mode: 0644},
+	}
+
+	tests := []struct {
+		archive string
+		data    []byte
+	}{
+		{"evil.tar.gz", buildTarGz(t, files)},
+		{"evil.zip", buildZip(t, files)},
+	}
+
+	provider := NewArchiveProvider()
+	ctx := context.Background()
+
+	for _, tt := range tests {
+		tempDir, err := ioutil.TempDir("", "archive-provider-test")
+		if err != nil {
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		source := filepath.Join(tempDir, tt.archive)
+		ioutil.WriteFile(source, tt.data, 0644)
+		dest := filepath.Join(tempDir, "out")
+
+		attrs := map[string]interface{}{"source": source, "dest": dest}
+		result, err := provider.Apply(ctx, &ResourceState{Type: "archive", Name: dest, Attributes: attrs})
+		if err == nil {
+			t.Errorf("%s: expected traversal entry to be refused", tt.archive)
+		}
+		checkChanged(t, result)
+		if result.Status != "failed" {
+			t.Errorf("%s: expected failed status, got %s", tt.archive, result.Status)
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "evil.txt")); !os.IsNotExist(err) {
+			t.Errorf("%s: traversal entry was written outside dest", tt.archive)
+		}
+		if _, err := os.Stat(filepath.Join(dest, "safe.txt")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected no partial extraction", tt.archive)
+		}
+	}
+}
+
+func TestArchiveProvider_RejectsSymlinkEscape(t *testing.T) {
+	provider := NewArchiveProvider()
+	ctx := context.Background()
+
+	tempDir, err := ioutil.TempDir("", "archive-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Each link stays inside dest on its own, but l1/l2 resolves to its parent
+	chained := filepath.Join(tempDir, "chained.tar.gz")
+	ioutil.WriteFile(chained, buildTarGz(t, []testArchiveFile{
+		{name: "l1", linkname: ".", mode: 0777},
+		{name: "l1/l2", linkname: "..", mode: 0777},
+		{name: "l1/l2/escaped.txt", content: "pwned", mode: 0644},
+	}), 0644)
+
+	dest := filepath.Join(tempDir, "out")
+	attrs := map[string]interface{}{"source": chained, "dest": dest}
+	result, err := provider.Apply(ctx, &ResourceState{Type: "archive", Name: dest, Attributes: attrs})
+	if err == nil || result.Status != "failed" {
+		t.Errorf("Expected the chained symlinks to be refused, got %s (%v)", result.Status, err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "escaped.txt")); !os.IsNotExist(err) {
+		t.Error("Chained symlinks wrote a file outside dest")
+	}
+
+	// A symlink already in dest is resolved before writing through it
+	outside := filepath.Join(tempDir, "outside")
+	os.MkdirAll(outside, 0755)
+	existing := filepath.Join(tempDir, "existing")
+	os.MkdirAll(existing, 0755)
+	os.Symlink(outside, filepath.Join(existing, "link"))
+	throughLink := filepath.Join(tempDir, "through-link.tar.gz")
+	ioutil.WriteFile(throughLink, buildTarGz(t, []testArchiveFile{
+		{name: "link/escaped.txt", content: "pwned", mode: 0644},
+	}), 0644)
+
+	attrs = map[string]interface{}{"source": throughLink, "dest": existing, "creates": filepath.Join(existing, "done")}
+	if _, err := provider.Apply(ctx, &ResourceState{Type: "archive", Name: existing, Attributes: attrs}); err == nil {
+		t.Error("Expected writing through an existing symlink to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "escaped.txt")); !os.IsNotExist(err) {
+		t.Error("An existing symlink wrote a file outside dest")
+	}
+}
@@ -0,0 +1,368 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cronMarkerPrefix identifies the comment line that precedes a managed cron entry
+const cronMarkerPrefix = "# zero: "
+
+// cronField describes the valid range of a schedule field
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names []string // Optional symbolic names, indexed from min
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "weekday", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat", "sun"}},
+}
+
+// CronProvider implements crontab entry management
+type CronProvider struct {
+	platform     *PlatformChecker
+	readCrontab  func(ctx context.Context, user string) (string, error)
+	writeCrontab func(ctx context.Context, user, content string) error
+}
+
+// NewCronProvider creates a new cron provider
+func NewCronProvider() *CronProvider {
+	return &CronProvider{
+		platform:     &PlatformChecker{},
+		readCrontab:  readCrontab,
+		writeCrontab: writeCrontab,
+	}
+}
+
+// Validate validates cron resource attributes
+func (p *CronProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("cron provider is not supported on Windows")
+	}
+
+	name, ok := attributes["name"]
+	if !ok {
+		return fmt.Errorf("cron resource requires 'name' attribute")
+	}
+	nameStr, ok := name.(string)
+	if !ok {
+		return fmt.Errorf("cron 'name' must be a string")
+	}
+	if nameStr == "" || strings.ContainsAny(nameStr, "\r\n") {
+		return fmt.Errorf("cron 'name' must be a non-empty single line")
+	}
+
+	state := presenceState(attributes)
+	if state != "present" && state != "absent" {
+		return fmt.Errorf("cron 'state' must be one of: present, absent")
+	}
+
+	if user, ok := attributes["user"]; ok {
+		if _, ok := user.(string); !ok {
+			return fmt.Errorf("cron 'user' must be a string")
+		}
+	}
+
+	command, hasCommand := attributes["command"]
+	if hasCommand {
+		commandStr, ok := command.(string)
+		if !ok {
+			return fmt.Errorf("cron 'command' must be a string")
+		}
+		if strings.ContainsAny(commandStr, "\r\n") {
+			return fmt.Errorf("cron 'command' must be a single line")
+		}
+	} else if state == "present" {
+		return fmt.Errorf("cron resource requires 'command' attribute")
+	}
+
+	for _, field := range cronFields {
+		value, err := cronFieldValue(attributes, field.name)
+		if err != nil {
+			return err
+		}
+		if err := field.validate(value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Plan determines what changes would be made to the crontab
+func (p *CronProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	name := desired["name"].(string)
+
+	result := &ResourceState{
+		Type:       "cron",
+		Name:       name,
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	user, _ := desired["user"].(string)
+	crontab, err := p.readCrontab(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, found := findCronEntry(crontab, name)
+
+	if presenceState(desired) == "absent" {
+		if found {
+			result.Status = "planned"
+			result.Changes = []string{"state"}
+		}
+		return result, nil
+	}
+
+	entry, err := cronEntry(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		result.Status = "planned"
+		result.Changes = []string{"state"}
+	} else if existing != entry {
+		result.Status = "planned"
+		result.Changes = []string{"entry"}
+	}
+
+	return result, nil
+}
+
+// DesiredAttributes translates an intent into cron attributes
+func (p *CronProvider) DesiredAttributes(intent Intent, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	return intentAttributes(intent, attributes, map[string]interface{}{"state": "absent"})
+}
+
+// Apply rewrites the managed crontab entry, leaving other entries intact
+func (p *CronProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	name := state.Attributes["name"].(string)
+
+	result := &ResourceState{
+		Type:       "cron",
+		Name:       name,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	user, _ := state.Attributes["user"].(string)
+	crontab, err := p.readCrontab(ctx, user)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	existing, found := findCronEntry(crontab, name)
+
+	var updated string
+	if presenceState(state.Attributes) == "absent" {
+		if !found {
+			return result, nil
+		}
+		updated = setCronEntry(crontab, name, "")
+		result.Status = "deleted"
+	} else {
+		entry, err := cronEntry(state.Attributes)
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+		if found && existing == entry {
+			return result, nil
+		}
+		updated = setCronEntry(crontab, name, entry)
+		if found {
+			result.Status = "updated"
+		} else {
+			result.Status = "created"
+		}
+	}
+
+	if err := p.writeCrontab(ctx, user, updated); err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+	result.Changed = true
+
+	return result, nil
+}
+
+// validate checks a schedule field such as "*/5", "1-5" or "mon,wed"
+func (f cronField) validate(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		rangePart := part
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			step, err := strconv.Atoi(part[slash+1:])
+			if err != nil || step <= 0 {
+				return fmt.Errorf("cron '%s' has invalid step in %q", f.name, value)
+			}
+			rangePart = part[:slash]
+		}
+
+		if rangePart == "*" {
+			continue
+		}
+
+		bounds := strings.SplitN(rangePart, "-", 2)
+		var values []int
+		for _, bound := range bounds {
+			n, ok := f.parse(bound)
+			if !ok {
+				return fmt.Errorf("cron '%s' has invalid value %q", f.name, value)
+			}
+			values = append(values, n)
+		}
+		if len(values) == 2 && values[0] > values[1] {
+			return fmt.Errorf("cron '%s' has invalid range in %q", f.name, value)
+		}
+	}
+	return nil
+}
+
+// parse converts a single field value to a number within range
+func (f cronField) parse(value string) (int, bool) {
+	for i, name := range f.names {
+		if strings.EqualFold(value, name) {
+			return f.min + i, true
+		}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < f.min || n > f.max {
+		return 0, false
+	}
+	return n, true
+}
+
+// cronFieldValue returns a schedule field as a string, defaulting to "*"
+func cronFieldValue(attributes map[string]interface{}, key string) (string, error) {
+	value, ok := attributes[key]
+	if !ok {
+		return "*", nil
+	}
+	if str, ok := value.(string); ok {
+		if str == "" {
+			return "", fmt.Errorf("cron '%s' must not be empty", key)
+		}
+		return str, nil
+	}
+	n, _, err := intAttribute(attributes, key)
+	if err != nil {
+		return "", fmt.Errorf("cron %v", err)
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+// cronEntry builds the crontab line for the desired attributes
+func cronEntry(attributes map[string]interface{}) (string, error) {
+	fields := make([]string, 0, len(cronFields)+1)
+	for _, field := range cronFields {
+		value, err := cronFieldValue(attributes, field.name)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, value)
+	}
+	command, _ := attributes["command"].(string)
+	fields = append(fields, command)
+	return strings.Join(fields, " "), nil
+}
+
+// findCronEntry returns the entry line following the marker for name
+func findCronEntry(crontab, name string) (string, bool) {
+	lines := strings.Split(crontab, "\n")
+	marker := cronMarkerPrefix + name
+	for i, line := range lines {
+		if line == marker {
+			if i+1 < len(lines) {
+				return lines[i+1], true
+			}
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// setCronEntry replaces or appends the managed block for name, removing it when entry is empty
+func setCronEntry(crontab, name, entry string) string {
+	marker := cronMarkerPrefix + name
+
+	var lines []string
+	if crontab != "" {
+		lines = strings.Split(strings.TrimSuffix(crontab, "\n"), "\n")
+	}
+
+	var result []string
+	replaced := false
+	for i := 0; i < len(lines); i++ {
+		if lines[i] == marker {
+			i++ // Skip the managed entry line
+			if entry != "" && !replaced {
+				result = append(result, marker, entry)
+				replaced = true
+			}
+			continue
+		}
+		result = append(result, lines[i])
+	}
+
+	if entry != "" && !replaced {
+		result = append(result, marker, entry)
+	}
+
+	if len(result) == 0 {
+		return ""
+	}
+	return strings.Join(result, "\n") + "\n"
+}
+
+// readCrontab returns the crontab of user, or the current user if empty
+func readCrontab(ctx context.Context, user string) (string, error) {
+	args := []string{"-l"}
+	if user != "" {
+		args = append([]string{"-u", user}, args...)
+	}
+
+	output, err := exec.CommandContext(ctx, "crontab", args...).CombinedOutput()
+	if err != nil {
+		// crontab -l fails when the user has no crontab yet
+		if strings.Contains(strings.ToLower(string(output)), "no crontab") {
+			return "", nil
+		}
+		return "", fmt.Errorf("error reading crontab: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return string(output), nil
+}
+
+// writeCrontab replaces the crontab of user, or the current user if empty
+func writeCrontab(ctx context.Context, user, content string) error {
+	args := []string{"-"}
+	if user != "" {
+		args = append([]string{"-u", user}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, "crontab", args...)
+	cmd.Stdin = bytes.NewBufferString(content)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error writing crontab: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package providers
+
+import (
+	"context"
+	"runtime"
+	"testing"
+)
+
+// fakeCrontab is an in-memory crontab store for a single user
+type fakeCrontab struct {
+	content string
+	writes  int
+}
+
+func newTestCronProvider(content string) (*CronProvider, *fakeCrontab) {
+	crontab := &fakeCrontab{content: content}
+	provider := NewCronProvider()
+	provider.readCrontab = func(ctx context.Context, user string) (string, error) {
+		return crontab.content, nil
+	}
+	provider.writeCrontab = func(ctx context.Context, user, content string) error {
+		crontab.content = content
+		crontab.writes++
+		return nil
+	}
+	return provider, crontab
+}
+
+func TestCronProvider_Validate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("cron provider is not supported on Windows")
+	}
+
+	provider := NewCronProvider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"minimal", map[string]interface{}{"name": "backup", "command": "/usr/local/bin/backup"}, false},
+		{"full schedule", map[string]interface{}{
+			"name":    "backup",
+			"command": "/usr/local/bin/backup",
+			"minute":  "*/15",
+			"hour":    int64(2),
+			"day":     "1-15",
+			"month":   "jan,jul",
+			"weekday": "mon-fri",
+			"user":    "root",
+		}, false},
+		{"absent without command", map[string]interface{}{"name": "backup", "state": "absent"}, false},
+		{"missing name", map[string]interface{}{"command": "true"}, true},
+		{"missing command", map[string]interface{}{"name": "backup"}, true},
+		{"minute out of range", map[string]interface{}{"name": "backup", "command": "true", "minute": int64(60)}, true},
+		{"hour not a number", map[string]interface{}{"name": "backup", "command": "true", "hour": "noon"}, true},
+		{"reversed range", map[string]interface{}{"name": "backup", "command": "true", "day": "10-2"}, true},
+		{"zero step", map[string]interface{}{"name": "backup", "command": "true", "minute": "*/0"}, true},
+		{"multiline command", map[string]interface{}{"name": "backup", "command": "true\nfalse"}, true},
+		{"invalid state", map[string]interface{}{"name": "backup", "command": "true", "state": "enabled"}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestCronProvider_PlanAndApply(t *testing.T) {
+	ctx := context.Background()
+	other := "MAILTO=ops@example.com\n0 * * * * /usr/bin/other\n"
+	attrs := map[string]interface{}{
+		"name":    "backup",
+		"command": "/usr/local/bin/backup",
+		"minute":  int64(30),
+		"hour":    int64(2),
+	}
+
+	// Empty crontab: entry should be created
+	provider, crontab := newTestCronProvider("")
+	plan, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" {
+		t.Errorf("Expected planned status for empty crontab, got %s", plan.Status)
+	}
+	result, err := provider.Apply(ctx, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "created" {
+		t.Errorf("Expected created status, got %s", result.Status)
+	}
+	expected := "# zero: backup\n30 2 * * * /usr/local/bin/backup\n"
+	if crontab.content != expected {
+		t.Errorf("Expected crontab %q, got %q", expected, crontab.content)
+	}
+
+	// Re-applying is idempotent
+	plan, _ = provider.Plan(ctx, nil, attrs)
+	if plan.Status != "unchanged" {
+		t.Errorf("Expected unchanged status on second plan, got %s", plan.Status)
+	}
+	result, _ = provider.Apply(ctx, plan)
+	checkChanged(t, result)
+	if result.Status != "unchanged" || crontab.writes != 1 {
+		t.Errorf("Expected no rewrite on second apply, got status %s and %d writes", result.Status, crontab.writes)
+	}
+
+	// Changing the schedule updates only the managed block
+	provider, crontab = newTestCronProvider(other + "# zero: backup\n0 1 * * * /usr/local/bin/backup\n# trailing comment\n")
+	plan, _ = provider.Plan(ctx, nil, attrs)
+	if plan.Status != "planned" {
+		t.Errorf("Expected planned status for changed schedule, got %s", plan.Status)
+	}
+	result, _ = provider.Apply(ctx, plan)
+	checkChanged(t, result)
+	if result.Status != "updated" {
+		t.Errorf("Expected updated status, got %s", result.Status)
+	}
+	expected = other + "# zero: backup\n30 2 * * * /usr/local/bin/backup\n# trailing comment\n"
+	if crontab.content != expected {
+		t.Errorf("Expected crontab %q, got %q", expected, crontab.content)
+	}
+
+	// Removing the entry leaves other entries intact
+	absent := map[string]interface{}{"name": "backup", "state": "absent"}
+	plan, _ = provider.Plan(ctx, nil, absent)
+	if plan.Status != "planned" {
+		t.Errorf("Expected planned status for removal, got %s", plan.Status)
+	}
+	result, _ = provider.Apply(ctx, plan)
+	checkChanged(t, result)
+	if result.Status != "deleted" {
+		t.Errorf("Expected deleted status, got %s", result.Status)
+	}
+	expected = other + "# trailing comment\n"
+	if crontab.content != expected {
+		t.Errorf("Expected crontab %q, got %q", expected, crontab.content)
+	}
+}
@@ -0,0 +1,177 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around each change
+	diffContext = 3
+
+	// maxDiffLines caps the number of lines in a rendered diff
+	maxDiffLines = 60
+
+	// maxDiffCells caps the size of the table used to compare two files, so
+	// large files are summarized instead of diffed
+	maxDiffCells = 4000000
+)
+
+// diffLine is one line of a line-based diff: ' ' for a line both sides
+// share, '-' for a removed line and '+' for an added one
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// unifiedDiff renders a unified diff of the change from the before to the
+// after content, labeled with name. Diffs longer than maxDiffLines are
+// truncated, and binary or very large content is summarized in a single line.
+func unifiedDiff(name, before, after string) string {
+	if strings.ContainsRune(before, 0) || strings.ContainsRune(after, 0) {
+		return "binary content differs"
+	}
+
+	a, b := splitLines(before), splitLines(after)
+	if len(a)*len(b) > maxDiffCells {
+		return fmt.Sprintf("content differs (%d lines -> %d lines, too large to diff)", len(a), len(b))
+	}
+
+	lines := diffLines(a, b)
+	hunks := diffHunks(lines)
+	if len(hunks) == 0 {
+		return "content differs only in a trailing newline"
+	}
+
+	out := []string{"--- " + name, "+++ " + name}
+	for _, hunk := range hunks {
+		out = append(out, hunk...)
+	}
+
+	if len(out) > maxDiffLines {
+		more := len(out) - maxDiffLines
+		out = append(out[:maxDiffLines], fmt.Sprintf("... diff truncated (%d more lines)", more))
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// splitLines splits content into lines without their terminators
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// diffLines returns the shortest line-based edit from a to b, using the
+// longest common subsequence of their lines
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	return lines
+}
+
+// diffHunks groups the changed lines of a diff into hunks with diffContext
+// lines of context, each starting with its @@ header
+func diffHunks(lines []diffLine) [][]string {
+	var hunks [][]string
+
+	for start := 0; start < len(lines); {
+		// Find the next change
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// Extend the hunk while changes are close enough to share context
+		last := first
+		for k := first + 1; k < len(lines); k++ {
+			if lines[k].kind == ' ' {
+				continue
+			}
+			if k-last-1 > 2*diffContext {
+				break
+			}
+			last = k
+		}
+
+		from := first - diffContext
+		if from < start {
+			from = start
+		}
+		to := last + diffContext + 1
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		// Line numbers are 1-based positions of the hunk in each file
+		oldStart, newStart := 1, 1
+		for _, line := range lines[:from] {
+			if line.kind != '+' {
+				oldStart++
+			}
+			if line.kind != '-' {
+				newStart++
+			}
+		}
+
+		var body []string
+		oldCount, newCount := 0, 0
+		for _, line := range lines[from:to] {
+			if line.kind != '+' {
+				oldCount++
+			}
+			if line.kind != '-' {
+				newCount++
+			}
+			body = append(body, string(line.kind)+line.text)
+		}
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+
+		header := fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, oldCount, newStart, newCount)
+		hunks = append(hunks, append([]string{header}, body...))
+		start = to
+	}
+
+	return hunks
+}
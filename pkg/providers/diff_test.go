@@ -0,0 +1,75 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	after := "a\nb\nc\nD\ne\nf\ng\nh\ni\nj\nk\n"
+
+	expected := strings.Join([]string{
+		"--- /etc/app.conf",
+		"+++ /etc/app.conf",
+		"@@ -1,10 +1,11 @@",
+		" a",
+		" b",
+		" c",
+		"-d",
+		"+D",
+		" e",
+		" f",
+		" g",
+		" h",
+		" i",
+		" j",
+		"+k",
+	}, "\n")
+
+	if diff := unifiedDiff("/etc/app.conf", before, after); diff != expected {
+		t.Errorf("Expected diff:\n%s\ngot:\n%s", expected, diff)
+	}
+}
+
+func TestUnifiedDiff_SeparateHunks(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	before := strings.Join(lines, "\n") + "\n"
+	lines[1] = "changed 2"
+	lines[18] = "changed 19"
+	after := strings.Join(lines, "\n") + "\n"
+
+	diff := unifiedDiff("f", before, after)
+	for _, want := range []string{"@@ -1,5 +1,5 @@", "-line 2\n+changed 2", "@@ -16,5 +16,5 @@", "-line 19\n+changed 19"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Expected diff to contain %q, got:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "line 10") {
+		t.Errorf("Expected unchanged lines far from a change to be left out, got:\n%s", diff)
+	}
+}
+
+func TestUnifiedDiff_Limits(t *testing.T) {
+	var before, after strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&before, "old %d\n", i)
+		fmt.Fprintf(&after, "new %d\n", i)
+	}
+
+	diff := unifiedDiff("f", before.String(), after.String())
+	if lines := strings.Split(diff, "\n"); len(lines) != maxDiffLines+1 || !strings.HasPrefix(lines[maxDiffLines], "... diff truncated") {
+		t.Errorf("Expected diff truncated to %d lines, got %d", maxDiffLines, len(lines))
+	}
+
+	if diff := unifiedDiff("f", "a\x00b", "a\x00c"); diff != "binary content differs" {
+		t.Errorf("Expected binary summary, got %q", diff)
+	}
+	if diff := unifiedDiff("f", "a\n", "a"); diff != "content differs only in a trailing newline" {
+		t.Errorf("Expected trailing newline summary, got %q", diff)
+	}
+}
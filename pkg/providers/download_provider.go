@@ -0,0 +1,284 @@
+package providers
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultDownloadTimeout bounds a download when no timeout attribute is set
+const defaultDownloadTimeout = 10 * time.Minute
+
+// DownloadProvider fetches files over HTTP(S)
+type DownloadProvider struct {
+	platform *PlatformChecker
+	client   *http.Client
+}
+
+// NewDownloadProvider creates a new download provider
+func NewDownloadProvider() *DownloadProvider {
+	return &DownloadProvider{
+		platform: &PlatformChecker{},
+		client:   &http.Client{},
+	}
+}
+
+// Validate validates download resource attributes
+func (p *DownloadProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	url, ok := attributes["url"]
+	if !ok {
+		return fmt.Errorf("download resource requires 'url' attribute")
+	}
+	urlStr, ok := url.(string)
+	if !ok {
+		return fmt.Errorf("download 'url' must be a string")
+	}
+	if !strings.HasPrefix(urlStr, "http://") && !strings.HasPrefix(urlStr, "https://") {
+		return fmt.Errorf("download 'url' must use http or https")
+	}
+
+	path, ok := attributes["path"]
+	if !ok {
+		return fmt.Errorf("download resource requires 'path' attribute")
+	}
+	if _, ok := path.(string); !ok {
+		return fmt.Errorf("download 'path' must be a string")
+	}
+
+	if checksum, ok := attributes["checksum"]; ok {
+		checksumStr, ok := checksum.(string)
+		if !ok {
+			return fmt.Errorf("download 'checksum' must be a string")
+		}
+		if _, _, err := parseChecksum(checksumStr); err != nil {
+			return fmt.Errorf("download %v", err)
+		}
+	}
+
+	if _, _, err := modeAttribute(attributes, "mode"); err != nil {
+		return fmt.Errorf("download %v", err)
+	}
+
+	if headers, ok := attributes["headers"]; ok {
+		if _, ok := headers.(map[string]interface{}); !ok {
+			return fmt.Errorf("download 'headers' must be a map")
+		}
+	}
+
+	if _, _, err := durationAttribute(attributes, "timeout"); err != nil {
+		return fmt.Errorf("download %v", err)
+	}
+
+	return nil
+}
+
+// Plan determines whether the file needs to be downloaded
+func (p *DownloadProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	path := desired["path"].(string)
+
+	result := &ResourceState{
+		Type:       "download",
+		Name:       path,
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	needed, err := p.needsDownload(path, desired)
+	if err != nil {
+		return nil, err
+	}
+	if needed {
+		result.Status = "planned"
+	}
+
+	return result, nil
+}
+
+// Apply downloads the file, verifies it and moves it into place
+func (p *DownloadProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	path := state.Attributes["path"].(string)
+
+	result := &ResourceState{
+		Type:       "download",
+		Name:       path,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	_, statErr := os.Stat(path)
+	exists := statErr == nil
+
+	needed, err := p.needsDownload(path, state.Attributes)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+	if !needed {
+		return result, nil
+	}
+
+	if err := p.download(ctx, path, state.Attributes); err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	result.Changed = true
+	if exists {
+		result.Status = "updated"
+	} else {
+		result.Status = "created"
+	}
+
+	return result, nil
+}
+
+// needsDownload reports whether path is missing or fails the checksum
+func (p *DownloadProvider) needsDownload(path string, attributes map[string]interface{}) (bool, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("error checking %s: %v", path, err)
+	}
+
+	checksum, ok := attributes["checksum"].(string)
+	if !ok {
+		return false, nil
+	}
+
+	matches, err := fileMatchesChecksum(path, checksum)
+	if err != nil {
+		return false, err
+	}
+	return !matches, nil
+}
+
+// download streams url to a temp file next to path and renames it into place
+func (p *DownloadProvider) download(ctx context.Context, path string, attributes map[string]interface{}) error {
+	url := attributes["url"].(string)
+
+	timeout, ok, _ := durationAttribute(attributes, "timeout")
+	if !ok {
+		timeout = defaultDownloadTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid download request for %s: %v", url, err)
+	}
+	if headers, ok := attributes["headers"].(map[string]interface{}); ok {
+		for key, value := range headers {
+			req.Header.Set(key, fmt.Sprintf("%v", value))
+		}
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", dir, err)
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".download-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file in %s: %v", dir, err)
+	}
+	tmpPath := tmp.Name()
+	// Remove the partial file on any failure; this is a no-op after the rename
+	defer os.Remove(tmpPath)
+
+	var writer io.Writer = tmp
+	var hasher hash.Hash
+	var expected string
+	if checksum, ok := attributes["checksum"].(string); ok {
+		hasher, expected, _ = parseChecksum(checksum)
+		writer = io.MultiWriter(tmp, hasher)
+	}
+
+	if _, err := io.Copy(writer, resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", tmpPath, err)
+	}
+
+	if hasher != nil {
+		if actual := hex.EncodeToString(hasher.Sum(nil)); actual != expected {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, expected, actual)
+		}
+	}
+
+	mode := os.FileMode(0644)
+	if m, ok, _ := modeAttribute(attributes, "mode"); ok {
+		mode = m
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return fmt.Errorf("failed to change mode of %s: %v", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to move download into place at %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// parseChecksum splits an "algo:hex" checksum into a hasher and the expected digest
+func parseChecksum(checksum string) (hash.Hash, string, error) {
+	parts := strings.SplitN(checksum, ":", 2)
+	if len(parts) != 2 {
+		return nil, "", fmt.Errorf("checksum must have the form algo:hex, got %q", checksum)
+	}
+
+	hasher, err := newHasher(parts[0])
+	if err != nil {
+		return nil, "", err
+	}
+
+	expected := strings.ToLower(parts[1])
+	if decoded, err := hex.DecodeString(expected); err != nil || len(decoded) != hasher.Size() {
+		return nil, "", fmt.Errorf("invalid %s checksum %q", parts[0], parts[1])
+	}
+
+	return hasher, expected, nil
+}
+
+// fileMatchesChecksum reports whether the file at path has the given "algo:hex" checksum
+func fileMatchesChecksum(path, checksum string) (bool, error) {
+	hasher, expected, err := parseChecksum(checksum)
+	if err != nil {
+		return false, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(hasher, f); err != nil {
+		return false, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)) == expected, nil
+}
@@ -0,0 +1,188 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadProvider_Validate(t *testing.T) {
+	provider := NewDownloadProvider()
+	ctx := context.Background()
+	sum := sha256.Sum256([]byte("payload"))
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"minimal", map[string]interface{}{"url": "https://example.com/tool.tar.gz", "path": "/tmp/tool.tar.gz"}, false},
+		{"full", map[string]interface{}{
+			"url":      "https://example.com/tool",
+			"path":     "/usr/local/bin/tool",
+			"checksum": "sha256:" + hex.EncodeToString(sum[:]),
+			"mode":     "0755",
+			"headers":  map[string]interface{}{"Authorization": "Bearer token"},
+			"timeout":  "30s",
+		}, false},
+		{"missing url", map[string]interface{}{"path": "/tmp/x"}, true},
+		{"unsupported scheme", map[string]interface{}{"url": "ftp://example.com/x", "path": "/tmp/x"}, true},
+		{"missing path", map[string]interface{}{"url": "https://example.com/x"}, true},
+		{"malformed checksum", map[string]interface{}{"url": "https://example.com/x", "path": "/tmp/x", "checksum": "abc123"}, true},
+		{"unknown algorithm", map[string]interface{}{"url": "https://example.com/x", "path": "/tmp/x", "checksum": "crc32:abcd"}, true},
+		{"wrong digest length", map[string]interface{}{"url": "https://example.com/x", "path": "/tmp/x", "checksum": "sha256:abcd"}, true},
+		{"invalid mode", map[string]interface{}{"url": "https://example.com/x", "path": "/tmp/x", "mode": "999"}, true},
+		{"invalid headers", map[string]interface{}{"url": "https://example.com/x", "path": "/tmp/x", "headers": "X-A: b"}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestDownloadProvider_PlanAndApply(t *testing.T) {
+	payload := []byte("binary payload")
+	sum := sha256.Sum256(payload)
+	checksum := "sha256:" + hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/tool", http.StatusFound)
+		case "/tool":
+			if r.Header.Get("X-Token") != "secret" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			w.Write(payload)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tempDir, err := ioutil.TempDir("", "download-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	provider := NewDownloadProvider()
+	ctx := context.Background()
+	path := filepath.Join(tempDir, "bin", "tool")
+	attrs := map[string]interface{}{
+		"url":      server.URL + "/redirect",
+		"path":     path,
+		"checksum": checksum,
+		"mode":     "0755",
+		"headers":  map[string]interface{}{"X-Token": "secret"},
+	}
+
+	plan, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" {
+		t.Errorf("Expected planned status for missing file, got %s", plan.Status)
+	}
+
+	result, err := provider.Apply(ctx, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "created" {
+		t.Errorf("Expected created status, got %s", result.Status)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read downloaded file: %v", err)
+	}
+	if string(content) != string(payload) {
+		t.Errorf("Expected downloaded content %q, got %q", payload, content)
+	}
+
+	// A matching file is left alone
+	plan, _ = provider.Plan(ctx, nil, attrs)
+	if plan.Status != "unchanged" {
+		t.Errorf("Expected unchanged status for matching checksum, got %s", plan.Status)
+	}
+
+	// A corrupted file is downloaded again
+	ioutil.WriteFile(path, []byte("corrupted"), 0644)
+	plan, _ = provider.Plan(ctx, nil, attrs)
+	if plan.Status != "planned" {
+		t.Errorf("Expected planned status for checksum drift, got %s", plan.Status)
+	}
+	result, err = provider.Apply(ctx, plan)
+	if err != nil || result.Status != "updated" {
+		t.Errorf("Expected updated status, got %s (%v)", result.Status, err)
+	}
+}
+
+func TestDownloadProvider_ApplyFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tool" {
+			w.Write([]byte("unexpected payload"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	tempDir, err := ioutil.TempDir("", "download-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	provider := NewDownloadProvider()
+	ctx := context.Background()
+	sum := sha256.Sum256([]byte("expected payload"))
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr string
+	}{
+		{"checksum mismatch", server.URL + "/tool", "checksum mismatch"},
+		{"not found", server.URL + "/missing", "404"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(tempDir, "tool")
+		attrs := map[string]interface{}{
+			"url":      tt.url,
+			"path":     path,
+			"checksum": "sha256:" + hex.EncodeToString(sum[:]),
+		}
+
+		result, err := provider.Apply(ctx, &ResourceState{Type: "download", Name: path, Attributes: attrs})
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.wantErr, err)
+		}
+		checkChanged(t, result)
+		if result.Status != "failed" {
+			t.Errorf("%s: expected failed status, got %s", tt.name, result.Status)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s: expected no file at destination", tt.name)
+		}
+
+		entries, _ := ioutil.ReadDir(tempDir)
+		if len(entries) != 0 {
+			t.Errorf("%s: expected no partial files, found %d entries", tt.name, len(entries))
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// ExecProvider runs arbitrary commands guarded by creates/unless/onlyif checks
+type ExecProvider struct {
+	platform *PlatformChecker
+}
+
+// NewExecProvider creates a new exec provider
+func NewExecProvider() *ExecProvider {
+	return &ExecProvider{
+		platform: &PlatformChecker{},
+	}
+}
+
+// Validate validates exec resource attributes
+func (p *ExecProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	command, ok := attributes["command"]
+	if !ok {
+		return fmt.Errorf("exec resource requires 'command' attribute")
+	}
+	if _, ok := command.(string); !ok {
+		return fmt.Errorf("exec 'command' must be a string")
+	}
+
+	for _, key := range []string{"cwd", "creates", "unless", "onlyif"} {
+		if value, ok := attributes[key]; ok {
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("exec '%s' must be a string", key)
+			}
+		}
+	}
+
+	if env, ok := attributes["env"]; ok {
+		if _, ok := env.(map[string]interface{}); !ok {
+			return fmt.Errorf("exec 'env' must be a map")
+		}
+	}
+
+	if _, _, err := durationAttribute(attributes, "timeout"); err != nil {
+		return fmt.Errorf("exec %v", err)
+	}
+
+	return nil
+}
+
+// Plan determines whether the command would run, evaluating guards only
+func (p *ExecProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	result := &ResourceState{
+		Type:       "exec",
+		Name:       execName(desired),
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	run, err := p.shouldRun(ctx, desired)
+	if err != nil {
+		return nil, err
+	}
+	if run {
+		result.Status = "planned"
+	}
+
+	return result, nil
+}
+
+// Apply runs the command if its guards allow it
+func (p *ExecProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	command := state.Attributes["command"].(string)
+
+	result := &ResourceState{
+		Type:       "exec",
+		Name:       execName(state.Attributes),
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	run, err := p.shouldRun(ctx, state.Attributes)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+	if !run {
+		return result, nil
+	}
+
+	timeout, _, _ := durationAttribute(state.Attributes, "timeout")
+	output, err := p.runShell(ctx, command, state.Attributes, timeout)
+	result.Output = string(output)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	result.Status = "updated"
+	result.Changed = true
+	return result, nil
+}
+
+// execName returns the resource name, falling back to the command itself
+func execName(attributes map[string]interface{}) string {
+	if name, ok := attributes["name"].(string); ok && name != "" {
+		return name
+	}
+	return attributes["command"].(string)
+}
+
+// shouldRun evaluates the creates, unless and onlyif guards in that order
+func (p *ExecProvider) shouldRun(ctx context.Context, attributes map[string]interface{}) (bool, error) {
+	if creates, ok := attributes["creates"].(string); ok {
+		if _, err := os.Stat(creates); err == nil {
+			return false, nil
+		} else if !os.IsNotExist(err) {
+			return false, fmt.Errorf("error checking %s: %v", creates, err)
+		}
+	}
+
+	if unless, ok := attributes["unless"].(string); ok {
+		if _, err := p.runShell(ctx, unless, attributes, 0); err == nil {
+			return false, nil
+		} else if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+	}
+
+	if onlyif, ok := attributes["onlyif"].(string); ok {
+		if _, err := p.runShell(ctx, onlyif, attributes, 0); err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// runShell runs command through the platform shell using the resource's cwd and env
+func (p *ExecProvider) runShell(ctx context.Context, command string, attributes map[string]interface{}, timeout time.Duration) ([]byte, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	// Don't wait on pipes held open by orphaned children once the command is killed
+	cmd.WaitDelay = time.Second
+
+	if cwd, ok := attributes["cwd"].(string); ok {
+		cmd.Dir = cwd
+	}
+
+	if env, ok := attributes["env"].(map[string]interface{}); ok {
+		keys := make([]string, 0, len(env))
+		for key := range env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		cmd.Env = os.Environ()
+		for _, key := range keys {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%v", key, env[key]))
+		}
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+			return output, fmt.Errorf("command timed out after %v: %s", timeout, command)
+		}
+		return output, fmt.Errorf("command failed: %v", err)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,175 @@
+package providers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecProvider_Validate(t *testing.T) {
+	provider := NewExecProvider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"minimal", map[string]interface{}{"command": "echo hi"}, false},
+		{"full", map[string]interface{}{
+			"command": "make install",
+			"cwd":     "/tmp",
+			"env":     map[string]interface{}{"PREFIX": "/usr/local"},
+			"creates": "/usr/local/bin/tool",
+			"unless":  "which tool",
+			"onlyif":  "test -f Makefile",
+			"timeout": "5m",
+		}, false},
+		{"numeric timeout", map[string]interface{}{"command": "true", "timeout": int64(30)}, false},
+		{"missing command", map[string]interface{}{"cwd": "/tmp"}, true},
+		{"invalid command", map[string]interface{}{"command": []string{"echo", "hi"}}, true},
+		{"invalid env", map[string]interface{}{"command": "true", "env": "A=B"}, true},
+		{"invalid timeout", map[string]interface{}{"command": "true", "timeout": "soon"}, true},
+		{"invalid guard", map[string]interface{}{"command": "true", "unless": true}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestExecProvider_Guards(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("guard commands use POSIX shell syntax")
+	}
+
+	provider := NewExecProvider()
+	ctx := context.Background()
+
+	tempDir, err := ioutil.TempDir("", "exec-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	existing := filepath.Join(tempDir, "exists")
+	if err := ioutil.WriteFile(existing, []byte{}, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	marker := filepath.Join(tempDir, "ran")
+	command := "touch " + marker
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantRun bool
+	}{
+		{"no guards", map[string]interface{}{}, true},
+		{"creates exists", map[string]interface{}{"creates": existing}, false},
+		{"creates missing", map[string]interface{}{"creates": filepath.Join(tempDir, "missing")}, true},
+		{"unless succeeds", map[string]interface{}{"unless": "true"}, false},
+		{"unless fails", map[string]interface{}{"unless": "false"}, true},
+		{"onlyif succeeds", map[string]interface{}{"onlyif": "true"}, true},
+		{"onlyif fails", map[string]interface{}{"onlyif": "false"}, false},
+		{"creates short-circuits onlyif", map[string]interface{}{"creates": existing, "onlyif": "true"}, false},
+	}
+
+	for _, tt := range tests {
+		os.Remove(marker)
+		tt.attrs["command"] = command
+
+		plan, err := provider.Plan(ctx, nil, tt.attrs)
+		if err != nil {
+			t.Fatalf("%s: Plan failed: %v", tt.name, err)
+		}
+		if _, err := os.Stat(marker); err == nil {
+			t.Errorf("%s: Plan must not run the command", tt.name)
+		}
+		wantPlan := "unchanged"
+		if tt.wantRun {
+			wantPlan = "planned"
+		}
+		if plan.Status != wantPlan {
+			t.Errorf("%s: expected plan status %s, got %s", tt.name, wantPlan, plan.Status)
+		}
+
+		result, err := provider.Apply(ctx, plan)
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", tt.name, err)
+		}
+		_, statErr := os.Stat(marker)
+		if ran := statErr == nil; ran != tt.wantRun {
+			t.Errorf("%s: expected command run %v, got %v", tt.name, tt.wantRun, ran)
+		}
+		wantApply := "unchanged"
+		if tt.wantRun {
+			wantApply = "updated"
+		}
+		checkChanged(t, result)
+		if result.Status != wantApply {
+			t.Errorf("%s: expected apply status %s, got %s", tt.name, wantApply, result.Status)
+		}
+	}
+}
+
+func TestExecProvider_ApplyOutputAndEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+
+	provider := NewExecProvider()
+	tempDir, err := ioutil.TempDir("", "exec-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	attrs := map[string]interface{}{
+		"command": "echo $GREETING from $(pwd)",
+		"cwd":     tempDir,
+		"env":     map[string]interface{}{"GREETING": "hello"},
+	}
+	result, err := provider.Apply(context.Background(), &ResourceState{Type: "exec", Name: "greet", Attributes: attrs})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if !strings.HasPrefix(result.Output, "hello from ") {
+		t.Errorf("Expected captured output with env applied, got %q", result.Output)
+	}
+}
+
+func TestExecProvider_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+
+	provider := NewExecProvider()
+	attrs := map[string]interface{}{
+		"command": "sleep 5",
+		"timeout": "100ms",
+	}
+
+	start := time.Now()
+	result, err := provider.Apply(context.Background(), &ResourceState{Type: "exec", Name: "slow", Attributes: attrs})
+	if err == nil {
+		t.Fatal("Expected timeout error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected command to be killed at the timeout, took %v", elapsed)
+	}
+	checkChanged(t, result)
+	if result.Status != "failed" {
+		t.Errorf("Expected failed status, got %s", result.Status)
+	}
+	if !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected timed out error, got %v", err)
+	}
+}
@@ -2,43 +2,60 @@ package providers
 
 import (
 	"context"
-	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"syscall"
+
+	"github.com/dangerclosesec/zero/pkg/logging"
 )
 
 // FileProvider implements file resource management
 type FileProvider struct {
-	platform *PlatformChecker
+	platform   *PlatformChecker
+	runCommand CommandRunner
+	chmod      func(name string, mode os.FileMode) error
+	readFile   func(name string) ([]byte, error)
 }
 
 // NewFileProvider creates a new file provider
 func NewFileProvider() *FileProvider {
 	return &FileProvider{
-		platform: &PlatformChecker{},
+		platform:   &PlatformChecker{},
+		runCommand: runCommand,
+		chmod:      os.Chmod,
+		readFile:   ioutil.ReadFile,
 	}
 }
 
+// fileSchema declares the file attributes with a fixed type or set of values
+var fileSchema = Schema{
+	{Name: "path", Type: TypeString, Required: true},
+	{Name: "state", Type: TypeString, OneOf: []string{"present", "absent", "directory", "link"}},
+	{Name: "target", Type: TypeString},
+	{Name: "force", Type: TypeBool},
+	{Name: "recursive", Type: TypeBool},
+	{Name: "backup", Type: TypeBool},
+	{Name: "validate", Type: TypeString},
+	{Name: "checksum", Type: TypeString, OneOf: []string{"md5", "sha1", "sha256", "sha512"}},
+	{Name: "source_checksum", Type: TypeString},
+	{Name: "preserve_mode", Type: TypeBool},
+	{Name: "ensure_parent", Type: TypeBool},
+}
+
 // Validate validates file resource attributes
 func (p *FileProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
-	// Check for required attributes
-	path, ok := attributes["path"]
-	if !ok {
-		return fmt.Errorf("file resource requires 'path' attribute")
-	}
-
-	// Validate path is a string
-	_, ok = path.(string)
-	if !ok {
-		return fmt.Errorf("file 'path' must be a string")
+	if err := fileSchema.Validate("file", attributes); err != nil {
+		return err
 	}
+	path := attributes["path"].(string)
 
 	// Check for mutually exclusive attributes
 	if content, hasContent := attributes["content"]; hasContent {
@@ -47,32 +64,91 @@ func (p *FileProvider) Validate(ctx context.Context, attributes map[string]inter
 		}
 	}
 
-	// Validate state if present
-	if state, hasState := attributes["state"]; hasState {
-		stateStr, ok := state.(string)
+	// Check what the state requires
+	if stateStr, hasState := attributes["state"].(string); hasState {
+		// A directory source must not contain the directory it is copied to
+		if source, ok := attributes["source"].(string); ok && stateStr == "directory" {
+			if err := checkSourceNesting(path, source); err != nil {
+				return err
+			}
+			if _, hasSourceChecksum := attributes["source_checksum"]; hasSourceChecksum {
+				return fmt.Errorf("file 'source_checksum' cannot be used with a directory source")
+			}
+		}
+
+		// Links need a target to point at
+		if _, hasTarget := attributes["target"]; stateStr == "link" && !hasTarget {
+			return fmt.Errorf("file resource with state 'link' requires 'target' attribute")
+		}
+	}
+
+	// Validate the per-entry modes recursive allows
+	recursive, _ := attributes["recursive"].(bool)
+	for _, key := range []string{"file_mode", "dir_mode"} {
+		_, ok := attributes[key]
 		if !ok {
-			return fmt.Errorf("file 'state' must be a string")
+			continue
+		}
+		if !recursive {
+			return fmt.Errorf("file '%s' requires 'recursive' to be true", key)
 		}
+		if _, _, err := modeAttribute(attributes, key); err != nil {
+			return fmt.Errorf("file %v", err)
+		}
+	}
+
+	// Validate the validate command if present
+	if command, hasValidate := attributes["validate"].(string); hasValidate {
+		if !strings.Contains(command, "%s") || len(strings.Fields(command)) == 0 {
+			return fmt.Errorf("file 'validate' must be a command with a %%s placeholder for the file path")
+		}
+	}
 
-		if stateStr != "present" && stateStr != "absent" && stateStr != "directory" {
-			return fmt.Errorf("file 'state' must be one of: present, absent, directory")
+	// Validate source checksum if present
+	if checksumStr, hasSourceChecksum := attributes["source_checksum"].(string); hasSourceChecksum {
+		if _, hasSource := attributes["source"]; !hasSource {
+			return fmt.Errorf("file 'source_checksum' requires 'source'")
+		}
+		if algorithm, _, _ := strings.Cut(checksumStr, ":"); algorithm != "sha256" && algorithm != "sha512" {
+			return fmt.Errorf("file 'source_checksum' must use sha256 or sha512, got %q", checksumStr)
+		}
+		if _, _, err := parseChecksum(checksumStr); err != nil {
+			return fmt.Errorf("file 'source_checksum' %v", err)
 		}
 	}
 
 	// Validate mode if present
-	if mode, hasMode := attributes["mode"]; hasMode {
-		modeStr, ok := mode.(string)
-		if !ok {
-			return fmt.Errorf("file 'mode' must be a string")
+	if _, _, err := modeAttribute(attributes, "mode"); err != nil {
+		return fmt.Errorf("file %v", err)
+	}
+
+	// Validate owner and group if present
+	for _, key := range []string{"owner", "group"} {
+		if value, ok := attributes[key]; ok {
+			if _, ok := ownershipAttribute(attributes, key); !ok {
+				return fmt.Errorf("file '%s' must be a name or a numeric id, got %v", key, value)
+			}
 		}
+	}
 
-		// Try to parse as octal
-		_, err := strconv.ParseInt(modeStr, 8, 32)
-		if err != nil {
-			return fmt.Errorf("invalid file mode: %s", modeStr)
+	// Validate preserve_mode if present
+	if _, ok := attributes["preserve_mode"]; ok {
+		if _, hasSource := attributes["source"]; !hasSource {
+			return fmt.Errorf("file 'preserve_mode' requires 'source'")
 		}
 	}
 
+	// Validate parent_mode if present
+	ensureParent := true
+	if value, ok := attributes["ensure_parent"].(bool); ok {
+		ensureParent = value
+	}
+	if _, hasParentMode, err := modeAttribute(attributes, "parent_mode"); err != nil {
+		return fmt.Errorf("file %v", err)
+	} else if hasParentMode && !ensureParent {
+		return fmt.Errorf("file 'parent_mode' can't be used with ensure_parent = false")
+	}
+
 	return nil
 }
 
@@ -89,26 +165,123 @@ func (p *FileProvider) fileExists(path string) (bool, os.FileInfo, error) {
 	return true, info, nil
 }
 
-// calculateMD5 calculates the MD5 hash of a file
-func (p *FileProvider) calculateMD5(path string) (string, error) {
-	file, err := os.Open(path)
+// linkPointsTo reports whether path is a symlink to target, and whether anything exists at path
+func (p *FileProvider) linkPointsTo(path, target string) (bool, bool, error) {
+	info, err := os.Lstat(path)
 	if err != nil {
-		return "", err
+		if os.IsNotExist(err) {
+			return false, false, nil
+		}
+		return false, false, err
 	}
-	defer file.Close()
 
-	hash := md5.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", err
+	if info.Mode()&os.ModeSymlink == 0 {
+		return false, true, nil
+	}
+
+	current, err := os.Readlink(path)
+	if err != nil {
+		return false, true, err
+	}
+
+	return current == target, true, nil
+}
+
+// defaultChecksum is the algorithm used to compare files with their source
+const defaultChecksum = "sha256"
+
+// checksumAlgorithm returns the checksum attribute, defaulting to SHA-256
+func checksumAlgorithm(attributes map[string]interface{}) string {
+	if algorithm, ok := attributes["checksum"].(string); ok {
+		return algorithm
+	}
+	return defaultChecksum
+}
+
+// verifyChecksum checks data read from path against an "algo:hex" checksum
+func verifyChecksum(path string, data []byte, checksum string) error {
+	hasher, expected, err := parseChecksum(checksum)
+	if err != nil {
+		return err
+	}
+	hasher.Write(data)
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", path, expected, actual)
+	}
+	return nil
+}
+
+// matchesSource reports whether the file at path has the same checksum as source
+func (p *FileProvider) matchesSource(path, source, algorithm string) (bool, error) {
+	current, err := fileChecksum(path, algorithm)
+	if err != nil {
+		return false, err
 	}
 
-	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+	expected, err := fileChecksum(source, algorithm)
+	if err != nil {
+		return false, err
+	}
+
+	return current == expected, nil
 }
 
-// Plan determines what changes would be made to a file
+// Read returns the live state of the path: whether it exists, whether it is
+// a file or a directory, its owner, group and mode, its uid and gid, and the
+// target when the path is a symlink. Symlinks are otherwise followed.
+func (p *FileProvider) Read(ctx context.Context, attributes map[string]interface{}) (map[string]interface{}, error) {
+	path, _ := attributes["path"].(string)
+	live := map[string]interface{}{"path": path, "exists": false}
+
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		live["target"] = target
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	exists, info, err := p.fileExists(path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return live, nil
+	}
+
+	live["exists"] = true
+	live["type"] = "file"
+	if info.IsDir() {
+		live["type"] = "directory"
+	}
+	for key, value := range p.readPermissions(info) {
+		live[key] = value
+	}
+
+	return live, nil
+}
+
+// liveFileState returns current when it is the result of Read, and otherwise
+// reads the live state of the path
+func (p *FileProvider) liveFileState(ctx context.Context, current, desired map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := current["exists"].(bool); ok {
+		return current, nil
+	}
+	return p.Read(ctx, desired)
+}
+
+// Plan determines what changes would be made to a file. The current state is
+// taken from current when it was returned by Read.
 func (p *FileProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
 	path := desired["path"].(string)
 
+	desired, err := preservedMode(desired)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get desired state or default to "present"
 	state := "present"
 	if desiredState, ok := desired["state"].(string); ok {
@@ -123,56 +296,75 @@ func (p *FileProvider) Plan(ctx context.Context, current, desired map[string]int
 	}
 
 	// Check if the file exists
-	exists, fileInfo, err := p.fileExists(path)
+	live, err := p.liveFileState(ctx, current, desired)
 	if err != nil {
 		return nil, err
 	}
+	exists, _ := live["exists"].(bool)
+	isDir := live["type"] == "directory"
+
+	// A path that must be created needs its parent when ensure_parent is false
+	if !exists && state != "absent" {
+		if err := checkParent(path, desired); err != nil {
+			return nil, err
+		}
+	}
 
 	switch state {
 	case "absent":
 		if exists {
 			// File exists, needs to be removed
 			result.Status = "planned"
+			result.Changes = append(result.Changes, "state")
+		}
+
+	case "link":
+		target, isLink := live["target"].(string)
+		if !isLink || target != desired["target"].(string) {
+			// Link is missing, points elsewhere or path is not a link
+			result.Status = "planned"
+			if exists || isLink {
+				result.Changes = append(result.Changes, "target")
+			} else {
+				result.Changes = append(result.Changes, "state")
+			}
 		}
 
 	case "directory":
 		if !exists {
 			// Directory doesn't exist, needs to be created
 			result.Status = "planned"
-		} else if !fileInfo.IsDir() {
+			result.Changes = append(result.Changes, "state")
+		} else if !isDir {
 			// Path exists but is not a directory
 			result.Status = "planned"
+			result.Changes = append(result.Changes, "type")
+		} else if recursive, _ := desired["recursive"].(bool); recursive {
+			// Directory exists, check permissions of everything in it
+			changes, err := p.treeDrift(path, desired)
+			if err != nil {
+				return nil, err
+			}
+			if len(changes) > 0 {
+				result.Status = "planned"
+				result.Changes = append(result.Changes, changes...)
+			}
 		} else {
 			// Directory exists, check permissions
-			if owner, hasOwner := desired["owner"].(string); hasOwner && runtime.GOOS != "windows" {
-				currentOwner, err := p.getOwner(fileInfo)
-				if err != nil {
-					return nil, err
-				}
-
-				if currentOwner != owner {
-					result.Status = "planned"
-				}
-			}
-
-			if group, hasGroup := desired["group"].(string); hasGroup && runtime.GOOS != "windows" {
-				currentGroup, err := p.getGroup(fileInfo)
-				if err != nil {
-					return nil, err
-				}
+			addPermissionChanges(result, permissionChanges(live, desired))
+		}
 
-				if currentGroup != group {
-					result.Status = "planned"
-				}
+		// Check which files a directory source would copy into an existing
+		// directory, or a directory that will be created
+		if source, hasSource := desired["source"].(string); hasSource && (!exists || isDir) {
+			create, update, err := sourceDrift(path, source, checksumAlgorithm(desired))
+			if err != nil {
+				return nil, err
 			}
-
-			if mode, hasMode := desired["mode"].(string); hasMode && runtime.GOOS != "windows" {
-				desiredMode, _ := strconv.ParseInt(mode, 8, 32)
-				currentMode := fileInfo.Mode().Perm()
-
-				if os.FileMode(desiredMode) != currentMode {
-					result.Status = "planned"
-				}
+			if len(create) > 0 || len(update) > 0 {
+				result.Status = "planned"
+				result.Changes = append(result.Changes, "content")
+				result.Details = sourceDetails(create, update)
 			}
 		}
 
@@ -183,78 +375,81 @@ func (p *FileProvider) Plan(ctx context.Context, current, desired map[string]int
 		if !exists {
 			// File doesn't exist, needs to be created
 			result.Status = "planned"
-		} else if fileInfo.IsDir() {
+			result.Changes = append(result.Changes, "state")
+		} else if isDir {
 			// Path exists but is a directory, not a file
 			result.Status = "planned"
+			result.Changes = append(result.Changes, "type")
 		} else if hasContent {
-			// File exists, check if content matches
-			currentContent, err := ioutil.ReadFile(path)
-			if err != nil {
-				return nil, err
-			}
+			// File exists, check if content matches. A file that hasn't
+			// changed since it was last written with this content isn't read.
+			if !cachedContentMatches(ctx, path, content) {
+				currentContent, err := p.readFile(path)
+				if err != nil {
+					return nil, err
+				}
 
-			if string(currentContent) != content {
-				result.Status = "planned"
+				if string(currentContent) != content {
+					result.Status = "planned"
+					result.Changes = append(result.Changes, "content")
+					result.addDiff("content", "", "")
+					result.Details = unifiedDiff(path, string(currentContent), content)
+				}
 			}
 		} else if hasSource {
 			// File exists, check if content matches source
-			currentMD5, err := p.calculateMD5(path)
+			matches, err := p.matchesSource(path, source, checksumAlgorithm(desired))
 			if err != nil {
 				return nil, err
 			}
 
-			sourceMD5, err := p.calculateMD5(source)
-			if err != nil {
-				return nil, err
-			}
-
-			if currentMD5 != sourceMD5 {
+			if !matches {
 				result.Status = "planned"
-			}
-		}
+				result.Changes = append(result.Changes, "content")
+				result.addDiff("content", "", "")
 
-		// Check permissions for file
-		if exists && !fileInfo.IsDir() && runtime.GOOS != "windows" {
-			if owner, hasOwner := desired["owner"].(string); hasOwner {
-				currentOwner, err := p.getOwner(fileInfo)
+				currentContent, err := ioutil.ReadFile(path)
 				if err != nil {
 					return nil, err
 				}
-
-				if currentOwner != owner {
-					result.Status = "planned"
-				}
-			}
-
-			if group, hasGroup := desired["group"].(string); hasGroup {
-				currentGroup, err := p.getGroup(fileInfo)
+				sourceContent, err := ioutil.ReadFile(source)
 				if err != nil {
 					return nil, err
 				}
-
-				if currentGroup != group {
-					result.Status = "planned"
-				}
+				result.Details = unifiedDiff(path, string(currentContent), string(sourceContent))
 			}
+		}
 
-			if mode, hasMode := desired["mode"].(string); hasMode {
-				desiredMode, _ := strconv.ParseInt(mode, 8, 32)
-				currentMode := fileInfo.Mode().Perm()
-
-				if os.FileMode(desiredMode) != currentMode {
-					result.Status = "planned"
-				}
-			}
+		// Check permissions for file
+		if exists && !isDir {
+			addPermissionChanges(result, permissionChanges(live, desired))
 		}
 	}
 
 	return result, nil
 }
 
+// DesiredAttributes translates an intent into file attributes
+func (p *FileProvider) DesiredAttributes(intent Intent, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	return intentAttributes(intent, attributes, map[string]interface{}{"state": "absent"})
+}
+
 // Apply creates, updates, or deletes a file
 func (p *FileProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
 	path := state.Attributes["path"].(string)
 
+	if IsDryRun(ctx) {
+		return p.dryRun(ctx, state)
+	}
+
+	attributes, err := preservedMode(state.Attributes)
+	if err != nil {
+		return &ResourceState{Type: state.Type, Name: state.Name, Attributes: state.Attributes, Status: "failed", Error: err}, err
+	}
+	withMode := *state
+	withMode.Attributes = attributes
+	state = &withMode
+
 	// Get desired state or default to "present"
 	desiredState := "present"
 	if state, ok := state.Attributes["state"].(string); ok {
@@ -286,10 +481,63 @@ func (p *FileProvider) Apply(ctx context.Context, state *ResourceState) (*Resour
 			result.Status = "deleted"
 		}
 
+	case "link":
+		target := state.Attributes["target"].(string)
+		force, _ := state.Attributes["force"].(bool)
+
+		correct, linkExists, err := p.linkPointsTo(path, target)
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+		if correct {
+			break
+		}
+
+		// Relative targets are resolved from the directory containing the link
+		resolved := target
+		if !filepath.IsAbs(resolved) {
+			resolved = filepath.Join(filepath.Dir(path), target)
+		}
+		if _, err := os.Stat(resolved); os.IsNotExist(err) && !force {
+			err := fmt.Errorf("symlink target %s does not exist (set force = true to link anyway)", target)
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+
+		if err := p.ensureParent(path, state.Attributes); err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+
+		// Replace whatever is at path: a stale link, a regular file or a directory
+		if linkExists {
+			if err := os.RemoveAll(path); err != nil {
+				result.Status = "failed"
+				result.Error = err
+				return result, err
+			}
+		}
+
+		if err := os.Symlink(target, path); err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+
+		if linkExists {
+			result.Status = "updated"
+		} else {
+			result.Status = "created"
+		}
+
 	case "directory":
 		if !exists {
 			// Create the directory
-			if err := os.MkdirAll(path, 0755); err != nil {
+			if err := p.makeDir(path, state.Attributes); err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
@@ -303,7 +551,7 @@ func (p *FileProvider) Apply(ctx context.Context, state *ResourceState) (*Resour
 				return result, err
 			}
 
-			if err := os.MkdirAll(path, 0755); err != nil {
+			if err := p.makeDir(path, state.Attributes); err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
@@ -311,13 +559,42 @@ func (p *FileProvider) Apply(ctx context.Context, state *ResourceState) (*Resour
 			result.Status = "updated"
 		}
 
-		// Set permissions for directory
-		if runtime.GOOS != "windows" {
-			if err := p.setPermissions(path, state.Attributes); err != nil {
+		// Copy in the files of a directory source
+		if source, hasSource := state.Attributes["source"].(string); hasSource {
+			copied, err := p.copySource(path, source, checksumAlgorithm(state.Attributes))
+			if err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
 			}
+			if copied && result.Status == "unchanged" {
+				result.Status = "updated"
+			}
+		}
+
+		// Set permissions for directory
+		if runtime.GOOS != "windows" {
+			if recursive, _ := state.Attributes["recursive"].(bool); recursive {
+				changed, err := p.setTreePermissions(path, state.Attributes)
+				if err != nil {
+					result.Status = "failed"
+					result.Error = err
+					return result, err
+				}
+				if changed && result.Status == "unchanged" {
+					result.Status = "updated"
+				}
+			} else {
+				changed, err := p.setPathPermissions(path, state.Attributes)
+				if err != nil {
+					result.Status = "failed"
+					result.Error = err
+					return result, err
+				}
+				if changed && result.Status == "unchanged" {
+					result.Status = "updated"
+				}
+			}
 		}
 
 	case "present":
@@ -337,9 +614,9 @@ func (p *FileProvider) Apply(ctx context.Context, state *ResourceState) (*Resour
 				return result, err
 			}
 			needsUpdate = true
-		} else if hasContent {
+		} else if hasContent && !cachedContentMatches(ctx, path, content) {
 			// Check if content matches
-			currentContent, err := ioutil.ReadFile(path)
+			currentContent, err := p.readFile(path)
 			if err != nil {
 				result.Status = "failed"
 				result.Error = err
@@ -351,21 +628,14 @@ func (p *FileProvider) Apply(ctx context.Context, state *ResourceState) (*Resour
 			}
 		} else if hasSource {
 			// Check if content matches source
-			currentMD5, err := p.calculateMD5(path)
+			matches, err := p.matchesSource(path, source, checksumAlgorithm(state.Attributes))
 			if err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
 			}
 
-			sourceMD5, err := p.calculateMD5(source)
-			if err != nil {
-				result.Status = "failed"
-				result.Error = err
-				return result, err
-			}
-
-			if currentMD5 != sourceMD5 {
+			if !matches {
 				needsUpdate = true
 			}
 		}
@@ -373,20 +643,24 @@ func (p *FileProvider) Apply(ctx context.Context, state *ResourceState) (*Resour
 		// Create or update file
 		if needsUpdate {
 			// Ensure parent directory exists
-			dir := filepath.Dir(path)
-			if err := os.MkdirAll(dir, 0755); err != nil {
+			if err := p.ensureParent(path, state.Attributes); err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
 			}
 
-			if hasContent {
-				// Write content to file
-				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			// Keep a copy of the file being replaced if asked to
+			if backup, _ := state.Attributes["backup"].(bool); backup && exists && !fileInfo.IsDir() {
+				if err := p.backupFile(path); err != nil {
 					result.Status = "failed"
 					result.Error = err
 					return result, err
 				}
+			}
+
+			var data []byte
+			if hasContent {
+				data = []byte(content)
 			} else if hasSource {
 				// Copy from source file
 				sourceData, err := ioutil.ReadFile(source)
@@ -395,14 +669,34 @@ func (p *FileProvider) Apply(ctx context.Context, state *ResourceState) (*Resour
 					result.Error = err
 					return result, err
 				}
+				// Refuse to copy a source that isn't what's expected
+				if sourceChecksum, ok := state.Attributes["source_checksum"].(string); ok {
+					if err := verifyChecksum(source, sourceData, sourceChecksum); err != nil {
+						result.Status = "failed"
+						result.Error = err
+						return result, err
+					}
+				}
+				data = sourceData
+			}
 
-				if err := ioutil.WriteFile(path, sourceData, 0644); err != nil {
-					result.Status = "failed"
-					result.Error = err
-					return result, err
+			// Check the new content with the validate command before it replaces the file
+			var check func(tmpPath string) error
+			if validate, ok := state.Attributes["validate"].(string); ok {
+				check = func(tmpPath string) error {
+					output, err := p.validateContent(ctx, validate, tmpPath)
+					result.Output = output
+					return err
 				}
 			}
 
+			// The file is written with its mode, or created empty without content or source
+			if err := p.writeFileAtomic(path, data, p.fileMode(state.Attributes, fileInfo), check); err != nil {
+				result.Status = "failed"
+				result.Error = err
+				return result, err
+			}
+
 			if exists {
 				result.Status = "updated"
 			} else {
@@ -412,17 +706,604 @@ func (p *FileProvider) Apply(ctx context.Context, state *ResourceState) (*Resour
 
 		// Set permissions for file
 		if runtime.GOOS != "windows" {
-			if err := p.setPermissions(path, state.Attributes); err != nil {
+			changed, err := p.setPathPermissions(path, state.Attributes)
+			if err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
 			}
+			if changed && result.Status == "unchanged" {
+				result.Status = "updated"
+			}
+		}
+
+		// Record what the file holds so the next run can skip reading it
+		if hasContent {
+			result.Outputs = contentOutputs(path, content)
 		}
 	}
 
+	result.Changed = result.Status != "unchanged"
 	return result, nil
 }
 
+// SupportsDryRun reports that Apply honors IsDryRun
+func (p *FileProvider) SupportsDryRun() bool {
+	return true
+}
+
+// dryRun returns the status Apply would return for the path, planning the
+// change instead of making it
+func (p *FileProvider) dryRun(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	path := state.Attributes["path"].(string)
+	result := &ResourceState{
+		Type:       state.Type,
+		Name:       state.Name,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	planned, err := p.Plan(ctx, nil, state.Attributes)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+	if planned.Status != "planned" {
+		return result, nil
+	}
+
+	_, err = os.Lstat(path)
+	exists := err == nil
+	switch desiredState, _ := state.Attributes["state"].(string); {
+	case desiredState == "absent":
+		result.Status = "deleted"
+	case exists:
+		result.Status = "updated"
+	default:
+		result.Status = "created"
+	}
+	result.Changed = true
+	result.Changes = planned.Changes
+	result.Diff = planned.Diff
+	result.Details = planned.Details
+
+	changes := ""
+	if len(planned.Changes) > 0 {
+		changes = " (" + strings.Join(planned.Changes, ", ") + ")"
+	}
+	logging.FromContext(ctx).Infof("Would %s %s%s", strings.TrimSuffix(result.Status, "d"), path, changes)
+	return result, nil
+}
+
+// contentOutputs returns the SHA-256 of the content of the file at path with
+// the file's size and modification time, or nil if it can't be read
+func contentOutputs(path, content string) map[string]string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(content))
+	return map[string]string{
+		"content_sha256": hex.EncodeToString(sum[:]),
+		"content_size":   strconv.FormatInt(info.Size(), 10),
+		"content_mtime":  strconv.FormatInt(info.ModTime().UnixNano(), 10),
+	}
+}
+
+// cachedContentMatches reports whether the file at path still holds content,
+// judged without reading it: the last apply recorded content with the same
+// hash, and the file's size and modification time are unchanged since then
+func cachedContentMatches(ctx context.Context, path, content string) bool {
+	prior := priorOutputs(ctx)
+	if prior["content_sha256"] == "" {
+		return false
+	}
+
+	current := contentOutputs(path, content)
+	return current != nil &&
+		current["content_sha256"] == prior["content_sha256"] &&
+		current["content_size"] == prior["content_size"] &&
+		current["content_mtime"] == prior["content_mtime"]
+}
+
+// preservedMode returns the attributes with mode set to the permission bits
+// of the source file when preserve_mode is true and no mode is given, so the
+// copy is created and kept with the source's mode. Directory sources already
+// copy each entry with its own mode.
+func preservedMode(attributes map[string]interface{}) (map[string]interface{}, error) {
+	preserve, _ := attributes["preserve_mode"].(bool)
+	source, hasSource := attributes["source"].(string)
+	if _, hasMode := attributes["mode"]; !preserve || !hasSource || hasMode {
+		return attributes, nil
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the mode of source %s: %v", source, err)
+	}
+	if info.IsDir() {
+		return attributes, nil
+	}
+
+	result := make(map[string]interface{}, len(attributes)+1)
+	for key, value := range attributes {
+		result[key] = value
+	}
+	result["mode"] = fmt.Sprintf("%04o", info.Mode().Perm())
+	return result, nil
+}
+
+// fileMode returns the mode a written file should have: the mode attribute,
+// else the mode of the file being replaced, else 0644
+func (p *FileProvider) fileMode(attributes map[string]interface{}, existing os.FileInfo) os.FileMode {
+	if mode, hasMode, _ := modeAttribute(attributes, "mode"); hasMode {
+		return mode
+	}
+	if existing != nil && existing.Mode().IsRegular() {
+		return existing.Mode().Perm()
+	}
+	return 0644
+}
+
+// dirMode returns the mode a created directory should have: dir_mode or mode,
+// else 0755
+func dirMode(attributes map[string]interface{}) os.FileMode {
+	if mode, hasMode, _ := modeAttribute(entryAttributes(attributes, true), "mode"); hasMode {
+		return mode
+	}
+	return 0755
+}
+
+// makeDir creates the directory at path with its dir_mode or mode, after
+// ensuring its parent exists. The directory is created with the mode rather
+// than changed to it afterwards, and is only changed when the umask removed
+// some of its bits.
+func (p *FileProvider) makeDir(path string, attributes map[string]interface{}) error {
+	if err := p.ensureParent(path, attributes); err != nil {
+		return err
+	}
+	mode := dirMode(attributes)
+	if err := os.Mkdir(path, mode); err != nil {
+		return err
+	}
+	return p.chmodIfChanged(path, mode)
+}
+
+// checkParent returns an error if the parent directory of path doesn't exist
+// and ensure_parent is false
+func checkParent(path string, attributes map[string]interface{}) error {
+	if ensure, ok := attributes["ensure_parent"].(bool); !ok || ensure {
+		return nil
+	}
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("parent directory %s does not exist (set ensure_parent = true to create it)", dir)
+	}
+	return nil
+}
+
+// ensureParent creates the missing parent directories of path with
+// parent_mode, or 0755, applied exactly. With ensure_parent = false a
+// missing parent is an error instead.
+func (p *FileProvider) ensureParent(path string, attributes map[string]interface{}) error {
+	if err := checkParent(path, attributes); err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0755)
+	if parentMode, ok, _ := modeAttribute(attributes, "parent_mode"); ok {
+		mode = parentMode
+	}
+
+	// Find the missing directories, nearest the root first
+	var missing []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		missing = append([]string{dir}, missing...)
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	for _, dir := range missing {
+		if err := os.Mkdir(dir, mode); err != nil && !os.IsExist(err) {
+			return err
+		}
+		if err := p.chmodIfChanged(dir, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chmodIfChanged changes the mode of path unless it already has mode
+func (p *FileProvider) chmodIfChanged(path string, mode os.FileMode) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm() == mode {
+		return nil
+	}
+	return p.chmod(path, mode)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never see a partially written file. The temporary
+// file is given mode before anything is written, so the file never appears
+// at path with any other mode. If check is set, it is called with the
+// temporary file's path and must succeed for the file to be replaced.
+func (p *FileProvider) writeFileAtomic(path string, data []byte, mode os.FileMode, check func(tmpPath string) error) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %v", path, err)
+	}
+	tmpPath := tmp.Name()
+
+	// Clean up the temporary file unless it was renamed into place
+	renamed := false
+	defer func() {
+		if !renamed {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	// TempFile creates files with mode 0600
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to change mode of %s: %v", path, err)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	if check != nil {
+		if err := check(tmpPath); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", path, err)
+	}
+	renamed = true
+
+	return nil
+}
+
+// validateContent runs a validate command, substituting path for each %s in
+// its arguments, and returns the command's output
+func (p *FileProvider) validateContent(ctx context.Context, command, path string) (string, error) {
+	args := strings.Fields(command)
+	for i, arg := range args {
+		args[i] = strings.ReplaceAll(arg, "%s", path)
+	}
+
+	output, err := p.runCommand(ctx, args[0], args[1:]...)
+	if err != nil {
+		return string(output), fmt.Errorf("validation command %q failed: %v: %s", command, err, strings.TrimSpace(string(output)))
+	}
+
+	return string(output), nil
+}
+
+// backupFile copies the file at path to path.bak, keeping its mode
+func (p *FileProvider) backupFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s for backup: %v", path, err)
+	}
+
+	if err := p.writeFileAtomic(path+".bak", data, info.Mode().Perm(), nil); err != nil {
+		return fmt.Errorf("failed to back up %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// permissionAttributes are the attributes permissionDiff compares, in the
+// order changes to them are reported
+var permissionAttributes = []string{"owner", "group", "mode"}
+
+// permissionDrift returns which of the owner, group and mode attributes
+// differ from the entry's current permissions
+func (p *FileProvider) permissionDrift(info os.FileInfo, attributes map[string]interface{}) ([]string, error) {
+	diff, err := p.permissionDiff(info, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	for _, attribute := range permissionAttributes {
+		if _, ok := diff[attribute]; ok {
+			changes = append(changes, attribute)
+		}
+	}
+	return changes, nil
+}
+
+// permissionDiff returns the current and desired values of the owner, group
+// and mode attributes that differ from the entry's current permissions
+func (p *FileProvider) permissionDiff(info os.FileInfo, attributes map[string]interface{}) (map[string]AttributeDiff, error) {
+	if runtime.GOOS == "windows" {
+		return make(map[string]AttributeDiff), nil
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, fmt.Errorf("failed to get file stats")
+	}
+
+	// Owner and group names are only looked up when they are compared
+	live := map[string]interface{}{
+		"mode": fmt.Sprintf("%04o", info.Mode().Perm()),
+		"uid":  strconv.FormatUint(uint64(stat.Uid), 10),
+		"gid":  strconv.FormatUint(uint64(stat.Gid), 10),
+	}
+	if _, hasOwner := ownershipAttribute(attributes, "owner"); hasOwner {
+		if owner, err := p.getOwner(info); err == nil {
+			live["owner"] = owner
+		}
+	}
+	if _, hasGroup := ownershipAttribute(attributes, "group"); hasGroup {
+		if group, err := p.getGroup(info); err == nil {
+			live["group"] = group
+		}
+	}
+
+	return permissionChanges(live, attributes), nil
+}
+
+// readPermissions returns the owner, group and mode of an entry, with the
+// numeric uid and gid. An owner or group whose name can't be looked up, such
+// as an id missing from the passwd database, is left out.
+func (p *FileProvider) readPermissions(info os.FileInfo) map[string]interface{} {
+	permissions := map[string]interface{}{"mode": fmt.Sprintf("%04o", info.Mode().Perm())}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		permissions["uid"] = strconv.FormatUint(uint64(stat.Uid), 10)
+		permissions["gid"] = strconv.FormatUint(uint64(stat.Gid), 10)
+	}
+	if owner, err := p.getOwner(info); err == nil {
+		permissions["owner"] = owner
+	}
+	if group, err := p.getGroup(info); err == nil {
+		permissions["group"] = group
+	}
+	return permissions
+}
+
+// ownershipIDKeys maps the owner and group attributes to the live values
+// holding their numeric ids
+var ownershipIDKeys = map[string]string{"owner": "uid", "group": "gid"}
+
+// ownershipAttribute returns an owner or group attribute as a string. Names
+// are returned as is and numeric ids, written as numbers or strings, in
+// decimal.
+func ownershipAttribute(attributes map[string]interface{}, key string) (string, bool) {
+	switch value := attributes[key].(type) {
+	case string:
+		return value, value != ""
+	case int:
+		return strconv.Itoa(value), value >= 0
+	case int64:
+		return strconv.FormatInt(value, 10), value >= 0
+	case float64:
+		return strconv.FormatInt(int64(value), 10), value >= 0 && value == float64(int64(value))
+	default:
+		return "", false
+	}
+}
+
+// isNumericID reports whether an owner or group is a numeric id rather than a name
+func isNumericID(value string) bool {
+	_, err := strconv.ParseUint(value, 10, 32)
+	return err == nil
+}
+
+// lookupUID returns the uid of an owner, which is either a numeric id or a
+// user name
+func lookupUID(owner string) (int, error) {
+	if isNumericID(owner) {
+		return strconv.Atoi(owner)
+	}
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// lookupGID returns the gid of a group, which is either a numeric id or a
+// group name
+func lookupGID(group string) (int, error) {
+	if isNumericID(group) {
+		return strconv.Atoi(group)
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// ownershipMatches reports whether the desired owner or group is the entry's
+// current one, comparing by name or, failing that, by numeric id
+func ownershipMatches(attribute, desired string, live map[string]interface{}) bool {
+	if current, _ := live[attribute].(string); current == desired {
+		return true
+	}
+	currentID, ok := live[ownershipIDKeys[attribute]].(string)
+	if !ok {
+		return false
+	}
+
+	lookup := lookupUID
+	if attribute == "group" {
+		lookup = lookupGID
+	}
+	id, err := lookup(desired)
+	return err == nil && strconv.Itoa(id) == currentID
+}
+
+// permissionChanges returns the current and desired values of the owner,
+// group and mode attributes that differ between the live permissions, as
+// returned by readPermissions, and the desired attributes
+func permissionChanges(live, attributes map[string]interface{}) map[string]AttributeDiff {
+	diff := make(map[string]AttributeDiff)
+	if runtime.GOOS == "windows" {
+		return diff
+	}
+
+	for _, attribute := range []string{"owner", "group"} {
+		desired, ok := ownershipAttribute(attributes, attribute)
+		if !ok || ownershipMatches(attribute, desired, live) {
+			continue
+		}
+
+		// An id without a name is shown as the number
+		current, _ := live[attribute].(string)
+		if current == "" {
+			current, _ = live[ownershipIDKeys[attribute]].(string)
+		}
+		diff[attribute] = AttributeDiff{Old: current, New: desired}
+	}
+
+	if desiredMode, hasMode, _ := modeAttribute(attributes, "mode"); hasMode {
+		currentMode, _ := live["mode"].(string)
+		if current, err := strconv.ParseInt(currentMode, 8, 32); err != nil || os.FileMode(current) != desiredMode {
+			diff["mode"] = AttributeDiff{Old: currentMode, New: fmt.Sprintf("%04o", desiredMode)}
+		}
+	}
+
+	return diff
+}
+
+// addPermissionChanges records permission differences in a planned state
+func addPermissionChanges(result *ResourceState, diff map[string]AttributeDiff) {
+	for _, attribute := range permissionAttributes {
+		change, ok := diff[attribute]
+		if !ok {
+			continue
+		}
+		result.Status = "planned"
+		result.Changes = append(result.Changes, attribute)
+		result.addDiff(attribute, change.Old, change.New)
+	}
+}
+
+// entryAttributes returns the owner, group and mode to apply to one entry of
+// a recursive directory: dir_mode or file_mode, falling back to mode
+func entryAttributes(attributes map[string]interface{}, isDir bool) map[string]interface{} {
+	entry := make(map[string]interface{})
+	for _, key := range []string{"owner", "group", "mode"} {
+		if value, ok := attributes[key]; ok {
+			entry[key] = value
+		}
+	}
+
+	modeKey := "file_mode"
+	if isDir {
+		modeKey = "dir_mode"
+	}
+	if mode, ok := attributes[modeKey]; ok {
+		entry["mode"] = mode
+	}
+
+	return entry
+}
+
+// walkTree calls fn for the directory at root and every file and directory
+// below it. Symlinks are skipped so permissions never leak outside the tree.
+func walkTree(root string, fn func(path string, info os.FileInfo) error) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&os.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, info)
+	})
+}
+
+// treeDrift returns which permissions differ anywhere in the directory tree
+func (p *FileProvider) treeDrift(root string, attributes map[string]interface{}) ([]string, error) {
+	found := make(map[string]bool)
+	err := walkTree(root, func(path string, info os.FileInfo) error {
+		changes, err := p.permissionDrift(info, entryAttributes(attributes, info.IsDir()))
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			found[change] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	for _, change := range []string{"owner", "group", "mode"} {
+		if found[change] {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// setTreePermissions applies owner, group and mode to every entry in the
+// directory tree that drifted, reporting whether anything changed
+func (p *FileProvider) setTreePermissions(root string, attributes map[string]interface{}) (bool, error) {
+	changed := false
+	err := walkTree(root, func(path string, info os.FileInfo) error {
+		entry := entryAttributes(attributes, info.IsDir())
+		changes, err := p.permissionDrift(info, entry)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		changed = true
+		return p.setPermissions(path, entry)
+	})
+	return changed, err
+}
+
+// setPathPermissions sets the owner, group and mode of path when they have
+// drifted, and reports whether they had
+func (p *FileProvider) setPathPermissions(path string, attributes map[string]interface{}) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	changes, err := p.permissionDrift(info, attributes)
+	if err != nil || len(changes) == 0 {
+		return false, err
+	}
+	return true, p.setPermissions(path, attributes)
+}
+
 // getOwner gets the owner of a file
 func (p *FileProvider) getOwner(fileInfo os.FileInfo) (string, error) {
 	if runtime.GOOS == "windows" {
@@ -467,79 +1348,39 @@ func (p *FileProvider) setPermissions(path string, attributes map[string]interfa
 		return nil // Not supported on Windows
 	}
 
-	// Set owner and group
-	if owner, hasOwner := attributes["owner"].(string); hasOwner {
-		if group, hasGroup := attributes["group"].(string); hasGroup {
-			// Get UID for owner
-			u, err := user.Lookup(owner)
-			if err != nil {
+	// Set owner and group. An id of -1 leaves the other one unchanged.
+	owner, hasOwner := ownershipAttribute(attributes, "owner")
+	group, hasGroup := ownershipAttribute(attributes, "group")
+	if hasOwner || hasGroup {
+		uid, gid := -1, -1
+		var err error
+		if hasOwner {
+			if uid, err = lookupUID(owner); err != nil {
 				return fmt.Errorf("failed to lookup owner '%s': %v", owner, err)
 			}
-			uid, _ := strconv.Atoi(u.Uid)
-
-			// Get GID for group
-			g, err := user.LookupGroup(group)
-			if err != nil {
+		}
+		if hasGroup {
+			if gid, err = lookupGID(group); err != nil {
 				return fmt.Errorf("failed to lookup group '%s': %v", group, err)
 			}
-			gid, _ := strconv.Atoi(g.Gid)
+		}
 
-			// Change owner and group
-			if err := os.Chown(path, uid, gid); err != nil {
+		if err := os.Chown(path, uid, gid); err != nil {
+			switch {
+			case hasOwner && hasGroup:
 				return fmt.Errorf("failed to change ownership to %s:%s: %v", owner, group, err)
-			}
-		} else {
-			// Get UID for owner
-			u, err := user.Lookup(owner)
-			if err != nil {
-				return fmt.Errorf("failed to lookup owner '%s': %v", owner, err)
-			}
-			uid, _ := strconv.Atoi(u.Uid)
-
-			// Change owner only
-			fileInfo, err := os.Stat(path)
-			if err != nil {
-				return err
-			}
-
-			stat, ok := fileInfo.Sys().(*syscall.Stat_t)
-			if !ok {
-				return fmt.Errorf("failed to get file stats")
-			}
-
-			if err := os.Chown(path, uid, int(stat.Gid)); err != nil {
+			case hasOwner:
 				return fmt.Errorf("failed to change owner to %s: %v", owner, err)
+			default:
+				return fmt.Errorf("failed to change group to %s: %v", group, err)
 			}
 		}
-	} else if group, hasGroup := attributes["group"].(string); hasGroup {
-		// Get GID for group
-		g, err := user.LookupGroup(group)
-		if err != nil {
-			return fmt.Errorf("failed to lookup group '%s': %v", group, err)
-		}
-		gid, _ := strconv.Atoi(g.Gid)
-
-		// Change group only
-		fileInfo, err := os.Stat(path)
-		if err != nil {
-			return err
-		}
-
-		stat, ok := fileInfo.Sys().(*syscall.Stat_t)
-		if !ok {
-			return fmt.Errorf("failed to get file stats")
-		}
-
-		if err := os.Chown(path, int(stat.Uid), gid); err != nil {
-			return fmt.Errorf("failed to change group to %s: %v", group, err)
-		}
 	}
 
-	// Set mode
-	if mode, hasMode := attributes["mode"].(string); hasMode {
-		modeVal, _ := strconv.ParseInt(mode, 8, 32)
-		if err := os.Chmod(path, os.FileMode(modeVal)); err != nil {
-			return fmt.Errorf("failed to change mode to %s: %v", mode, err)
+	// Set mode, unless the file was already created with it
+	if mode, hasMode, _ := modeAttribute(attributes, "mode"); hasMode {
+		if err := p.chmodIfChanged(path, mode); err != nil {
+			return fmt.Errorf("failed to change mode to %04o: %v", mode, err)
 		}
 	}
 
@@ -2,11 +2,21 @@ package providers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/user"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/dangerclosesec/zero/pkg/logging"
 )
 
 func TestFileProvider_Validate(t *testing.T) {
@@ -108,6 +118,7 @@ func TestFileProvider_Plan_FilePresent(t *testing.T) {
 		t.Fatalf("Plan returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "unchanged" {
 		t.Errorf("Expected status 'unchanged', got '%s'", result.Status)
 	}
@@ -119,6 +130,7 @@ func TestFileProvider_Plan_FilePresent(t *testing.T) {
 		t.Fatalf("Plan returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "planned" {
 		t.Errorf("Expected status 'planned', got '%s'", result.Status)
 	}
@@ -150,6 +162,7 @@ func TestFileProvider_Plan_FileAbsent(t *testing.T) {
 		t.Fatalf("Plan returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "planned" {
 		t.Errorf("Expected status 'planned', got '%s'", result.Status)
 	}
@@ -161,6 +174,7 @@ func TestFileProvider_Plan_FileAbsent(t *testing.T) {
 		t.Fatalf("Plan returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "unchanged" {
 		t.Errorf("Expected status 'unchanged', got '%s'", result.Status)
 	}
@@ -195,6 +209,7 @@ func TestFileProvider_Plan_Directory(t *testing.T) {
 		t.Fatalf("Plan returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "unchanged" {
 		t.Errorf("Expected status 'unchanged', got '%s'", result.Status)
 	}
@@ -211,6 +226,7 @@ func TestFileProvider_Plan_Directory(t *testing.T) {
 		t.Fatalf("Plan returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "planned" {
 		t.Errorf("Expected status 'planned', got '%s'", result.Status)
 	}
@@ -247,6 +263,7 @@ func TestFileProvider_Apply_CreateFile(t *testing.T) {
 		t.Fatalf("Apply returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "created" {
 		t.Errorf("Expected status 'created', got '%s'", result.Status)
 	}
@@ -293,6 +310,7 @@ func TestFileProvider_Apply_CreateDirectory(t *testing.T) {
 		t.Fatalf("Apply returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "created" {
 		t.Errorf("Expected status 'created', got '%s'", result.Status)
 	}
@@ -308,6 +326,176 @@ func TestFileProvider_Apply_CreateDirectory(t *testing.T) {
 	}
 }
 
+func TestFileProvider_Apply_CreateWithMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping on Windows due to permission differences")
+	}
+
+	tempDir := t.TempDir()
+	provider := NewFileProvider()
+	var chmods []string
+	provider.chmod = func(name string, mode os.FileMode) error {
+		chmods = append(chmods, name)
+		return os.Chmod(name, mode)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		attrs    map[string]interface{}
+		wantMode os.FileMode
+	}{
+		{map[string]interface{}{"path": filepath.Join(tempDir, "secret"), "content": "token\n", "mode": "0600"}, 0600},
+		{map[string]interface{}{"path": filepath.Join(tempDir, "empty"), "mode": int64(640)}, 0640},
+		{map[string]interface{}{"path": filepath.Join(tempDir, "private"), "state": "directory", "mode": "0700"}, 0700},
+	}
+
+	for _, tt := range tests {
+		path := tt.attrs["path"].(string)
+		result, err := provider.Apply(ctx, &ResourceState{Type: "file", Attributes: tt.attrs})
+		if err != nil {
+			t.Fatalf("Apply returned error for %s: %v", path, err)
+		}
+		checkChanged(t, result)
+		if result.Status != "created" {
+			t.Errorf("Expected %s to be created, got %s", path, result.Status)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", path, err)
+		}
+		if info.Mode().Perm() != tt.wantMode {
+			t.Errorf("Expected %s to have mode %04o, got %04o", path, tt.wantMode, info.Mode().Perm())
+		}
+	}
+
+	// Modes the umask can't reduce are set when the files are created
+	if len(chmods) > 0 {
+		t.Errorf("Expected no separate chmod, got chmod of %v", chmods)
+	}
+}
+
+func TestFileProvider_Apply_ModeDrift(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping on Windows due to permission differences")
+	}
+
+	tempDir := t.TempDir()
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	filePath := filepath.Join(tempDir, "config")
+	dirPath := filepath.Join(tempDir, "data")
+	if err := os.WriteFile(filePath, []byte("same\n"), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", filePath, err)
+	}
+	if err := os.Mkdir(dirPath, 0700); err != nil {
+		t.Fatalf("Failed to create %s: %v", dirPath, err)
+	}
+
+	tests := []struct {
+		attrs    map[string]interface{}
+		wantMode os.FileMode
+	}{
+		{map[string]interface{}{"path": filePath, "content": "same\n", "mode": "0644"}, 0644},
+		{map[string]interface{}{"path": dirPath, "state": "directory", "mode": "0755"}, 0755},
+	}
+
+	for _, tt := range tests {
+		path := tt.attrs["path"].(string)
+
+		// Only the mode differs, which is still a change
+		result, err := provider.Apply(ctx, &ResourceState{Type: "file", Attributes: tt.attrs})
+		if err != nil {
+			t.Fatalf("Apply returned error for %s: %v", path, err)
+		}
+		checkChanged(t, result)
+		if result.Status != "updated" {
+			t.Errorf("Expected %s to be updated, got %s", path, result.Status)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", path, err)
+		}
+		if info.Mode().Perm() != tt.wantMode {
+			t.Errorf("Expected %s to have mode %04o, got %04o", path, tt.wantMode, info.Mode().Perm())
+		}
+
+		// Once fixed there is nothing left to change
+		result, err = provider.Apply(ctx, &ResourceState{Type: "file", Attributes: tt.attrs})
+		if err != nil {
+			t.Fatalf("Apply returned error for %s: %v", path, err)
+		}
+		checkChanged(t, result)
+		if result.Status != "unchanged" {
+			t.Errorf("Expected %s to be unchanged, got %s", path, result.Status)
+		}
+	}
+}
+
+func TestFileProvider_Apply_ParentMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping on Windows due to permission differences")
+	}
+
+	tempDir := t.TempDir()
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	path := filepath.Join(tempDir, "secrets", "app", "token")
+	attrs := map[string]interface{}{"path": path, "content": "token\n", "mode": "0600", "parent_mode": "0700"}
+	if err := provider.Validate(ctx, attrs); err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+	if _, err := provider.Apply(ctx, &ResourceState{Type: "file", Attributes: attrs}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	for _, dir := range []string{filepath.Join(tempDir, "secrets"), filepath.Join(tempDir, "secrets", "app")} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Fatalf("Expected parent %s to be created: %v", dir, err)
+		}
+		if info.Mode().Perm() != 0700 {
+			t.Errorf("Expected parent %s to have mode 0700, got %04o", dir, info.Mode().Perm())
+		}
+	}
+}
+
+func TestFileProvider_EnsureParentFalse(t *testing.T) {
+	tempDir := t.TempDir()
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	path := filepath.Join(tempDir, "missing", "app.conf")
+	attrs := map[string]interface{}{"path": path, "content": "port=80\n", "ensure_parent": false}
+	if err := provider.Validate(ctx, attrs); err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+
+	if _, err := provider.Plan(ctx, nil, attrs); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected plan to report the missing parent, got %v", err)
+	}
+	result, err := provider.Apply(ctx, &ResourceState{Type: "file", Attributes: attrs})
+	if err == nil || result.Status != "failed" {
+		t.Fatalf("Expected apply to fail, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Error("Expected the parent directory not to be created")
+	}
+
+	// An existing parent is fine
+	attrs["path"] = filepath.Join(tempDir, "app.conf")
+	if _, err := provider.Apply(ctx, &ResourceState{Type: "file", Attributes: attrs}); err != nil {
+		t.Errorf("Expected apply to succeed with an existing parent, got %v", err)
+	}
+
+	invalid := map[string]interface{}{"path": path, "ensure_parent": false, "parent_mode": "0700"}
+	if err := provider.Validate(ctx, invalid); err == nil {
+		t.Error("Expected error for parent_mode with ensure_parent = false")
+	}
+}
+
 func TestFileProvider_Apply_RemoveFile(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir, err := ioutil.TempDir("", "file_provider_test")
@@ -342,6 +530,7 @@ func TestFileProvider_Apply_RemoveFile(t *testing.T) {
 		t.Fatalf("Apply returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "deleted" {
 		t.Errorf("Expected status 'deleted', got '%s'", result.Status)
 	}
@@ -387,6 +576,7 @@ func TestFileProvider_Apply_UpdateFile(t *testing.T) {
 		t.Fatalf("Apply returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "updated" {
 		t.Errorf("Expected status 'updated', got '%s'", result.Status)
 	}
@@ -403,11 +593,81 @@ func TestFileProvider_Apply_UpdateFile(t *testing.T) {
 }
 
 // Utility functions used by FileProvider
+func TestFileProvider_Apply_DryRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not managed on Windows")
+	}
+
+	provider := NewFileProvider()
+	recorder := &logging.Recorder{}
+	ctx := WithDryRun(logging.NewContext(context.Background(), recorder))
+
+	tempDir, err := ioutil.TempDir("", "file-provider-dry-run-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	existing := filepath.Join(tempDir, "existing")
+	if err := ioutil.WriteFile(existing, []byte("old"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	os.Chmod(existing, 0600)
+
+	// snapshot lists every path under the temp dir with its mode and content
+	snapshot := func() map[string]string {
+		entries := make(map[string]string)
+		filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			content, _ := ioutil.ReadFile(path)
+			entries[path] = fmt.Sprintf("%v %s", info.Mode(), content)
+			return nil
+		})
+		return entries
+	}
+	before := snapshot()
+
+	tests := []struct {
+		name       string
+		attributes map[string]interface{}
+		wantStatus string
+	}{
+		{"create file", map[string]interface{}{"path": filepath.Join(tempDir, "sub", "new"), "content": "new"}, "created"},
+		{"create directory", map[string]interface{}{"path": filepath.Join(tempDir, "dir"), "state": "directory"}, "created"},
+		{"create link", map[string]interface{}{"path": filepath.Join(tempDir, "link"), "state": "link", "target": existing}, "created"},
+		{"update content and mode", map[string]interface{}{"path": existing, "content": "new", "mode": "0644"}, "updated"},
+		{"delete file", map[string]interface{}{"path": existing, "state": "absent"}, "deleted"},
+		{"unchanged", map[string]interface{}{"path": existing, "content": "old", "mode": "0600"}, "unchanged"},
+	}
+
+	for _, tt := range tests {
+		result, err := provider.Apply(ctx, &ResourceState{Type: "file", Name: tt.name, Attributes: tt.attributes})
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, result.Status)
+		}
+	}
+
+	if after := snapshot(); !reflect.DeepEqual(before, after) {
+		t.Errorf("Expected no filesystem changes in a dry run, before %v, after %v", before, after)
+	}
+
+	messages := recorder.Messages(logging.LevelInfo)
+	if want := "Would update " + existing + " (content, mode)"; len(messages) != 5 || messages[3] != want {
+		t.Errorf("Expected message %q among the dry run messages, got %q", want, messages)
+	}
+}
+
 func TestFileProvider_UtilityFunctions(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("Skipping on Windows due to permission differences")
 	}
-	
+
 	provider := NewFileProvider()
 
 	// Create a temporary directory for testing
@@ -444,12 +704,809 @@ func TestFileProvider_UtilityFunctions(t *testing.T) {
 		t.Error("Expected fileExists to return non-nil fileInfo for existing file")
 	}
 
-	// Test calculateMD5
-	md5, err := provider.calculateMD5(testFilePath)
+	// Test matchesSource
+	matches, err := provider.matchesSource(testFilePath, testFilePath, defaultChecksum)
+	if err != nil {
+		t.Errorf("matchesSource returned error: %v", err)
+	}
+	if !matches {
+		t.Error("Expected matchesSource to match a file with itself")
+	}
+}
+func TestFileProvider_Validate_Mode(t *testing.T) {
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		mode     interface{}
+		wantMode os.FileMode
+		wantErr  bool
+	}{
+		{"string", "0644", 0644, false},
+		{"string without leading zero", "755", 0755, false},
+		{"integer", int64(644), 0644, false},
+		{"integer with special bits", int64(1777), 01777, false},
+		{"out of range", int64(17777), 0, true},
+		{"out of range string", "77777", 0, true},
+		{"not octal", int64(689), 0, true},
+		{"invalid string", "rw-r--r--", 0, true},
+		{"boolean", true, 0, true},
+	}
+
+	for _, tt := range tests {
+		attrs := map[string]interface{}{"path": "/path/to/file", "mode": tt.mode}
+		err := provider.Validate(ctx, attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if err == nil {
+			if mode := provider.fileMode(attrs, nil); mode != tt.wantMode {
+				t.Errorf("%s: expected mode %04o, got %04o", tt.name, tt.wantMode, mode)
+			}
+		}
+	}
+}
+
+func TestFileProvider_Validate_Link(t *testing.T) {
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	validAttrs := map[string]interface{}{
+		"path":   "/usr/local/bin/tool",
+		"state":  "link",
+		"target": "/opt/tool/bin/tool",
+		"force":  true,
+	}
+	if err := provider.Validate(ctx, validAttrs); err != nil {
+		t.Errorf("Expected no error for valid link attributes, got: %v", err)
+	}
+
+	missingTarget := map[string]interface{}{
+		"path":  "/usr/local/bin/tool",
+		"state": "link",
+	}
+	if err := provider.Validate(ctx, missingTarget); err == nil {
+		t.Error("Expected error for link without target, got nil")
+	}
+
+	invalidForce := map[string]interface{}{
+		"path":   "/usr/local/bin/tool",
+		"state":  "link",
+		"target": "/opt/tool/bin/tool",
+		"force":  "yes",
+	}
+	if err := provider.Validate(ctx, invalidForce); err == nil {
+		t.Error("Expected error for non-boolean force, got nil")
+	}
+}
+
+func TestFileProvider_Link(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on Windows")
+	}
+
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	tempDir, err := ioutil.TempDir("", "file-provider-link-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "v1")
+	second := filepath.Join(tempDir, "v2")
+	for _, dir := range []string{first, second} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("Failed to create target: %v", err)
+		}
+	}
+	link := filepath.Join(tempDir, "current")
+
+	apply := func(target string, force bool) (*ResourceState, error) {
+		attrs := map[string]interface{}{"path": link, "state": "link", "target": target}
+		if force {
+			attrs["force"] = true
+		}
+		plan, err := provider.Plan(ctx, nil, attrs)
+		if err != nil {
+			t.Fatalf("Plan failed: %v", err)
+		}
+		if plan.Status == "unchanged" {
+			return plan, nil
+		}
+		return provider.Apply(ctx, plan)
+	}
+
+	assertLink := func(want string) {
+		got, err := os.Readlink(link)
+		if err != nil {
+			t.Fatalf("Expected %s to be a symlink: %v", link, err)
+		}
+		if got != want {
+			t.Errorf("Expected link to point to %s, got %s", want, got)
+		}
+	}
+
+	// Create
+	result, err := apply(first, false)
+	if err != nil || result.Status != "created" {
+		t.Fatalf("Expected created status, got %s (%v)", result.Status, err)
+	}
+	assertLink(first)
+
+	// No drift
+	result, _ = apply(first, false)
+	checkChanged(t, result)
+	if result.Status != "unchanged" {
+		t.Errorf("Expected unchanged status, got %s", result.Status)
+	}
+
+	// Repoint
+	result, err = apply(second, false)
+	if err != nil || result.Status != "updated" {
+		t.Errorf("Expected updated status on repoint, got %s (%v)", result.Status, err)
+	}
+	assertLink(second)
+
+	// Replace a regular file with a link
+	os.Remove(link)
+	ioutil.WriteFile(link, []byte("not a link"), 0644)
+	result, err = apply(first, false)
+	if err != nil || result.Status != "updated" {
+		t.Errorf("Expected updated status replacing a file, got %s (%v)", result.Status, err)
+	}
+	assertLink(first)
+
+	// Missing target requires force
+	missing := filepath.Join(tempDir, "missing")
+	if result, err = apply(missing, false); err == nil || result.Status != "failed" {
+		t.Errorf("Expected failure for missing target without force, got %s (%v)", result.Status, err)
+	}
+	assertLink(first)
+
+	result, err = apply(missing, true)
+	if err != nil || result.Status != "updated" {
+		t.Errorf("Expected updated status with force, got %s (%v)", result.Status, err)
+	}
+	assertLink(missing)
+}
+
+func TestFileProvider_Plan_Changes(t *testing.T) {
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	tempDir, err := ioutil.TempDir("", "file-provider-changes-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "config")
+
+	// Missing file is a state change
+	result, err := provider.Plan(ctx, nil, map[string]interface{}{"path": path, "content": "a"})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(result.Changes) != 1 || result.Changes[0] != "state" {
+		t.Errorf("Expected [state] changes for a missing file, got %v", result.Changes)
+	}
+
+	// Existing file with different content is a content change
+	if err := ioutil.WriteFile(path, []byte("b"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	result, err = provider.Plan(ctx, nil, map[string]interface{}{"path": path, "content": "a"})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(result.Changes) != 1 || result.Changes[0] != "content" {
+		t.Errorf("Expected [content] changes for an existing file, got %v", result.Changes)
+	}
+}
+
+func TestFileProvider_Plan_Diff(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not managed on Windows")
+	}
+
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	tempDir, err := ioutil.TempDir("", "file-provider-diff-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "config")
+	if err := ioutil.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	os.Chmod(path, 0600)
+
+	result, err := provider.Plan(ctx, nil, map[string]interface{}{"path": path, "content": "new", "mode": "0644"})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	expected := map[string]AttributeDiff{
+		"content": {},
+		"mode":    {Old: "0600", New: "0644"},
+	}
+	if !reflect.DeepEqual(result.Diff, expected) {
+		t.Errorf("Expected diff %v, got %v", expected, result.Diff)
+	}
+	if !strings.Contains(result.Details, "-old\n+new") {
+		t.Errorf("Expected content diff in details, got %q", result.Details)
+	}
+}
+
+func TestFileProvider_Plan_CachedContentHash(t *testing.T) {
+	provider := NewFileProvider()
+
+	tempDir, err := ioutil.TempDir("", "file-provider-hash-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "config")
+	desired := map[string]interface{}{"path": path, "content": "hello"}
+
+	applied, err := provider.Apply(context.Background(), &ResourceState{Type: "file", Name: "config", Attributes: desired})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	sum := sha256.Sum256([]byte("hello"))
+	if applied.Outputs["content_sha256"] != hex.EncodeToString(sum[:]) {
+		t.Fatalf("Expected the content hash in outputs, got %v", applied.Outputs)
+	}
+
+	reads := 0
+	provider.readFile = func(name string) ([]byte, error) {
+		reads++
+		return ioutil.ReadFile(name)
+	}
+
+	// With the hash from the last apply the unchanged file isn't read
+	ctx := WithPriorOutputs(context.Background(), applied.Outputs)
+	result, err := provider.Plan(ctx, nil, desired)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if result.Status != "unchanged" || reads != 0 {
+		t.Errorf("Expected unchanged without reading the file, got status %s after %d reads", result.Status, reads)
+	}
+
+	// New content has a different hash, so the file is read to diff it
+	result, err = provider.Plan(ctx, nil, map[string]interface{}{"path": path, "content": "world"})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if result.Status != "planned" || reads != 1 {
+		t.Errorf("Expected a planned change after reading the file, got status %s after %d reads", result.Status, reads)
+	}
+
+	// A file changed outside of zero no longer matches the cache
+	if err := ioutil.WriteFile(path, []byte("jello"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Failed to set file times: %v", err)
+	}
+	result, err = provider.Plan(ctx, nil, desired)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if result.Status != "planned" || reads != 2 {
+		t.Errorf("Expected the modified file to be read and planned, got status %s after %d reads", result.Status, reads)
+	}
+}
+
+func TestFileProvider_Read(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not managed on Windows")
+	}
+
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	tempDir, err := ioutil.TempDir("", "file-provider-read-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "config")
+	if err := ioutil.WriteFile(path, []byte("data"), 0640); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	os.Chmod(path, 0640)
+
+	live, err := provider.Read(ctx, map[string]interface{}{"path": path})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if live["exists"] != true || live["type"] != "file" || live["mode"] != "0640" {
+		t.Errorf("Unexpected live state %v", live)
+	}
+
+	// Plan compares against the live state it is given
+	result, err := provider.Plan(ctx, live, map[string]interface{}{"path": path, "mode": "0640"})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "unchanged" {
+		t.Errorf("Expected no changes when the live state matches, got %s %v", result.Status, result.Changes)
+	}
+
+	live["mode"] = "0600"
+	result, err = provider.Plan(ctx, live, map[string]interface{}{"path": path, "mode": "0640"})
 	if err != nil {
-		t.Errorf("calculateMD5 returned error: %v", err)
+		t.Fatalf("Plan failed: %v", err)
 	}
-	if md5 == "" {
-		t.Error("Expected calculateMD5 to return non-empty string")
+	checkChanged(t, result)
+	if result.Status != "planned" || !reflect.DeepEqual(result.Diff, map[string]AttributeDiff{"mode": {Old: "0600", New: "0640"}}) {
+		t.Errorf("Expected a mode update from the live state, got %s %v", result.Status, result.Diff)
+	}
+
+	// Links report their target, and missing paths don't exist
+	link := filepath.Join(tempDir, "link")
+	if err := os.Symlink(filepath.Join(tempDir, "missing"), link); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	live, err = provider.Read(ctx, map[string]interface{}{"path": link})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if live["exists"] != false || live["target"] != filepath.Join(tempDir, "missing") {
+		t.Errorf("Unexpected live state for a broken link %v", live)
+	}
+}
+
+func TestFileProvider_NumericOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ownership is not managed on Windows")
+	}
+
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	tempDir, err := ioutil.TempDir("", "file-provider-owner-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "config")
+	if err := ioutil.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// Numeric ids are valid, as numbers or strings
+	for _, owner := range []interface{}{int64(54321), "54321", "www-data"} {
+		if err := provider.Validate(ctx, map[string]interface{}{"path": path, "owner": owner}); err != nil {
+			t.Errorf("Expected owner %v to be valid, got %v", owner, err)
+		}
+	}
+	for _, owner := range []interface{}{int64(-1), 1.5, true, ""} {
+		if err := provider.Validate(ctx, map[string]interface{}{"path": path, "owner": owner}); err == nil {
+			t.Errorf("Expected owner %v to be rejected", owner)
+		}
+	}
+
+	// The current owner's id matches, even though it is compared to a name
+	uid := int64(os.Getuid())
+	result, err := provider.Plan(ctx, nil, map[string]interface{}{"path": path, "owner": uid, "group": fmt.Sprint(os.Getgid())})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "unchanged" {
+		t.Errorf("Expected the current uid and gid to match, got %s %v", result.Status, result.Diff)
+	}
+
+	// 54321 isn't in the passwd database, so it can only be used as an id
+	if _, err := user.LookupId("54321"); err == nil {
+		t.Skip("uid 54321 exists on this system")
+	}
+	desired := map[string]interface{}{"path": path, "owner": int64(54321), "group": "54321"}
+	result, err = provider.Plan(ctx, nil, desired)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "planned" || result.Diff["owner"].New != "54321" || result.Diff["group"].New != "54321" {
+		t.Errorf("Expected an ownership change to 54321, got %s %v", result.Status, result.Diff)
+	}
+
+	if os.Getuid() != 0 {
+		t.Skip("changing ownership requires root")
+	}
+	result, err = provider.Apply(ctx, &ResourceState{Type: "file", Name: path, Attributes: desired})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
 	}
-}
\ No newline at end of file
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if stat := info.Sys().(*syscall.Stat_t); stat.Uid != 54321 || stat.Gid != 54321 {
+		t.Errorf("Expected ownership 54321:54321, got %d:%d", stat.Uid, stat.Gid)
+	}
+
+	// An owner without a name is compared by id
+	result, err = provider.Plan(ctx, nil, desired)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "unchanged" {
+		t.Errorf("Expected no changes once owned by 54321, got %s %v", result.Status, result.Diff)
+	}
+	live, err := provider.Read(ctx, map[string]interface{}{"path": path})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if _, ok := live["owner"]; ok || live["uid"] != "54321" {
+		t.Errorf("Expected the uid without an owner name, got %v", live)
+	}
+}
+
+func TestFileProvider_Source(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "file-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	source := filepath.Join(tempDir, "source.conf")
+	path := filepath.Join(tempDir, "target.conf")
+	ioutil.WriteFile(source, []byte("new content"), 0644)
+	ioutil.WriteFile(path, []byte("old content"), 0644)
+
+	for _, checksum := range []string{"", "sha256", "md5"} {
+		attrs := map[string]interface{}{"path": path, "source": source}
+		if checksum != "" {
+			attrs["checksum"] = checksum
+		}
+		ioutil.WriteFile(path, []byte("old content"), 0644)
+
+		// Differing hashes update the file from the source
+		plan, err := provider.Plan(ctx, nil, attrs)
+		if err != nil {
+			t.Fatalf("%q: Plan failed: %v", checksum, err)
+		}
+		if plan.Status != "planned" {
+			t.Errorf("%q: expected planned status for differing source, got %s", checksum, plan.Status)
+		}
+
+		result, err := provider.Apply(ctx, plan)
+		if err != nil {
+			t.Fatalf("%q: Apply failed: %v", checksum, err)
+		}
+		checkChanged(t, result)
+		if result.Status != "updated" {
+			t.Errorf("%q: expected updated status, got %s", checksum, result.Status)
+		}
+		if data, _ := ioutil.ReadFile(path); string(data) != "new content" {
+			t.Errorf("%q: expected source content, got %q", checksum, data)
+		}
+
+		// Matching hashes leave the file alone
+		plan, err = provider.Plan(ctx, nil, attrs)
+		if err != nil {
+			t.Fatalf("%q: Plan failed: %v", checksum, err)
+		}
+		if plan.Status != "unchanged" {
+			t.Errorf("%q: expected unchanged status for matching source, got %s", checksum, plan.Status)
+		}
+		result, err = provider.Apply(ctx, plan)
+		if err != nil {
+			t.Fatalf("%q: Apply failed: %v", checksum, err)
+		}
+		checkChanged(t, result)
+		if result.Status != "unchanged" {
+			t.Errorf("%q: expected unchanged status, got %s", checksum, result.Status)
+		}
+	}
+
+	if err := provider.Validate(ctx, map[string]interface{}{"path": path, "checksum": "crc32"}); err == nil {
+		t.Error("Expected error for unsupported checksum algorithm, got nil")
+	}
+}
+
+func TestFileProvider_SourceChecksum(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "file-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	source := filepath.Join(tempDir, "source.conf")
+	path := filepath.Join(tempDir, "target.conf")
+	ioutil.WriteFile(source, []byte("new content"), 0644)
+
+	sum := sha256.Sum256([]byte("new content"))
+	good := "sha256:" + hex.EncodeToString(sum[:])
+	bad := "sha256:" + strings.Repeat("0", 64)
+
+	// A mismatching source fails without touching the target
+	ioutil.WriteFile(path, []byte("old content"), 0644)
+	attrs := map[string]interface{}{"path": path, "source": source, "source_checksum": bad}
+	if err := provider.Validate(ctx, attrs); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	plan, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	result, err := provider.Apply(ctx, plan)
+	if err == nil || result.Status != "failed" || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected failed status with checksum mismatch, got %s: %v", result.Status, err)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "old content" {
+		t.Errorf("Expected target to be left alone, got %q", data)
+	}
+
+	// A matching source is copied
+	attrs["source_checksum"] = good
+	plan, err = provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if result, err := provider.Apply(ctx, plan); err != nil || result.Status != "updated" || !result.Changed {
+		t.Fatalf("Expected updated status, got %v: %v", result, err)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "new content" {
+		t.Errorf("Expected source content, got %q", data)
+	}
+
+	invalid := []map[string]interface{}{
+		{"path": path, "source": source, "source_checksum": "md5:" + strings.Repeat("0", 32)},
+		{"path": path, "source": source, "source_checksum": "sha256:abc"},
+		{"path": path, "content": "x", "source_checksum": good},
+	}
+	for _, attrs := range invalid {
+		if err := provider.Validate(ctx, attrs); err == nil {
+			t.Errorf("Expected error for %v, got nil", attrs)
+		}
+	}
+}
+
+func TestFileProvider_Apply_Backup(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "file-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	path := filepath.Join(tempDir, "app.conf")
+	ioutil.WriteFile(path, []byte("old content"), 0640)
+
+	attrs := map[string]interface{}{"path": path, "content": "new content", "backup": true}
+	result, err := provider.Apply(ctx, &ResourceState{Type: "file", Name: path, Attributes: attrs})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "updated" {
+		t.Errorf("Expected updated status, got %s", result.Status)
+	}
+
+	if data, _ := ioutil.ReadFile(path); string(data) != "new content" {
+		t.Errorf("Expected new content, got %q", data)
+	}
+	backup, err := ioutil.ReadFile(path + ".bak")
+	if err != nil {
+		t.Fatalf("Expected backup file: %v", err)
+	}
+	if string(backup) != "old content" {
+		t.Errorf("Expected backup of old content, got %q", backup)
+	}
+
+	// Without a mode attribute the replaced file keeps its mode
+	if runtime.GOOS != "windows" {
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != 0640 {
+			t.Errorf("Expected mode 0640 to be kept, got %o", info.Mode().Perm())
+		}
+	}
+
+	// No temporary files are left behind
+	entries, _ := ioutil.ReadDir(tempDir)
+	if len(entries) != 2 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("Expected only the file and its backup, got %v", names)
+	}
+}
+
+func TestFileProvider_writeFileAtomic_CleansUpOnFailure(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "file-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	provider := NewFileProvider()
+
+	// A non-empty directory can't be replaced by a rename
+	path := filepath.Join(tempDir, "busy")
+	os.MkdirAll(filepath.Join(path, "child"), 0755)
+
+	if err := provider.writeFileAtomic(path, []byte("content"), 0644, nil); err == nil {
+		t.Fatal("Expected error replacing a non-empty directory, got nil")
+	}
+
+	entries, _ := ioutil.ReadDir(tempDir)
+	if len(entries) != 1 || entries[0].Name() != "busy" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("Expected temporary file to be removed, got %v", names)
+	}
+}
+
+func TestFileProvider_RecursiveDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+
+	tempDir, err := ioutil.TempDir("", "file-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	// Build a tree with the wrong modes throughout
+	root := filepath.Join(tempDir, "site")
+	os.MkdirAll(filepath.Join(root, "assets", "img"), 0700)
+	os.Chmod(root, 0700)
+	os.Chmod(filepath.Join(root, "assets"), 0700)
+	ioutil.WriteFile(filepath.Join(root, "index.html"), []byte("x"), 0600)
+	ioutil.WriteFile(filepath.Join(root, "assets", "img", "logo.png"), []byte("x"), 0600)
+
+	attrs := map[string]interface{}{
+		"path":      root,
+		"state":     "directory",
+		"recursive": true,
+		"dir_mode":  "0755",
+		"file_mode": "0644",
+	}
+
+	plan, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" || !reflect.DeepEqual(plan.Changes, []string{"mode"}) {
+		t.Errorf("Expected planned mode change, got %s %v", plan.Status, plan.Changes)
+	}
+
+	result, err := provider.Apply(ctx, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "updated" {
+		t.Errorf("Expected updated status, got %s", result.Status)
+	}
+
+	expected := map[string]os.FileMode{
+		"":                    0755,
+		"assets":              0755,
+		"assets/img":          0755,
+		"index.html":          0644,
+		"assets/img/logo.png": 0644,
+	}
+	for rel, want := range expected {
+		info, err := os.Stat(filepath.Join(root, rel))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", rel, err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("Expected %q to have mode %o, got %o", rel, want, info.Mode().Perm())
+		}
+	}
+
+	// A drifted descendant alone is detected
+	os.Chmod(filepath.Join(root, "assets", "img", "logo.png"), 0600)
+	plan, err = provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" {
+		t.Errorf("Expected drift in a descendant to be planned, got %s", plan.Status)
+	}
+
+	if err := provider.Validate(ctx, map[string]interface{}{"path": root, "state": "directory", "file_mode": "0644"}); err == nil {
+		t.Error("Expected error for file_mode without recursive, got nil")
+	}
+}
+
+func TestFileProvider_Apply_Validate(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "file-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	// The validator accepts files that don't mention "broken"
+	var validated []string
+	provider.runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		validated = append(validated, strings.Join(append([]string{name}, args...), " "))
+		data, err := ioutil.ReadFile(args[len(args)-1])
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(string(data), "broken") {
+			return []byte("parse error on line 1"), fmt.Errorf("exit status 1")
+		}
+		return []byte("parsed OK"), nil
+	}
+
+	path := filepath.Join(tempDir, "sudoers")
+	ioutil.WriteFile(path, []byte("original"), 0440)
+
+	// Rejected content leaves the original file untouched
+	attrs := map[string]interface{}{"path": path, "content": "broken", "validate": "visudo -cf %s"}
+	result, err := provider.Apply(ctx, &ResourceState{Type: "file", Name: path, Attributes: attrs})
+	if err == nil {
+		t.Fatal("Expected error for rejected content, got nil")
+	}
+	checkChanged(t, result)
+	if result.Status != "failed" || !strings.Contains(result.Output, "parse error") {
+		t.Errorf("Expected failed status with validator output, got %s %q", result.Status, result.Output)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "original" {
+		t.Errorf("Expected original content to be kept, got %q", data)
+	}
+	if len(validated) != 1 || !strings.HasPrefix(validated[0], "visudo -cf "+filepath.Join(tempDir, ".sudoers.tmp-")) {
+		t.Errorf("Expected validator to run on a temporary file, got %v", validated)
+	}
+	if entries, _ := ioutil.ReadDir(tempDir); len(entries) != 1 {
+		t.Errorf("Expected temporary file to be removed, got %d entries", len(entries))
+	}
+
+	// Accepted content replaces the file
+	attrs["content"] = "valid"
+	result, err = provider.Apply(ctx, &ResourceState{Type: "file", Name: path, Attributes: attrs})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "updated" {
+		t.Errorf("Expected updated status, got %s", result.Status)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "valid" {
+		t.Errorf("Expected validated content, got %q", data)
+	}
+
+	if err := provider.Validate(ctx, map[string]interface{}{"path": path, "validate": "visudo -c"}); err == nil {
+		t.Error("Expected error for validate command without a placeholder, got nil")
+	}
+}
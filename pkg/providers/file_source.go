@@ -0,0 +1,256 @@
+package providers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sourceEntry is a file or directory copied from a directory source
+type sourceEntry struct {
+	path string // Location in the source
+	info os.FileInfo
+}
+
+// isGlobSource reports whether a source is a glob pattern rather than a path
+func isGlobSource(source string) bool {
+	return strings.ContainsAny(source, "*?[")
+}
+
+// sourceRoot returns the directory a source copies from: the source itself,
+// or the directory holding the first wildcard of a glob
+func sourceRoot(source string) string {
+	if !isGlobSource(source) {
+		return filepath.Clean(source)
+	}
+	return filepath.Dir(source[:strings.IndexAny(source, "*?[")] + "x")
+}
+
+// checkSourceNesting returns an error if the destination directory is inside
+// the source, which would make the copy include itself
+func checkSourceNesting(path, source string) error {
+	dest, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	root, err := filepath.Abs(sourceRoot(source))
+	if err != nil {
+		return err
+	}
+	if dest == root || strings.HasPrefix(dest, root+string(filepath.Separator)) {
+		return fmt.Errorf("file 'path' %s must not be inside 'source' %s", path, source)
+	}
+	return nil
+}
+
+// sourceEntries lists what a directory source copies, keyed by the path
+// relative to the destination directory. A directory source copies its whole
+// tree and a glob copies each match by its base name, with matching
+// directories copied whole. Symlinks are skipped.
+func sourceEntries(source string) (map[string]sourceEntry, error) {
+	roots := make(map[string]string)
+	if isGlobSource(source) {
+		matches, err := filepath.Glob(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source pattern %s: %v", source, err)
+		}
+		for _, match := range matches {
+			roots[filepath.Base(match)] = match
+		}
+	} else {
+		info, err := os.Stat(source)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("source %s must be a directory or a glob pattern when state is directory", source)
+		}
+		roots["."] = source
+	}
+
+	entries := make(map[string]sourceEntry)
+	for prefix, root := range roots {
+		err := walkTree(root, func(path string, info os.FileInfo) error {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if rel = filepath.Join(prefix, rel); rel != "." {
+				entries[rel] = sourceEntry{path: path, info: info}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// sourceDrift returns the files and directories a directory source would
+// create and update in the destination, in path order. A file is updated when
+// its checksum differs from the source, and anything in the way of a file or
+// directory is replaced. Directories end with a path separator.
+func sourceDrift(path, source, algorithm string) ([]string, []string, error) {
+	entries, err := sourceEntries(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rels := make([]string, 0, len(entries))
+	for rel := range entries {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	var create, update []string
+	for _, rel := range rels {
+		entry := entries[rel]
+		dest := filepath.Join(path, rel)
+
+		// Directories are listed with a trailing separator
+		if entry.info.IsDir() {
+			info, err := os.Stat(dest)
+			if os.IsNotExist(err) {
+				create = append(create, rel+string(filepath.Separator))
+			} else if err != nil {
+				return nil, nil, err
+			} else if !info.IsDir() {
+				update = append(update, rel+string(filepath.Separator))
+			}
+			continue
+		}
+
+		info, err := os.Stat(dest)
+		if os.IsNotExist(err) {
+			create = append(create, rel)
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if info.IsDir() {
+			update = append(update, rel)
+			continue
+		}
+
+		expected, err := fileChecksum(entry.path, algorithm)
+		if err != nil {
+			return nil, nil, err
+		}
+		actual, err := fileChecksum(dest, algorithm)
+		if err != nil {
+			return nil, nil, err
+		}
+		if actual != expected {
+			update = append(update, rel)
+		}
+	}
+
+	return create, update, nil
+}
+
+// sourceDetails summarizes the files a directory source would copy
+func sourceDetails(create, update []string) string {
+	var details []string
+	if len(create) > 0 {
+		details = append(details, "create "+strings.Join(create, ", "))
+	}
+	if len(update) > 0 {
+		details = append(details, "update "+strings.Join(update, ", "))
+	}
+	return strings.Join(details, "; ")
+}
+
+// copySource copies the files of a directory source that are missing or
+// differ into the destination directory, keeping the source's relative paths
+// and modes. It reports whether anything was copied.
+func (p *FileProvider) copySource(path, source, algorithm string) (bool, error) {
+	if err := checkSourceNesting(path, source); err != nil {
+		return false, err
+	}
+
+	create, update, err := sourceDrift(path, source, algorithm)
+	if err != nil {
+		return false, err
+	}
+	if len(create) == 0 && len(update) == 0 {
+		return false, nil
+	}
+
+	entries, err := sourceEntries(source)
+	if err != nil {
+		return false, err
+	}
+
+	changed := append(create, update...)
+	sort.Strings(changed)
+	for _, rel := range changed {
+		if strings.HasSuffix(rel, string(filepath.Separator)) {
+			if err := p.makeSourceDirs(path, strings.TrimSuffix(rel, string(filepath.Separator)), entries); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		entry := entries[rel]
+		dest := filepath.Join(path, rel)
+
+		// Create missing parent directories with the source directories' modes
+		if err := p.makeSourceDirs(path, filepath.Dir(rel), entries); err != nil {
+			return false, err
+		}
+
+		// A directory in the way of a file is replaced
+		if info, err := os.Stat(dest); err == nil && info.IsDir() {
+			if err := os.RemoveAll(dest); err != nil {
+				return false, err
+			}
+		}
+
+		data, err := ioutil.ReadFile(entry.path)
+		if err != nil {
+			return false, err
+		}
+		if err := p.writeFileAtomic(dest, data, entry.info.Mode().Perm(), nil); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// makeSourceDirs creates the directories leading to rel under path, giving
+// each new directory the mode of the matching source directory
+func (p *FileProvider) makeSourceDirs(path, rel string, entries map[string]sourceEntry) error {
+	if rel == "." {
+		return nil
+	}
+	if err := p.makeSourceDirs(path, filepath.Dir(rel), entries); err != nil {
+		return err
+	}
+
+	dest := filepath.Join(path, rel)
+	if info, err := os.Stat(dest); err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		// A file in the way of a directory is replaced
+		if err := os.Remove(dest); err != nil {
+			return err
+		}
+	}
+
+	mode := os.FileMode(0755)
+	if entry, ok := entries[rel]; ok {
+		mode = entry.info.Mode().Perm()
+	}
+	if err := os.Mkdir(dest, mode); err != nil {
+		return err
+	}
+	// Apply the mode exactly, regardless of the umask
+	return os.Chmod(dest, mode)
+}
@@ -0,0 +1,219 @@
+package providers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFileProvider_DirectorySource(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "file-source-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	source := filepath.Join(tempDir, "source")
+	dest := filepath.Join(tempDir, "dest")
+	os.MkdirAll(filepath.Join(source, "conf.d"), 0755)
+	ioutil.WriteFile(filepath.Join(source, "app.conf"), []byte("app"), 0644)
+	ioutil.WriteFile(filepath.Join(source, "run.sh"), []byte("#!/bin/sh\n"), 0755)
+	ioutil.WriteFile(filepath.Join(source, "conf.d", "site.conf"), []byte("site"), 0600)
+	os.Chmod(filepath.Join(source, "run.sh"), 0755)
+	os.Chmod(filepath.Join(source, "conf.d", "site.conf"), 0600)
+
+	attrs := map[string]interface{}{"path": dest, "state": "directory", "source": source}
+	if err := provider.Validate(ctx, attrs); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	plan, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	sep := string(filepath.Separator)
+	expected := "create app.conf, conf.d" + sep + ", " + filepath.Join("conf.d", "site.conf") + ", run.sh"
+	if plan.Status != "planned" || plan.Details != expected {
+		t.Errorf("Expected planned %q, got %s %q", expected, plan.Status, plan.Details)
+	}
+
+	result, err := provider.Apply(ctx, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "created" {
+		t.Errorf("Expected created status, got %s", result.Status)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dest, "conf.d", "site.conf")); string(data) != "site" {
+		t.Errorf("Expected nested file to be copied, got %q", data)
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(filepath.Join(dest, "run.sh")); err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("Expected run.sh to keep mode 0755, got %v", info.Mode().Perm())
+		}
+		if info, err := os.Stat(filepath.Join(dest, "conf.d", "site.conf")); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("Expected site.conf to keep mode 0600, got %v", info.Mode().Perm())
+		}
+	}
+
+	// A copied tree is unchanged on re-plan
+	plan, err = provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "unchanged" {
+		t.Errorf("Expected unchanged status after copy, got %s %q", plan.Status, plan.Details)
+	}
+
+	// A single changed file is the only update
+	ioutil.WriteFile(filepath.Join(dest, "app.conf"), []byte("edited"), 0644)
+	plan, err = provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" || plan.Details != "update app.conf" {
+		t.Errorf("Expected planned update of app.conf, got %s %q", plan.Status, plan.Details)
+	}
+	if result, err := provider.Apply(ctx, plan); err != nil || result.Status != "updated" || !result.Changed {
+		t.Fatalf("Expected updated status, got %v: %v", result, err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dest, "app.conf")); string(data) != "app" {
+		t.Errorf("Expected app.conf to be restored, got %q", data)
+	}
+}
+
+func TestFileProvider_GlobSource(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "file-source-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	source := filepath.Join(tempDir, "source")
+	os.MkdirAll(source, 0755)
+	ioutil.WriteFile(filepath.Join(source, "a.conf"), []byte("a"), 0644)
+	ioutil.WriteFile(filepath.Join(source, "b.conf"), []byte("b"), 0644)
+	ioutil.WriteFile(filepath.Join(source, "notes.txt"), []byte("n"), 0644)
+
+	dest := filepath.Join(tempDir, "dest")
+	create, update, err := sourceDrift(dest, filepath.Join(source, "*.conf"), defaultChecksum)
+	if err != nil {
+		t.Fatalf("sourceDrift failed: %v", err)
+	}
+	if len(create) != 2 || create[0] != "a.conf" || create[1] != "b.conf" || len(update) != 0 {
+		t.Errorf("Expected to create a.conf and b.conf, got create %v update %v", create, update)
+	}
+}
+
+func TestCheckSourceNesting(t *testing.T) {
+	tests := []struct {
+		path, source string
+		wantErr      bool
+	}{
+		{"/srv/site", "/srv/site", true},
+		{"/srv/site/copy", "/srv/site", true},
+		{"/srv/site/copy", "/srv/site/*.conf", true},
+		{"/srv/site-copy", "/srv/site", false},
+		{"/srv/copy", "/srv/site/*.conf", false},
+	}
+
+	for _, tt := range tests {
+		err := checkSourceNesting(filepath.FromSlash(tt.path), filepath.FromSlash(tt.source))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkSourceNesting(%s, %s) error = %v, wantErr %v", tt.path, tt.source, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFileProvider_PreserveMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+
+	tempDir := t.TempDir()
+	provider := NewFileProvider()
+	ctx := context.Background()
+
+	source := filepath.Join(tempDir, "deploy.sh")
+	if err := ioutil.WriteFile(source, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	os.Chmod(source, 0750)
+
+	// The copy inherits the source's mode
+	dest := filepath.Join(tempDir, "bin", "deploy.sh")
+	attrs := map[string]interface{}{"path": dest, "source": source, "preserve_mode": true}
+	if err := provider.Validate(ctx, attrs); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	plan, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	result, err := provider.Apply(ctx, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "created" {
+		t.Errorf("Expected created status, got %s", result.Status)
+	}
+	if info, err := os.Stat(dest); err != nil || info.Mode().Perm() != 0750 {
+		t.Errorf("Expected the copy to have mode 0750, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	// A change to the source's mode is planned for the copy
+	os.Chmod(source, 0755)
+	plan, err = provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" {
+		t.Errorf("Expected the mode change to be planned, got %s", plan.Status)
+	}
+	if _, err := provider.Apply(ctx, plan); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if info, _ := os.Stat(dest); info.Mode().Perm() != 0755 {
+		t.Errorf("Expected the copy to follow the source to 0755, got %v", info.Mode().Perm())
+	}
+
+	// An explicit mode wins over the source's
+	override := filepath.Join(tempDir, "override.sh")
+	attrs = map[string]interface{}{"path": override, "source": source, "preserve_mode": true, "mode": "0700"}
+	plan, err = provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if _, err := provider.Apply(ctx, plan); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if info, err := os.Stat(override); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("Expected the explicit mode 0700, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	// Without preserve_mode the copy gets the default mode
+	plain := filepath.Join(tempDir, "plain.sh")
+	plan, _ = provider.Plan(ctx, nil, map[string]interface{}{"path": plain, "source": source})
+	if _, err := provider.Apply(ctx, plan); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if info, err := os.Stat(plain); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected the default mode 0644, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	// preserve_mode needs a source and must be a boolean
+	if err := provider.Validate(ctx, map[string]interface{}{"path": plain, "preserve_mode": true}); err == nil {
+		t.Error("Expected error for preserve_mode without source")
+	}
+	if err := provider.Validate(ctx, map[string]interface{}{"path": plain, "source": source, "preserve_mode": "yes"}); err == nil {
+		t.Error("Expected error for a non-boolean preserve_mode")
+	}
+}
@@ -0,0 +1,269 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// shaPattern matches abbreviated or full commit hashes
+var shaPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// GitProvider manages git checkouts
+type GitProvider struct {
+	platform           *PlatformChecker
+	runCommand         CommandRunner
+	isCommandAvailable func(command string) bool
+}
+
+// NewGitProvider creates a new git provider
+func NewGitProvider() *GitProvider {
+	platform := &PlatformChecker{}
+	return &GitProvider{
+		platform:           platform,
+		runCommand:         runCommand,
+		isCommandAvailable: platform.IsCommandAvailable,
+	}
+}
+
+// Validate validates git resource attributes
+func (p *GitProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	for _, key := range []string{"repo", "path"} {
+		value, ok := attributes[key]
+		if !ok {
+			return fmt.Errorf("git resource requires '%s' attribute", key)
+		}
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("git '%s' must be a string", key)
+		}
+	}
+
+	if ref, ok := attributes["ref"]; ok {
+		refStr, ok := ref.(string)
+		if !ok {
+			return fmt.Errorf("git 'ref' must be a string")
+		}
+		if refStr == "" || strings.HasPrefix(refStr, "-") {
+			return fmt.Errorf("git 'ref' is invalid: %q", refStr)
+		}
+	}
+
+	if depth, ok, err := intAttribute(attributes, "depth"); err != nil {
+		return fmt.Errorf("git %v", err)
+	} else if ok && depth < 1 {
+		return fmt.Errorf("git 'depth' must be at least 1")
+	}
+
+	if !p.isCommandAvailable("git") {
+		return fmt.Errorf("git resource requires the git command, which was not found in PATH")
+	}
+
+	return nil
+}
+
+// Plan determines whether the repository needs to be cloned or updated
+func (p *GitProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	path := desired["path"].(string)
+
+	result := &ResourceState{
+		Type:       "git",
+		Name:       path,
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	action, err := p.pendingAction(ctx, desired)
+	if err != nil {
+		return nil, err
+	}
+	if action != "" {
+		result.Status = "planned"
+		result.Changes = []string{action}
+	}
+
+	return result, nil
+}
+
+// Apply clones the repository or fetches and checks out the desired ref
+func (p *GitProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	path := state.Attributes["path"].(string)
+
+	result := &ResourceState{
+		Type:       "git",
+		Name:       path,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	if !p.isCommandAvailable("git") {
+		err := fmt.Errorf("git command not found in PATH")
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	action, err := p.pendingAction(ctx, state.Attributes)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	switch action {
+	case "clone":
+		err = p.clone(ctx, state.Attributes)
+		result.Status = "created"
+	case "ref":
+		err = p.update(ctx, state.Attributes)
+		result.Status = "updated"
+	default:
+		return result, nil
+	}
+
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+	result.Changed = true
+	result.Changes = []string{action}
+
+	return result, nil
+}
+
+// pendingAction returns "clone" if the checkout is missing, "ref" if HEAD is
+// not at the desired ref, or "" if the checkout is up to date
+func (p *GitProvider) pendingAction(ctx context.Context, attributes map[string]interface{}) (string, error) {
+	path := attributes["path"].(string)
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "clone", nil
+	} else if err != nil {
+		return "", fmt.Errorf("error checking %s: %v", path, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("git path %s exists and is not a directory", path)
+	}
+
+	if _, err := os.Stat(filepath.Join(path, ".git")); os.IsNotExist(err) {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return "", fmt.Errorf("error reading %s: %v", path, err)
+		}
+		if len(entries) == 0 {
+			return "clone", nil
+		}
+		return "", fmt.Errorf("git path %s exists and is not a git repository", path)
+	}
+
+	ref, ok := attributes["ref"].(string)
+	if !ok {
+		// Without a ref any existing checkout is acceptable
+		return "", nil
+	}
+
+	head, err := p.git(ctx, path, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	want, found := p.resolveRef(ctx, path, ref)
+	if !found || want != head {
+		return "ref", nil
+	}
+
+	return "", nil
+}
+
+// resolveRef returns the commit a ref points to locally, preferring remote branches
+func (p *GitProvider) resolveRef(ctx context.Context, path, ref string) (string, bool) {
+	for _, candidate := range []string{"refs/remotes/origin/" + ref, ref + "^{commit}"} {
+		if commit, err := p.git(ctx, path, "rev-parse", "--verify", "--quiet", candidate); err == nil && commit != "" {
+			return commit, true
+		}
+	}
+	return "", false
+}
+
+// clone clones the repository, checking out ref if set
+func (p *GitProvider) clone(ctx context.Context, attributes map[string]interface{}) error {
+	repo := attributes["repo"].(string)
+	path := attributes["path"].(string)
+	ref, hasRef := attributes["ref"].(string)
+
+	args := []string{"clone"}
+	if depth, ok, _ := intAttribute(attributes, "depth"); ok {
+		args = append(args, "--depth", strconv.FormatInt(depth, 10))
+	}
+	// --branch accepts branches and tags but not commit hashes
+	if hasRef && !shaPattern.MatchString(ref) {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, "--", repo, path)
+
+	if _, err := p.git(ctx, "", args...); err != nil {
+		return err
+	}
+
+	if hasRef && shaPattern.MatchString(ref) {
+		if _, err := p.git(ctx, path, "checkout", "--quiet", ref); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// update fetches from origin and moves the checkout to ref
+func (p *GitProvider) update(ctx context.Context, attributes map[string]interface{}) error {
+	path := attributes["path"].(string)
+	ref := attributes["ref"].(string)
+
+	args := []string{"fetch", "--tags"}
+	if depth, ok, _ := intAttribute(attributes, "depth"); ok {
+		args = append(args, "--depth", strconv.FormatInt(depth, 10))
+	}
+	args = append(args, "origin")
+	if shaPattern.MatchString(ref) {
+		// Shallow clones only have the fetched history, so ask for the commit explicitly
+		args = append(args, ref)
+	}
+
+	if _, err := p.git(ctx, path, args...); err != nil {
+		return err
+	}
+
+	if _, err := p.git(ctx, path, "checkout", "--quiet", ref); err != nil {
+		return err
+	}
+
+	// Branches track origin, so move the local branch to the fetched commit
+	if _, err := p.git(ctx, path, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+ref); err == nil {
+		if _, err := p.git(ctx, path, "reset", "--hard", "--quiet", "origin/"+ref); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// git runs a git subcommand, inside dir if set, and returns its trimmed output
+func (p *GitProvider) git(ctx context.Context, dir string, args ...string) (string, error) {
+	subcommand := args[0]
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+
+	output, err := p.runCommand(ctx, "git", args...)
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %v: %s", subcommand, err, strings.TrimSpace(string(output)))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
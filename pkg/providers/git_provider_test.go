@@ -0,0 +1,199 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestGitProvider() (*GitProvider, *commandRecorder) {
+	recorder := &commandRecorder{output: map[string]string{}, fail: map[string]error{}}
+	provider := NewGitProvider()
+	provider.runCommand = recorder.run
+	provider.isCommandAvailable = func(command string) bool { return true }
+	return provider, recorder
+}
+
+func TestGitProvider_Validate(t *testing.T) {
+	provider, _ := newTestGitProvider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"minimal", map[string]interface{}{"repo": "https://example.com/app.git", "path": "/srv/app"}, false},
+		{"full", map[string]interface{}{"repo": "https://example.com/app.git", "path": "/srv/app", "ref": "v1.2.0", "depth": int64(1)}, false},
+		{"missing repo", map[string]interface{}{"path": "/srv/app"}, true},
+		{"missing path", map[string]interface{}{"repo": "https://example.com/app.git"}, true},
+		{"option-like ref", map[string]interface{}{"repo": "r", "path": "/srv/app", "ref": "--upload-pack=x"}, true},
+		{"zero depth", map[string]interface{}{"repo": "r", "path": "/srv/app", "depth": int64(0)}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+
+	provider.isCommandAvailable = func(command string) bool { return false }
+	if err := provider.Validate(ctx, tests[0].attrs); err == nil {
+		t.Error("Expected error when git is not installed, got nil")
+	}
+}
+
+func TestGitProvider_Clone(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "git-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "app")
+	sha := "0123456789abcdef0123456789abcdef01234567"
+
+	tests := []struct {
+		name         string
+		attrs        map[string]interface{}
+		wantCommands [][]string
+	}{
+		{"branch with depth", map[string]interface{}{"repo": "https://example.com/app.git", "path": path, "ref": "main", "depth": int64(1)},
+			[][]string{{"git", "clone", "--depth", "1", "--branch", "main", "--", "https://example.com/app.git", path}}},
+		{"commit", map[string]interface{}{"repo": "https://example.com/app.git", "path": path, "ref": sha},
+			[][]string{
+				{"git", "clone", "--", "https://example.com/app.git", path},
+				{"git", "-C", path, "checkout", "--quiet", sha},
+			}},
+	}
+
+	for _, tt := range tests {
+		provider, recorder := newTestGitProvider()
+
+		plan, err := provider.Plan(context.Background(), nil, tt.attrs)
+		if err != nil {
+			t.Fatalf("%s: Plan failed: %v", tt.name, err)
+		}
+		if plan.Status != "planned" || !reflect.DeepEqual(plan.Changes, []string{"clone"}) {
+			t.Errorf("%s: expected planned clone, got %s %v", tt.name, plan.Status, plan.Changes)
+		}
+
+		result, err := provider.Apply(context.Background(), plan)
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != "created" {
+			t.Errorf("%s: expected created status, got %s", tt.name, result.Status)
+		}
+		if !reflect.DeepEqual(recorder.commands, tt.wantCommands) {
+			t.Errorf("%s: expected commands %v, got %v", tt.name, tt.wantCommands, recorder.commands)
+		}
+	}
+}
+
+func TestGitProvider_Update(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "git-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "app")
+	if err := os.MkdirAll(filepath.Join(path, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create checkout: %v", err)
+	}
+
+	notFound := errors.New("exit status 1")
+	prefix := "git -C " + path + " "
+	attrs := map[string]interface{}{"repo": "https://example.com/app.git", "path": path, "ref": "main"}
+
+	// HEAD already at origin/main
+	provider, recorder := newTestGitProvider()
+	recorder.output[prefix+"rev-parse HEAD"] = "aaaa\n"
+	recorder.output[prefix+"rev-parse --verify --quiet refs/remotes/origin/main"] = "aaaa\n"
+
+	plan, err := provider.Plan(context.Background(), nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "unchanged" {
+		t.Errorf("Expected unchanged status when HEAD matches, got %s", plan.Status)
+	}
+
+	// HEAD behind origin/main
+	provider, recorder = newTestGitProvider()
+	recorder.output[prefix+"rev-parse HEAD"] = "aaaa\n"
+	recorder.output[prefix+"rev-parse --verify --quiet refs/remotes/origin/main"] = "bbbb\n"
+
+	plan, err = provider.Plan(context.Background(), nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" || !reflect.DeepEqual(plan.Changes, []string{"ref"}) {
+		t.Errorf("Expected planned ref update, got %s %v", plan.Status, plan.Changes)
+	}
+
+	recorder.commands = nil
+	result, err := provider.Apply(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "updated" {
+		t.Errorf("Expected updated status, got %s", result.Status)
+	}
+
+	wantCommands := [][]string{
+		{"git", "-C", path, "rev-parse", "HEAD"},
+		{"git", "-C", path, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/main"},
+		{"git", "-C", path, "fetch", "--tags", "origin"},
+		{"git", "-C", path, "checkout", "--quiet", "main"},
+		{"git", "-C", path, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/main"},
+		{"git", "-C", path, "reset", "--hard", "--quiet", "origin/main"},
+	}
+	if !reflect.DeepEqual(recorder.commands, wantCommands) {
+		t.Errorf("Expected commands %v, got %v", wantCommands, recorder.commands)
+	}
+
+	// A tag that isn't known locally is fetched and checked out without a reset
+	provider, recorder = newTestGitProvider()
+	recorder.output[prefix+"rev-parse HEAD"] = "aaaa\n"
+	recorder.fail[prefix+"rev-parse --verify --quiet refs/remotes/origin/v2.0.0"] = notFound
+	recorder.fail[prefix+"rev-parse --verify --quiet v2.0.0^{commit}"] = notFound
+
+	tagAttrs := map[string]interface{}{"repo": "https://example.com/app.git", "path": path, "ref": "v2.0.0"}
+	result, err = provider.Apply(context.Background(), &ResourceState{Type: "git", Name: path, Attributes: tagAttrs})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "updated" {
+		t.Errorf("Expected updated status for new tag, got %s", result.Status)
+	}
+	last := recorder.commands[len(recorder.commands)-1]
+	if !reflect.DeepEqual(last, []string{"git", "-C", path, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/v2.0.0"}) {
+		t.Errorf("Expected no reset for a tag, last command was %v", last)
+	}
+}
+
+func TestGitProvider_NotARepository(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "git-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ioutil.WriteFile(filepath.Join(tempDir, "file"), []byte("x"), 0644)
+
+	provider, _ := newTestGitProvider()
+	attrs := map[string]interface{}{"repo": "https://example.com/app.git", "path": tempDir}
+	if _, err := provider.Plan(context.Background(), nil, attrs); err == nil {
+		t.Error("Expected error for a non-empty directory that is not a repository, got nil")
+	}
+}
@@ -0,0 +1,326 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// groupEntry describes an existing local group
+type groupEntry struct {
+	GID     string
+	Members []string
+}
+
+// GroupProvider implements local group management
+type GroupProvider struct {
+	platform    *PlatformChecker
+	runCommand  CommandRunner
+	lookupGroup func(ctx context.Context, name string) (*groupEntry, error)
+}
+
+// NewGroupProvider creates a new group provider
+func NewGroupProvider() *GroupProvider {
+	p := &GroupProvider{
+		platform:   &PlatformChecker{},
+		runCommand: runCommand,
+	}
+	p.lookupGroup = p.readGroup
+	return p
+}
+
+// Validate validates group resource attributes
+func (p *GroupProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("group provider is not supported on Windows")
+	}
+
+	name, ok := attributes["name"]
+	if !ok {
+		return fmt.Errorf("group resource requires 'name' attribute")
+	}
+	if _, ok := name.(string); !ok {
+		return fmt.Errorf("group 'name' must be a string")
+	}
+
+	if state, ok := attributes["state"]; ok {
+		stateStr, ok := state.(string)
+		if !ok || (stateStr != "present" && stateStr != "absent") {
+			return fmt.Errorf("group 'state' must be one of: present, absent")
+		}
+	}
+
+	if gid, ok, err := intAttribute(attributes, "gid"); err != nil {
+		return fmt.Errorf("group %v", err)
+	} else if ok && gid < 0 {
+		return fmt.Errorf("group 'gid' must not be negative")
+	}
+
+	if _, _, err := stringSliceAttribute(attributes, "members"); err != nil {
+		return fmt.Errorf("group %v", err)
+	}
+
+	return nil
+}
+
+// Plan determines what changes would be made to a group
+func (p *GroupProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	name := desired["name"].(string)
+
+	result := &ResourceState{
+		Type:       "group",
+		Name:       name,
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	entry, err := p.lookupGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if presenceState(desired) == "absent" {
+		if entry != nil {
+			result.Status = "planned"
+			result.Changes = []string{"state"}
+		}
+		return result, nil
+	}
+
+	if entry == nil {
+		result.Status = "planned"
+		result.Changes = []string{"state"}
+		return result, nil
+	}
+
+	if changes := groupChanges(entry, desired); len(changes) > 0 {
+		result.Status = "planned"
+		result.Changes = changes
+	}
+
+	return result, nil
+}
+
+// DesiredAttributes translates an intent into group attributes
+func (p *GroupProvider) DesiredAttributes(intent Intent, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	return intentAttributes(intent, attributes, map[string]interface{}{"state": "absent"})
+}
+
+// Apply creates, modifies or deletes the group
+func (p *GroupProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	name := state.Attributes["name"].(string)
+
+	result := &ResourceState{
+		Type:       "group",
+		Name:       name,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	if runtime.GOOS == "windows" {
+		err := fmt.Errorf("group provider is not supported on Windows")
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	entry, err := p.lookupGroup(ctx, name)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	if presenceState(state.Attributes) == "absent" {
+		if entry == nil {
+			return result, nil
+		}
+		if err := p.deleteGroup(ctx, name); err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+		result.Status = "deleted"
+		result.Changed = true
+		return result, nil
+	}
+
+	if entry == nil {
+		if err := p.createGroup(ctx, name, state.Attributes); err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+		result.Status = "created"
+		result.Changed = true
+		result.Outputs = p.groupOutputs(ctx, name)
+		return result, nil
+	}
+
+	changes := groupChanges(entry, state.Attributes)
+	if len(changes) == 0 {
+		result.Outputs = map[string]string{"gid": entry.GID}
+		return result, nil
+	}
+
+	if err := p.modifyGroup(ctx, name, entry, changes, state.Attributes); err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+	result.Status = "updated"
+	result.Changed = true
+	result.Changes = changes
+	result.Outputs = p.groupOutputs(ctx, name)
+
+	return result, nil
+}
+
+// groupOutputs reads back the gid of an applied group so that later resources can
+// refer to it. A group that cannot be read has no outputs.
+func (p *GroupProvider) groupOutputs(ctx context.Context, name string) map[string]string {
+	entry, err := p.lookupGroup(ctx, name)
+	if err != nil || entry == nil || entry.GID == "" {
+		return nil
+	}
+	return map[string]string{"gid": entry.GID}
+}
+
+// groupChanges lists the attributes whose desired value differs from the entry
+func groupChanges(entry *groupEntry, desired map[string]interface{}) []string {
+	var changes []string
+
+	if gid, ok, _ := intAttribute(desired, "gid"); ok && strconv.FormatInt(gid, 10) != entry.GID {
+		changes = append(changes, "gid")
+	}
+	if members, ok, _ := stringSliceAttribute(desired, "members"); ok && !sameStringSet(members, entry.Members) {
+		changes = append(changes, "members")
+	}
+
+	return changes
+}
+
+// readGroup looks up the current group details, returning nil if the group does not exist
+func (p *GroupProvider) readGroup(ctx context.Context, name string) (*groupEntry, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		if _, ok := err.(user.UnknownGroupError); ok {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error looking up group %s: %v", name, err)
+	}
+
+	entry := &groupEntry{GID: g.Gid}
+
+	members, err := p.readMembers(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	entry.Members = members
+
+	return entry, nil
+}
+
+// readMembers returns the explicit members of a group
+func (p *GroupProvider) readMembers(ctx context.Context, name string) ([]string, error) {
+	if runtime.GOOS == "darwin" {
+		output, err := p.runCommand(ctx, "dscl", ".", "-read", "/Groups/"+name, "GroupMembership")
+		if err != nil {
+			// dscl fails when the group has no GroupMembership attribute
+			return nil, nil
+		}
+		line := strings.TrimPrefix(strings.TrimSpace(string(output)), "GroupMembership:")
+		return strings.Fields(line), nil
+	}
+
+	output, err := p.runCommand(ctx, "getent", "group", name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading members of group %s: %v", name, err)
+	}
+	fields := strings.Split(strings.TrimSpace(string(output)), ":")
+	if len(fields) < 4 || fields[3] == "" {
+		return nil, nil
+	}
+	return strings.Split(fields[3], ","), nil
+}
+
+// createGroup adds a new group and its members
+func (p *GroupProvider) createGroup(ctx context.Context, name string, attributes map[string]interface{}) error {
+	gid, hasGID, _ := intAttribute(attributes, "gid")
+	members, _, _ := stringSliceAttribute(attributes, "members")
+
+	var commands [][]string
+	if runtime.GOOS == "darwin" {
+		if !hasGID {
+			return fmt.Errorf("group 'gid' is required to create groups on macOS")
+		}
+		path := "/Groups/" + name
+		commands = append(commands,
+			[]string{"dscl", ".", "-create", path},
+			[]string{"dscl", ".", "-create", path, "PrimaryGroupID", strconv.FormatInt(gid, 10)},
+		)
+	} else {
+		args := []string{"groupadd"}
+		if hasGID {
+			args = append(args, "-g", strconv.FormatInt(gid, 10))
+		}
+		commands = append(commands, append(args, name))
+	}
+
+	commands = append(commands, p.membershipCommands(name, members, nil)...)
+	return runCommands(ctx, p.runCommand, commands)
+}
+
+// modifyGroup updates only the drifted attributes of an existing group
+func (p *GroupProvider) modifyGroup(ctx context.Context, name string, entry *groupEntry, changes []string, attributes map[string]interface{}) error {
+	gid, _, _ := intAttribute(attributes, "gid")
+	members, _, _ := stringSliceAttribute(attributes, "members")
+
+	var commands [][]string
+	for _, change := range changes {
+		switch change {
+		case "gid":
+			if runtime.GOOS == "darwin" {
+				commands = append(commands, []string{"dscl", ".", "-create", "/Groups/" + name, "PrimaryGroupID", strconv.FormatInt(gid, 10)})
+			} else {
+				commands = append(commands, []string{"groupmod", "-g", strconv.FormatInt(gid, 10), name})
+			}
+		case "members":
+			add, remove := diffStringSets(entry.Members, members)
+			commands = append(commands, p.membershipCommands(name, add, remove)...)
+		}
+	}
+
+	return runCommands(ctx, p.runCommand, commands)
+}
+
+// membershipCommands builds the commands that add and remove group members
+func (p *GroupProvider) membershipCommands(name string, add, remove []string) [][]string {
+	var commands [][]string
+	for _, member := range add {
+		if runtime.GOOS == "darwin" {
+			commands = append(commands, []string{"dscl", ".", "-append", "/Groups/" + name, "GroupMembership", member})
+		} else {
+			commands = append(commands, []string{"gpasswd", "-a", member, name})
+		}
+	}
+	for _, member := range remove {
+		if runtime.GOOS == "darwin" {
+			commands = append(commands, []string{"dscl", ".", "-delete", "/Groups/" + name, "GroupMembership", member})
+		} else {
+			commands = append(commands, []string{"gpasswd", "-d", member, name})
+		}
+	}
+	return commands
+}
+
+// deleteGroup removes a group
+func (p *GroupProvider) deleteGroup(ctx context.Context, name string) error {
+	if runtime.GOOS == "darwin" {
+		return runCommands(ctx, p.runCommand, [][]string{{"dscl", ".", "-delete", "/Groups/" + name}})
+	}
+	return runCommands(ctx, p.runCommand, [][]string{{"groupdel", name}})
+}
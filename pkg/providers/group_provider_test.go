@@ -0,0 +1,209 @@
+package providers
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func newTestGroupProvider(entry *groupEntry) (*GroupProvider, *commandRecorder) {
+	recorder := &commandRecorder{}
+	provider := NewGroupProvider()
+	provider.runCommand = recorder.run
+	provider.lookupGroup = func(ctx context.Context, name string) (*groupEntry, error) {
+		return entry, nil
+	}
+	return provider, recorder
+}
+
+func TestGroupProvider_Validate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("group provider is not supported on Windows")
+	}
+
+	provider := NewGroupProvider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"minimal", map[string]interface{}{"name": "deployers"}, false},
+		{"full", map[string]interface{}{
+			"name":    "deployers",
+			"gid":     int64(2000),
+			"members": []string{"alice", "bob"},
+			"state":   "present",
+		}, false},
+		{"missing name", map[string]interface{}{"gid": int64(2000)}, true},
+		{"invalid state", map[string]interface{}{"name": "deployers", "state": "removed"}, true},
+		{"invalid gid", map[string]interface{}{"name": "deployers", "gid": true}, true},
+		{"invalid members", map[string]interface{}{"name": "deployers", "members": "alice"}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestDiffStringSets(t *testing.T) {
+	tests := []struct {
+		current    []string
+		desired    []string
+		wantAdd    []string
+		wantRemove []string
+	}{
+		{nil, []string{"alice"}, []string{"alice"}, nil},
+		{[]string{"alice", "bob"}, []string{"bob", "carol"}, []string{"carol"}, []string{"alice"}},
+		{[]string{"alice", "bob"}, []string{"bob", "alice"}, nil, nil},
+		{[]string{"alice"}, []string{}, nil, []string{"alice"}},
+	}
+
+	for _, tt := range tests {
+		add, remove := diffStringSets(tt.current, tt.desired)
+		if !reflect.DeepEqual(add, tt.wantAdd) || !reflect.DeepEqual(remove, tt.wantRemove) {
+			t.Errorf("diffStringSets(%v, %v) = %v, %v; expected %v, %v",
+				tt.current, tt.desired, add, remove, tt.wantAdd, tt.wantRemove)
+		}
+	}
+}
+
+func TestGroupProvider_Plan(t *testing.T) {
+	ctx := context.Background()
+	existing := &groupEntry{GID: "2000", Members: []string{"alice", "bob"}}
+
+	tests := []struct {
+		name        string
+		entry       *groupEntry
+		desired     map[string]interface{}
+		wantStatus  string
+		wantChanges []string
+	}{
+		{"create", nil, map[string]interface{}{"name": "deployers"}, "planned", []string{"state"}},
+		{"in sync", existing, map[string]interface{}{
+			"name":    "deployers",
+			"gid":     int64(2000),
+			"members": []string{"bob", "alice"},
+		}, "unchanged", nil},
+		{"member drift", existing, map[string]interface{}{
+			"name":    "deployers",
+			"members": []string{"alice"},
+		}, "planned", []string{"members"}},
+		{"remove", existing, map[string]interface{}{"name": "deployers", "state": "absent"}, "planned", []string{"state"}},
+	}
+
+	for _, tt := range tests {
+		provider, _ := newTestGroupProvider(tt.entry)
+		result, err := provider.Plan(ctx, nil, tt.desired)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, result.Status)
+		}
+		if !reflect.DeepEqual(result.Changes, tt.wantChanges) {
+			t.Errorf("%s: expected changes %v, got %v", tt.name, tt.wantChanges, result.Changes)
+		}
+	}
+}
+
+func TestGroupProvider_Apply(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("command expectations are specific to Linux")
+	}
+
+	ctx := context.Background()
+	existing := &groupEntry{GID: "2000", Members: []string{"alice", "bob"}}
+
+	tests := []struct {
+		name         string
+		entry        *groupEntry
+		attrs        map[string]interface{}
+		wantStatus   string
+		wantCommands [][]string
+	}{
+		{"create", nil, map[string]interface{}{
+			"name":    "deployers",
+			"gid":     int64(2000),
+			"members": []string{"alice"},
+		}, "created", [][]string{
+			{"groupadd", "-g", "2000", "deployers"},
+			{"gpasswd", "-a", "alice", "deployers"},
+		}},
+		{"reconcile members", existing, map[string]interface{}{
+			"name":    "deployers",
+			"members": []string{"bob", "carol"},
+		}, "updated", [][]string{
+			{"gpasswd", "-a", "carol", "deployers"},
+			{"gpasswd", "-d", "alice", "deployers"},
+		}},
+		{"change gid", existing, map[string]interface{}{
+			"name": "deployers",
+			"gid":  int64(2001),
+		}, "updated", [][]string{{"groupmod", "-g", "2001", "deployers"}}},
+		{"delete", existing, map[string]interface{}{
+			"name":  "deployers",
+			"state": "absent",
+		}, "deleted", [][]string{{"groupdel", "deployers"}}},
+	}
+
+	for _, tt := range tests {
+		provider, recorder := newTestGroupProvider(tt.entry)
+		result, err := provider.Apply(ctx, &ResourceState{Type: "group", Name: tt.attrs["name"].(string), Attributes: tt.attrs})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, result.Status)
+		}
+		if !reflect.DeepEqual(recorder.commands, tt.wantCommands) {
+			t.Errorf("%s: expected commands %v, got %v", tt.name, tt.wantCommands, recorder.commands)
+		}
+	}
+}
+
+func TestGroupProvider_ApplyOutputs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("group provider is not supported on Windows")
+	}
+
+	ctx := context.Background()
+	attrs := map[string]interface{}{"name": "staff"}
+
+	// The gid assigned on create is read back once the group exists
+	provider, _ := newTestGroupProvider(nil)
+	lookups := 0
+	provider.lookupGroup = func(ctx context.Context, name string) (*groupEntry, error) {
+		lookups++
+		if lookups == 1 {
+			return nil, nil
+		}
+		return &groupEntry{GID: "1042"}, nil
+	}
+	result, err := provider.Apply(ctx, &ResourceState{Type: "group", Name: "staff", Attributes: attrs})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "created" || result.Outputs["gid"] != "1042" {
+		t.Errorf("Expected created with gid 1042, got %s %v", result.Status, result.Outputs)
+	}
+
+	// An unchanged group reports its existing gid
+	provider, _ = newTestGroupProvider(&groupEntry{GID: "2000"})
+	result, err = provider.Apply(ctx, &ResourceState{Type: "group", Name: "staff", Attributes: attrs})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "unchanged" || result.Outputs["gid"] != "2000" {
+		t.Errorf("Expected unchanged with gid 2000, got %s %v", result.Status, result.Outputs)
+	}
+}
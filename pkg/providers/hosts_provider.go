@@ -0,0 +1,248 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// HostsProvider manages entries in the system hosts file
+type HostsProvider struct {
+	platform  *PlatformChecker
+	hostsPath string
+}
+
+// NewHostsProvider creates a new hosts provider
+func NewHostsProvider() *HostsProvider {
+	return &HostsProvider{
+		platform:  &PlatformChecker{},
+		hostsPath: defaultHostsPath(),
+	}
+}
+
+// defaultHostsPath returns the location of the hosts file on this platform
+func defaultHostsPath() string {
+	if runtime.GOOS == "windows" {
+		root := os.Getenv("SystemRoot")
+		if root == "" {
+			root = `C:\Windows`
+		}
+		return filepath.Join(root, "System32", "drivers", "etc", "hosts")
+	}
+	return "/etc/hosts"
+}
+
+// hostsEntry is a parsed hosts file line
+type hostsEntry struct {
+	IP        string
+	Hostnames []string
+	Comment   string
+}
+
+// Validate validates hosts resource attributes
+func (p *HostsProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	ip, ok := attributes["ip"]
+	if !ok && presenceState(attributes) == "present" {
+		return fmt.Errorf("hosts resource requires 'ip' attribute")
+	}
+	if ok {
+		ipStr, isString := ip.(string)
+		if !isString {
+			return fmt.Errorf("hosts 'ip' must be a string")
+		}
+		if net.ParseIP(ipStr) == nil {
+			return fmt.Errorf("hosts 'ip' must be an IP address, got %q", ipStr)
+		}
+	}
+
+	hostnames, hasHostnames, err := stringSliceAttribute(attributes, "hostnames")
+	if err != nil {
+		return fmt.Errorf("hosts %v", err)
+	}
+	if !hasHostnames {
+		return fmt.Errorf("hosts resource requires 'hostnames' attribute")
+	}
+	if len(hostnames) == 0 {
+		return fmt.Errorf("hosts 'hostnames' must not be empty")
+	}
+	for _, hostname := range hostnames {
+		if hostname == "" || strings.ContainsAny(hostname, " \t#\r\n") {
+			return fmt.Errorf("hosts 'hostnames' contains an invalid hostname %q", hostname)
+		}
+	}
+
+	if comment, ok := attributes["comment"]; ok {
+		commentStr, isString := comment.(string)
+		if !isString {
+			return fmt.Errorf("hosts 'comment' must be a string")
+		}
+		if strings.ContainsAny(commentStr, "\r\n") {
+			return fmt.Errorf("hosts 'comment' must be a single line")
+		}
+	}
+
+	state := presenceState(attributes)
+	if state != "present" && state != "absent" {
+		return fmt.Errorf("hosts 'state' must be one of: present, absent")
+	}
+
+	return nil
+}
+
+// Plan determines whether the entry would be inserted, updated or removed
+func (p *HostsProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	hostnames, _, _ := stringSliceAttribute(desired, "hostnames")
+
+	result := &ResourceState{
+		Type:       "hosts",
+		Name:       hostnames[0],
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	content, _, err := p.readHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, action := editHostsEntry(content, desired); action != "" {
+		result.Status = "planned"
+		result.Changes = []string{action}
+	}
+
+	return result, nil
+}
+
+// DesiredAttributes translates an intent into hosts attributes
+func (p *HostsProvider) DesiredAttributes(intent Intent, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	return intentAttributes(intent, attributes, map[string]interface{}{"state": "absent"})
+}
+
+// Apply rewrites the managed entry, leaving comments and other entries as they are
+func (p *HostsProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	hostnames, _, _ := stringSliceAttribute(state.Attributes, "hostnames")
+
+	result := &ResourceState{
+		Type:       "hosts",
+		Name:       hostnames[0],
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	content, mode, err := p.readHosts()
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	updated, action := editHostsEntry(content, state.Attributes)
+	if action == "" {
+		return result, nil
+	}
+
+	if err := ioutil.WriteFile(p.hostsPath, []byte(updated), mode); err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	result.Changed = true
+	result.Changes = []string{action}
+	switch action {
+	case "remove":
+		result.Status = "deleted"
+	case "insert":
+		result.Status = "created"
+	default:
+		result.Status = "updated"
+	}
+
+	return result, nil
+}
+
+// readHosts returns the hosts file content and mode. A missing file is empty.
+func (p *HostsProvider) readHosts() (string, os.FileMode, error) {
+	info, err := os.Stat(p.hostsPath)
+	if os.IsNotExist(err) {
+		return "", 0644, nil
+	} else if err != nil {
+		return "", 0, err
+	}
+
+	data, err := ioutil.ReadFile(p.hostsPath)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return string(data), info.Mode().Perm(), nil
+}
+
+// parseHostsLine parses an entry line, returning false for blank and comment lines
+func parseHostsLine(line string) (hostsEntry, bool) {
+	body, comment, _ := strings.Cut(line, "#")
+	fields := strings.Fields(body)
+	if len(fields) < 2 {
+		return hostsEntry{}, false
+	}
+	return hostsEntry{IP: fields[0], Hostnames: fields[1:], Comment: strings.TrimSpace(comment)}, true
+}
+
+// formatHostsEntry renders an entry as a hosts file line
+func formatHostsEntry(entry hostsEntry) string {
+	line := entry.IP + "\t" + strings.Join(entry.Hostnames, " ")
+	if entry.Comment != "" {
+		line += " # " + entry.Comment
+	}
+	return line
+}
+
+// editHostsEntry applies the desired entry to content and returns the new
+// content with the action taken: "insert", "update", "remove" or "" if none.
+// Entries are matched by their first hostname, which is the resource's primary
+// hostname.
+func editHostsEntry(content string, attributes map[string]interface{}) (string, string) {
+	hostnames, _, _ := stringSliceAttribute(attributes, "hostnames")
+	primary := hostnames[0]
+
+	pieces, newline := linePieces(content)
+
+	managed := func(piece string) (hostsEntry, bool) {
+		entry, ok := parseHostsLine(strings.TrimRight(piece, "\r\n"))
+		return entry, ok && entry.Hostnames[0] == primary
+	}
+
+	if presenceState(attributes) == "absent" {
+		kept := removeLines(pieces, func(body string) bool {
+			_, ok := managed(body)
+			return ok
+		})
+		if len(kept) == len(pieces) {
+			return content, ""
+		}
+		return strings.Join(kept, ""), "remove"
+	}
+
+	comment, _ := attributes["comment"].(string)
+	desired := hostsEntry{IP: attributes["ip"].(string), Hostnames: hostnames, Comment: comment}
+
+	for i, piece := range pieces {
+		entry, ok := managed(piece)
+		if !ok {
+			continue
+		}
+		if entry.IP == desired.IP && strings.Join(entry.Hostnames, " ") == strings.Join(desired.Hostnames, " ") && entry.Comment == desired.Comment {
+			return content, ""
+		}
+		body := strings.TrimRight(piece, "\r\n")
+		pieces[i] = formatHostsEntry(desired) + piece[len(body):]
+		return strings.Join(pieces, ""), "update"
+	}
+
+	return strings.Join(appendLine(pieces, formatHostsEntry(desired), newline), ""), "insert"
+}
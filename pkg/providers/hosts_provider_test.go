@@ -0,0 +1,121 @@
+package providers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// hostsFixture is a hosts file with comments and unrelated entries that must survive edits
+const hostsFixture = `# Static table lookup for hostnames.
+127.0.0.1	localhost
+::1	localhost ip6-localhost
+
+# Internal services
+10.0.0.5	db.internal db # primary database
+`
+
+func newTestHostsProvider(t *testing.T) (*HostsProvider, string) {
+	tempDir, err := ioutil.TempDir("", "hosts-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	path := filepath.Join(tempDir, "hosts")
+	if err := ioutil.WriteFile(path, []byte(hostsFixture), 0644); err != nil {
+		t.Fatalf("Failed to write hosts fixture: %v", err)
+	}
+
+	provider := NewHostsProvider()
+	provider.hostsPath = path
+	return provider, path
+}
+
+func TestHostsProvider_Validate(t *testing.T) {
+	provider := NewHostsProvider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"minimal", map[string]interface{}{"ip": "10.0.0.10", "hostnames": []string{"app.internal"}}, false},
+		{"ipv6 with comment", map[string]interface{}{"ip": "fd00::10", "hostnames": []string{"app.internal", "app"}, "comment": "app server"}, false},
+		{"absent without ip", map[string]interface{}{"hostnames": []string{"app.internal"}, "state": "absent"}, false},
+		{"missing ip", map[string]interface{}{"hostnames": []string{"app.internal"}}, true},
+		{"invalid ip", map[string]interface{}{"ip": "10.0.0", "hostnames": []string{"app.internal"}}, true},
+		{"missing hostnames", map[string]interface{}{"ip": "10.0.0.10"}, true},
+		{"empty hostnames", map[string]interface{}{"ip": "10.0.0.10", "hostnames": []string{}}, true},
+		{"hostname with space", map[string]interface{}{"ip": "10.0.0.10", "hostnames": []string{"app internal"}}, true},
+		{"multiline comment", map[string]interface{}{"ip": "10.0.0.10", "hostnames": []string{"app"}, "comment": "a\nb"}, true},
+		{"invalid state", map[string]interface{}{"ip": "10.0.0.10", "hostnames": []string{"app"}, "state": "gone"}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestHostsProvider_Apply(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		attrs      map[string]interface{}
+		wantAction string
+		wantStatus string
+		want       string
+	}{
+		{"insert", map[string]interface{}{"ip": "10.0.0.10", "hostnames": []string{"app.internal", "app"}}, "insert", "created",
+			hostsFixture + "10.0.0.10\tapp.internal app\n"},
+		{"update", map[string]interface{}{"ip": "10.0.0.6", "hostnames": []string{"db.internal", "db"}, "comment": "replica"}, "update", "updated",
+			"# Static table lookup for hostnames.\n127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost\n\n# Internal services\n10.0.0.6\tdb.internal db # replica\n"},
+		{"remove", map[string]interface{}{"hostnames": []string{"db.internal"}, "state": "absent"}, "remove", "deleted",
+			"# Static table lookup for hostnames.\n127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost\n\n# Internal services\n"},
+		{"unchanged", map[string]interface{}{"ip": "10.0.0.5", "hostnames": []string{"db.internal", "db"}, "comment": "primary database"}, "", "unchanged",
+			hostsFixture},
+	}
+
+	for _, tt := range tests {
+		provider, path := newTestHostsProvider(t)
+
+		plan, err := provider.Plan(ctx, nil, tt.attrs)
+		if err != nil {
+			t.Fatalf("%s: Plan failed: %v", tt.name, err)
+		}
+		if tt.wantAction == "" {
+			if plan.Status != "unchanged" {
+				t.Errorf("%s: expected unchanged plan, got %s %v", tt.name, plan.Status, plan.Changes)
+			}
+		} else if plan.Status != "planned" || len(plan.Changes) != 1 || plan.Changes[0] != tt.wantAction {
+			t.Errorf("%s: expected planned %s, got %s %v", tt.name, tt.wantAction, plan.Status, plan.Changes)
+		}
+
+		result, err := provider.Apply(ctx, plan)
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, result.Status)
+		}
+
+		content, _ := ioutil.ReadFile(path)
+		if string(content) != tt.want {
+			t.Errorf("%s: unexpected hosts file:\n%s\nwant:\n%s", tt.name, content, tt.want)
+		}
+
+		// A second run finds nothing to do
+		plan, err = provider.Plan(ctx, nil, tt.attrs)
+		if err != nil || plan.Status != "unchanged" {
+			t.Errorf("%s: expected unchanged plan after apply, got %v (%v)", tt.name, plan, err)
+		}
+	}
+}
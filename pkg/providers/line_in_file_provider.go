@@ -0,0 +1,263 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LineInFileProvider ensures a single line is present in or absent from a text file
+type LineInFileProvider struct {
+	platform *PlatformChecker
+}
+
+// NewLineInFileProvider creates a new line_in_file provider
+func NewLineInFileProvider() *LineInFileProvider {
+	return &LineInFileProvider{
+		platform: &PlatformChecker{},
+	}
+}
+
+// Validate validates line_in_file resource attributes
+func (p *LineInFileProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	path, ok := attributes["path"]
+	if !ok {
+		return fmt.Errorf("line_in_file resource requires 'path' attribute")
+	}
+	if _, ok := path.(string); !ok {
+		return fmt.Errorf("line_in_file 'path' must be a string")
+	}
+
+	state := presenceState(attributes)
+	if state != "present" && state != "absent" {
+		return fmt.Errorf("line_in_file 'state' must be one of: present, absent")
+	}
+
+	line, hasLine := attributes["line"]
+	if hasLine {
+		lineStr, ok := line.(string)
+		if !ok {
+			return fmt.Errorf("line_in_file 'line' must be a string")
+		}
+		if strings.ContainsAny(lineStr, "\r\n") {
+			return fmt.Errorf("line_in_file 'line' must be a single line")
+		}
+	}
+
+	expr, hasRegexp := attributes["regexp"]
+	if hasRegexp {
+		exprStr, ok := expr.(string)
+		if !ok {
+			return fmt.Errorf("line_in_file 'regexp' must be a string")
+		}
+		if _, err := regexp.Compile(exprStr); err != nil {
+			return fmt.Errorf("line_in_file 'regexp' is invalid: %v", err)
+		}
+	}
+
+	if state == "present" && !hasLine {
+		return fmt.Errorf("line_in_file resource requires 'line' attribute")
+	}
+	if state == "absent" && !hasLine && !hasRegexp {
+		return fmt.Errorf("line_in_file resource with state 'absent' requires 'line' or 'regexp' attribute")
+	}
+
+	if create, ok := attributes["create"]; ok {
+		if _, ok := create.(bool); !ok {
+			return fmt.Errorf("line_in_file 'create' must be a boolean")
+		}
+	}
+
+	return nil
+}
+
+// Plan determines whether the line would be inserted, replaced or removed
+func (p *LineInFileProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	path := desired["path"].(string)
+
+	result := &ResourceState{
+		Type:       "line_in_file",
+		Name:       path,
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	content, _, err := p.readContent(path, desired)
+	if err != nil {
+		return nil, err
+	}
+
+	_, action := editLine(content, desired)
+	if action != "" {
+		result.Status = "planned"
+		result.Changes = []string{action}
+	}
+
+	return result, nil
+}
+
+// DesiredAttributes translates an intent into line_in_file attributes
+func (p *LineInFileProvider) DesiredAttributes(intent Intent, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	return intentAttributes(intent, attributes, map[string]interface{}{"state": "absent"})
+}
+
+// Apply edits the file in place, preserving other content and line endings
+func (p *LineInFileProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	path := state.Attributes["path"].(string)
+
+	result := &ResourceState{
+		Type:       "line_in_file",
+		Name:       path,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	content, mode, err := p.readContent(path, state.Attributes)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	updated, action := editLine(content, state.Attributes)
+	if action == "" {
+		return result, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+	if err := ioutil.WriteFile(path, []byte(updated), mode); err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	result.Changed = true
+	result.Changes = []string{action}
+	switch action {
+	case "remove":
+		result.Status = "deleted"
+	case "insert":
+		result.Status = "created"
+	default:
+		result.Status = "updated"
+	}
+
+	return result, nil
+}
+
+// readContent returns the file content and mode, treating a missing file as
+// empty when it may be created or when the line should be absent
+func (p *LineInFileProvider) readContent(path string, attributes map[string]interface{}) (string, os.FileMode, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		create, _ := attributes["create"].(bool)
+		if presenceState(attributes) == "present" && !create {
+			return "", 0, fmt.Errorf("file %s does not exist (set create = true to create it)", path)
+		}
+		return "", 0644, nil
+	} else if err != nil {
+		return "", 0, err
+	}
+	if info.IsDir() {
+		return "", 0, fmt.Errorf("%s is a directory", path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return string(data), info.Mode().Perm(), nil
+}
+
+// editLine applies the desired line state to content and returns the new
+// content with the action taken: "insert", "replace", "remove" or "" if none
+func editLine(content string, attributes map[string]interface{}) (string, string) {
+	line, _ := attributes["line"].(string)
+
+	var expr *regexp.Regexp
+	if exprStr, ok := attributes["regexp"].(string); ok {
+		expr = regexp.MustCompile(exprStr)
+	}
+
+	pieces, newline := linePieces(content)
+
+	matches := func(body string) bool {
+		if expr != nil {
+			return expr.MatchString(body)
+		}
+		return body == line
+	}
+
+	if presenceState(attributes) == "absent" {
+		kept := removeLines(pieces, matches)
+		if len(kept) == len(pieces) {
+			return content, ""
+		}
+		return strings.Join(kept, ""), "remove"
+	}
+
+	// Replace the last line matching the regexp, as long as the line isn't already present
+	last := -1
+	for i, piece := range pieces {
+		body := strings.TrimRight(piece, "\r\n")
+		if body == line {
+			return content, ""
+		}
+		if expr != nil && expr.MatchString(body) {
+			last = i
+		}
+	}
+
+	if last >= 0 {
+		body := strings.TrimRight(pieces[last], "\r\n")
+		pieces[last] = line + pieces[last][len(body):]
+		return strings.Join(pieces, ""), "replace"
+	}
+
+	return strings.Join(appendLine(pieces, line, newline), ""), "insert"
+}
+
+// linePieces splits content into lines that each keep their own terminator,
+// so untouched lines are written back verbatim, and returns the newline the
+// content uses for new lines
+func linePieces(content string) ([]string, string) {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+
+	pieces := strings.SplitAfter(content, "\n")
+	if len(pieces) > 0 && pieces[len(pieces)-1] == "" {
+		pieces = pieces[:len(pieces)-1]
+	}
+	return pieces, newline
+}
+
+// removeLines returns the pieces whose line, without its terminator, doesn't match
+func removeLines(pieces []string, matches func(body string) bool) []string {
+	kept := pieces[:0:0]
+	for _, piece := range pieces {
+		if !matches(strings.TrimRight(piece, "\r\n")) {
+			kept = append(kept, piece)
+		}
+	}
+	return kept
+}
+
+// appendLine adds line at the end of pieces, terminating the last piece first
+// if the content didn't end with a newline
+func appendLine(pieces []string, line, newline string) []string {
+	if len(pieces) > 0 && !strings.HasSuffix(pieces[len(pieces)-1], "\n") {
+		pieces[len(pieces)-1] += newline
+	}
+	return append(pieces, line+newline)
+}
@@ -0,0 +1,134 @@
+package providers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLineInFileProvider_Validate(t *testing.T) {
+	provider := NewLineInFileProvider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"minimal", map[string]interface{}{"path": "/etc/hosts", "line": "127.0.0.1 app"}, false},
+		{"regexp", map[string]interface{}{"path": "/etc/ssh/sshd_config", "line": "PermitRootLogin no", "regexp": "^#?PermitRootLogin", "create": false}, false},
+		{"absent by regexp", map[string]interface{}{"path": "/etc/hosts", "regexp": "app$", "state": "absent"}, false},
+		{"missing path", map[string]interface{}{"line": "x"}, true},
+		{"missing line", map[string]interface{}{"path": "/etc/hosts"}, true},
+		{"absent without line or regexp", map[string]interface{}{"path": "/etc/hosts", "state": "absent"}, true},
+		{"invalid regexp", map[string]interface{}{"path": "/etc/hosts", "line": "x", "regexp": "("}, true},
+		{"multiline", map[string]interface{}{"path": "/etc/hosts", "line": "a\nb"}, true},
+		{"invalid create", map[string]interface{}{"path": "/etc/hosts", "line": "x", "create": "yes"}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestEditLine(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		attrs      map[string]interface{}
+		want       string
+		wantAction string
+	}{
+		{"insert when absent", "a\nb\n", map[string]interface{}{"line": "c"}, "a\nb\nc\n", "insert"},
+		{"insert without trailing newline", "a\nb", map[string]interface{}{"line": "c"}, "a\nb\nc\n", "insert"},
+		{"insert into empty", "", map[string]interface{}{"line": "c"}, "c\n", "insert"},
+		{"insert keeps crlf", "a\r\nb\r\n", map[string]interface{}{"line": "c"}, "a\r\nb\r\nc\r\n", "insert"},
+		{"already present", "a\nc\nb\n", map[string]interface{}{"line": "c"}, "a\nc\nb\n", ""},
+		{"replace on regexp match", "Port 22\n#PermitRootLogin yes\nX11Forwarding no\n",
+			map[string]interface{}{"line": "PermitRootLogin no", "regexp": "^#?PermitRootLogin"},
+			"Port 22\nPermitRootLogin no\nX11Forwarding no\n", "replace"},
+		{"replace keeps crlf", "a\r\nkey=1\r\nb\r\n",
+			map[string]interface{}{"line": "key=2", "regexp": "^key="},
+			"a\r\nkey=2\r\nb\r\n", "replace"},
+		{"replace last match", "key=1\nkey=2\n",
+			map[string]interface{}{"line": "key=3", "regexp": "^key="},
+			"key=1\nkey=3\n", "replace"},
+		{"regexp without match inserts", "a\n",
+			map[string]interface{}{"line": "key=3", "regexp": "^key="},
+			"a\nkey=3\n", "insert"},
+		{"remove exact line", "a\nb\na\n", map[string]interface{}{"line": "a", "state": "absent"}, "b\n", "remove"},
+		{"remove by regexp", "a\nkey=1\nb\n", map[string]interface{}{"regexp": "^key=", "state": "absent"}, "a\nb\n", "remove"},
+		{"remove when missing", "a\nb\n", map[string]interface{}{"line": "c", "state": "absent"}, "a\nb\n", ""},
+	}
+
+	for _, tt := range tests {
+		got, action := editLine(tt.content, tt.attrs)
+		if got != tt.want || action != tt.wantAction {
+			t.Errorf("%s: expected (%q, %q), got (%q, %q)", tt.name, tt.want, tt.wantAction, got, action)
+		}
+	}
+}
+
+func TestLineInFileProvider_Apply(t *testing.T) {
+	provider := NewLineInFileProvider()
+	ctx := context.Background()
+
+	tempDir, err := ioutil.TempDir("", "line-in-file-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "config")
+
+	// Missing file without create is an error
+	attrs := map[string]interface{}{"path": path, "line": "enabled=true"}
+	if _, err := provider.Plan(ctx, nil, attrs); err == nil {
+		t.Error("Expected error planning against a missing file without create, got nil")
+	}
+
+	// Insert creates the file
+	attrs["create"] = true
+	plan, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" || len(plan.Changes) != 1 || plan.Changes[0] != "insert" {
+		t.Errorf("Expected planned insert, got %s %v", plan.Status, plan.Changes)
+	}
+	if result, err := provider.Apply(ctx, plan); err != nil || result.Status != "created" || !result.Changed {
+		t.Fatalf("Expected created status, got %v (%v)", result, err)
+	}
+
+	// Replace on regexp match
+	attrs = map[string]interface{}{"path": path, "line": "enabled=false", "regexp": "^enabled="}
+	plan, _ = provider.Plan(ctx, nil, attrs)
+	if len(plan.Changes) != 1 || plan.Changes[0] != "replace" {
+		t.Errorf("Expected planned replace, got %v", plan.Changes)
+	}
+	if result, err := provider.Apply(ctx, plan); err != nil || result.Status != "updated" || !result.Changed {
+		t.Fatalf("Expected updated status, got %v (%v)", result, err)
+	}
+
+	content, _ := ioutil.ReadFile(path)
+	if string(content) != "enabled=false\n" {
+		t.Errorf("Expected replaced content, got %q", content)
+	}
+
+	// Remove
+	attrs = map[string]interface{}{"path": path, "regexp": "^enabled=", "state": "absent"}
+	plan, _ = provider.Plan(ctx, nil, attrs)
+	if result, err := provider.Apply(ctx, plan); err != nil || result.Status != "deleted" || !result.Changed {
+		t.Fatalf("Expected deleted status, got %v (%v)", result, err)
+	}
+
+	content, _ = ioutil.ReadFile(path)
+	if string(content) != "" {
+		t.Errorf("Expected empty file after removal, got %q", content)
+	}
+}
@@ -2,129 +2,521 @@ package providers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dangerclosesec/zero/pkg/logging"
 )
 
+// packageCommands maps each package manager to the command prefixes used to
+// query, install, remove and upgrade packages. The package names are
+// appended to the prefix.
+var packageCommands = map[string]map[string][]string{
+	"apt": {
+		"query":   {"dpkg", "-s"},
+		"install": {"apt-get", "install", "-y"},
+		"remove":  {"apt-get", "remove", "-y"},
+		"upgrade": {"apt-get", "install", "--only-upgrade", "-y"},
+	},
+	"dnf": {
+		"query":   {"dnf", "list", "installed"},
+		"install": {"dnf", "install", "-y"},
+		"remove":  {"dnf", "remove", "-y"},
+		"upgrade": {"dnf", "update", "-y"},
+	},
+	"yum": {
+		"query":   {"yum", "list", "installed"},
+		"install": {"yum", "install", "-y"},
+		"remove":  {"yum", "remove", "-y"},
+		"upgrade": {"yum", "update", "-y"},
+	},
+	"pacman": {
+		"query":   {"pacman", "-Q"},
+		"install": {"pacman", "-S", "--noconfirm"},
+		"remove":  {"pacman", "-R", "--noconfirm"},
+		"upgrade": {"pacman", "-Syu", "--noconfirm"},
+	},
+	"zypper": {
+		"query":   {"zypper", "search", "--installed-only"},
+		"install": {"zypper", "install", "-y"},
+		"remove":  {"zypper", "remove", "-y"},
+		"upgrade": {"zypper", "update", "-y"},
+	},
+	"apk": {
+		"query":   {"apk", "info", "-e"},
+		"install": {"apk", "add"},
+		"remove":  {"apk", "del"},
+		"upgrade": {"apk", "upgrade"},
+	},
+	"brew": {
+		"query":   {"brew", "list", "--versions"},
+		"install": {"brew", "install"},
+		"remove":  {"brew", "uninstall"},
+		"upgrade": {"brew", "upgrade"},
+	},
+	"port": {
+		"query":   {"port", "installed"},
+		"install": {"port", "install"},
+		"remove":  {"port", "uninstall"},
+		"upgrade": {"port", "upgrade"},
+	},
+	"choco": {
+		"query":   {"choco", "list", "--local-only"},
+		"install": {"choco", "install", "--yes"},
+		"remove":  {"choco", "uninstall", "--yes"},
+		"upgrade": {"choco", "upgrade", "--yes"},
+	},
+	"winget": {
+		"query":   {"winget", "list", "--exact"},
+		"install": {"winget", "install", "--exact", "--silent"},
+		"remove":  {"winget", "uninstall", "--exact", "--silent"},
+		"upgrade": {"winget", "upgrade", "--exact", "--silent"},
+	},
+	"snap": {
+		"query":   {"snap", "list"},
+		"install": {"snap", "install"},
+		"remove":  {"snap", "remove"},
+		"upgrade": {"snap", "refresh"},
+	},
+	"flatpak": {
+		"query":   {"flatpak", "info"},
+		"install": {"flatpak", "install", "-y"},
+		"remove":  {"flatpak", "uninstall", "-y"},
+		"upgrade": {"flatpak", "update", "-y"},
+	},
+}
+
+// defaultFlatpakRemote is the remote flatpak applications are installed from
+// when 'remote' isn't set
+const defaultFlatpakRemote = "flathub"
+
 // PackageProvider implements package management
 type PackageProvider struct {
-	platform *PlatformChecker
+	platform       *PlatformChecker
+	runCommand     CommandRunner
+	packageManager func() string
+	pacmanConf     string
 }
 
 // NewPackageProvider creates a new package provider
 func NewPackageProvider() *PackageProvider {
+	platform := &PlatformChecker{}
 	return &PackageProvider{
-		platform: &PlatformChecker{},
+		platform:       platform,
+		runCommand:     runCommand,
+		packageManager: platform.GetPackageManager,
+		pacmanConf:     "/etc/pacman.conf",
 	}
 }
 
+// packageSchema declares the package attributes with a fixed type or set of
+// values. Which of name and names is required, and the package managers
+// provider may name, are checked by Validate.
+var packageSchema = Schema{
+	{Name: "name", Type: TypeString},
+	{Name: "state", Type: TypeString, OneOf: []string{"installed", "removed", "latest"}},
+	{Name: "hold", Type: TypeBool},
+	{Name: "provider", Type: TypeString},
+}
+
 // Validate validates package resource attributes
 func (p *PackageProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
-	// Check for required attributes
-	name, ok := attributes["name"]
-	if !ok {
-		return fmt.Errorf("package resource requires 'name' attribute")
+	if err := packageSchema.Validate("package", attributes); err != nil {
+		return err
 	}
 
-	// Validate name is a string
-	_, ok = name.(string)
-	if !ok {
-		return fmt.Errorf("package 'name' must be a string")
+	// Exactly one of name or names is required
+	_, hasName := attributes["name"]
+	names, hasNames, err := stringSliceAttribute(attributes, "names")
+	if err != nil {
+		return fmt.Errorf("package %v", err)
+	}
+	if hasName == hasNames {
+		return fmt.Errorf("package resource requires exactly one of 'name' or 'names' attribute")
+	}
+
+	if hasNames {
+		if len(names) == 0 {
+			return fmt.Errorf("package 'names' must not be empty")
+		}
+		if _, ok := attributes["version"]; ok {
+			return fmt.Errorf("package 'version' can't be used with 'names'")
+		}
+	}
+
+	// Validate hold if present
+	if _, hasHold := attributes["hold"]; hasHold && attributes["state"] == "removed" {
+		return fmt.Errorf("package 'hold' can't be used with state 'removed'")
 	}
 
-	// Validate state if present
-	if state, hasState := attributes["state"].(string); hasState {
-		if state != "installed" && state != "removed" && state != "latest" {
-			return fmt.Errorf("package 'state' must be one of: installed, removed, latest")
+	// Validate provider if present
+	if name, hasProvider := attributes["provider"].(string); hasProvider {
+		if _, known := packageCommands[name]; !known && name != "auto" {
+			return fmt.Errorf("package 'provider' must be one of: auto, %s", strings.Join(packageManagers(), ", "))
 		}
 	}
 
 	// Check package manager availability
-	pkgManager := p.platform.GetPackageManager()
+	pkgManager := p.getPackageManager(attributes)
 	if pkgManager == "unknown" {
 		return fmt.Errorf("no supported package manager found on this system")
 	}
 
+	// Validate channel and remote, which select where snaps and flatpaks come from
+	for _, option := range [][2]string{{"channel", "snap"}, {"remote", "flatpak"}} {
+		key, manager := option[0], option[1]
+		value, ok := attributes[key]
+		if !ok {
+			continue
+		}
+		if str, isString := value.(string); !isString || str == "" {
+			return fmt.Errorf("package '%s' must be a non-empty string", key)
+		}
+		if pkgManager != manager {
+			return fmt.Errorf("package '%s' only applies to %s packages", key, manager)
+		}
+	}
+
+	if _, hasHold := attributes["hold"]; hasHold && !supportsHolds(pkgManager) {
+		logging.FromContext(ctx).Warnf("package manager '%s' doesn't support holds, ignoring 'hold'", pkgManager)
+	}
+
 	return nil
 }
 
-// isPackageInstalled checks if a package is installed
-func (p *PackageProvider) isPackageInstalled(name string) (bool, error) {
-	pkgManager := p.platform.GetPackageManager()
+// packageManagers returns the names of the supported package managers in order
+func packageManagers() []string {
+	names := make([]string, 0, len(packageCommands))
+	for name := range packageCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
-	var cmd *exec.Cmd
+// getPackageManager returns the package manager for a resource: the one named
+// by 'provider', or the detected one
+func (p *PackageProvider) getPackageManager(attributes map[string]interface{}) string {
+	if provider, ok := attributes["provider"].(string); ok && provider != "auto" {
+		return provider
+	}
+	return p.packageManager()
+}
 
-	switch pkgManager {
-	case "apt":
-		cmd = exec.Command("dpkg", "-s", name)
-	case "dnf", "yum":
-		cmd = exec.Command(pkgManager, "list", "installed", name)
-	case "pacman":
-		cmd = exec.Command("pacman", "-Q", name)
-	case "zypper":
-		cmd = exec.Command("zypper", "search", "--installed-only", name)
-	case "apk":
-		cmd = exec.Command("apk", "info", "-e", name)
-	case "brew":
-		cmd = exec.Command("brew", "list", "--versions", name)
-	case "port":
-		cmd = exec.Command("port", "installed", name)
-	case "choco":
-		cmd = exec.Command("choco", "list", "--local-only", name)
-	case "winget":
-		cmd = exec.Command("winget", "list", "--exact", name)
-	default:
+// packageNames returns the packages a resource manages, from 'name' or 'names'
+func packageNames(attributes map[string]interface{}) []string {
+	if name, ok := attributes["name"].(string); ok {
+		return []string{name}
+	}
+	names, _, _ := stringSliceAttribute(attributes, "names")
+	return names
+}
+
+// isPackageInstalled checks if a package is installed
+func (p *PackageProvider) isPackageInstalled(ctx context.Context, pkgManager, name string) (bool, error) {
+	query, ok := packageCommands[pkgManager]["query"]
+	if !ok {
 		return false, fmt.Errorf("unsupported package manager: %s", pkgManager)
 	}
 
-	err := cmd.Run()
+	_, err := p.runCommand(ctx, query[0], append(query[1:], name)...)
 	return err == nil, nil
 }
 
-// getLatestVersion checks if a package has the latest version
-func (p *PackageProvider) getLatestVersion(name string) (string, error) {
-	pkgManager := p.platform.GetPackageManager()
+// partitionInstalled splits names into the packages that are missing and the
+// packages that are installed
+func (p *PackageProvider) partitionInstalled(ctx context.Context, pkgManager string, names []string) ([]string, []string, error) {
+	var missing, installed []string
+	for _, name := range names {
+		ok, err := p.isPackageInstalled(ctx, pkgManager, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			installed = append(installed, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	return missing, installed, nil
+}
 
-	var cmd *exec.Cmd
+// outdatedPackages returns the installed packages that have a newer version
+// available, the installed and candidate versions of each outdated package
+// keyed by name, and a note for each package that is already current. When
+// the versions can't be compared every package is treated as outdated.
+func (p *PackageProvider) outdatedPackages(ctx context.Context, pkgManager string, names []string) ([]string, map[string]AttributeDiff, []string, error) {
+	var outdated, current []string
+	versions := make(map[string]AttributeDiff)
+	for _, name := range names {
+		installed, candidate, ok, err := p.packageVersions(ctx, pkgManager, name)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		switch {
+		case !ok:
+			outdated = append(outdated, name)
+		case compareVersions(installed, candidate) < 0:
+			outdated = append(outdated, name)
+			versions[name] = AttributeDiff{Old: installed, New: candidate}
+		default:
+			current = append(current, fmt.Sprintf("%s %s is the latest version", name, installed))
+		}
+	}
+	return outdated, versions, current, nil
+}
+
+// versionUpgrades describes the version transition of each outdated package
+func versionUpgrades(outdated []string, versions map[string]AttributeDiff) []string {
+	var upgrades []string
+	for _, name := range outdated {
+		if version, ok := versions[name]; ok {
+			upgrades = append(upgrades, fmt.Sprintf("%s %s -> %s", name, version.Old, version.New))
+		}
+	}
+	return upgrades
+}
 
+// packageVersions returns the installed and candidate versions of a package.
+// The installed version is empty when the package isn't installed, and the
+// candidate is the newest version the manager can install. It reports false
+// for package managers whose versions can't be compared.
+func (p *PackageProvider) packageVersions(ctx context.Context, pkgManager, name string) (string, string, bool, error) {
+	var command []string
 	switch pkgManager {
 	case "apt":
-		cmd = exec.Command("apt-cache", "policy", name)
-	case "dnf":
-		cmd = exec.Command("dnf", "info", name)
-	case "yum":
-		cmd = exec.Command("yum", "info", name)
-	case "pacman":
-		cmd = exec.Command("pacman", "-Si", name)
-	case "zypper":
-		cmd = exec.Command("zypper", "info", name)
-	case "apk":
-		cmd = exec.Command("apk", "info", name)
+		command = []string{"apt-cache", "policy", name}
+	case "dnf", "yum":
+		command = []string{pkgManager, "info", name}
 	case "brew":
-		cmd = exec.Command("brew", "info", "--json=v1", name)
-	case "port":
-		cmd = exec.Command("port", "info", name)
-	case "choco":
-		cmd = exec.Command("choco", "info", name, "--limit-output")
-	case "winget":
-		cmd = exec.Command("winget", "show", name)
+		command = []string{"brew", "info", "--json=v1", name}
 	default:
-		return "", fmt.Errorf("unsupported package manager: %s", pkgManager)
+		return "", "", false, nil
 	}
 
-	output, err := cmd.CombinedOutput()
+	output, err := p.runCommand(ctx, command[0], command[1:]...)
 	if err != nil {
-		return "", fmt.Errorf("failed to get package info: %v", err)
+		return "", "", false, fmt.Errorf("failed to get package info for %s: %v: %s", name, err, strings.TrimSpace(string(output)))
+	}
+
+	var installed, candidate string
+	switch pkgManager {
+	case "apt":
+		installed, candidate = parseAptPolicy(string(output))
+	case "dnf", "yum":
+		installed, candidate = parseRpmInfo(string(output))
+	case "brew":
+		installed, candidate, err = parseBrewInfo(output)
+		if err != nil {
+			return "", "", false, err
+		}
 	}
 
-	// For simplicity, we're just returning the output of the command
-	// In a real implementation, we would parse the output to extract the version
-	return string(output), nil
+	if candidate == "" {
+		// Nothing newer is available than what is installed
+		candidate = installed
+	}
+
+	return installed, candidate, candidate != "", nil
+}
+
+// parseAptPolicy reads the Installed and Candidate versions from apt-cache policy
+func parseAptPolicy(output string) (string, string) {
+	var installed, candidate string
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "(none)" {
+			value = ""
+		}
+		switch key {
+		case "Installed":
+			installed = value
+		case "Candidate":
+			candidate = value
+		}
+	}
+	return installed, candidate
+}
+
+// parseRpmInfo reads the installed and available versions from dnf or yum
+// info, which lists each under an "Installed" or "Available" heading. The
+// version is given as version-release, prefixed with any non-zero epoch.
+func parseRpmInfo(output string) (string, string) {
+	versions := make(map[string]string)
+	section := ""
+	var epoch, version string
+
+	record := func(release string) {
+		if section == "" || version == "" || versions[section] != "" {
+			return
+		}
+		full := version + "-" + release
+		if epoch != "" && epoch != "0" {
+			full = epoch + ":" + full
+		}
+		versions[section] = full
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		lower := strings.ToLower(trimmed)
+		switch {
+		case strings.HasPrefix(lower, "installed packages"):
+			section, epoch, version = "installed", "", ""
+			continue
+		case strings.HasPrefix(lower, "available packages"), strings.HasPrefix(lower, "available upgrades"):
+			section, epoch, version = "available", "", ""
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Name":
+			epoch, version = "", ""
+		case "Epoch":
+			epoch = value
+		case "Version":
+			version = value
+		case "Release":
+			record(value)
+		}
+	}
+
+	return versions["installed"], versions["available"]
+}
+
+// parseBrewInfo reads the installed and stable versions from brew info --json=v1
+func parseBrewInfo(output []byte) (string, string, error) {
+	var formulae []struct {
+		Versions struct {
+			Stable string `json:"stable"`
+		} `json:"versions"`
+		Installed []struct {
+			Version string `json:"version"`
+		} `json:"installed"`
+	}
+	if err := json.Unmarshal(output, &formulae); err != nil {
+		return "", "", fmt.Errorf("failed to parse brew info: %v", err)
+	}
+	if len(formulae) == 0 {
+		return "", "", nil
+	}
+
+	formula := formulae[0]
+	installed := ""
+	if len(formula.Installed) > 0 {
+		installed = formula.Installed[len(formula.Installed)-1].Version
+	}
+
+	return installed, formula.Versions.Stable, nil
+}
+
+// compareVersions compares two package versions the way dpkg does: an
+// optional numeric epoch, then alternating runs of non-digits and digits
+// where "~" sorts before everything, even the end of the version. It returns
+// -1, 0 or 1.
+func compareVersions(a, b string) int {
+	epochA, restA := splitEpoch(a)
+	epochB, restB := splitEpoch(b)
+	if epochA != epochB {
+		if epochA < epochB {
+			return -1
+		}
+		return 1
+	}
+
+	for restA != "" || restB != "" {
+		// Compare the non-digit prefixes character by character
+		for (restA != "" && !isDigitByte(restA[0])) || (restB != "" && !isDigitByte(restB[0])) {
+			orderA, orderB := versionOrder(restA), versionOrder(restB)
+			if orderA != orderB {
+				if orderA < orderB {
+					return -1
+				}
+				return 1
+			}
+			restA, restB = restA[1:], restB[1:]
+		}
+
+		// Compare the digit runs numerically
+		var numA, numB string
+		numA, restA = leadingDigits(restA)
+		numB, restB = leadingDigits(restB)
+		numA, numB = strings.TrimLeft(numA, "0"), strings.TrimLeft(numB, "0")
+		if len(numA) != len(numB) {
+			if len(numA) < len(numB) {
+				return -1
+			}
+			return 1
+		}
+		if numA != numB {
+			if numA < numB {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// splitEpoch splits "epoch:version" into its numeric epoch and version
+func splitEpoch(version string) (int, string) {
+	if i := strings.Index(version, ":"); i > 0 {
+		if epoch, err := strconv.Atoi(version[:i]); err == nil {
+			return epoch, version[i+1:]
+		}
+	}
+	return 0, version
+}
+
+// versionOrder ranks the first character of a version's non-digit part:
+// "~" lowest, then the end of the part, then letters, then other characters
+func versionOrder(s string) int {
+	if s == "" || isDigitByte(s[0]) {
+		return 0
+	}
+	c := s[0]
+	switch {
+	case c == '~':
+		return -1
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
+
+// leadingDigits splits s into its leading run of digits and the rest
+func leadingDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigitByte(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigitByte(c byte) bool {
+	return c >= '0' && c <= '9'
 }
 
 // Plan determines what changes would be made to a package
 func (p *PackageProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
-	name := desired["name"].(string)
+	names := packageNames(desired)
 
 	// Get desired state or default to "installed"
 	state := "installed"
@@ -134,42 +526,83 @@ func (p *PackageProvider) Plan(ctx context.Context, current, desired map[string]
 
 	result := &ResourceState{
 		Type:       "package",
-		Name:       name,
+		Name:       strings.Join(names, " "),
 		Attributes: desired,
 		Status:     "unchanged",
 	}
 
-	// Check if the package is installed
-	installed, err := p.isPackageInstalled(name)
+	// Check which packages are installed
+	pkgManager := p.getPackageManager(desired)
+	missing, installed, err := p.partitionInstalled(ctx, pkgManager, names)
 	if err != nil {
 		return nil, err
 	}
 
+	var details []string
 	switch state {
 	case "installed":
-		if !installed {
+		if len(missing) > 0 {
 			result.Status = "planned"
+			details = append(details, "install "+strings.Join(missing, ", "))
 		}
 	case "removed":
-		if installed {
+		if len(installed) > 0 {
 			result.Status = "planned"
+			details = append(details, "remove "+strings.Join(installed, ", "))
 		}
 	case "latest":
-		if !installed {
+		if len(missing) > 0 {
 			result.Status = "planned"
-		} else {
-			// Check if package is at the latest version
-			// This is a simplified implementation
-			result.Status = "planned" // Assume we always need to update
+			details = append(details, "install "+strings.Join(missing, ", "))
+		}
+
+		// Upgrade only when a newer version is available
+		outdated, versions, upToDate, err := p.outdatedPackages(ctx, pkgManager, installed)
+		if err != nil {
+			return nil, err
+		}
+		if len(outdated) > 0 {
+			result.Status = "planned"
+			result.Changes = append(result.Changes, "version")
+		}
+		for _, name := range outdated {
+			if version, ok := versions[name]; ok {
+				result.addDiff(name+" version", version.Old, version.New)
+			}
+		}
+		details = append(details, upToDate...)
+	}
+
+	// Check that the packages are held or released as desired. Like Apply,
+	// this includes packages that are about to be installed, which have to
+	// be held once they are.
+	if hold, ok := desired["hold"].(bool); ok && state != "removed" {
+		change, err := p.holdChanges(ctx, pkgManager, names, hold)
+		if err != nil {
+			return nil, err
+		}
+		if len(change) > 0 {
+			result.Status = "planned"
+			result.Changes = append(result.Changes, "hold")
+			details = append(details, holdVerb(hold)+" "+strings.Join(change, ", "))
+		}
+		for _, name := range change {
+			result.addDiff(name+" hold", strconv.FormatBool(!hold), strconv.FormatBool(hold))
 		}
 	}
+	result.Details = strings.Join(details, "; ")
 
 	return result, nil
 }
 
-// Apply installs, updates, or removes a package
+// DesiredAttributes translates an intent into package attributes
+func (p *PackageProvider) DesiredAttributes(intent Intent, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	return intentAttributes(intent, attributes, map[string]interface{}{"state": "removed"})
+}
+
+// Apply installs, updates, or removes packages
 func (p *PackageProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
-	name := state.Attributes["name"].(string)
+	names := packageNames(state.Attributes)
 
 	// Get desired state or default to "installed"
 	desiredState := "installed"
@@ -190,20 +623,21 @@ func (p *PackageProvider) Apply(ctx context.Context, state *ResourceState) (*Res
 		Status:     "unchanged",
 	}
 
-	// Check if the package is installed
-	installed, err := p.isPackageInstalled(name)
+	// Check which packages are installed
+	pkgManager := p.getPackageManager(state.Attributes)
+	missing, installed, err := p.partitionInstalled(ctx, pkgManager, names)
 	if err != nil {
 		result.Status = "failed"
 		result.Error = err
 		return result, err
 	}
 
-	pkgManager := p.platform.GetPackageManager()
-
 	switch desiredState {
 	case "installed":
-		if !installed {
-			if err := p.installPackage(pkgManager, name, version); err != nil {
+		if len(missing) > 0 {
+			output, err := p.runPackageCommand(ctx, pkgManager, "install", missing, version, state.Attributes)
+			result.Output += output
+			if err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
@@ -211,8 +645,10 @@ func (p *PackageProvider) Apply(ctx context.Context, state *ResourceState) (*Res
 			result.Status = "created"
 		}
 	case "removed":
-		if installed {
-			if err := p.removePackage(pkgManager, name); err != nil {
+		if len(installed) > 0 {
+			output, err := p.runPackageCommand(ctx, pkgManager, "remove", installed, "", state.Attributes)
+			result.Output += output
+			if err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
@@ -220,159 +656,374 @@ func (p *PackageProvider) Apply(ctx context.Context, state *ResourceState) (*Res
 			result.Status = "deleted"
 		}
 	case "latest":
-		if !installed {
-			if err := p.installPackage(pkgManager, name, ""); err != nil {
+		if len(missing) > 0 {
+			output, err := p.runPackageCommand(ctx, pkgManager, "install", missing, "", state.Attributes)
+			result.Output += output
+			if err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
 			}
 			result.Status = "created"
-		} else {
-			if err := p.updatePackage(pkgManager, name); err != nil {
+		}
+
+		outdated, versions, upToDate, err := p.outdatedPackages(ctx, pkgManager, installed)
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+		if len(outdated) > 0 {
+			output, err := p.runPackageCommand(ctx, pkgManager, "upgrade", outdated, "", state.Attributes)
+			result.Output += output
+			if err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
 			}
-			result.Status = "updated"
+			if result.Status == "unchanged" {
+				result.Status = "updated"
+			}
+			result.Details = strings.Join(append(versionUpgrades(outdated, versions), upToDate...), "; ")
 		}
 	}
 
+	// Hold or release the packages once they are installed
+	if hold, ok := state.Attributes["hold"].(bool); ok && desiredState != "removed" {
+		change, err := p.holdChanges(ctx, pkgManager, names, hold)
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+		if len(change) > 0 {
+			if err := p.setHold(ctx, pkgManager, change, hold); err != nil {
+				result.Status = "failed"
+				result.Error = err
+				return result, err
+			}
+			if result.Status == "unchanged" {
+				result.Status = "updated"
+			}
+		}
+	}
+
+	result.Changed = result.Status != "unchanged"
 	return result, nil
 }
 
-// installPackage installs a package
-func (p *PackageProvider) installPackage(pkgManager, name, version string) error {
-	var cmd *exec.Cmd
-
-	// Prepare package name with version if specified
-	pkg := name
-	if version != "" {
-		switch pkgManager {
-		case "apt":
-			pkg = fmt.Sprintf("%s=%s", name, version)
-		case "dnf", "yum":
-			pkg = fmt.Sprintf("%s-%s", name, version)
-		case "pacman":
-			pkg = fmt.Sprintf("%s=%s", name, version)
-		case "zypper":
-			pkg = fmt.Sprintf("%s=%s", name, version)
-		case "apk":
-			pkg = fmt.Sprintf("%s=%s", name, version)
-		case "brew":
-			// Homebrew doesn't support installing specific versions directly
-			pkg = name
-		case "port":
-			pkg = fmt.Sprintf("%s@%s", name, version)
-		case "choco":
-			pkg = fmt.Sprintf("%s --version=%s", name, version)
-		case "winget":
-			pkg = fmt.Sprintf("%s --version %s", name, version)
+// supportsHolds reports whether holds can be managed for a package manager
+func supportsHolds(pkgManager string) bool {
+	switch pkgManager {
+	case "apt", "dnf", "yum", "pacman":
+		return true
+	}
+	return false
+}
+
+func holdVerb(hold bool) string {
+	if hold {
+		return "hold"
+	}
+	return "unhold"
+}
+
+// holdChanges returns the packages whose hold status differs from hold. It
+// returns nothing for package managers that don't support holds.
+func (p *PackageProvider) holdChanges(ctx context.Context, pkgManager string, names []string, hold bool) ([]string, error) {
+	if !supportsHolds(pkgManager) {
+		return nil, nil
+	}
+
+	held, err := p.heldPackages(ctx, pkgManager)
+	if errors.Is(err, errVersionlockUnavailable) {
+		// Without the plugin nothing can be held, which is only a problem
+		// when packages should be
+		if !hold {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%v; install %s to hold packages", err, versionlockPlugin(pkgManager))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var change []string
+	for _, name := range names {
+		if held[name] != hold {
+			change = append(change, name)
 		}
 	}
+	return change, nil
+}
+
+// errVersionlockUnavailable is returned by heldPackages when dnf or yum can't
+// list version locks, usually because the versionlock plugin isn't installed
+var errVersionlockUnavailable = errors.New("failed to list version locks")
+
+// versionlockPlugin returns the package that provides the versionlock plugin
+func versionlockPlugin(pkgManager string) string {
+	if pkgManager == "yum" {
+		return "yum-plugin-versionlock"
+	}
+	return "python3-dnf-plugin-versionlock"
+}
+
+// heldPackages returns the set of packages that are held at their version
+func (p *PackageProvider) heldPackages(ctx context.Context, pkgManager string) (map[string]bool, error) {
+	held := make(map[string]bool)
 
 	switch pkgManager {
 	case "apt":
-		cmd = exec.Command("apt-get", "install", "-y", pkg)
-	case "dnf":
-		cmd = exec.Command("dnf", "install", "-y", pkg)
-	case "yum":
-		cmd = exec.Command("yum", "install", "-y", pkg)
+		output, err := p.runCommand(ctx, "apt-mark", "showhold")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list held packages: %v: %s", err, strings.TrimSpace(string(output)))
+		}
+		for _, name := range strings.Fields(string(output)) {
+			held[name] = true
+		}
+	case "dnf", "yum":
+		output, err := p.runCommand(ctx, pkgManager, "versionlock", "list")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v: %s", errVersionlockUnavailable, err, strings.TrimSpace(string(output)))
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			if name := versionlockName(strings.TrimSpace(line)); name != "" {
+				held[name] = true
+			}
+		}
 	case "pacman":
-		cmd = exec.Command("pacman", "-S", "--noconfirm", pkg)
-	case "zypper":
-		cmd = exec.Command("zypper", "install", "-y", pkg)
-	case "apk":
-		cmd = exec.Command("apk", "add", pkg)
-	case "brew":
-		cmd = exec.Command("brew", "install", pkg)
-	case "port":
-		cmd = exec.Command("port", "install", pkg)
-	case "choco":
-		cmd = exec.Command("choco", "install", "--yes", pkg)
-	case "winget":
-		cmd = exec.Command("winget", "install", "--exact", "--silent", pkg)
-	default:
-		return fmt.Errorf("unsupported package manager: %s", pkgManager)
+		data, err := ioutil.ReadFile(p.pacmanConf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", p.pacmanConf, err)
+		}
+		for _, name := range pacmanIgnored(string(data)) {
+			held[name] = true
+		}
 	}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to install package %s: %v\nOutput: %s", name, err, string(output))
+	return held, nil
+}
+
+// versionlockName returns the package name of a versionlock entry such as
+// "nginx-1:1.20.1-10.fc34.*" or "0:nginx-1.20.1-10.el7.*", or "" for lines
+// that aren't entries
+func versionlockName(entry string) string {
+	if !strings.HasSuffix(entry, ".*") || strings.ContainsAny(entry, " \t") {
+		return ""
 	}
+	entry = strings.TrimSuffix(entry, ".*")
 
-	return nil
+	// Drop a leading epoch
+	if i := strings.Index(entry, ":"); i >= 0 && i < strings.Index(entry, "-") {
+		entry = entry[i+1:]
+	}
+
+	// Drop the version and release
+	parts := strings.Split(entry, "-")
+	if len(parts) < 3 {
+		return ""
+	}
+	return strings.Join(parts[:len(parts)-2], "-")
+}
+
+// pacmanIgnored returns the packages listed on IgnorePkg lines in pacman.conf
+func pacmanIgnored(conf string) []string {
+	var names []string
+	for _, line := range strings.Split(conf, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok && strings.TrimSpace(key) == "IgnorePkg" {
+			names = append(names, strings.Fields(value)...)
+		}
+	}
+	return names
 }
 
-// removePackage removes a package
-func (p *PackageProvider) removePackage(pkgManager, name string) error {
-	var cmd *exec.Cmd
+// setPacmanIgnored rewrites pacman.conf so that IgnorePkg lists exactly
+// names. The list replaces the first IgnorePkg line, or is added after the
+// [options] section header when there is none.
+func setPacmanIgnored(conf string, names []string) string {
+	lines := strings.Split(conf, "\n")
+	ignoreLine := "IgnorePkg = " + strings.Join(names, " ")
+
+	isIgnoreLine := func(line string) bool {
+		key, _, ok := strings.Cut(strings.TrimSpace(line), "=")
+		return ok && strings.TrimSpace(key) == "IgnorePkg"
+	}
+
+	hasIgnoreLine := false
+	for _, line := range lines {
+		if isIgnoreLine(line) {
+			hasIgnoreLine = true
+			break
+		}
+	}
+
+	result := make([]string, 0, len(lines)+1)
+	written := len(names) == 0
+	for _, line := range lines {
+		if isIgnoreLine(line) {
+			if !written {
+				result = append(result, ignoreLine)
+				written = true
+			}
+			continue
+		}
+		result = append(result, line)
+		if !hasIgnoreLine && !written && strings.TrimSpace(line) == "[options]" {
+			result = append(result, ignoreLine)
+			written = true
+		}
+	}
 
+	return strings.Join(result, "\n")
+}
+
+// setHold holds or releases packages
+func (p *PackageProvider) setHold(ctx context.Context, pkgManager string, names []string, hold bool) error {
+	var command []string
 	switch pkgManager {
 	case "apt":
-		cmd = exec.Command("apt-get", "remove", "-y", name)
-	case "dnf":
-		cmd = exec.Command("dnf", "remove", "-y", name)
-	case "yum":
-		cmd = exec.Command("yum", "remove", "-y", name)
+		command = append([]string{"apt-mark", holdVerb(hold)}, names...)
+	case "dnf", "yum":
+		action := "delete"
+		if hold {
+			action = "add"
+		}
+		command = append([]string{pkgManager, "versionlock", action}, names...)
 	case "pacman":
-		cmd = exec.Command("pacman", "-R", "--noconfirm", name)
-	case "zypper":
-		cmd = exec.Command("zypper", "remove", "-y", name)
-	case "apk":
-		cmd = exec.Command("apk", "del", name)
-	case "brew":
-		cmd = exec.Command("brew", "uninstall", name)
-	case "port":
-		cmd = exec.Command("port", "uninstall", name)
-	case "choco":
-		cmd = exec.Command("choco", "uninstall", "--yes", name)
-	case "winget":
-		cmd = exec.Command("winget", "uninstall", "--exact", "--silent", name)
+		return p.setPacmanHold(names, hold)
 	default:
-		return fmt.Errorf("unsupported package manager: %s", pkgManager)
+		return nil
 	}
 
-	output, err := cmd.CombinedOutput()
+	output, err := p.runCommand(ctx, command[0], command[1:]...)
 	if err != nil {
-		return fmt.Errorf("failed to remove package %s: %v\nOutput: %s", name, err, string(output))
+		return fmt.Errorf("failed to %s package %s: %v\nOutput: %s", holdVerb(hold), strings.Join(names, " "), err, string(output))
+	}
+	return nil
+}
+
+// setPacmanHold adds packages to, or removes them from, IgnorePkg in pacman.conf
+func (p *PackageProvider) setPacmanHold(names []string, hold bool) error {
+	info, err := os.Stat(p.pacmanConf)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", p.pacmanConf, err)
+	}
+	data, err := ioutil.ReadFile(p.pacmanConf)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", p.pacmanConf, err)
+	}
+
+	ignored := pacmanIgnored(string(data))
+	if hold {
+		ignored = append(ignored, names...)
+	} else {
+		release := make(map[string]bool, len(names))
+		for _, name := range names {
+			release[name] = true
+		}
+		kept := ignored[:0]
+		for _, name := range ignored {
+			if !release[name] {
+				kept = append(kept, name)
+			}
+		}
+		ignored = kept
+	}
+
+	if err := ioutil.WriteFile(p.pacmanConf, []byte(setPacmanIgnored(string(data), ignored)), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %v", p.pacmanConf, err)
 	}
+	return nil
+}
 
+// packageOptions returns the arguments that go before the package names: the
+// channel snaps are installed or refreshed from, and the remote flatpaks are
+// installed from
+func packageOptions(pkgManager, action string, attributes map[string]interface{}) []string {
+	switch pkgManager {
+	case "snap":
+		if channel, ok := attributes["channel"].(string); ok && action != "remove" {
+			return []string{"--channel=" + channel}
+		}
+	case "flatpak":
+		if action == "install" {
+			remote, ok := attributes["remote"].(string)
+			if !ok {
+				remote = defaultFlatpakRemote
+			}
+			return []string{remote}
+		}
+	}
 	return nil
 }
 
-// updatePackage updates a package to the latest version
-func (p *PackageProvider) updatePackage(pkgManager, name string) error {
-	var cmd *exec.Cmd
+// packageCommand builds the commands that install, remove or upgrade
+// packages, with options placed before the package names. Every package goes
+// in a single call, except with winget, and snap with a channel, which take
+// one package per call.
+func packageCommand(pkgManager, action string, names []string, version string, options []string) ([][]string, error) {
+	prefix, ok := packageCommands[pkgManager][action]
+	if !ok {
+		return nil, fmt.Errorf("unsupported package manager: %s", pkgManager)
+	}
+	prefix = append(append([]string{}, prefix...), options...)
+
+	if pkgManager == "winget" || (pkgManager == "snap" && len(options) > 0) {
+		commands := make([][]string, 0, len(names))
+		for _, name := range names {
+			command := append(append([]string{}, prefix...), versionedPackage(pkgManager, name, version)...)
+			commands = append(commands, command)
+		}
+		return commands, nil
+	}
+
+	command := prefix
+	for _, name := range names {
+		command = append(command, versionedPackage(pkgManager, name, version)...)
+	}
+	return [][]string{command}, nil
+}
+
+// versionedPackage returns the arguments that select a package at version
+func versionedPackage(pkgManager, name, version string) []string {
+	if version == "" {
+		return []string{name}
+	}
 
 	switch pkgManager {
-	case "apt":
-		cmd = exec.Command("apt-get", "install", "--only-upgrade", "-y", name)
-	case "dnf":
-		cmd = exec.Command("dnf", "update", "-y", name)
-	case "yum":
-		cmd = exec.Command("yum", "update", "-y", name)
-	case "pacman":
-		cmd = exec.Command("pacman", "-Syu", "--noconfirm", name)
-	case "zypper":
-		cmd = exec.Command("zypper", "update", "-y", name)
-	case "apk":
-		cmd = exec.Command("apk", "upgrade", name)
-	case "brew":
-		cmd = exec.Command("brew", "upgrade", name)
+	case "apt", "pacman", "zypper", "apk":
+		return []string{fmt.Sprintf("%s=%s", name, version)}
+	case "dnf", "yum":
+		return []string{fmt.Sprintf("%s-%s", name, version)}
 	case "port":
-		cmd = exec.Command("port", "upgrade", name)
-	case "choco":
-		cmd = exec.Command("choco", "upgrade", "--yes", name)
-	case "winget":
-		cmd = exec.Command("winget", "upgrade", "--exact", "--silent", name)
+		return []string{fmt.Sprintf("%s@%s", name, version)}
+	case "choco", "winget":
+		return []string{name, "--version", version}
 	default:
-		return fmt.Errorf("unsupported package manager: %s", pkgManager)
+		// Homebrew, snap and flatpak don't support installing specific
+		// versions directly
+		return []string{name}
 	}
+}
 
-	output, err := cmd.CombinedOutput()
+// runPackageCommand installs, removes or upgrades packages, returning the
+// combined output of the commands it ran
+func (p *PackageProvider) runPackageCommand(ctx context.Context, pkgManager, action string, names []string, version string, attributes map[string]interface{}) (string, error) {
+	commands, err := packageCommand(pkgManager, action, names, version, packageOptions(pkgManager, action, attributes))
 	if err != nil {
-		return fmt.Errorf("failed to update package %s: %v\nOutput: %s", name, err, string(output))
+		return "", err
 	}
 
-	return nil
+	var combined strings.Builder
+	for _, command := range commands {
+		output, err := p.runCommand(ctx, command[0], command[1:]...)
+		combined.Write(output)
+		if err != nil {
+			return combined.String(), fmt.Errorf("failed to %s package %s: %v", action, strings.Join(names, " "), err)
+		}
+	}
+
+	return combined.String(), nil
 }
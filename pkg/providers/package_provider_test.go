@@ -0,0 +1,581 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAptPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		wantInstalled string
+		wantCandidate string
+	}{
+		{"upgradable", `nginx:
+  Installed: 1.18.0-0ubuntu1
+  Candidate: 1.18.0-0ubuntu1.4
+  Version table:
+     1.18.0-0ubuntu1.4 500
+        500 http://archive.ubuntu.com/ubuntu focal-updates/main amd64 Packages
+ *** 1.18.0-0ubuntu1 100
+        100 /var/lib/dpkg/status
+`, "1.18.0-0ubuntu1", "1.18.0-0ubuntu1.4"},
+		{"not installed", `curl:
+  Installed: (none)
+  Candidate: 7.68.0-1ubuntu2.18
+  Version table:
+     7.68.0-1ubuntu2.18 500
+`, "", "7.68.0-1ubuntu2.18"},
+		{"epoch", `vim:
+  Installed: 2:8.1.2269-1ubuntu5
+  Candidate: 2:8.1.2269-1ubuntu5.21
+`, "2:8.1.2269-1ubuntu5", "2:8.1.2269-1ubuntu5.21"},
+	}
+
+	for _, tt := range tests {
+		installed, candidate := parseAptPolicy(tt.output)
+		if installed != tt.wantInstalled || candidate != tt.wantCandidate {
+			t.Errorf("%s: expected %q/%q, got %q/%q", tt.name, tt.wantInstalled, tt.wantCandidate, installed, candidate)
+		}
+	}
+}
+
+func TestParseRpmInfo(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		wantInstalled string
+		wantCandidate string
+	}{
+		{"upgradable", `Last metadata expiration check: 0:10:12 ago.
+Installed Packages
+Name         : nginx
+Epoch        : 1
+Version      : 1.20.1
+Release      : 10.fc34
+Architecture : x86_64
+Size         : 1.7 M
+
+Available Packages
+Name         : nginx
+Epoch        : 1
+Version      : 1.20.2
+Release      : 1.fc34
+Architecture : x86_64
+`, "1:1.20.1-10.fc34", "1:1.20.2-1.fc34"},
+		{"up to date", `Installed Packages
+Name         : curl
+Version      : 7.76.1
+Release      : 14.el9
+Architecture : x86_64
+`, "7.76.1-14.el9", ""},
+		{"not installed", `Available Packages
+Name         : htop
+Epoch        : 0
+Version      : 3.2.1
+Release      : 1.el9
+`, "", "3.2.1-1.el9"},
+	}
+
+	for _, tt := range tests {
+		installed, candidate := parseRpmInfo(tt.output)
+		if installed != tt.wantInstalled || candidate != tt.wantCandidate {
+			t.Errorf("%s: expected %q/%q, got %q/%q", tt.name, tt.wantInstalled, tt.wantCandidate, installed, candidate)
+		}
+	}
+}
+
+func TestParseBrewInfo(t *testing.T) {
+	output := `[{"name":"wget","versions":{"stable":"1.21.4","head":"HEAD","bottle":true},"installed":[{"version":"1.21.3","used_options":[]}]}]`
+
+	installed, candidate, err := parseBrewInfo([]byte(output))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if installed != "1.21.3" || candidate != "1.21.4" {
+		t.Errorf("Expected 1.21.3/1.21.4, got %q/%q", installed, candidate)
+	}
+
+	installed, candidate, err = parseBrewInfo([]byte(`[{"name":"jq","versions":{"stable":"1.7.1"},"installed":[]}]`))
+	if err != nil || installed != "" || candidate != "1.7.1" {
+		t.Errorf("Expected not installed with candidate 1.7.1, got %q/%q (%v)", installed, candidate, err)
+	}
+
+	if _, _, err := parseBrewInfo([]byte("Error: No available formula")); err == nil {
+		t.Error("Expected error for invalid brew output, got nil")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.18.0-0ubuntu1", "1.18.0-0ubuntu1.4", -1},
+		{"2:1.0", "1:9.9", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0a", -1},
+		{"1.01", "1.1", 0},
+		{"1:1.20.2-1.fc34", "1:1.20.1-10.fc34", 1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPackageProvider_packageVersions(t *testing.T) {
+	recorder := &commandRecorder{output: map[string]string{
+		"apt-cache policy nginx": "nginx:\n  Installed: 1.18.0-0ubuntu1\n  Candidate: 1.18.0-0ubuntu1\n",
+	}}
+	provider := NewPackageProvider()
+	provider.runCommand = recorder.run
+
+	installed, candidate, ok, err := provider.packageVersions(context.Background(), "apt", "nginx")
+	if err != nil || !ok {
+		t.Fatalf("Unexpected result: ok=%v err=%v", ok, err)
+	}
+	if compareVersions(installed, candidate) != 0 {
+		t.Errorf("Expected installed version to be current, got %q/%q", installed, candidate)
+	}
+
+	if _, _, ok, _ := provider.packageVersions(context.Background(), "pacman", "nginx"); ok {
+		t.Error("Expected versions to be unavailable for pacman")
+	}
+}
+
+func newTestPackageProvider(pkgManager string) (*PackageProvider, *commandRecorder) {
+	recorder := &commandRecorder{output: map[string]string{}, fail: map[string]error{}}
+	provider := NewPackageProvider()
+	provider.runCommand = recorder.run
+	provider.packageManager = func() string { return pkgManager }
+	return provider, recorder
+}
+
+func TestPackageProvider_Validate(t *testing.T) {
+	provider, _ := newTestPackageProvider("apt")
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"name", map[string]interface{}{"name": "nginx"}, false},
+		{"names", map[string]interface{}{"names": []string{"curl", "git"}, "state": "latest"}, false},
+		{"neither", map[string]interface{}{"state": "installed"}, true},
+		{"both", map[string]interface{}{"name": "nginx", "names": []string{"curl"}}, true},
+		{"invalid name", map[string]interface{}{"name": int64(1)}, true},
+		{"invalid names", map[string]interface{}{"names": []interface{}{"curl", int64(1)}}, true},
+		{"empty names", map[string]interface{}{"names": []string{}}, true},
+		{"names with version", map[string]interface{}{"names": []string{"curl"}, "version": "7.0"}, true},
+		{"invalid state", map[string]interface{}{"name": "nginx", "state": "gone"}, true},
+		{"provider", map[string]interface{}{"name": "nginx", "provider": "dnf"}, false},
+		{"auto provider", map[string]interface{}{"name": "nginx", "provider": "auto"}, false},
+		{"invalid provider", map[string]interface{}{"name": "nginx", "provider": "emerge"}, true},
+		{"non-string provider", map[string]interface{}{"name": "nginx", "provider": true}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestPackageProvider_PlanPartial(t *testing.T) {
+	provider, recorder := newTestPackageProvider("apt")
+	recorder.fail["dpkg -s git"] = errors.New("exit status 1")
+	recorder.fail["dpkg -s jq"] = errors.New("exit status 1")
+
+	attrs := map[string]interface{}{"names": []string{"curl", "git", "jq"}}
+	result, err := provider.Plan(context.Background(), nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "planned" || result.Details != "install git, jq" {
+		t.Errorf("Expected planned install of git, jq, got %s %q", result.Status, result.Details)
+	}
+
+	attrs["state"] = "removed"
+	result, err = provider.Plan(context.Background(), nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "planned" || result.Details != "remove curl" {
+		t.Errorf("Expected planned removal of curl, got %s %q", result.Status, result.Details)
+	}
+}
+
+func TestPackageProvider_ApplyBatched(t *testing.T) {
+	tests := []struct {
+		name         string
+		pkgManager   string
+		attrs        map[string]interface{}
+		missing      []string
+		wantStatus   string
+		wantCommands [][]string
+	}{
+		{"install missing", "apt", map[string]interface{}{"names": []string{"curl", "git", "jq"}}, []string{"git", "jq"}, "created",
+			[][]string{{"apt-get", "install", "-y", "git", "jq"}}},
+		{"remove installed", "dnf", map[string]interface{}{"names": []string{"curl", "git"}, "state": "removed"}, []string{"git"}, "deleted",
+			[][]string{{"dnf", "remove", "-y", "curl"}}},
+		{"all present", "apt", map[string]interface{}{"names": []string{"curl", "git"}}, nil, "unchanged", nil},
+		{"winget one per call", "winget", map[string]interface{}{"names": []string{"Git.Git", "jqlang.jq"}}, []string{"Git.Git", "jqlang.jq"}, "created",
+			[][]string{
+				{"winget", "install", "--exact", "--silent", "Git.Git"},
+				{"winget", "install", "--exact", "--silent", "jqlang.jq"},
+			}},
+		{"single with version", "apt", map[string]interface{}{"name": "nginx", "version": "1.18.0"}, []string{"nginx"}, "created",
+			[][]string{{"apt-get", "install", "-y", "nginx=1.18.0"}}},
+	}
+
+	for _, tt := range tests {
+		provider, recorder := newTestPackageProvider(tt.pkgManager)
+		query := strings.Join(packageCommands[tt.pkgManager]["query"], " ")
+		for _, name := range tt.missing {
+			recorder.fail[query+" "+name] = errors.New("exit status 1")
+		}
+
+		result, err := provider.Apply(context.Background(), &ResourceState{Type: "package", Attributes: tt.attrs})
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, result.Status)
+		}
+
+		// Drop the installed checks and keep the commands that change packages
+		var commands [][]string
+		for _, command := range recorder.commands {
+			if !strings.HasPrefix(strings.Join(command, " "), query+" ") {
+				commands = append(commands, command)
+			}
+		}
+		if !reflect.DeepEqual(commands, tt.wantCommands) {
+			t.Errorf("%s: expected commands %v, got %v", tt.name, tt.wantCommands, commands)
+		}
+	}
+}
+
+func TestPackageProvider_ProviderOverride(t *testing.T) {
+	provider, recorder := newTestPackageProvider("apt")
+	recorder.fail["dnf list installed nginx"] = errors.New("exit status 1")
+
+	attrs := map[string]interface{}{"name": "nginx", "provider": "dnf"}
+	result, err := provider.Apply(context.Background(), &ResourceState{Type: "package", Attributes: attrs})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "created" {
+		t.Errorf("Expected status created, got %s", result.Status)
+	}
+
+	want := [][]string{{"dnf", "list", "installed", "nginx"}, {"dnf", "install", "-y", "nginx"}}
+	if !reflect.DeepEqual(recorder.commands, want) {
+		t.Errorf("Expected the dnf override to be used, got %v", recorder.commands)
+	}
+
+	if got := provider.getPackageManager(map[string]interface{}{"provider": "auto"}); got != "apt" {
+		t.Errorf("Expected auto to use the detected package manager, got %s", got)
+	}
+}
+
+func TestPackageProvider_ApplyOutput(t *testing.T) {
+	ctx := context.Background()
+	attrs := map[string]interface{}{"name": "nginx"}
+
+	provider, recorder := newTestPackageProvider("apt")
+	recorder.fail["dpkg -s nginx"] = errors.New("exit status 1")
+	recorder.output["apt-get install -y nginx"] = "Setting up nginx (1.18.0) ...\n"
+	result, err := provider.Apply(ctx, &ResourceState{Type: "package", Attributes: attrs})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if result.Output != "Setting up nginx (1.18.0) ...\n" {
+		t.Errorf("Expected install output to be captured, got %q", result.Output)
+	}
+
+	provider, recorder = newTestPackageProvider("apt")
+	recorder.fail["dpkg -s nginx"] = errors.New("exit status 1")
+	recorder.fail["apt-get install -y nginx"] = errors.New("exit status 100")
+	recorder.output["apt-get install -y nginx"] = "E: Unable to locate package nginx\n"
+	result, err = provider.Apply(ctx, &ResourceState{Type: "package", Attributes: attrs})
+	if err == nil || result.Status != "failed" {
+		t.Fatalf("Expected apply to fail, got %v", err)
+	}
+	if result.Output != "E: Unable to locate package nginx\n" {
+		t.Errorf("Expected failure output to be captured, got %q", result.Output)
+	}
+	if strings.Contains(err.Error(), "Unable to locate") {
+		t.Errorf("Expected output to be kept out of the error, got %v", err)
+	}
+}
+
+func TestPackageCommand_SnapFlatpak(t *testing.T) {
+	tests := []struct {
+		name         string
+		pkgManager   string
+		action       string
+		names        []string
+		attrs        map[string]interface{}
+		wantCommands [][]string
+	}{
+		{"snap install", "snap", "install", []string{"core", "lxd"}, map[string]interface{}{},
+			[][]string{{"snap", "install", "core", "lxd"}}},
+		{"snap install channel", "snap", "install", []string{"core", "lxd"}, map[string]interface{}{"channel": "latest/edge"},
+			[][]string{{"snap", "install", "--channel=latest/edge", "core"}, {"snap", "install", "--channel=latest/edge", "lxd"}}},
+		{"snap remove", "snap", "remove", []string{"lxd"}, map[string]interface{}{"channel": "latest/edge"},
+			[][]string{{"snap", "remove", "lxd"}}},
+		{"snap upgrade", "snap", "upgrade", []string{"lxd"}, map[string]interface{}{"channel": "5.0/stable"},
+			[][]string{{"snap", "refresh", "--channel=5.0/stable", "lxd"}}},
+		{"flatpak install", "flatpak", "install", []string{"org.gimp.GIMP"}, map[string]interface{}{},
+			[][]string{{"flatpak", "install", "-y", "flathub", "org.gimp.GIMP"}}},
+		{"flatpak install remote", "flatpak", "install", []string{"org.gimp.GIMP"}, map[string]interface{}{"remote": "fedora"},
+			[][]string{{"flatpak", "install", "-y", "fedora", "org.gimp.GIMP"}}},
+		{"flatpak remove", "flatpak", "remove", []string{"org.gimp.GIMP"}, map[string]interface{}{"remote": "fedora"},
+			[][]string{{"flatpak", "uninstall", "-y", "org.gimp.GIMP"}}},
+		{"flatpak upgrade", "flatpak", "upgrade", []string{"org.gimp.GIMP"}, map[string]interface{}{},
+			[][]string{{"flatpak", "update", "-y", "org.gimp.GIMP"}}},
+	}
+
+	for _, tt := range tests {
+		commands, err := packageCommand(tt.pkgManager, tt.action, tt.names, "", packageOptions(tt.pkgManager, tt.action, tt.attrs))
+		if err != nil {
+			t.Fatalf("%s: packageCommand failed: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(commands, tt.wantCommands) {
+			t.Errorf("%s: expected commands %v, got %v", tt.name, tt.wantCommands, commands)
+		}
+	}
+}
+
+func TestPackageProvider_SnapFlatpak(t *testing.T) {
+	ctx := context.Background()
+
+	provider, recorder := newTestPackageProvider("apt")
+	recorder.fail["snap list lxd"] = errors.New("exit status 1")
+	attrs := map[string]interface{}{"name": "lxd", "provider": "snap", "channel": "latest/stable"}
+	if err := provider.Validate(ctx, attrs); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	result, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "planned" || result.Details != "install lxd" {
+		t.Errorf("Expected planned install of lxd, got %s %q", result.Status, result.Details)
+	}
+
+	// Flatpak applications that are installed are removed
+	provider, recorder = newTestPackageProvider("flatpak")
+	attrs = map[string]interface{}{"name": "org.gimp.GIMP", "state": "removed"}
+	result, err = provider.Apply(ctx, &ResourceState{Type: "package", Attributes: attrs})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	want := [][]string{{"flatpak", "info", "org.gimp.GIMP"}, {"flatpak", "uninstall", "-y", "org.gimp.GIMP"}}
+	checkChanged(t, result)
+	if result.Status != "deleted" || !reflect.DeepEqual(recorder.commands, want) {
+		t.Errorf("Expected flatpak removal, got %s %v", result.Status, recorder.commands)
+	}
+
+	// channel and remote only apply to their own package manager
+	for _, attrs := range []map[string]interface{}{
+		{"name": "nginx", "channel": "stable"},
+		{"name": "lxd", "provider": "snap", "remote": "flathub"},
+		{"name": "lxd", "provider": "snap", "channel": ""},
+	} {
+		if err := provider.Validate(ctx, attrs); err == nil {
+			t.Errorf("Expected error for attributes %v", attrs)
+		}
+	}
+}
+
+func TestPackageProvider_AptHold(t *testing.T) {
+	tests := []struct {
+		name         string
+		hold         bool
+		held         string
+		wantStatus   string
+		wantCommands [][]string
+	}{
+		{"hold", true, "", "updated", [][]string{
+			{"dpkg", "-s", "nginx"},
+			{"dpkg", "-s", "curl"},
+			{"apt-mark", "showhold"},
+			{"apt-mark", "hold", "nginx", "curl"},
+		}},
+		{"partially held", true, "nginx\n", "updated", [][]string{
+			{"dpkg", "-s", "nginx"},
+			{"dpkg", "-s", "curl"},
+			{"apt-mark", "showhold"},
+			{"apt-mark", "hold", "curl"},
+		}},
+		{"unhold", false, "curl\nnginx\n", "updated", [][]string{
+			{"dpkg", "-s", "nginx"},
+			{"dpkg", "-s", "curl"},
+			{"apt-mark", "showhold"},
+			{"apt-mark", "unhold", "nginx", "curl"},
+		}},
+		{"already held", true, "curl\nnginx\n", "unchanged", [][]string{
+			{"dpkg", "-s", "nginx"},
+			{"dpkg", "-s", "curl"},
+			{"apt-mark", "showhold"},
+		}},
+	}
+
+	for _, tt := range tests {
+		provider, recorder := newTestPackageProvider("apt")
+		recorder.output["apt-mark showhold"] = tt.held
+		attrs := map[string]interface{}{"names": []string{"nginx", "curl"}, "hold": tt.hold}
+
+		plan, err := provider.Plan(context.Background(), nil, attrs)
+		if err != nil {
+			t.Fatalf("%s: Plan failed: %v", tt.name, err)
+		}
+		wantPlan := "unchanged"
+		if tt.wantStatus != "unchanged" {
+			wantPlan = "planned"
+		}
+		if plan.Status != wantPlan {
+			t.Errorf("%s: expected plan status %s, got %s", tt.name, wantPlan, plan.Status)
+		}
+
+		recorder.commands = nil
+		result, err := provider.Apply(context.Background(), plan)
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, result.Status)
+		}
+		if !reflect.DeepEqual(recorder.commands, tt.wantCommands) {
+			t.Errorf("%s: expected commands %v, got %v", tt.name, tt.wantCommands, recorder.commands)
+		}
+	}
+}
+
+func TestPackageProvider_Plan_VersionlockUnavailable(t *testing.T) {
+	provider, recorder := newTestPackageProvider("dnf")
+	recorder.fail["dnf versionlock list"] = errors.New("exit status 2")
+	recorder.output["dnf versionlock list"] = "No such command: versionlock."
+
+	// Releasing packages needs nothing from the plugin
+	plan, err := provider.Plan(context.Background(), nil, map[string]interface{}{"name": "nginx", "hold": false})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "unchanged" {
+		t.Errorf("Expected unchanged status, got %s (%s)", plan.Status, plan.Details)
+	}
+
+	// Holding them does
+	_, err = provider.Plan(context.Background(), nil, map[string]interface{}{"name": "nginx", "hold": true})
+	if err == nil || !strings.Contains(err.Error(), "install python3-dnf-plugin-versionlock") {
+		t.Errorf("Expected an error naming the versionlock plugin, got %v", err)
+	}
+}
+
+func TestPackageProvider_Plan_HoldDrift(t *testing.T) {
+	versionlocks := `Last metadata expiration check: 0:12:04 ago on Mon 06 May 2024 10:00:00 AM UTC.
+nginx-1:1.20.1-10.fc34.*
+0:openssl-libs-3.0.7-2.fc38.*
+`
+
+	tests := []struct {
+		name        string
+		names       []string
+		hold        bool
+		wantStatus  string
+		wantDetails string
+		wantDiff    map[string]AttributeDiff
+	}{
+		{"held as desired", []string{"nginx", "openssl-libs"}, true, "unchanged", "", nil},
+		{"not held", []string{"nginx", "curl"}, true, "planned", "hold curl",
+			map[string]AttributeDiff{"curl hold": {Old: "false", New: "true"}}},
+		{"released as desired", []string{"curl"}, false, "unchanged", "", nil},
+		{"still held", []string{"nginx", "openssl-libs", "curl"}, false, "planned", "unhold nginx, openssl-libs",
+			map[string]AttributeDiff{
+				"nginx hold":        {Old: "true", New: "false"},
+				"openssl-libs hold": {Old: "true", New: "false"},
+			}},
+		{"held once installed", []string{"nginx", "git"}, true, "planned", "install git; hold git",
+			map[string]AttributeDiff{"git hold": {Old: "false", New: "true"}}},
+	}
+
+	for _, tt := range tests {
+		provider, recorder := newTestPackageProvider("dnf")
+		recorder.output["dnf versionlock list"] = versionlocks
+		recorder.fail["dnf list installed git"] = errors.New("no matching packages")
+
+		plan, err := provider.Plan(context.Background(), nil, map[string]interface{}{"names": tt.names, "hold": tt.hold})
+		if err != nil {
+			t.Fatalf("%s: Plan failed: %v", tt.name, err)
+		}
+		if plan.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, plan.Status)
+		}
+		if plan.Details != tt.wantDetails {
+			t.Errorf("%s: expected details %q, got %q", tt.name, tt.wantDetails, plan.Details)
+		}
+		if !reflect.DeepEqual(plan.Diff, tt.wantDiff) {
+			t.Errorf("%s: expected diff %v, got %v", tt.name, tt.wantDiff, plan.Diff)
+		}
+		if wantChanges := tt.wantDiff != nil; wantChanges != containsString(plan.Changes, "hold") {
+			t.Errorf("%s: expected hold in changes to be %v, got %v", tt.name, wantChanges, plan.Changes)
+		}
+	}
+}
+
+func TestVersionlockName(t *testing.T) {
+	tests := map[string]string{
+		"nginx-1:1.20.1-10.fc34.*":             "nginx",
+		"0:python3-libs-3.9.16-1.el9.*":        "python3-libs",
+		"Last metadata expiration check: 0:01": "",
+		"":                                     "",
+	}
+
+	for entry, want := range tests {
+		if got := versionlockName(entry); got != want {
+			t.Errorf("versionlockName(%q) = %q, want %q", entry, got, want)
+		}
+	}
+}
+
+func TestSetPacmanIgnored(t *testing.T) {
+	conf := "[options]\nHoldPkg = pacman glibc\n#IgnorePkg =\n\n[core]\nInclude = /etc/pacman.d/mirrorlist"
+
+	added := setPacmanIgnored(conf, []string{"linux", "nginx"})
+	if !reflect.DeepEqual(pacmanIgnored(added), []string{"linux", "nginx"}) {
+		t.Errorf("Expected IgnorePkg to be added, got:\n%s", added)
+	}
+	if !strings.HasPrefix(added, "[options]\nIgnorePkg = linux nginx\n") {
+		t.Errorf("Expected IgnorePkg under [options], got:\n%s", added)
+	}
+
+	replaced := setPacmanIgnored(added, []string{"linux"})
+	if !reflect.DeepEqual(pacmanIgnored(replaced), []string{"linux"}) {
+		t.Errorf("Expected IgnorePkg to be replaced, got:\n%s", replaced)
+	}
+
+	if removed := setPacmanIgnored(replaced, nil); removed != conf {
+		t.Errorf("Expected the original configuration once nothing is ignored, got:\n%s", removed)
+	}
+}
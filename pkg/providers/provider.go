@@ -2,11 +2,22 @@ package providers
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // ResourceState represents the state of a resource
@@ -14,10 +25,31 @@ type ResourceState struct {
 	Type       string
 	Name       string
 	Attributes map[string]interface{}
-	Status     string // "created", "updated", "deleted", "unchanged", "failed"
+	Status     string                   // "created", "updated", "deleted", "unchanged", "failed"
+	Changed    bool                     // Set by Apply when the resource was created, updated or deleted
+	Changes    []string                 // What differs from the current system state
+	Output     string                   // Combined output of any command run for the resource
+	Details    string                   // Human-readable summary of the change, such as a version upgrade
+	Diff       map[string]AttributeDiff // Current and desired values of changed attributes
+	Outputs    map[string]string        // Values computed on apply that later resources can refer to
 	Error      error
 }
 
+// AttributeDiff is the current and desired value of a changed attribute. Both
+// are empty for values that are too large to show, such as file content.
+type AttributeDiff struct {
+	Old string
+	New string
+}
+
+// addDiff records the current and desired value of a changed attribute
+func (s *ResourceState) addDiff(attribute, old, desired string) {
+	if s.Diff == nil {
+		s.Diff = make(map[string]AttributeDiff)
+	}
+	s.Diff[attribute] = AttributeDiff{Old: old, New: desired}
+}
+
 // ResourceProvider defines the interface for all resource providers
 type ResourceProvider interface {
 	// Validate checks if the resource attributes are valid
@@ -30,6 +62,103 @@ type ResourceProvider interface {
 	Apply(ctx context.Context, state *ResourceState) (*ResourceState, error)
 }
 
+// Reader is implemented by providers that can read the live state of a
+// resource from the system. The engine passes the result of Read to Plan as
+// the current state, in place of the attributes recorded in the state file.
+type Reader interface {
+	// Read returns the live attributes of the resource described by attributes
+	Read(ctx context.Context, attributes map[string]interface{}) (map[string]interface{}, error)
+}
+
+type priorOutputsKey struct{}
+
+// WithPriorOutputs returns a copy of ctx carrying the outputs recorded for a
+// resource by its last apply. The engine passes them to Plan and Apply so a
+// provider can reuse values it computed then, such as a content hash.
+func WithPriorOutputs(ctx context.Context, outputs map[string]string) context.Context {
+	return context.WithValue(ctx, priorOutputsKey{}, outputs)
+}
+
+// priorOutputs returns the outputs carried by ctx, or nil if there are none
+func priorOutputs(ctx context.Context) map[string]string {
+	outputs, _ := ctx.Value(priorOutputsKey{}).(map[string]string)
+	return outputs
+}
+
+// Intent is the outcome the engine asks a provider to drive a resource toward
+type Intent string
+
+const (
+	// IntentPresent converges the resource to its configured attributes
+	IntentPresent Intent = "present"
+
+	// IntentAbsent removes the resource from the system
+	IntentAbsent Intent = "absent"
+)
+
+// IntentTranslator is implemented by providers whose resources can be driven
+// toward an intent other than their configuration, such as removal. The
+// engine uses it to destroy resources of every type the same way, leaving
+// each provider to say how removal is written in its own attributes.
+type IntentTranslator interface {
+	// DesiredAttributes returns a copy of attributes that expresses intent,
+	// or false if the resource can't be driven toward it
+	DesiredAttributes(intent Intent, attributes map[string]interface{}) (map[string]interface{}, bool)
+}
+
+// intentAttributes returns a copy of attributes for intent, applying the
+// removal overrides for IntentAbsent
+func intentAttributes(intent Intent, attributes, removal map[string]interface{}) (map[string]interface{}, bool) {
+	var overrides map[string]interface{}
+	switch intent {
+	case IntentPresent:
+	case IntentAbsent:
+		overrides = removal
+	default:
+		return nil, false
+	}
+
+	result := make(map[string]interface{}, len(attributes)+len(overrides))
+	for key, value := range attributes {
+		result[key] = value
+	}
+	for key, value := range overrides {
+		result[key] = value
+	}
+	return result, true
+}
+
+// DryRunner is implemented by providers whose Apply honors IsDryRun: given a
+// dry-run context, it reports the status it would return and logs what it
+// would do, without running commands or writing files. The engine only calls
+// Apply in a dry run for providers that implement it.
+type DryRunner interface {
+	// SupportsDryRun reports whether Apply can be called in a dry run
+	SupportsDryRun() bool
+}
+
+type dryRunKey struct{}
+
+// WithDryRun returns a copy of ctx that asks Apply to report what it would do
+// instead of doing it
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether ctx asks for a dry run
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// Notifiable is implemented by providers whose resources can respond to
+// notifications from other resources that changed, such as a service
+// restarting after its configuration file is updated
+type Notifiable interface {
+	// Notify runs the resource's notification handler after it was applied
+	Notify(ctx context.Context, state *ResourceState) (*ResourceState, error)
+}
+
 // ProviderRegistry maintains a mapping of resource types to their providers
 type ProviderRegistry struct {
 	providers map[string]ResourceProvider
@@ -56,31 +185,124 @@ func (r *ProviderRegistry) Get(resourceType string) (ResourceProvider, error) {
 	return provider, nil
 }
 
-// PlatformChecker provides OS detection functionality
-type PlatformChecker struct{}
+// PlatformChecker provides OS detection functionality. The detected package
+// manager and init system are cached, so detection runs once per checker.
+type PlatformChecker struct {
+	mu             sync.Mutex
+	packageManager string // Detected package manager, or "" before detection
+	initSystem     string // Detected init system, or "" before detection
+
+	// lookPath and stat replace exec.LookPath and os.Stat in tests
+	lookPath func(file string) (string, error)
+	stat     func(name string) (os.FileInfo, error)
+}
 
-// IsSupported checks if the current platform is in the list of supported platforms
+// ResetCache forgets the detected package manager and init system, so the
+// next call detects them again
+func (p *PlatformChecker) ResetCache() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.packageManager = ""
+	p.initSystem = ""
+}
+
+// statPath calls the stat hook, defaulting to os.Stat
+func (p *PlatformChecker) statPath(name string) (os.FileInfo, error) {
+	if p.stat != nil {
+		return p.stat(name)
+	}
+	return os.Stat(name)
+}
+
+// platformFamilies maps platform family names to the operating systems in them
+var platformFamilies = map[string][]string{
+	"unix":  {"linux", "darwin"},
+	"posix": {"linux", "darwin"},
+}
+
+// IsSupported checks if the current platform is in the list of supported
+// platforms. Entries are operating systems or families such as posix, and an
+// entry prefixed with "!" excludes the platform. Any matching exclusion wins
+// regardless of order; a list of only exclusions allows every other platform.
 func (p *PlatformChecker) IsSupported(platforms []string) bool {
-	currentOS := runtime.GOOS
+	return platformSupported(platforms, runtime.GOOS)
+}
+
+// platformSupported reports whether the platforms list allows goos
+func platformSupported(platforms []string, goos string) bool {
+	included, hasInclusions := false, false
 
 	for _, platform := range platforms {
-		switch platform {
-		case "linux", "darwin", "windows":
-			if currentOS == platform {
-				return true
+		if name, negated := strings.CutPrefix(platform, "!"); negated {
+			if platformMatches(name, goos) {
+				return false
 			}
-		case "unix":
-			if currentOS == "linux" || currentOS == "darwin" {
+			continue
+		}
+
+		hasInclusions = true
+		if platformMatches(platform, goos) {
+			included = true
+		}
+	}
+
+	if !hasInclusions {
+		return len(platforms) > 0
+	}
+	return included
+}
+
+// platformMatches reports whether goos is the named platform or in the named family
+func platformMatches(name, goos string) bool {
+	if family, ok := platformFamilies[name]; ok {
+		for _, member := range family {
+			if member == goos {
 				return true
 			}
 		}
+		return false
+	}
+	return name == goos
+}
+
+// archAliases maps common architecture names to their Go equivalents
+var archAliases = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"i386":    "386",
+	"i686":    "386",
+}
+
+// IsArchSupported checks if the current architecture is in the list of
+// supported architectures. Names may be Go's (amd64, arm64) or the common
+// uname forms (x86_64, aarch64).
+func (p *PlatformChecker) IsArchSupported(arches []string) bool {
+	for _, arch := range arches {
+		if alias, ok := archAliases[arch]; ok {
+			arch = alias
+		}
+		if arch == runtime.GOARCH {
+			return true
+		}
 	}
 
 	return false
 }
 
-// DetectInitSystem detects the init system used on Linux
+// DetectInitSystem detects the init system used on Linux. The result is
+// cached after the first call.
 func (p *PlatformChecker) DetectInitSystem() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.initSystem == "" {
+		p.initSystem = p.detectInitSystem()
+	}
+	return p.initSystem
+}
+
+// detectInitSystem inspects the system for its init system
+func (p *PlatformChecker) detectInitSystem() string {
 	// Only applicable on Linux
 	if runtime.GOOS != "linux" {
 		if runtime.GOOS == "darwin" {
@@ -93,12 +315,12 @@ func (p *PlatformChecker) DetectInitSystem() string {
 	}
 
 	// Check for systemd
-	if _, err := os.Stat("/run/systemd/system"); err == nil {
+	if _, err := p.statPath("/run/systemd/system"); err == nil {
 		return "systemd"
 	}
 
 	// Check for upstart
-	if _, err := os.Stat("/sbin/initctl"); err == nil {
+	if _, err := p.statPath("/sbin/initctl"); err == nil {
 		cmd := exec.Command("/sbin/initctl", "--version")
 		output, err := cmd.CombinedOutput()
 		if err == nil && strings.Contains(string(output), "upstart") {
@@ -107,7 +329,7 @@ func (p *PlatformChecker) DetectInitSystem() string {
 	}
 
 	// Check for SysV init (fallback)
-	if _, err := os.Stat("/etc/init.d"); err == nil {
+	if _, err := p.statPath("/etc/init.d"); err == nil {
 		return "sysvinit"
 	}
 
@@ -116,12 +338,28 @@ func (p *PlatformChecker) DetectInitSystem() string {
 
 // IsCommandAvailable checks if a command is available on the system
 func (p *PlatformChecker) IsCommandAvailable(command string) bool {
-	_, err := exec.LookPath(command)
+	lookPath := exec.LookPath
+	if p.lookPath != nil {
+		lookPath = p.lookPath
+	}
+	_, err := lookPath(command)
 	return err == nil
 }
 
-// GetPackageManager detects the package manager on the system
+// GetPackageManager detects the package manager on the system. The result is
+// cached after the first call.
 func (p *PlatformChecker) GetPackageManager() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.packageManager == "" {
+		p.packageManager = p.detectPackageManager()
+	}
+	return p.packageManager
+}
+
+// detectPackageManager looks for the package manager commands of the platform
+func (p *PlatformChecker) detectPackageManager() string {
 	switch runtime.GOOS {
 	case "darwin":
 		// Check for Homebrew first
@@ -169,9 +407,235 @@ func (p *PlatformChecker) GetPackageManager() string {
 		if p.IsCommandAvailable("apk") {
 			return "apk"
 		}
+		// Immutable distributions may only have snap or flatpak
+		if p.IsCommandAvailable("snap") {
+			return "snap"
+		}
+		if p.IsCommandAvailable("flatpak") {
+			return "flatpak"
+		}
 		return "unknown"
 
 	default:
 		return "unknown"
 	}
 }
+
+// CommandRunner runs an external command and returns its combined output.
+// Providers hold one so tests can substitute a fake.
+type CommandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// runCommand is the default CommandRunner backed by os/exec
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// runCommands runs each command in order, stopping at the first failure
+func runCommands(ctx context.Context, run CommandRunner, commands [][]string) error {
+	for _, command := range commands {
+		output, err := run(ctx, command[0], command[1:]...)
+		if err != nil {
+			return fmt.Errorf("error running %s: %v: %s", command[0], err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// newHasher returns a hash for a checksum algorithm: md5, sha1, sha256 or sha512
+func newHasher(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+}
+
+// fileChecksum returns the hex digest of the file at path using the algorithm
+func fileChecksum(path, algorithm string) (string, error) {
+	hasher, err := newHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// presenceState returns the desired "state" attribute, defaulting to "present"
+func presenceState(attributes map[string]interface{}) string {
+	if state, ok := attributes["state"].(string); ok {
+		return state
+	}
+	return "present"
+}
+
+// intAttribute reads an integer attribute, accepting parsed numbers as well
+// as numeric strings
+func intAttribute(attributes map[string]interface{}, key string) (int64, bool, error) {
+	value, ok := attributes[key]
+	if !ok {
+		return 0, false, nil
+	}
+
+	switch v := value.(type) {
+	case int:
+		return int64(v), true, nil
+	case int64:
+		return v, true, nil
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, true, fmt.Errorf("'%s' must be a whole number", key)
+		}
+		return int64(v), true, nil
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, true, fmt.Errorf("'%s' must be a number", key)
+		}
+		return i, true, nil
+	default:
+		return 0, true, fmt.Errorf("'%s' must be a number", key)
+	}
+}
+
+// durationAttribute reads a duration attribute given as a string such as "30s"
+// or as a whole number of seconds
+func durationAttribute(attributes map[string]interface{}, key string) (time.Duration, bool, error) {
+	value, ok := attributes[key]
+	if !ok {
+		return 0, false, nil
+	}
+
+	if str, ok := value.(string); ok {
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return 0, true, fmt.Errorf("'%s' must be a duration such as \"30s\"", key)
+		}
+		if d < 0 {
+			return 0, true, fmt.Errorf("'%s' must not be negative", key)
+		}
+		return d, true, nil
+	}
+
+	seconds, _, err := intAttribute(attributes, key)
+	if err != nil {
+		return 0, true, fmt.Errorf("'%s' must be a duration such as \"30s\"", key)
+	}
+	if seconds < 0 {
+		return 0, true, fmt.Errorf("'%s' must not be negative", key)
+	}
+	return time.Duration(seconds) * time.Second, true, nil
+}
+
+// modeAttribute reads a permission mode given as an octal string such as
+// "0644" or as a number whose digits are read as octal, so mode = 0644 and
+// mode = "0644" mean the same
+func modeAttribute(attributes map[string]interface{}, key string) (os.FileMode, bool, error) {
+	value, ok := attributes[key]
+	if !ok {
+		return 0, false, nil
+	}
+
+	var digits string
+	switch v := value.(type) {
+	case string:
+		digits = v
+	case int, int64:
+		digits = fmt.Sprint(v)
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, true, fmt.Errorf("'%s' must be an octal mode such as \"0644\", got %v", key, v)
+		}
+		digits = strconv.FormatInt(int64(v), 10)
+	default:
+		return 0, true, fmt.Errorf("'%s' must be a string or a number", key)
+	}
+
+	mode, err := strconv.ParseUint(digits, 8, 32)
+	if err != nil {
+		return 0, true, fmt.Errorf("'%s' must be an octal mode such as \"0644\", got %v", key, value)
+	}
+	if mode > 07777 {
+		return 0, true, fmt.Errorf("'%s' must be at most 7777, got %v", key, value)
+	}
+	return os.FileMode(mode), true, nil
+}
+
+// stringSliceAttribute reads a list of strings attribute
+func stringSliceAttribute(attributes map[string]interface{}, key string) ([]string, bool, error) {
+	value, ok := attributes[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	switch v := value.(type) {
+	case []string:
+		return v, true, nil
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, true, fmt.Errorf("'%s' must be a list of strings", key)
+			}
+			result = append(result, str)
+		}
+		return result, true, nil
+	default:
+		return nil, true, fmt.Errorf("'%s' must be a list of strings", key)
+	}
+}
+
+// sameStringSet reports whether a and b contain the same strings, ignoring order
+func sameStringSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string(nil), a...)
+	sortedB := append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffStringSets returns the items to add to current and remove from it so it matches desired
+func diffStringSets(current, desired []string) (add, remove []string) {
+	have := make(map[string]bool, len(current))
+	for _, item := range current {
+		have[item] = true
+	}
+	want := make(map[string]bool, len(desired))
+	for _, item := range desired {
+		want[item] = true
+		if !have[item] {
+			add = append(add, item)
+		}
+	}
+	for _, item := range current {
+		if !want[item] {
+			remove = append(remove, item)
+		}
+	}
+	return add, remove
+}
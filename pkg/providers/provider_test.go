@@ -3,7 +3,13 @@ package providers
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -28,6 +34,19 @@ func (m *MockProvider) Apply(ctx context.Context, state *ResourceState) (*Resour
 	return m.ApplyResponse, m.ApplyError
 }
 
+// checkChanged fails the test unless a result is marked changed exactly when
+// its status is created, updated or deleted
+func checkChanged(t *testing.T, result *ResourceState) {
+	t.Helper()
+	if result == nil {
+		return
+	}
+	want := result.Status == "created" || result.Status == "updated" || result.Status == "deleted"
+	if result.Changed != want {
+		t.Errorf("Expected Changed to be %v for status %s", want, result.Status)
+	}
+}
+
 func TestProviderRegistry_Register(t *testing.T) {
 	registry := NewProviderRegistry()
 	mockProvider := &MockProvider{}
@@ -83,6 +102,111 @@ func TestPlatformChecker_IsSupported(t *testing.T) {
 	}
 }
 
+func TestPlatformSupported(t *testing.T) {
+	tests := []struct {
+		platforms []string
+		goos      string
+		want      bool
+	}{
+		{[]string{"linux"}, "linux", true},
+		{[]string{"linux", "darwin"}, "windows", false},
+		{[]string{"posix"}, "darwin", true},
+		{[]string{"posix"}, "windows", false},
+		{[]string{"unix"}, "linux", true},
+		{[]string{"!windows"}, "linux", true},
+		{[]string{"!windows"}, "windows", false},
+		{[]string{"!windows", "!darwin"}, "darwin", false},
+		{[]string{"!posix"}, "windows", true},
+		{[]string{"!posix"}, "linux", false},
+		// Exclusions win over inclusions, in any order
+		{[]string{"posix", "!darwin"}, "linux", true},
+		{[]string{"posix", "!darwin"}, "darwin", false},
+		{[]string{"!darwin", "posix"}, "darwin", false},
+		{[]string{"posix", "!darwin"}, "windows", false},
+		{[]string{}, "linux", false},
+	}
+
+	for _, tt := range tests {
+		if got := platformSupported(tt.platforms, tt.goos); got != tt.want {
+			t.Errorf("platformSupported(%v, %s) = %v, expected %v", tt.platforms, tt.goos, got, tt.want)
+		}
+	}
+}
+
+func TestPlatformChecker_CachesDetection(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		t.Skip("package managers are only detected on Linux, macOS and Windows")
+	}
+
+	var mu sync.Mutex
+	lookups, stats := 0, 0
+	checker := &PlatformChecker{
+		lookPath: func(file string) (string, error) {
+			mu.Lock()
+			lookups++
+			mu.Unlock()
+			return "", os.ErrNotExist
+		},
+		stat: func(name string) (os.FileInfo, error) {
+			mu.Lock()
+			stats++
+			mu.Unlock()
+			return nil, os.ErrNotExist
+		},
+	}
+
+	// Parallel applies share providers, so detection must be safe to race
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checker.GetPackageManager()
+			checker.DetectInitSystem()
+		}()
+	}
+	wg.Wait()
+
+	firstLookups, firstStats := lookups, stats
+	if firstLookups == 0 {
+		t.Fatal("Expected package manager detection to look for commands")
+	}
+
+	for i := 0; i < 5; i++ {
+		if manager := checker.GetPackageManager(); manager != "unknown" {
+			t.Errorf("Expected unknown package manager, got %s", manager)
+		}
+		checker.DetectInitSystem()
+	}
+	if lookups != firstLookups || stats != firstStats {
+		t.Errorf("Expected detection to run once, got %d lookups and %d stats after %d and %d", lookups, stats, firstLookups, firstStats)
+	}
+
+	// Resetting the cache detects again
+	checker.ResetCache()
+	checker.GetPackageManager()
+	if lookups != 2*firstLookups {
+		t.Errorf("Expected detection to run again after ResetCache, got %d lookups", lookups)
+	}
+}
+
+func TestPlatformChecker_IsArchSupported(t *testing.T) {
+	checker := &PlatformChecker{}
+
+	if !checker.IsArchSupported([]string{runtime.GOARCH}) {
+		t.Errorf("Expected current arch %s to be supported", runtime.GOARCH)
+	}
+	if checker.IsArchSupported([]string{"invalid-arch"}) {
+		t.Error("Expected 'invalid-arch' to not be supported")
+	}
+
+	for alias, arch := range archAliases {
+		if checker.IsArchSupported([]string{alias}) != (arch == runtime.GOARCH) {
+			t.Errorf("Expected alias %s to match only on %s", alias, arch)
+		}
+	}
+}
+
 func TestPlatformChecker_DetectInitSystem(t *testing.T) {
 	checker := &PlatformChecker{}
 	initSystem := checker.DetectInitSystem()
@@ -139,4 +263,74 @@ func TestValidationErrorFormatting(t *testing.T) {
 	if err.Error() != "validation error: test error message" {
 		t.Errorf("Unexpected error message: %s", err.Error())
 	}
-}
\ No newline at end of file
+}
+
+// commandRecorder is a fake CommandRunner that records each invocation
+type commandRecorder struct {
+	commands [][]string
+	output   map[string]string // Output keyed by the full command line
+	fail     map[string]error  // Errors keyed by the full command line or the command name
+}
+
+func (r *commandRecorder) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	command := append([]string{name}, args...)
+	r.commands = append(r.commands, command)
+	key := strings.Join(command, " ")
+	if err, ok := r.fail[key]; ok {
+		return []byte(r.output[key]), err
+	}
+	if err, ok := r.fail[name]; ok {
+		return []byte(r.output[key]), err
+	}
+	return []byte(r.output[key]), nil
+}
+
+func TestFileChecksum(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "abc")
+	ioutil.WriteFile(path, []byte("abc"), 0644)
+
+	expected := map[string]string{
+		"md5":    "900150983cd24fb0d6963f7d28e17f72",
+		"sha256": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+	}
+	for algorithm, want := range expected {
+		got, err := fileChecksum(path, algorithm)
+		if err != nil {
+			t.Fatalf("%s: fileChecksum returned error: %v", algorithm, err)
+		}
+		if got != want {
+			t.Errorf("%s: expected %s, got %s", algorithm, want, got)
+		}
+	}
+
+	if _, err := fileChecksum(path, "crc32"); err == nil {
+		t.Error("Expected error for unsupported algorithm, got nil")
+	}
+}
+
+func TestIntentAttributes(t *testing.T) {
+	attributes := map[string]interface{}{"name": "nginx", "state": "running", "enabled": true}
+
+	removed, ok := NewServiceProvider().DesiredAttributes(IntentAbsent, attributes)
+	if !ok || removed["state"] != "stopped" || removed["enabled"] != false {
+		t.Errorf("Expected the service to be stopped and disabled, got %v", removed)
+	}
+	if attributes["state"] != "running" {
+		t.Error("Expected the configured attributes to be left unmodified")
+	}
+
+	present, ok := NewServiceProvider().DesiredAttributes(IntentPresent, attributes)
+	if !ok || !reflect.DeepEqual(present, attributes) {
+		t.Errorf("Expected IntentPresent to keep the configured attributes, got %v", present)
+	}
+
+	if _, ok := NewPackageProvider().DesiredAttributes(Intent("paused"), attributes); ok {
+		t.Error("Expected an unknown intent to be rejected")
+	}
+}
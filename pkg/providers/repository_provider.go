@@ -0,0 +1,472 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// repositoryNamePattern matches repository names, which are used in file names
+var repositoryNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// armoredKeyHeader starts an ASCII-armored public key
+const armoredKeyHeader = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+
+// RepositoryProvider adds apt and dnf/yum package repositories, along with
+// the keys their packages are signed with
+type RepositoryProvider struct {
+	runCommand     CommandRunner
+	packageManager func() string
+	fetchKey       func(ctx context.Context, url string) ([]byte, error)
+	aptSourcesDir  string
+	aptKeyringDir  string
+	yumReposDir    string
+	rpmKeyDir      string
+}
+
+// NewRepositoryProvider creates a new repository provider
+func NewRepositoryProvider() *RepositoryProvider {
+	platform := &PlatformChecker{}
+	return &RepositoryProvider{
+		runCommand:     runCommand,
+		packageManager: platform.GetPackageManager,
+		fetchKey:       fetchKey,
+		aptSourcesDir:  "/etc/apt/sources.list.d",
+		aptKeyringDir:  "/etc/apt/keyrings",
+		yumReposDir:    "/etc/yum.repos.d",
+		rpmKeyDir:      "/etc/pki/rpm-gpg",
+	}
+}
+
+// repositorySchema declares the repository attributes with a fixed type or
+// set of values. Which attributes each package manager needs is checked by
+// Validate.
+var repositorySchema = Schema{
+	{Name: "name", Type: TypeString, Required: true},
+	{Name: "uri", Type: TypeString},
+	{Name: "baseurl", Type: TypeString},
+	{Name: "key", Type: TypeString},
+	{Name: "suite", Type: TypeString},
+	{Name: "components", Type: TypeList},
+	{Name: "provider", Type: TypeString, OneOf: []string{"auto", "apt", "dnf", "yum"}},
+	{Name: "state", Type: TypeString, OneOf: []string{"present", "absent"}},
+}
+
+// Validate validates repository resource attributes
+func (p *RepositoryProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("repository provider is only supported on Linux")
+	}
+
+	if err := repositorySchema.Validate("repository", attributes); err != nil {
+		return err
+	}
+
+	name := attributes["name"].(string)
+	if !repositoryNamePattern.MatchString(name) {
+		return fmt.Errorf("repository 'name' may only contain letters, digits, '.', '_' and '-', got %q", name)
+	}
+
+	pkgManager := p.getPackageManager(attributes)
+	if pkgManager != "apt" && pkgManager != "dnf" && pkgManager != "yum" {
+		return fmt.Errorf("repository resource requires apt, dnf or yum, found %s", pkgManager)
+	}
+
+	if attributes["state"] == "absent" {
+		return nil
+	}
+
+	// Either name can be used for the address of the repository
+	_, hasURI := attributes["uri"]
+	_, hasBaseURL := attributes["baseurl"]
+	if hasURI == hasBaseURL {
+		return fmt.Errorf("repository resource requires exactly one of 'uri' or 'baseurl' attribute")
+	}
+
+	if key, ok := attributes["key"].(string); ok {
+		// A key fetched over plain http could be replaced in transit
+		if strings.HasPrefix(key, "http://") {
+			return fmt.Errorf("repository 'key' URL must use https, got %s", key)
+		}
+		if !isKeyURL(key) && !strings.Contains(key, armoredKeyHeader) {
+			return fmt.Errorf("repository 'key' must be an https URL or an ASCII-armored public key")
+		}
+	}
+
+	components, hasComponents, err := stringSliceAttribute(attributes, "components")
+	if err != nil {
+		return fmt.Errorf("repository %v", err)
+	}
+
+	if pkgManager != "apt" {
+		for _, key := range []string{"suite", "components"} {
+			if _, ok := attributes[key]; ok {
+				return fmt.Errorf("repository '%s' only applies to apt repositories", key)
+			}
+		}
+		return nil
+	}
+
+	// A flat repository's suite is a path ending in "/", and has no components
+	suite, ok := attributes["suite"].(string)
+	if !ok || suite == "" {
+		return fmt.Errorf("repository resource requires 'suite' attribute for apt repositories")
+	}
+	if strings.HasSuffix(suite, "/") {
+		if hasComponents {
+			return fmt.Errorf("repository 'components' can't be used with a flat repository suite ending in '/'")
+		}
+	} else if len(components) == 0 {
+		return fmt.Errorf("repository resource requires 'components' attribute for apt repositories")
+	}
+
+	return nil
+}
+
+// getPackageManager returns the package manager for a resource: the one named
+// by 'provider', or the detected one
+func (p *RepositoryProvider) getPackageManager(attributes map[string]interface{}) string {
+	if provider, ok := attributes["provider"].(string); ok && provider != "auto" {
+		return provider
+	}
+	return p.packageManager()
+}
+
+// isKeyURL reports whether a key attribute is the URL of a key rather than the key itself
+func isKeyURL(key string) bool {
+	return strings.HasPrefix(key, "https://")
+}
+
+// fetchKey downloads a key
+func fetchKey(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key request for %s: %v", url, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download key %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to download key %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// repositoryFile is a file a repository is made of
+type repositoryFile struct {
+	kind    string // "source" or "key", as reported in Changes
+	path    string
+	content []byte
+}
+
+// desiredFiles returns the key file of a repository, when it has a key, and
+// its source file, which is always last. A key given as a URL is fetched.
+func (p *RepositoryProvider) desiredFiles(ctx context.Context, attributes map[string]interface{}) ([]repositoryFile, error) {
+	name := attributes["name"].(string)
+	pkgManager := p.getPackageManager(attributes)
+
+	var key []byte
+	if keyAttr, ok := attributes["key"].(string); ok {
+		if isKeyURL(keyAttr) {
+			fetched, err := p.fetchKey(ctx, keyAttr)
+			if err != nil {
+				return nil, err
+			}
+			key = fetched
+		} else {
+			key = []byte(keyAttr)
+		}
+	}
+
+	url, ok := attributes["uri"].(string)
+	if !ok {
+		url = attributes["baseurl"].(string)
+	}
+
+	var files []repositoryFile
+	keyPath := ""
+	if key != nil {
+		keyPath = p.keyPath(pkgManager, name, key)
+		files = append(files, repositoryFile{kind: "key", path: keyPath, content: key})
+	}
+
+	if pkgManager == "apt" {
+		suite := attributes["suite"].(string)
+		components, _, _ := stringSliceAttribute(attributes, "components")
+		source := renderAptSource(url, suite, components, keyPath)
+		files = append(files, repositoryFile{kind: "source", path: filepath.Join(p.aptSourcesDir, name+".list"), content: []byte(source)})
+	} else {
+		repo := renderYumRepo(name, url, keyPath)
+		files = append(files, repositoryFile{kind: "source", path: filepath.Join(p.yumReposDir, name+".repo"), content: []byte(repo)})
+	}
+
+	return files, nil
+}
+
+// keyPath returns where the key of a repository is kept. apt reads armored
+// keys from .asc files and binary keys from .gpg files.
+func (p *RepositoryProvider) keyPath(pkgManager, name string, key []byte) string {
+	if pkgManager != "apt" {
+		return filepath.Join(p.rpmKeyDir, "RPM-GPG-KEY-"+name)
+	}
+	if bytes.Contains(key, []byte(armoredKeyHeader)) {
+		return filepath.Join(p.aptKeyringDir, name+".asc")
+	}
+	return filepath.Join(p.aptKeyringDir, name+".gpg")
+}
+
+// managedPaths returns every file a repository may have written, so that
+// they can be removed without fetching its key
+func (p *RepositoryProvider) managedPaths(attributes map[string]interface{}) []string {
+	name := attributes["name"].(string)
+	if p.getPackageManager(attributes) == "apt" {
+		return []string{
+			filepath.Join(p.aptSourcesDir, name+".list"),
+			filepath.Join(p.aptKeyringDir, name+".asc"),
+			filepath.Join(p.aptKeyringDir, name+".gpg"),
+		}
+	}
+	return []string{
+		filepath.Join(p.yumReposDir, name+".repo"),
+		filepath.Join(p.rpmKeyDir, "RPM-GPG-KEY-"+name),
+	}
+}
+
+// renderAptSource renders a one-line apt source, signed by the key at keyPath if any
+func renderAptSource(uri, suite string, components []string, keyPath string) string {
+	line := "deb "
+	if keyPath != "" {
+		line += "[signed-by=" + keyPath + "] "
+	}
+	line += uri + " " + suite
+	if len(components) > 0 {
+		line += " " + strings.Join(components, " ")
+	}
+	return line + "\n"
+}
+
+// renderYumRepo renders a .repo file. Without a key, gpgcheck is left to the
+// default in dnf.conf or yum.conf.
+func renderYumRepo(name, baseurl, keyPath string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s]\n", name)
+	fmt.Fprintf(&sb, "name=%s\n", name)
+	fmt.Fprintf(&sb, "baseurl=%s\n", baseurl)
+	sb.WriteString("enabled=1\n")
+	if keyPath != "" {
+		sb.WriteString("gpgcheck=1\n")
+		fmt.Fprintf(&sb, "gpgkey=file://%s\n", keyPath)
+	}
+	return sb.String()
+}
+
+// changedFiles returns the files whose content on disk differs, and whether
+// the source file exists
+func changedFiles(files []repositoryFile) ([]repositoryFile, bool, error) {
+	var changed []repositoryFile
+	sourceExists := false
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file.path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, false, err
+		}
+		if file.kind == "source" && err == nil {
+			sourceExists = true
+		}
+		if err != nil || !bytes.Equal(data, file.content) {
+			changed = append(changed, file)
+		}
+	}
+	return changed, sourceExists, nil
+}
+
+// existingPaths returns the paths that exist
+func existingPaths(paths []string) ([]string, error) {
+	var existing []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			existing = append(existing, path)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+// unusedPaths returns the managed paths that are not among files
+func unusedPaths(managed []string, files []repositoryFile) []string {
+	var unused []string
+	for _, path := range managed {
+		used := false
+		for _, file := range files {
+			if file.path == path {
+				used = true
+				break
+			}
+		}
+		if !used {
+			unused = append(unused, path)
+		}
+	}
+	return unused
+}
+
+// Plan determines whether the repository files need to be written or removed
+func (p *RepositoryProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("repository provider is only supported on Linux")
+	}
+
+	name := desired["name"].(string)
+
+	result := &ResourceState{
+		Type:       "repository",
+		Name:       name,
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	if desired["state"] == "absent" {
+		existing, err := existingPaths(p.managedPaths(desired))
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) > 0 {
+			result.Status = "planned"
+			result.Details = "remove " + strings.Join(existing, ", ")
+		}
+		return result, nil
+	}
+
+	files, err := p.desiredFiles(ctx, desired)
+	if err != nil {
+		return nil, err
+	}
+	changed, _, err := changedFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) > 0 {
+		result.Status = "planned"
+		var paths []string
+		for _, file := range changed {
+			result.Changes = append(result.Changes, file.kind)
+			paths = append(paths, file.path)
+		}
+		result.Details = "write " + strings.Join(paths, ", ")
+	}
+
+	return result, nil
+}
+
+// Apply writes the repository source and key files, or removes them
+func (p *RepositoryProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("repository provider is only supported on Linux")
+	}
+
+	name := state.Attributes["name"].(string)
+	pkgManager := p.getPackageManager(state.Attributes)
+
+	result := &ResourceState{
+		Type:       "repository",
+		Name:       name,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	fail := func(err error) (*ResourceState, error) {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	if state.Attributes["state"] == "absent" {
+		existing, err := existingPaths(p.managedPaths(state.Attributes))
+		if err != nil {
+			return fail(err)
+		}
+		for _, path := range existing {
+			if err := os.Remove(path); err != nil {
+				return fail(fmt.Errorf("failed to remove %s: %v", path, err))
+			}
+		}
+		if len(existing) > 0 {
+			result.Status = "deleted"
+			result.Changed = true
+		}
+		return result, nil
+	}
+
+	files, err := p.desiredFiles(ctx, state.Attributes)
+	if err != nil {
+		return fail(err)
+	}
+	changed, sourceExists, err := changedFiles(files)
+	if err != nil {
+		return fail(err)
+	}
+	if len(changed) == 0 {
+		return result, nil
+	}
+
+	for _, file := range changed {
+		if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+			return fail(fmt.Errorf("failed to create directory %s: %v", filepath.Dir(file.path), err))
+		}
+		if err := ioutil.WriteFile(file.path, file.content, 0644); err != nil {
+			return fail(fmt.Errorf("failed to write %s: %v", file.path, err))
+		}
+		result.Changes = append(result.Changes, file.kind)
+
+		// rpm only trusts keys once they are imported
+		if file.kind == "key" && pkgManager != "apt" {
+			if err := runCommands(ctx, p.runCommand, [][]string{{"rpm", "--import", file.path}}); err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	// Remove the files the repository no longer uses, such as the .asc
+	// variant of a key that is now binary
+	unused, err := existingPaths(unusedPaths(p.managedPaths(state.Attributes), files))
+	if err != nil {
+		return fail(err)
+	}
+	for _, path := range unused {
+		if err := os.Remove(path); err != nil {
+			return fail(fmt.Errorf("failed to remove %s: %v", path, err))
+		}
+	}
+
+	// apt only sees the packages of a repository once its index is
+	// downloaded, so refresh just this repository's source
+	if pkgManager == "apt" {
+		source := files[len(files)-1].path
+		command := []string{"apt-get", "update",
+			"-o", "Dir::Etc::sourcelist=" + source,
+			"-o", "Dir::Etc::sourceparts=-",
+			"-o", "APT::Get::List-Cleanup=0"}
+		if err := runCommands(ctx, p.runCommand, [][]string{command}); err != nil {
+			return fail(err)
+		}
+	}
+
+	result.Status = "updated"
+	if !sourceExists {
+		result.Status = "created"
+	}
+	result.Changed = true
+	return result, nil
+}
@@ -0,0 +1,245 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+const testRepositoryKey = "-----BEGIN PGP PUBLIC KEY BLOCK-----\nmQINBGExample\n-----END PGP PUBLIC KEY BLOCK-----\n"
+
+const testBinaryRepositoryKey = "\x99\x02\x0d\x04example"
+
+func newTestRepositoryProvider(t *testing.T, pkgManager string) (*RepositoryProvider, *commandRecorder, string) {
+	if runtime.GOOS != "linux" {
+		t.Skip("repository provider is only supported on Linux")
+	}
+
+	tempDir, err := ioutil.TempDir("", "repository-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	recorder := &commandRecorder{output: map[string]string{}}
+	provider := NewRepositoryProvider()
+	provider.runCommand = recorder.run
+	provider.packageManager = func() string { return pkgManager }
+	provider.fetchKey = func(ctx context.Context, url string) ([]byte, error) {
+		switch url {
+		case "https://example.com/key.asc":
+			return []byte(testRepositoryKey), nil
+		case "https://example.com/key.gpg":
+			return []byte(testBinaryRepositoryKey), nil
+		}
+		return nil, fmt.Errorf("failed to download key %s: 404 Not Found", url)
+	}
+	provider.aptSourcesDir = filepath.Join(tempDir, "sources.list.d")
+	provider.aptKeyringDir = filepath.Join(tempDir, "keyrings")
+	provider.yumReposDir = filepath.Join(tempDir, "yum.repos.d")
+	provider.rpmKeyDir = filepath.Join(tempDir, "rpm-gpg")
+	return provider, recorder, tempDir
+}
+
+func TestRepositoryProvider_Validate(t *testing.T) {
+	provider, _, _ := newTestRepositoryProvider(t, "apt")
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"apt", map[string]interface{}{"name": "docker", "uri": "https://download.docker.com/linux/ubuntu", "suite": "jammy", "components": []string{"stable"}, "key": "https://example.com/key.asc"}, false},
+		{"flat apt", map[string]interface{}{"name": "local", "uri": "file:/srv/repo", "suite": "./"}, false},
+		{"dnf", map[string]interface{}{"name": "docker-ce", "baseurl": "https://download.docker.com/linux/fedora/$releasever/$basearch/stable", "key": testRepositoryKey, "provider": "dnf"}, false},
+		{"absent", map[string]interface{}{"name": "docker", "state": "absent"}, false},
+		{"invalid name", map[string]interface{}{"name": "../docker", "uri": "https://example.com", "suite": "jammy", "components": []string{"main"}}, true},
+		{"missing uri", map[string]interface{}{"name": "docker", "suite": "jammy", "components": []string{"main"}}, true},
+		{"uri and baseurl", map[string]interface{}{"name": "docker", "uri": "https://example.com", "baseurl": "https://example.com", "suite": "jammy", "components": []string{"main"}}, true},
+		{"missing suite", map[string]interface{}{"name": "docker", "uri": "https://example.com", "components": []string{"main"}}, true},
+		{"missing components", map[string]interface{}{"name": "docker", "uri": "https://example.com", "suite": "jammy"}, true},
+		{"flat with components", map[string]interface{}{"name": "local", "uri": "file:/srv/repo", "suite": "./", "components": []string{"main"}}, true},
+		{"http key", map[string]interface{}{"name": "docker", "uri": "https://example.com", "suite": "jammy", "components": []string{"main"}, "key": "http://example.com/key.asc"}, true},
+		{"invalid key", map[string]interface{}{"name": "docker", "uri": "https://example.com", "suite": "jammy", "components": []string{"main"}, "key": "/etc/key.asc"}, true},
+		{"suite for dnf", map[string]interface{}{"name": "docker", "baseurl": "https://example.com", "suite": "jammy", "provider": "dnf"}, true},
+		{"unsupported provider", map[string]interface{}{"name": "docker", "uri": "https://example.com", "provider": "pacman"}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestRepositoryProvider_Apt(t *testing.T) {
+	provider, recorder, tempDir := newTestRepositoryProvider(t, "apt")
+	ctx := context.Background()
+
+	attrs := map[string]interface{}{
+		"name":       "docker",
+		"uri":        "https://download.docker.com/linux/ubuntu",
+		"suite":      "jammy",
+		"components": []string{"stable", "test"},
+		"key":        "https://example.com/key.asc",
+	}
+
+	plan, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" || !reflect.DeepEqual(plan.Changes, []string{"key", "source"}) {
+		t.Errorf("Expected planned key and source, got %s %v", plan.Status, plan.Changes)
+	}
+
+	result, err := provider.Apply(ctx, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "created" {
+		t.Errorf("Expected created status, got %s", result.Status)
+	}
+
+	keyPath := filepath.Join(tempDir, "keyrings", "docker.asc")
+	sourcePath := filepath.Join(tempDir, "sources.list.d", "docker.list")
+	wantSource := "deb [signed-by=" + keyPath + "] https://download.docker.com/linux/ubuntu jammy stable test\n"
+	if data, _ := ioutil.ReadFile(sourcePath); string(data) != wantSource {
+		t.Errorf("Expected source %q, got %q", wantSource, string(data))
+	}
+	if data, _ := ioutil.ReadFile(keyPath); string(data) != testRepositoryKey {
+		t.Errorf("Expected the fetched key in %s, got %q", keyPath, string(data))
+	}
+
+	wantCommands := [][]string{{"apt-get", "update",
+		"-o", "Dir::Etc::sourcelist=" + sourcePath,
+		"-o", "Dir::Etc::sourceparts=-",
+		"-o", "APT::Get::List-Cleanup=0"}}
+	if !reflect.DeepEqual(recorder.commands, wantCommands) {
+		t.Errorf("Expected commands %v, got %v", wantCommands, recorder.commands)
+	}
+
+	// Applying again changes nothing
+	plan, err = provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "unchanged" {
+		t.Errorf("Expected unchanged status after apply, got %s (%s)", plan.Status, plan.Details)
+	}
+
+	// A changed suite only rewrites the source
+	attrs["suite"] = "noble"
+	plan, err = provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" || !reflect.DeepEqual(plan.Changes, []string{"source"}) {
+		t.Errorf("Expected planned source, got %s %v", plan.Status, plan.Changes)
+	}
+	if result, err := provider.Apply(ctx, plan); err != nil || result.Status != "updated" {
+		t.Errorf("Expected updated status, got %+v (%v)", result, err)
+	}
+
+	// Switching to a binary key replaces the armored key file
+	attrs["key"] = "https://example.com/key.gpg"
+	if _, err := provider.Apply(ctx, &ResourceState{Attributes: attrs}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	binaryKeyPath := filepath.Join(tempDir, "keyrings", "docker.gpg")
+	if data, _ := ioutil.ReadFile(binaryKeyPath); string(data) != testBinaryRepositoryKey {
+		t.Errorf("Expected the binary key in %s, got %q", binaryKeyPath, string(data))
+	}
+	if _, err := os.Stat(keyPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the armored key %s to be removed", keyPath)
+	}
+	keyPath = binaryKeyPath
+
+	// Removing the repository removes both files
+	absent := map[string]interface{}{"name": "docker", "state": "absent"}
+	plan, err = provider.Plan(ctx, nil, absent)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" {
+		t.Errorf("Expected planned removal, got %s", plan.Status)
+	}
+	result, err = provider.Apply(ctx, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "deleted" {
+		t.Errorf("Expected deleted status, got %s", result.Status)
+	}
+	for _, path := range []string{sourcePath, keyPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+}
+
+func TestRepositoryProvider_Dnf(t *testing.T) {
+	provider, recorder, tempDir := newTestRepositoryProvider(t, "dnf")
+	ctx := context.Background()
+
+	attrs := map[string]interface{}{
+		"name":    "docker-ce",
+		"baseurl": "https://download.docker.com/linux/fedora/$releasever/$basearch/stable",
+		"key":     testRepositoryKey,
+	}
+
+	plan, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	result, err := provider.Apply(ctx, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "created" {
+		t.Errorf("Expected created status, got %s", result.Status)
+	}
+
+	keyPath := filepath.Join(tempDir, "rpm-gpg", "RPM-GPG-KEY-docker-ce")
+	wantRepo := `[docker-ce]
+name=docker-ce
+baseurl=https://download.docker.com/linux/fedora/$releasever/$basearch/stable
+enabled=1
+gpgcheck=1
+gpgkey=file://` + keyPath + "\n"
+	if data, _ := ioutil.ReadFile(filepath.Join(tempDir, "yum.repos.d", "docker-ce.repo")); string(data) != wantRepo {
+		t.Errorf("Expected repo file %q, got %q", wantRepo, string(data))
+	}
+	if data, _ := ioutil.ReadFile(keyPath); string(data) != testRepositoryKey {
+		t.Errorf("Expected the key in %s, got %q", keyPath, string(data))
+	}
+
+	wantCommands := [][]string{{"rpm", "--import", keyPath}}
+	if !reflect.DeepEqual(recorder.commands, wantCommands) {
+		t.Errorf("Expected commands %v, got %v", wantCommands, recorder.commands)
+	}
+
+	plan, err = provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "unchanged" {
+		t.Errorf("Expected unchanged status after apply, got %s (%s)", plan.Status, plan.Details)
+	}
+}
+
+func TestRenderYumRepo_WithoutKey(t *testing.T) {
+	want := "[local]\nname=local\nbaseurl=file:///srv/repo\nenabled=1\n"
+	if got := renderYumRepo("local", "file:///srv/repo", ""); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
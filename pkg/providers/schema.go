@@ -0,0 +1,98 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AttributeType is the kind of value an attribute holds
+type AttributeType int
+
+const (
+	TypeAny    AttributeType = iota // Any value
+	TypeString                      // A string
+	TypeBool                        // A boolean
+	TypeNumber                      // An integer or floating point number
+	TypeList                        // A list of values
+)
+
+// String returns how the type is described in error messages
+func (t AttributeType) String() string {
+	switch t {
+	case TypeString:
+		return "a string"
+	case TypeBool:
+		return "a boolean"
+	case TypeNumber:
+		return "a number"
+	case TypeList:
+		return "a list"
+	default:
+		return "any value"
+	}
+}
+
+// matches reports whether value is of the type
+func (t AttributeType) matches(value interface{}) bool {
+	switch t {
+	case TypeString:
+		_, ok := value.(string)
+		return ok
+	case TypeBool:
+		_, ok := value.(bool)
+		return ok
+	case TypeNumber:
+		switch value.(type) {
+		case int, int64, float64:
+			return true
+		}
+		return false
+	case TypeList:
+		switch value.(type) {
+		case []interface{}, []string:
+			return true
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// Attribute describes one attribute of a resource type
+type Attribute struct {
+	Name     string
+	Type     AttributeType
+	Required bool
+	OneOf    []string // Values a string attribute is limited to, if any
+}
+
+// Schema declares the attributes of a resource type that can be checked
+// without knowing what they mean: which are required, their types and the
+// values they are limited to. Providers validate attributes against their
+// schema and add their own checks on top, so every provider reports these
+// problems with the same messages.
+type Schema []Attribute
+
+// Validate checks attributes against the schema, in the order the schema
+// declares them, and returns the first problem found. Attributes the schema
+// doesn't declare are left to the provider.
+func (s Schema) Validate(resourceType string, attributes map[string]interface{}) error {
+	for _, attribute := range s {
+		value, ok := attributes[attribute.Name]
+		if !ok {
+			if attribute.Required {
+				return fmt.Errorf("%s resource requires '%s' attribute", resourceType, attribute.Name)
+			}
+			continue
+		}
+
+		if !attribute.Type.matches(value) {
+			return fmt.Errorf("%s '%s' must be %s", resourceType, attribute.Name, attribute.Type)
+		}
+
+		if len(attribute.OneOf) > 0 && !containsString(attribute.OneOf, fmt.Sprint(value)) {
+			return fmt.Errorf("%s '%s' must be one of: %s", resourceType, attribute.Name, strings.Join(attribute.OneOf, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"testing"
+)
+
+func TestSchema_Validate(t *testing.T) {
+	schema := Schema{
+		{Name: "name", Type: TypeString, Required: true},
+		{Name: "state", Type: TypeString, OneOf: []string{"present", "absent"}},
+		{Name: "enabled", Type: TypeBool},
+		{Name: "port", Type: TypeNumber},
+		{Name: "aliases", Type: TypeList},
+		{Name: "extra"},
+	}
+
+	tests := []struct {
+		name       string
+		attributes map[string]interface{}
+		wantErr    string
+	}{
+		{"minimal", map[string]interface{}{"name": "web"}, ""},
+		{"all attributes", map[string]interface{}{
+			"name":    "web",
+			"state":   "absent",
+			"enabled": true,
+			"port":    int64(8080),
+			"aliases": []interface{}{"www"},
+			"extra":   1.5,
+		}, ""},
+		{"undeclared attributes are left alone", map[string]interface{}{"name": "web", "other": true}, ""},
+		{"missing required", map[string]interface{}{"state": "present"}, "sample resource requires 'name' attribute"},
+		{"wrong string type", map[string]interface{}{"name": 1}, "sample 'name' must be a string"},
+		{"wrong bool type", map[string]interface{}{"name": "web", "enabled": "yes"}, "sample 'enabled' must be a boolean"},
+		{"wrong number type", map[string]interface{}{"name": "web", "port": "8080"}, "sample 'port' must be a number"},
+		{"wrong list type", map[string]interface{}{"name": "web", "aliases": "www"}, "sample 'aliases' must be a list"},
+		{"value not allowed", map[string]interface{}{"name": "web", "state": "running"}, "sample 'state' must be one of: present, absent"},
+		{"first problem in schema order", map[string]interface{}{"name": 1, "state": "running"}, "sample 'name' must be a string"},
+	}
+
+	for _, tt := range tests {
+		err := schema.Validate("sample", tt.attributes)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
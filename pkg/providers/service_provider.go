@@ -2,69 +2,102 @@ package providers
 
 import (
 	"context"
+	"encoding/xml"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
+
+	"github.com/dangerclosesec/zero/pkg/logging"
+)
+
+// Defaults for how long Apply waits for a service to start or stop, and how
+// often it checks
+const (
+	defaultServiceWait         = 10 * time.Second
+	defaultServiceWaitInterval = 500 * time.Millisecond
 )
 
 // ServiceProvider implements service management
 type ServiceProvider struct {
-	platform *PlatformChecker
+	platform     *PlatformChecker
+	runCommand   CommandRunner
+	serviceState func(ctx context.Context, provider, name string) (ServiceState, error)
 }
 
 // ServiceState represents the current state of a service
 type ServiceState struct {
-	Running bool
-	Enabled bool
+	Running  bool
+	Enabled  bool
+	Masked   bool   // systemd unit is masked and can't be started
+	SubState string // Init system's finer-grained state, such as "running", "failed" or "auto-restart"
+	Detail   string // Human-readable status, such as why the service last exited
 }
 
 // NewServiceProvider creates a new service provider
 func NewServiceProvider() *ServiceProvider {
-	return &ServiceProvider{
-		platform: &PlatformChecker{},
+	p := &ServiceProvider{
+		platform:   &PlatformChecker{},
+		runCommand: runCommand,
 	}
+	p.serviceState = p.getServiceState
+	return p
+}
+
+// serviceSchema declares the service attributes with a fixed type or set of values
+var serviceSchema = Schema{
+	{Name: "name", Type: TypeString, Required: true},
+	{Name: "state", Type: TypeString, OneOf: []string{"running", "stopped", "restarted", "reloaded", "reloaded_or_restarted"}},
+	{Name: "enabled", Type: TypeBool},
+	{Name: "on_notify", Type: TypeString, OneOf: []string{"restart", "reload", "reload_or_restart"}},
+	{Name: "masked", Type: TypeBool},
+	{Name: "provider", Type: TypeString},
 }
 
 // Validate validates service resource attributes
 func (p *ServiceProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
-	// Check for required attributes
-	name, ok := attributes["name"]
-	if !ok {
-		return fmt.Errorf("service resource requires 'name' attribute")
-	}
-
-	// Validate name is a string
-	_, ok = name.(string)
-	if !ok {
-		return fmt.Errorf("service 'name' must be a string")
+	if err := serviceSchema.Validate("service", attributes); err != nil {
+		return err
 	}
 
-	// Validate state if present
-	if state, hasState := attributes["state"].(string); hasState {
-		if state != "running" && state != "stopped" && state != "restarted" && state != "reloaded" {
-			return fmt.Errorf("service 'state' must be one of: running, stopped, restarted, reloaded")
+	// Masking needs systemd and a service that stays stopped
+	if isMasked, hasMasked := attributes["masked"].(bool); hasMasked {
+		if provider := p.getServiceProvider(attributes); provider != "systemd" {
+			return fmt.Errorf("service 'masked' is only supported with systemd, not %s", provider)
+		}
+		if isMasked {
+			if state, _ := attributes["state"].(string); state != "" && state != "stopped" {
+				return fmt.Errorf("service 'masked' requires state 'stopped', not %s", state)
+			}
+			if enabled, _ := attributes["enabled"].(bool); enabled {
+				return fmt.Errorf("service 'masked' can't be used with 'enabled'")
+			}
 		}
 	}
 
-	// Validate enabled if present
-	if enabled, hasEnabled := attributes["enabled"]; hasEnabled {
-		_, ok = enabled.(bool)
-		if !ok {
-			return fmt.Errorf("service 'enabled' must be a boolean")
+	// Validate wait and wait_interval if present
+	for _, key := range []string{"wait", "wait_interval"} {
+		if _, _, err := durationAttribute(attributes, key); err != nil {
+			return fmt.Errorf("service %v", err)
 		}
 	}
+	if interval, ok, _ := durationAttribute(attributes, "wait_interval"); ok && interval == 0 {
+		return fmt.Errorf("service 'wait_interval' must be greater than zero")
+	}
 
 	// Validate provider if present
 	if provider, hasProvider := attributes["provider"].(string); hasProvider {
 		initSystem := p.platform.DetectInitSystem()
 		if provider != initSystem && provider != "auto" {
 			// If provider is specified, warn but don't fail
-			fmt.Printf("Warning: specified service provider '%s' differs from detected init system '%s'\n", provider, initSystem)
+			logging.FromContext(ctx).Warnf("specified service provider '%s' differs from detected init system '%s'", provider, initSystem)
 		}
 	}
 
@@ -83,7 +116,7 @@ func (p *ServiceProvider) getServiceProvider(attributes map[string]interface{})
 }
 
 // getServiceState gets the current running and enabled state of a service
-func (p *ServiceProvider) getServiceState(provider, name string) (ServiceState, error) {
+func (p *ServiceProvider) getServiceState(ctx context.Context, provider, name string) (ServiceState, error) {
 	state := ServiceState{
 		Running: false,
 		Enabled: false,
@@ -92,20 +125,26 @@ func (p *ServiceProvider) getServiceState(provider, name string) (ServiceState,
 	switch provider {
 	case "systemd":
 		// Check if service is running
-		cmdStatus := exec.Command("systemctl", "is-active", name+".service")
+		cmdStatus := exec.CommandContext(ctx, "systemctl", "is-active", name+".service")
 		if err := cmdStatus.Run(); err == nil {
 			state.Running = true
 		}
 
-		// Check if service is enabled
-		cmdEnabled := exec.Command("systemctl", "is-enabled", name+".service")
-		if err := cmdEnabled.Run(); err == nil {
+		// Check if service is enabled, or masked
+		output, err := p.runCommand(ctx, "systemctl", "is-enabled", name+".service")
+		if err == nil {
 			state.Enabled = true
 		}
+		state.Masked = strings.HasPrefix(strings.TrimSpace(string(output)), "masked")
+
+		// systemctl status exits non-zero for stopped and failed units, but
+		// still describes them
+		output, _ = p.runCommand(ctx, "systemctl", "status", "--no-pager", "--lines=0", name+".service")
+		state.SubState, state.Detail = parseSystemdStatus(string(output))
 
 	case "upstart":
 		// Check if service is running
-		cmdStatus := exec.Command("status", name)
+		cmdStatus := exec.CommandContext(ctx, "status", name)
 		output, err := cmdStatus.CombinedOutput()
 		if err == nil && strings.Contains(string(output), "start/running") {
 			state.Running = true
@@ -118,7 +157,7 @@ func (p *ServiceProvider) getServiceState(provider, name string) (ServiceState,
 
 	case "sysvinit":
 		// Check if service is running
-		cmdStatus := exec.Command("service", name, "status")
+		cmdStatus := exec.CommandContext(ctx, "service", name, "status")
 		if err := cmdStatus.Run(); err == nil {
 			state.Running = true
 		}
@@ -135,12 +174,19 @@ func (p *ServiceProvider) getServiceState(provider, name string) (ServiceState,
 
 	case "launchd":
 		// Check if service is loaded
-		cmdStatus := exec.Command("launchctl", "list")
+		cmdStatus := exec.CommandContext(ctx, "launchctl", "list")
 		output, err := cmdStatus.CombinedOutput()
 		if err == nil && strings.Contains(string(output), name) {
 			state.Running = true
 		}
 
+		if state.Running {
+			output, err := p.runCommand(ctx, "launchctl", "list", name)
+			if err == nil {
+				state.SubState, state.Detail = parseLaunchctlList(string(output))
+			}
+		}
+
 		// Check if service is enabled (has a plist in the LaunchDaemons directory)
 		plistPaths := []string{
 			"/Library/LaunchDaemons/" + name + ".plist",
@@ -158,14 +204,14 @@ func (p *ServiceProvider) getServiceState(provider, name string) (ServiceState,
 
 	case "windows":
 		// Check if service is running
-		cmdStatus := exec.Command("sc", "query", name)
+		cmdStatus := exec.CommandContext(ctx, "sc", "query", name)
 		output, err := cmdStatus.CombinedOutput()
 		if err == nil && strings.Contains(string(output), "RUNNING") {
 			state.Running = true
 		}
 
 		// Check if service is enabled
-		cmdConfig := exec.Command("sc", "qc", name)
+		cmdConfig := exec.CommandContext(ctx, "sc", "qc", name)
 		configOutput, err := cmdConfig.CombinedOutput()
 		if err == nil && strings.Contains(string(configOutput), "AUTO_START") {
 			state.Enabled = true
@@ -175,7 +221,117 @@ func (p *ServiceProvider) getServiceState(provider, name string) (ServiceState,
 	return state, nil
 }
 
-// Plan determines what changes would be made to a service
+// parseSystemdStatus reads the sub-state and a description of the service's
+// status from systemctl status output, using its Active and Main PID lines
+func parseSystemdStatus(output string) (string, string) {
+	var active, mainPID string
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Active":
+			active = strings.TrimSpace(value)
+		case "Main PID":
+			mainPID = strings.TrimSpace(value)
+		}
+	}
+	if active == "" {
+		return "", ""
+	}
+
+	// "active (running) since Mon 2024-01-01 10:00:00 UTC; 1h ago"
+	if i := strings.Index(active, " since "); i >= 0 {
+		active = active[:i]
+	}
+	detail := active
+
+	activeState, paren, _ := strings.Cut(active, " (")
+	paren, _, _ = strings.Cut(paren, ")")
+	subState := activeState
+	if paren != "" && !strings.Contains(paren, ":") {
+		subState = paren
+	}
+
+	// "1234 (code=exited, status=1/FAILURE)" says how the service exited
+	if i := strings.Index(mainPID, "(code="); i >= 0 {
+		detail += ", main process " + strings.TrimSuffix(mainPID[i+1:], ")")
+	}
+
+	return subState, detail
+}
+
+// parseLaunchctlList reads the sub-state and a description of the service's
+// status from launchctl list <label> output, using its PID and
+// LastExitStatus keys
+func parseLaunchctlList(output string) (string, string) {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		values[key] = strings.Trim(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), ";")), `"`)
+	}
+
+	subState := "waiting"
+	var details []string
+	if pid, ok := values["PID"]; ok {
+		subState = "running"
+		details = append(details, "running (pid "+pid+")")
+	} else {
+		details = append(details, "not running")
+	}
+	if status, ok := values["LastExitStatus"]; ok && status != "0" {
+		details = append(details, "last exit status "+status)
+	}
+
+	return subState, strings.Join(details, ", ")
+}
+
+// Read returns the live state of a service: whether it is running, enabled
+// and masked, with the init system's sub-state and status detail
+func (p *ServiceProvider) Read(ctx context.Context, attributes map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := attributes["name"].(string)
+	state, err := p.serviceState(ctx, p.getServiceProvider(attributes), name)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"name":      name,
+		"running":   state.Running,
+		"enabled":   state.Enabled,
+		"masked":    state.Masked,
+		"sub_state": state.SubState,
+		"detail":    state.Detail,
+	}, nil
+}
+
+// liveServiceState returns the service state held by current when it is the
+// result of Read, and otherwise reads it from the init system
+func (p *ServiceProvider) liveServiceState(ctx context.Context, current, desired map[string]interface{}) (ServiceState, error) {
+	if _, ok := current["running"].(bool); !ok {
+		live, err := p.Read(ctx, desired)
+		if err != nil {
+			return ServiceState{}, err
+		}
+		current = live
+	}
+
+	state := ServiceState{}
+	state.Running, _ = current["running"].(bool)
+	state.Enabled, _ = current["enabled"].(bool)
+	state.Masked, _ = current["masked"].(bool)
+	state.SubState, _ = current["sub_state"].(string)
+	state.Detail, _ = current["detail"].(string)
+	return state, nil
+}
+
+// Plan determines what changes would be made to a service. The current state
+// is taken from current when it was returned by Read.
 func (p *ServiceProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
 	name := desired["name"].(string)
 
@@ -198,11 +354,8 @@ func (p *ServiceProvider) Plan(ctx context.Context, current, desired map[string]
 		Status:     "unchanged",
 	}
 
-	// Get service provider
-	provider := p.getServiceProvider(desired)
-
 	// Get current service state
-	currentState, err := p.getServiceState(provider, name)
+	currentState, err := p.liveServiceState(ctx, current, desired)
 	if err != nil {
 		return nil, err
 	}
@@ -212,23 +365,38 @@ func (p *ServiceProvider) Plan(ctx context.Context, current, desired map[string]
 
 	if desiredState == "running" && !currentState.Running {
 		needsChange = true
+		result.addDiff("state", "stopped", "running")
 	} else if desiredState == "stopped" && currentState.Running {
 		needsChange = true
-	} else if desiredState == "restarted" || desiredState == "reloaded" {
+		result.addDiff("state", "running", "stopped")
+	} else if desiredState == "restarted" || desiredState == "reloaded" || desiredState == "reloaded_or_restarted" {
 		needsChange = true
 	}
 
 	if desiredEnabled != currentState.Enabled {
 		needsChange = true
+		result.addDiff("enabled", strconv.FormatBool(currentState.Enabled), strconv.FormatBool(desiredEnabled))
+	}
+
+	if masked, ok := desired["masked"].(bool); ok && masked != currentState.Masked {
+		needsChange = true
+		result.Changes = append(result.Changes, "masked")
+		result.addDiff("masked", strconv.FormatBool(currentState.Masked), strconv.FormatBool(masked))
 	}
 
 	if needsChange {
 		result.Status = "planned"
 	}
+	result.Details = currentState.Detail
 
 	return result, nil
 }
 
+// DesiredAttributes translates an intent into service attributes
+func (p *ServiceProvider) DesiredAttributes(intent Intent, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	return intentAttributes(intent, attributes, map[string]interface{}{"state": "stopped", "enabled": false})
+}
+
 // Apply applies the desired state to a service
 func (p *ServiceProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
 	name := state.Attributes["name"].(string)
@@ -256,19 +424,38 @@ func (p *ServiceProvider) Apply(ctx context.Context, state *ResourceState) (*Res
 	provider := p.getServiceProvider(state.Attributes)
 
 	// Get current service state
-	currentState, err := p.getServiceState(provider, name)
+	currentState, err := p.serviceState(ctx, provider, name)
 	if err != nil {
 		result.Status = "failed"
 		result.Error = err
 		return result, err
 	}
+	if currentState.Detail != "" {
+		result.Details = "was " + currentState.Detail
+	}
+
+	// Unmask the service before it is started, and mask it once it is stopped
+	desiredMasked, hasMasked := state.Attributes["masked"].(bool)
+	if hasMasked && !desiredMasked && currentState.Masked {
+		if err := p.maskService(ctx, provider, name, false); err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+		result.Status = "updated"
+	}
 
 	// Apply changes
 	if desiredState != "" {
 		switch desiredState {
 		case "running":
 			if !currentState.Running {
-				if err := p.startService(provider, name); err != nil {
+				if err := p.startService(ctx, provider, name); err != nil {
+					result.Status = "failed"
+					result.Error = err
+					return result, err
+				}
+				if err := p.waitForService(ctx, provider, name, state.Attributes, true); err != nil {
 					result.Status = "failed"
 					result.Error = err
 					return result, err
@@ -277,7 +464,12 @@ func (p *ServiceProvider) Apply(ctx context.Context, state *ResourceState) (*Res
 			}
 		case "stopped":
 			if currentState.Running {
-				if err := p.stopService(provider, name); err != nil {
+				if err := p.stopService(ctx, provider, name); err != nil {
+					result.Status = "failed"
+					result.Error = err
+					return result, err
+				}
+				if err := p.waitForService(ctx, provider, name, state.Attributes, false); err != nil {
 					result.Status = "failed"
 					result.Error = err
 					return result, err
@@ -285,14 +477,31 @@ func (p *ServiceProvider) Apply(ctx context.Context, state *ResourceState) (*Res
 				result.Status = "updated"
 			}
 		case "restarted":
-			if err := p.restartService(provider, name); err != nil {
+			if err := p.restartService(ctx, provider, name); err != nil {
+				result.Status = "failed"
+				result.Error = err
+				return result, err
+			}
+			if err := p.waitForService(ctx, provider, name, state.Attributes, true); err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
 			}
 			result.Status = "updated"
 		case "reloaded":
-			if err := p.reloadService(provider, name); err != nil {
+			if err := p.reloadService(ctx, provider, name); err != nil {
+				result.Status = "failed"
+				result.Error = err
+				return result, err
+			}
+			result.Status = "updated"
+		case "reloaded_or_restarted":
+			if err := p.reloadOrRestartService(ctx, provider, name); err != nil {
+				result.Status = "failed"
+				result.Error = err
+				return result, err
+			}
+			if err := p.waitForService(ctx, provider, name, state.Attributes, true); err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
@@ -304,13 +513,13 @@ func (p *ServiceProvider) Apply(ctx context.Context, state *ResourceState) (*Res
 	// Set service enabled/disabled state
 	if desiredEnabled != currentState.Enabled {
 		if desiredEnabled {
-			if err := p.enableService(provider, name); err != nil {
+			if err := p.enableService(ctx, provider, name); err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
 			}
 		} else {
-			if err := p.disableService(provider, name); err != nil {
+			if err := p.disableService(ctx, provider, name); err != nil {
 				result.Status = "failed"
 				result.Error = err
 				return result, err
@@ -322,23 +531,132 @@ func (p *ServiceProvider) Apply(ctx context.Context, state *ResourceState) (*Res
 		}
 	}
 
+	if hasMasked && desiredMasked && !currentState.Masked {
+		if err := p.maskService(ctx, provider, name, true); err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+		result.Status = "updated"
+	}
+
+	result.Changed = result.Status == "updated"
+	return result, nil
+}
+
+// Notify restarts the service, or reloads it when on_notify is "reload" or
+// "reload_or_restart", in response to a change in a resource that notifies it
+func (p *ServiceProvider) Notify(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	name := state.Attributes["name"].(string)
+
+	result := &ResourceState{
+		Type:       state.Type,
+		Name:       state.Name,
+		Attributes: state.Attributes,
+		Status:     "updated",
+	}
+
+	action := "restart"
+	if onNotify, ok := state.Attributes["on_notify"].(string); ok {
+		action = onNotify
+	}
+
+	provider := p.getServiceProvider(state.Attributes)
+
+	var err error
+	switch action {
+	case "reload":
+		err = p.reloadService(ctx, provider, name)
+	case "reload_or_restart":
+		err = p.reloadOrRestartService(ctx, provider, name)
+		if err == nil {
+			err = p.waitForService(ctx, provider, name, state.Attributes, true)
+		}
+	default:
+		err = p.restartService(ctx, provider, name)
+		if err == nil {
+			err = p.waitForService(ctx, provider, name, state.Attributes, true)
+		}
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	result.Changed = true
+	result.Changes = []string{action}
+	result.Details = notifyActions[action]
 	return result, nil
 }
 
+// notifyActions describes what Notify did for each on_notify action
+var notifyActions = map[string]string{
+	"restart":           "restarted",
+	"reload":            "reloaded",
+	"reload_or_restart": "reloaded or restarted",
+}
+
+// waitForService polls the service until it is running, or stopped when
+// running is false, for up to the 'wait' attribute. A wait of zero skips the
+// check.
+func (p *ServiceProvider) waitForService(ctx context.Context, provider, name string, attributes map[string]interface{}, running bool) error {
+	wait, ok, _ := durationAttribute(attributes, "wait")
+	if !ok {
+		wait = defaultServiceWait
+	}
+	if wait == 0 {
+		return nil
+	}
+	interval, ok, _ := durationAttribute(attributes, "wait_interval")
+	if !ok {
+		interval = defaultServiceWaitInterval
+	}
+
+	want := "running"
+	if !running {
+		want = "stopped"
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		state, err := p.serviceState(ctx, provider, name)
+		if err != nil {
+			return err
+		}
+		if state.Running == running {
+			return nil
+		}
+
+		if !time.Now().Add(interval).Before(deadline) {
+			if state.Detail != "" {
+				return fmt.Errorf("service %s did not reach %s within %v: %s", name, want, wait, state.Detail)
+			}
+			return fmt.Errorf("service %s did not reach %s within %v", name, want, wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
 // startService starts a service
-func (p *ServiceProvider) startService(provider, name string) error {
+func (p *ServiceProvider) startService(ctx context.Context, provider, name string) error {
 	var cmd *exec.Cmd
 
 	switch provider {
 	case "systemd":
-		cmd = exec.Command("systemctl", "start", name+".service")
+		cmd = exec.CommandContext(ctx, "systemctl", "start", name+".service")
 	case "upstart":
-		cmd = exec.Command("start", name)
+		cmd = exec.CommandContext(ctx, "start", name)
 	case "sysvinit":
-		cmd = exec.Command("service", name, "start")
+		cmd = exec.CommandContext(ctx, "service", name, "start")
 	case "launchd":
 		// Check if the service is already loaded
-		loadState, _ := p.getServiceState(provider, name)
+		loadState, _ := p.getServiceState(ctx, provider, name)
 		if !loadState.Enabled {
 			// Try to find the plist
 			plistPaths := []string{
@@ -359,16 +677,16 @@ func (p *ServiceProvider) startService(provider, name string) error {
 			}
 
 			// Load the service
-			cmd = exec.Command("launchctl", "load", plistPath)
+			cmd = exec.CommandContext(ctx, "launchctl", "load", plistPath)
 			if err := cmd.Run(); err != nil {
 				return fmt.Errorf("failed to load service %s: %v", name, err)
 			}
 		}
 
 		// Start the service
-		cmd = exec.Command("launchctl", "start", name)
+		cmd = exec.CommandContext(ctx, "launchctl", "start", name)
 	case "windows":
-		cmd = exec.Command("sc", "start", name)
+		cmd = exec.CommandContext(ctx, "sc", "start", name)
 	default:
 		return fmt.Errorf("unsupported service provider: %s", provider)
 	}
@@ -382,20 +700,20 @@ func (p *ServiceProvider) startService(provider, name string) error {
 }
 
 // stopService stops a service
-func (p *ServiceProvider) stopService(provider, name string) error {
+func (p *ServiceProvider) stopService(ctx context.Context, provider, name string) error {
 	var cmd *exec.Cmd
 
 	switch provider {
 	case "systemd":
-		cmd = exec.Command("systemctl", "stop", name+".service")
+		cmd = exec.CommandContext(ctx, "systemctl", "stop", name+".service")
 	case "upstart":
-		cmd = exec.Command("stop", name)
+		cmd = exec.CommandContext(ctx, "stop", name)
 	case "sysvinit":
-		cmd = exec.Command("service", name, "stop")
+		cmd = exec.CommandContext(ctx, "service", name, "stop")
 	case "launchd":
-		cmd = exec.Command("launchctl", "stop", name)
+		cmd = exec.CommandContext(ctx, "launchctl", "stop", name)
 	case "windows":
-		cmd = exec.Command("sc", "stop", name)
+		cmd = exec.CommandContext(ctx, "sc", "stop", name)
 	default:
 		return fmt.Errorf("unsupported service provider: %s", provider)
 	}
@@ -409,33 +727,33 @@ func (p *ServiceProvider) stopService(provider, name string) error {
 }
 
 // restartService restarts a service
-func (p *ServiceProvider) restartService(provider, name string) error {
-	var cmd *exec.Cmd
+func (p *ServiceProvider) restartService(ctx context.Context, provider, name string) error {
+	var command []string
 
 	switch provider {
 	case "systemd":
-		cmd = exec.Command("systemctl", "restart", name+".service")
+		command = []string{"systemctl", "restart", name + ".service"}
 	case "upstart":
-		cmd = exec.Command("restart", name)
+		command = []string{"restart", name}
 	case "sysvinit":
-		cmd = exec.Command("service", name, "restart")
+		command = []string{"service", name, "restart"}
 	case "launchd":
 		// For launchd, we need to stop and then start the service
-		if err := p.stopService(provider, name); err != nil {
+		if err := p.stopService(ctx, provider, name); err != nil {
 			return err
 		}
-		return p.startService(provider, name)
+		return p.startService(ctx, provider, name)
 	case "windows":
 		// For Windows, we need to stop and then start the service
-		if err := p.stopService(provider, name); err != nil {
+		if err := p.stopService(ctx, provider, name); err != nil {
 			return err
 		}
-		return p.startService(provider, name)
+		return p.startService(ctx, provider, name)
 	default:
 		return fmt.Errorf("unsupported service provider: %s", provider)
 	}
 
-	output, err := cmd.CombinedOutput()
+	output, err := p.runCommand(ctx, command[0], command[1:]...)
 	if err != nil {
 		return fmt.Errorf("failed to restart service %s: %v\nOutput: %s", name, err, string(output))
 	}
@@ -443,17 +761,32 @@ func (p *ServiceProvider) restartService(provider, name string) error {
 	return nil
 }
 
+// reloadOrRestartService reloads a service if it supports reloading and
+// restarts it otherwise. Only systemd can tell, so other init systems restart.
+func (p *ServiceProvider) reloadOrRestartService(ctx context.Context, provider, name string) error {
+	if provider != "systemd" {
+		return p.restartService(ctx, provider, name)
+	}
+
+	output, err := p.runCommand(ctx, "systemctl", "reload-or-restart", name+".service")
+	if err != nil {
+		return fmt.Errorf("failed to reload or restart service %s: %v\nOutput: %s", name, err, string(output))
+	}
+
+	return nil
+}
+
 // reloadService reloads a service configuration
-func (p *ServiceProvider) reloadService(provider, name string) error {
+func (p *ServiceProvider) reloadService(ctx context.Context, provider, name string) error {
 	var cmd *exec.Cmd
 
 	switch provider {
 	case "systemd":
-		cmd = exec.Command("systemctl", "reload", name+".service")
+		cmd = exec.CommandContext(ctx, "systemctl", "reload", name+".service")
 	case "upstart":
-		cmd = exec.Command("reload", name)
+		cmd = exec.CommandContext(ctx, "reload", name)
 	case "sysvinit":
-		cmd = exec.Command("service", name, "reload")
+		cmd = exec.CommandContext(ctx, "service", name, "reload")
 	case "launchd":
 		// For launchd, we need to unload and then load the service
 		// First find the plist
@@ -475,13 +808,13 @@ func (p *ServiceProvider) reloadService(provider, name string) error {
 		}
 
 		// Unload the service
-		unloadCmd := exec.Command("launchctl", "unload", plistPath)
+		unloadCmd := exec.CommandContext(ctx, "launchctl", "unload", plistPath)
 		if err := unloadCmd.Run(); err != nil {
 			return fmt.Errorf("failed to unload service %s: %v", name, err)
 		}
 
 		// Load the service
-		loadCmd := exec.Command("launchctl", "load", plistPath)
+		loadCmd := exec.CommandContext(ctx, "launchctl", "load", plistPath)
 		if err := loadCmd.Run(); err != nil {
 			return fmt.Errorf("failed to load service %s: %v", name, err)
 		}
@@ -489,7 +822,7 @@ func (p *ServiceProvider) reloadService(provider, name string) error {
 		return nil
 	case "windows":
 		// Windows doesn't have a direct equivalent of reload
-		return p.restartService(provider, name)
+		return p.restartService(ctx, provider, name)
 	default:
 		return fmt.Errorf("unsupported service provider: %s", provider)
 	}
@@ -503,12 +836,12 @@ func (p *ServiceProvider) reloadService(provider, name string) error {
 }
 
 // enableService enables a service to start at boot
-func (p *ServiceProvider) enableService(provider, name string) error {
+func (p *ServiceProvider) enableService(ctx context.Context, provider, name string) error {
 	var cmd *exec.Cmd
 
 	switch provider {
 	case "systemd":
-		cmd = exec.Command("systemctl", "enable", name+".service")
+		cmd = exec.CommandContext(ctx, "systemctl", "enable", name+".service")
 	case "upstart":
 		// Upstart services are enabled by default when installed
 		// Check if the .conf file exists
@@ -518,7 +851,7 @@ func (p *ServiceProvider) enableService(provider, name string) error {
 		return nil
 	case "sysvinit":
 		// Use update-rc.d to enable the service
-		cmd = exec.Command("update-rc.d", name, "defaults")
+		cmd = exec.CommandContext(ctx, "update-rc.d", name, "defaults")
 	case "launchd":
 		// Find the plist
 		plistPaths := []string{
@@ -539,9 +872,9 @@ func (p *ServiceProvider) enableService(provider, name string) error {
 		}
 
 		// Load the service with the -w flag to enable it at boot
-		cmd = exec.Command("launchctl", "load", "-w", plistPath)
+		cmd = exec.CommandContext(ctx, "launchctl", "load", "-w", plistPath)
 	case "windows":
-		cmd = exec.Command("sc", "config", name, "start=auto")
+		cmd = exec.CommandContext(ctx, "sc", "config", name, "start=auto")
 	default:
 		return fmt.Errorf("unsupported service provider: %s", provider)
 	}
@@ -554,13 +887,32 @@ func (p *ServiceProvider) enableService(provider, name string) error {
 	return nil
 }
 
+// maskService masks a systemd service so it can't be started, or unmasks it
+func (p *ServiceProvider) maskService(ctx context.Context, provider, name string, masked bool) error {
+	if provider != "systemd" {
+		return fmt.Errorf("masking services is only supported with systemd, not %s", provider)
+	}
+
+	action := "unmask"
+	if masked {
+		action = "mask"
+	}
+
+	output, err := p.runCommand(ctx, "systemctl", action, name+".service")
+	if err != nil {
+		return fmt.Errorf("failed to %s service %s: %v\nOutput: %s", action, name, err, string(output))
+	}
+
+	return nil
+}
+
 // disableService disables a service from starting at boot
-func (p *ServiceProvider) disableService(provider, name string) error {
+func (p *ServiceProvider) disableService(ctx context.Context, provider, name string) error {
 	var cmd *exec.Cmd
 
 	switch provider {
 	case "systemd":
-		cmd = exec.Command("systemctl", "disable", name+".service")
+		cmd = exec.CommandContext(ctx, "systemctl", "disable", name+".service")
 	case "upstart":
 		// Create an override file to disable the service
 		overridePath := "/etc/init/" + name + ".override"
@@ -571,7 +923,7 @@ func (p *ServiceProvider) disableService(provider, name string) error {
 		return nil
 	case "sysvinit":
 		// Use update-rc.d to disable the service
-		cmd = exec.Command("update-rc.d", name, "disable")
+		cmd = exec.CommandContext(ctx, "update-rc.d", name, "disable")
 	case "launchd":
 		// Find the plist
 		plistPaths := []string{
@@ -592,9 +944,9 @@ func (p *ServiceProvider) disableService(provider, name string) error {
 		}
 
 		// Unload the service with the -w flag to disable it at boot
-		cmd = exec.Command("launchctl", "unload", "-w", plistPath)
+		cmd = exec.CommandContext(ctx, "launchctl", "unload", "-w", plistPath)
 	case "windows":
-		cmd = exec.Command("sc", "config", name, "start=demand")
+		cmd = exec.CommandContext(ctx, "sc", "config", name, "start=demand")
 	default:
 		return fmt.Errorf("unsupported service provider: %s", provider)
 	}
@@ -607,96 +959,222 @@ func (p *ServiceProvider) disableService(provider, name string) error {
 	return nil
 }
 
-// CreateLaunchdPlist creates a launchd plist file for a service
+// LaunchdPlistOptions holds the optional settings of a launchd plist. Zero
+// values leave the setting out.
+type LaunchdPlistOptions struct {
+	Environment       map[string]string
+	StandardOutPath   string
+	StandardErrorPath string
+}
+
+// CreateLaunchdPlist creates a launchd plist file for a service that runs a
+// single command without arguments
 func (p *ServiceProvider) CreateLaunchdPlist(name, command string, runAtBoot bool, keepAlive bool) error {
+	return p.CreateLaunchdPlistWithOptions(name, []string{command}, runAtBoot, keepAlive, LaunchdPlistOptions{})
+}
+
+// CreateLaunchdPlistWithOptions creates a launchd plist file for a service
+// that runs args, the program followed by its arguments, with environment
+// variables and log files
+func (p *ServiceProvider) CreateLaunchdPlistWithOptions(name string, args []string, runAtBoot, keepAlive bool, options LaunchdPlistOptions) error {
 	// Only applicable on Darwin
 	if runtime.GOOS != "darwin" {
 		return fmt.Errorf("CreateLaunchdPlist is only applicable on macOS")
 	}
 
+	plist, err := renderLaunchdPlist(name, args, runAtBoot, keepAlive, options)
+	if err != nil {
+		return err
+	}
+
+	// Create the plist file
+	plistPath := "/Library/LaunchDaemons/" + name + ".plist"
+	if err := ioutil.WriteFile(plistPath, []byte(plist), 0644); err != nil {
+		return fmt.Errorf("failed to create plist file: %v", err)
+	}
+
+	// Set the permissions
+	if err := os.Chmod(plistPath, 0644); err != nil {
+		return fmt.Errorf("failed to set plist file permissions: %v", err)
+	}
+
+	// Change ownership to root:wheel
+	if err := exec.Command("sudo", "chown", "root:wheel", plistPath).Run(); err != nil {
+		return fmt.Errorf("failed to set plist file ownership: %v", err)
+	}
+
+	return nil
+}
+
+// renderLaunchdPlist renders the contents of a launchd plist
+func renderLaunchdPlist(label string, args []string, runAtBoot, keepAlive bool, options LaunchdPlistOptions) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("launchd plist for %s requires a program to run", label)
+	}
+
 	// Define the plist template
 	const plistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
 <!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
 <plist version="1.0">
 <dict>
     <key>Label</key>
-    <string>{{ .Label }}</string>
+    <string>{{ xml .Label }}</string>
     <key>ProgramArguments</key>
     <array>
-        <string>{{ .Command }}</string>
+{{- range .Args }}
+        <string>{{ xml . }}</string>
+{{- end }}
     </array>
+{{- if .Environment }}
+    <key>EnvironmentVariables</key>
+    <dict>
+{{- range .Environment }}
+        <key>{{ xml .Key }}</key>
+        <string>{{ xml .Value }}</string>
+{{- end }}
+    </dict>
+{{- end }}
+{{- if .StandardOutPath }}
+    <key>StandardOutPath</key>
+    <string>{{ xml .StandardOutPath }}</string>
+{{- end }}
+{{- if .StandardErrorPath }}
+    <key>StandardErrorPath</key>
+    <string>{{ xml .StandardErrorPath }}</string>
+{{- end }}
     <key>RunAtLoad</key>
     <{{ .RunAtLoad }}/>
-    {{ if .KeepAlive }}
+{{- if .KeepAlive }}
     <key>KeepAlive</key>
     <true/>
-    {{ end }}
+{{- end }}
 </dict>
-</plist>`
+</plist>
+`
 
 	// Parse the template
-	tmpl, err := template.New("plist").Parse(plistTemplate)
+	tmpl, err := template.New("plist").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(plistTemplate)
 	if err != nil {
-		return fmt.Errorf("failed to parse plist template: %v", err)
+		return "", fmt.Errorf("failed to parse plist template: %v", err)
+	}
+
+	// Environment variables are sorted so the plist renders the same every time
+	type variable struct{ Key, Value string }
+	keys := make([]string, 0, len(options.Environment))
+	for key := range options.Environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	environment := make([]variable, 0, len(keys))
+	for _, key := range keys {
+		environment = append(environment, variable{key, options.Environment[key]})
 	}
 
 	// Define the template data
 	data := struct {
-		Label     string
-		Command   string
-		RunAtLoad string
-		KeepAlive bool
+		Label             string
+		Args              []string
+		Environment       []variable
+		StandardOutPath   string
+		StandardErrorPath string
+		RunAtLoad         string
+		KeepAlive         bool
 	}{
-		Label:     name,
-		Command:   command,
-		RunAtLoad: "true",
-		KeepAlive: keepAlive,
+		Label:             label,
+		Args:              args,
+		Environment:       environment,
+		StandardOutPath:   options.StandardOutPath,
+		StandardErrorPath: options.StandardErrorPath,
+		RunAtLoad:         strconv.FormatBool(runAtBoot),
+		KeepAlive:         keepAlive,
 	}
 
-	if !runAtBoot {
-		data.RunAtLoad = "false"
+	// Execute the template
+	var plist strings.Builder
+	if err := tmpl.Execute(&plist, data); err != nil {
+		return "", fmt.Errorf("failed to execute plist template: %v", err)
 	}
 
-	// Create the plist file
-	plistPath := "/Library/LaunchDaemons/" + name + ".plist"
-	file, err := os.Create(plistPath)
+	return plist.String(), nil
+}
+
+// xmlEscape escapes a value for use as XML text
+func xmlEscape(value string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
+
+// SystemdUnitOptions holds the optional settings of a systemd unit file.
+// Zero values leave the setting out, except Restart which defaults to
+// "on-failure".
+type SystemdUnitOptions struct {
+	Environment      map[string]string
+	After            []string
+	Requires         []string
+	WorkingDirectory string
+	Restart          string
+}
+
+// CreateSystemdService creates a systemd service file
+func (p *ServiceProvider) CreateSystemdService(name, description, command string, wantedBy string) error {
+	return p.CreateSystemdServiceWithOptions(name, description, command, wantedBy, SystemdUnitOptions{})
+}
+
+// CreateSystemdServiceWithOptions creates a systemd service file with
+// environment variables, ordering dependencies, a working directory and a
+// restart policy
+func (p *ServiceProvider) CreateSystemdServiceWithOptions(name, description, command, wantedBy string, options SystemdUnitOptions) error {
+	// Only applicable on Linux with systemd
+	if runtime.GOOS != "linux" || p.platform.DetectInitSystem() != "systemd" {
+		return fmt.Errorf("CreateSystemdService is only applicable on Linux with systemd")
+	}
+
+	unit, err := renderSystemdUnit(description, command, wantedBy, options)
 	if err != nil {
-		return fmt.Errorf("failed to create plist file: %v", err)
+		return err
 	}
-	defer file.Close()
 
-	// Execute the template
-	if err := tmpl.Execute(file, data); err != nil {
-		return fmt.Errorf("failed to execute plist template: %v", err)
+	// Create the service file
+	servicePath := "/etc/systemd/system/" + name + ".service"
+	if err := ioutil.WriteFile(servicePath, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("failed to create service file: %v", err)
 	}
 
 	// Set the permissions
-	if err := os.Chmod(plistPath, 0644); err != nil {
-		return fmt.Errorf("failed to set plist file permissions: %v", err)
+	if err := os.Chmod(servicePath, 0644); err != nil {
+		return fmt.Errorf("failed to set service file permissions: %v", err)
 	}
 
-	// Change ownership to root:wheel
-	if err := exec.Command("sudo", "chown", "root:wheel", plistPath).Run(); err != nil {
-		return fmt.Errorf("failed to set plist file ownership: %v", err)
+	// Reload systemd
+	if err := exec.Command("systemctl", "daemon-reload").Run(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %v", err)
 	}
 
 	return nil
 }
 
-// CreateSystemdService creates a systemd service file
-func (p *ServiceProvider) CreateSystemdService(name, description, command string, wantedBy string) error {
-	// Only applicable on Linux with systemd
-	if runtime.GOOS != "linux" || p.platform.DetectInitSystem() != "systemd" {
-		return fmt.Errorf("CreateSystemdService is only applicable on Linux with systemd")
-	}
-
+// renderSystemdUnit renders the contents of a systemd service file
+func renderSystemdUnit(description, command, wantedBy string, options SystemdUnitOptions) (string, error) {
 	// Define the service file template
 	const serviceTemplate = `[Unit]
 Description={{ .Description }}
+{{- if .After }}
+After={{ join .After " " }}
+{{- end }}
+{{- if .Requires }}
+Requires={{ join .Requires " " }}
+{{- end }}
 
 [Service]
 ExecStart={{ .Command }}
-Restart=on-failure
+{{- if .WorkingDirectory }}
+WorkingDirectory={{ .WorkingDirectory }}
+{{- end }}
+{{- range .Environment }}
+Environment={{ . }}
+{{- end }}
+Restart={{ .Restart }}
 RestartSec=5
 
 [Install]
@@ -704,46 +1182,55 @@ WantedBy={{ .WantedBy }}
 `
 
 	// Parse the template
-	tmpl, err := template.New("service").Parse(serviceTemplate)
+	tmpl, err := template.New("service").Funcs(template.FuncMap{"join": strings.Join}).Parse(serviceTemplate)
 	if err != nil {
-		return fmt.Errorf("failed to parse service template: %v", err)
+		return "", fmt.Errorf("failed to parse service template: %v", err)
 	}
 
-	// Define the template data
-	data := struct {
-		Description string
-		Command     string
-		WantedBy    string
-	}{
-		Description: description,
-		Command:     command,
-		WantedBy:    wantedBy,
+	restart := options.Restart
+	if restart == "" {
+		restart = "on-failure"
 	}
 
-	// Create the service file
-	servicePath := "/etc/systemd/system/" + name + ".service"
-	file, err := os.Create(servicePath)
-	if err != nil {
-		return fmt.Errorf("failed to create service file: %v", err)
+	// Environment lines are sorted so the unit renders the same every time
+	keys := make([]string, 0, len(options.Environment))
+	for key := range options.Environment {
+		keys = append(keys, key)
 	}
-	defer file.Close()
-
-	// Execute the template
-	if err := tmpl.Execute(file, data); err != nil {
-		return fmt.Errorf("failed to execute service template: %v", err)
+	sort.Strings(keys)
+	environment := make([]string, 0, len(keys))
+	for _, key := range keys {
+		environment = append(environment, strconv.Quote(key+"="+options.Environment[key]))
 	}
 
-	// Set the permissions
-	if err := os.Chmod(servicePath, 0644); err != nil {
-		return fmt.Errorf("failed to set service file permissions: %v", err)
+	// Define the template data
+	data := struct {
+		Description      string
+		Command          string
+		WantedBy         string
+		After            []string
+		Requires         []string
+		WorkingDirectory string
+		Environment      []string
+		Restart          string
+	}{
+		Description:      description,
+		Command:          command,
+		WantedBy:         wantedBy,
+		After:            options.After,
+		Requires:         options.Requires,
+		WorkingDirectory: options.WorkingDirectory,
+		Environment:      environment,
+		Restart:          restart,
 	}
 
-	// Reload systemd
-	if err := exec.Command("systemctl", "daemon-reload").Run(); err != nil {
-		return fmt.Errorf("failed to reload systemd: %v", err)
+	// Execute the template
+	var unit strings.Builder
+	if err := tmpl.Execute(&unit, data); err != nil {
+		return "", fmt.Errorf("failed to execute service template: %v", err)
 	}
 
-	return nil
+	return unit.String(), nil
 }
 
 // CreateUpstartService creates an upstart service file
@@ -821,37 +1308,92 @@ func (p *ServiceProvider) CreateWindowsService(name, displayName, description, c
 		return fmt.Errorf("CreateWindowsService is only applicable on Windows")
 	}
 
-	// Validate start type
-	validStartTypes := map[string]string{
-		"auto":         "auto",
-		"automatic":    "auto",
-		"manual":       "demand",
-		"demand":       "demand",
-		"disabled":     "disabled",
-		"delayed-auto": "delayed-auto",
-	}
-
-	startTypeValue, ok := validStartTypes[strings.ToLower(startType)]
-	if !ok {
-		return fmt.Errorf("invalid start type '%s', must be one of: auto, manual, disabled, delayed-auto", startType)
+	args, err := scCreateArgs(name, displayName, command, startType)
+	if err != nil {
+		return err
 	}
 
 	// Create the service
-	createCmd := exec.Command("sc", "create", name,
-		fmt.Sprintf(`binPath=%s`, command),
-		fmt.Sprintf(`DisplayName=%s`, displayName),
-		fmt.Sprintf(`start=%s`, startTypeValue),
-	)
+	ctx := context.Background()
+	if output, err := p.runCommand(ctx, "sc", args...); err != nil {
+		return fmt.Errorf("failed to create service: %v\nOutput: %s", err, string(output))
+	}
 
-	if err := createCmd.Run(); err != nil {
-		return fmt.Errorf("failed to create service: %v", err)
+	// sc can accept arguments it doesn't understand, so check that the
+	// service exists and runs the intended command
+	output, err := p.runCommand(ctx, "sc", "qc", name)
+	if err != nil {
+		return fmt.Errorf("service %s was not created: %v\nOutput: %s", name, err, string(output))
+	}
+	if binPath := windowsBinPath(command); parseScBinaryPath(string(output)) != binPath {
+		return fmt.Errorf("service %s was created with binary path %q instead of %q", name, parseScBinaryPath(string(output)), binPath)
 	}
 
 	// Set the description
-	descCmd := exec.Command("sc", "description", name, description)
-	if err := descCmd.Run(); err != nil {
-		return fmt.Errorf("failed to set service description: %v", err)
+	if output, err := p.runCommand(ctx, "sc", "description", name, description); err != nil {
+		return fmt.Errorf("failed to set service description: %v\nOutput: %s", err, string(output))
 	}
 
 	return nil
 }
+
+// scStartTypes maps the start types CreateWindowsService accepts to the
+// values sc expects
+var scStartTypes = map[string]string{
+	"auto":         "auto",
+	"automatic":    "auto",
+	"manual":       "demand",
+	"demand":       "demand",
+	"disabled":     "disabled",
+	"delayed-auto": "delayed-auto",
+}
+
+// scCreateArgs returns the arguments of the sc create command for a service.
+// sc takes each option as a keyword ending in "=" followed by the value as a
+// separate argument, so "binPath=" and the path are passed apart.
+func scCreateArgs(name, displayName, command, startType string) ([]string, error) {
+	startTypeValue, ok := scStartTypes[strings.ToLower(startType)]
+	if !ok {
+		return nil, fmt.Errorf("invalid start type '%s', must be one of: auto, manual, disabled, delayed-auto", startType)
+	}
+	if strings.TrimSpace(command) == "" {
+		return nil, fmt.Errorf("service %s requires a command", name)
+	}
+
+	args := []string{"create", name, "binPath=", windowsBinPath(command)}
+	if displayName != "" {
+		args = append(args, "DisplayName=", displayName)
+	}
+	return append(args, "start=", startTypeValue), nil
+}
+
+// windowsBinPath returns the command as the binary path of a Windows service.
+// An unquoted executable path containing spaces is quoted, since Windows would
+// otherwise try to run each prefix of it ending at a space. The executable is
+// taken to end at ".exe" when arguments follow it.
+func windowsBinPath(command string) string {
+	command = strings.TrimSpace(command)
+	if strings.HasPrefix(command, `"`) || !strings.Contains(command, " ") {
+		return command
+	}
+
+	executable, args := command, ""
+	if i := strings.Index(strings.ToLower(command), ".exe "); i >= 0 {
+		executable, args = command[:i+len(".exe")], command[i+len(".exe"):]
+	}
+	if !strings.Contains(executable, " ") {
+		return command
+	}
+	return `"` + executable + `"` + args
+}
+
+// parseScBinaryPath returns the BINARY_PATH_NAME reported by sc qc
+func parseScBinaryPath(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && strings.TrimSpace(key) == "BINARY_PATH_NAME" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
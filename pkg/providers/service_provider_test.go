@@ -2,7 +2,9 @@ package providers
 
 import (
 	"context"
+	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -69,6 +71,43 @@ func TestServiceProvider_Validate(t *testing.T) {
 	if err := provider.Validate(ctx, invalidEnabledAttrs); err == nil {
 		t.Error("Expected error for invalid enabled type, got nil")
 	}
+
+	// Test valid on_notify
+	validNotifyAttrs := map[string]interface{}{
+		"name":      "test-service",
+		"on_notify": "reload",
+	}
+	if err := provider.Validate(ctx, validNotifyAttrs); err != nil {
+		t.Errorf("Expected no error for valid on_notify, got: %v", err)
+	}
+
+	// Test invalid on_notify
+	invalidNotifyAttrs := map[string]interface{}{
+		"name":      "test-service",
+		"on_notify": "stop",
+	}
+	if err := provider.Validate(ctx, invalidNotifyAttrs); err == nil {
+		t.Error("Expected error for invalid on_notify, got nil")
+	}
+
+	// Test valid wait and wait_interval
+	validWaitAttrs := map[string]interface{}{
+		"name":          "test-service",
+		"wait":          "30s",
+		"wait_interval": int64(1),
+	}
+	if err := provider.Validate(ctx, validWaitAttrs); err != nil {
+		t.Errorf("Expected no error for valid wait, got: %v", err)
+	}
+
+	// Test invalid wait
+	invalidWaitAttrs := map[string]interface{}{
+		"name": "test-service",
+		"wait": "soon",
+	}
+	if err := provider.Validate(ctx, invalidWaitAttrs); err == nil {
+		t.Error("Expected error for invalid wait, got nil")
+	}
 }
 
 func TestServiceProvider_getServiceProvider(t *testing.T) {
@@ -111,6 +150,7 @@ func TestServiceProvider_Plan(t *testing.T) {
 		t.Fatalf("Plan returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "planned" && result.Status != "unchanged" {
 		t.Errorf("Expected status 'planned' or 'unchanged', got '%s'", result.Status)
 	}
@@ -125,6 +165,7 @@ func TestServiceProvider_Plan(t *testing.T) {
 		t.Fatalf("Plan returned error: %v", err)
 	}
 
+	checkChanged(t, result)
 	if result.Status != "planned" && result.Status != "unchanged" {
 		t.Errorf("Expected status 'planned' or 'unchanged', got '%s'", result.Status)
 	}
@@ -156,7 +197,7 @@ func TestServiceProvider_Apply_Plan_NoChanges(t *testing.T) {
 	}
 
 	// Get the current state of the service for comparison
-	currentState, err := provider.getServiceState(provider.platform.DetectInitSystem(), knownService)
+	currentState, err := provider.getServiceState(context.Background(), provider.platform.DetectInitSystem(), knownService)
 	if err != nil {
 		t.Skipf("Failed to get current state of service %s: %v", knownService, err)
 	}
@@ -206,6 +247,70 @@ func TestServiceProvider_CreateLaunchdPlist(t *testing.T) {
 	}
 }
 
+func TestRenderLaunchdPlist(t *testing.T) {
+	plist, err := renderLaunchdPlist("com.example.app", []string{"/usr/local/bin/app", "--port", "8080", "--name=a&b"}, true, true, LaunchdPlistOptions{
+		Environment:       map[string]string{"PORT": "8080", "GREETING": "hello world"},
+		StandardOutPath:   "/var/log/app.log",
+		StandardErrorPath: "/var/log/app.err",
+	})
+	if err != nil {
+		t.Fatalf("renderLaunchdPlist failed: %v", err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    <string>com.example.app</string>
+    <key>ProgramArguments</key>
+    <array>
+        <string>/usr/local/bin/app</string>
+        <string>--port</string>
+        <string>8080</string>
+        <string>--name=a&amp;b</string>
+    </array>
+    <key>EnvironmentVariables</key>
+    <dict>
+        <key>GREETING</key>
+        <string>hello world</string>
+        <key>PORT</key>
+        <string>8080</string>
+    </dict>
+    <key>StandardOutPath</key>
+    <string>/var/log/app.log</string>
+    <key>StandardErrorPath</key>
+    <string>/var/log/app.err</string>
+    <key>RunAtLoad</key>
+    <true/>
+    <key>KeepAlive</key>
+    <true/>
+</dict>
+</plist>
+`
+	if plist != want {
+		t.Errorf("Unexpected plist:\n%s\nwant:\n%s", plist, want)
+	}
+
+	// Without options only the program and the load settings are rendered
+	plist, err = renderLaunchdPlist("com.example.app", []string{"ls"}, false, false, LaunchdPlistOptions{})
+	if err != nil {
+		t.Fatalf("renderLaunchdPlist failed: %v", err)
+	}
+	for _, unwanted := range []string{"EnvironmentVariables", "StandardOutPath", "StandardErrorPath", "KeepAlive"} {
+		if strings.Contains(plist, unwanted) {
+			t.Errorf("Expected no %s in plist:\n%s", unwanted, plist)
+		}
+	}
+	if !strings.Contains(plist, "<string>ls</string>") || !strings.Contains(plist, "<false/>") {
+		t.Errorf("Unexpected plist:\n%s", plist)
+	}
+
+	if _, err := renderLaunchdPlist("com.example.app", nil, true, false, LaunchdPlistOptions{}); err == nil {
+		t.Error("Expected an error for a plist without a program")
+	}
+}
+
 func TestServiceProvider_CreateSystemdService(t *testing.T) {
 	if runtime.GOOS != "linux" {
 		t.Skip("Skipping systemd test on non-Linux OS")
@@ -252,4 +357,390 @@ func TestServiceProvider_CreateWindowsService(t *testing.T) {
 			t.Error("Expected error when creating Windows service on non-Windows platform")
 		}
 	}
-}
\ No newline at end of file
+}
+func TestScCreateArgs(t *testing.T) {
+	args, err := scCreateArgs("app", "Example App", `C:\Program Files\App\app.exe --serve`, "manual")
+	if err != nil {
+		t.Fatalf("scCreateArgs failed: %v", err)
+	}
+	want := []string{"create", "app", "binPath=", `"C:\Program Files\App\app.exe" --serve`, "DisplayName=", "Example App", "start=", "demand"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Expected args %q, got %q", want, args)
+	}
+
+	if _, err := scCreateArgs("app", "", `C:\app.exe`, "sometimes"); err == nil {
+		t.Error("Expected an error for an invalid start type")
+	}
+	if _, err := scCreateArgs("app", "", " ", "auto"); err == nil {
+		t.Error("Expected an error for an empty command")
+	}
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{`C:\app\app.exe`, `C:\app\app.exe`},
+		{`C:\app\app.exe --serve`, `C:\app\app.exe --serve`},
+		{`C:\Program Files\App\app.exe`, `"C:\Program Files\App\app.exe"`},
+		{`C:\Program Files\App\App.EXE -v`, `"C:\Program Files\App\App.EXE" -v`},
+		{`"C:\Program Files\App\app.exe" --serve`, `"C:\Program Files\App\app.exe" --serve`},
+	}
+	for _, tt := range tests {
+		if got := windowsBinPath(tt.command); got != tt.want {
+			t.Errorf("windowsBinPath(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+
+	output := "[SC] QueryServiceConfig SUCCESS\r\n\r\nSERVICE_NAME: app\r\n        TYPE               : 10  WIN32_OWN_PROCESS\r\n        BINARY_PATH_NAME   : \"C:\\Program Files\\App\\app.exe\" --serve\r\n"
+	if got := parseScBinaryPath(output); got != `"C:\Program Files\App\app.exe" --serve` {
+		t.Errorf("Unexpected binary path %q", got)
+	}
+}
+
+func TestParseSystemdStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		wantSubState string
+		wantDetail   string
+	}{
+		{"running", `● nginx.service - A high performance web server and a reverse proxy server
+     Loaded: loaded (/lib/systemd/system/nginx.service; enabled; vendor preset: enabled)
+     Active: active (running) since Mon 2024-01-01 10:00:00 UTC; 2h 5min ago
+       Docs: man:nginx(8)
+   Main PID: 812 (nginx)
+      Tasks: 3 (limit: 4557)
+`, "running", "active (running)"},
+		{"failed", `× app.service - Example application
+     Loaded: loaded (/etc/systemd/system/app.service; enabled; vendor preset: enabled)
+     Active: failed (Result: exit-code) since Mon 2024-01-01 10:00:00 UTC; 5s ago
+    Process: 1234 ExecStart=/usr/local/bin/app (code=exited, status=1/FAILURE)
+   Main PID: 1234 (code=exited, status=1/FAILURE)
+`, "failed", "failed (Result: exit-code), main process code=exited, status=1/FAILURE"},
+		{"restarting", `● app.service - Example application
+     Loaded: loaded (/etc/systemd/system/app.service; enabled; vendor preset: enabled)
+     Active: activating (auto-restart) (Result: exit-code) since Mon 2024-01-01 10:00:00 UTC; 2s ago
+   Main PID: 1301 (code=exited, status=203/EXEC)
+`, "auto-restart", "activating (auto-restart) (Result: exit-code), main process code=exited, status=203/EXEC"},
+		{"inactive", `○ app.service - Example application
+     Loaded: loaded (/etc/systemd/system/app.service; disabled; vendor preset: enabled)
+     Active: inactive (dead)
+`, "dead", "inactive (dead)"},
+		{"unknown unit", "Unit missing.service could not be found.\n", "", ""},
+	}
+
+	for _, tt := range tests {
+		subState, detail := parseSystemdStatus(tt.output)
+		if subState != tt.wantSubState || detail != tt.wantDetail {
+			t.Errorf("%s: expected %q/%q, got %q/%q", tt.name, tt.wantSubState, tt.wantDetail, subState, detail)
+		}
+	}
+}
+
+func TestParseLaunchctlList(t *testing.T) {
+	output := `{
+	"LimitLoadToSessionType" = "System";
+	"Label" = "com.example.app";
+	"OnDemand" = false;
+	"LastExitStatus" = 256;
+	"PID" = 412;
+	"Program" = "/usr/local/bin/app";
+};
+`
+	subState, detail := parseLaunchctlList(output)
+	if subState != "running" || detail != "running (pid 412), last exit status 256" {
+		t.Errorf("Unexpected launchctl status %q/%q", subState, detail)
+	}
+
+	subState, detail = parseLaunchctlList("{\n\t\"Label\" = \"com.example.app\";\n\t\"LastExitStatus\" = 0;\n};\n")
+	if subState != "waiting" || detail != "not running" {
+		t.Errorf("Unexpected launchctl status %q/%q", subState, detail)
+	}
+}
+
+func TestServiceProvider_waitForService(t *testing.T) {
+	provider := NewServiceProvider()
+	ctx := context.Background()
+	attrs := map[string]interface{}{"name": "app", "wait": "1s", "wait_interval": "1ms"}
+
+	// The service comes up on the third poll
+	polls := 0
+	provider.serviceState = func(ctx context.Context, provider, name string) (ServiceState, error) {
+		polls++
+		return ServiceState{Running: polls >= 3}, nil
+	}
+	if err := provider.waitForService(ctx, "systemd", "app", attrs, true); err != nil {
+		t.Fatalf("Expected service to reach running, got error: %v", err)
+	}
+	if polls != 3 {
+		t.Errorf("Expected 3 polls, got %d", polls)
+	}
+
+	// Stopping waits for the process to exit
+	polls = 0
+	provider.serviceState = func(ctx context.Context, provider, name string) (ServiceState, error) {
+		polls++
+		return ServiceState{Running: polls < 2}, nil
+	}
+	if err := provider.waitForService(ctx, "systemd", "app", attrs, false); err != nil {
+		t.Fatalf("Expected service to stop, got error: %v", err)
+	}
+
+	// A service that crashes never reaches running
+	provider.serviceState = func(ctx context.Context, provider, name string) (ServiceState, error) {
+		return ServiceState{SubState: "failed", Detail: "failed (Result: exit-code)"}, nil
+	}
+	attrs["wait"] = "20ms"
+	err := provider.waitForService(ctx, "systemd", "app", attrs, true)
+	if err == nil || !strings.Contains(err.Error(), "failed (Result: exit-code)") {
+		t.Errorf("Expected error describing the failed service, got %v", err)
+	}
+
+	// A wait of zero skips the check
+	attrs["wait"] = int64(0)
+	if err := provider.waitForService(ctx, "systemd", "app", attrs, true); err != nil {
+		t.Errorf("Expected no error with wait disabled, got %v", err)
+	}
+}
+
+func TestServiceProvider_Masked(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		current      ServiceState
+		attrs        map[string]interface{}
+		wantStatus   string
+		wantCommands [][]string
+	}{
+		{"mask", ServiceState{}, map[string]interface{}{"masked": true}, "updated",
+			[][]string{{"systemctl", "mask", "app.service"}}},
+		{"unmask", ServiceState{Masked: true}, map[string]interface{}{"masked": false}, "updated",
+			[][]string{{"systemctl", "unmask", "app.service"}}},
+		{"already masked", ServiceState{Masked: true}, map[string]interface{}{"masked": true}, "unchanged", nil},
+	}
+
+	for _, tt := range tests {
+		recorder := &commandRecorder{}
+		provider := NewServiceProvider()
+		provider.runCommand = recorder.run
+		provider.serviceState = func(ctx context.Context, provider, name string) (ServiceState, error) {
+			return tt.current, nil
+		}
+
+		attrs := map[string]interface{}{"name": "app", "provider": "systemd"}
+		for key, value := range tt.attrs {
+			attrs[key] = value
+		}
+
+		plan, err := provider.Plan(ctx, nil, attrs)
+		if err != nil {
+			t.Fatalf("%s: Plan failed: %v", tt.name, err)
+		}
+		if (plan.Status == "planned") != (tt.wantStatus != "unchanged") {
+			t.Errorf("%s: unexpected plan status %s", tt.name, plan.Status)
+		}
+
+		result, err := provider.Apply(ctx, plan)
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, result.Status)
+		}
+		if !reflect.DeepEqual(recorder.commands, tt.wantCommands) {
+			t.Errorf("%s: expected commands %v, got %v", tt.name, tt.wantCommands, recorder.commands)
+		}
+	}
+
+	// Masking requires systemd and a stopped service
+	provider := NewServiceProvider()
+	invalid := []map[string]interface{}{
+		{"name": "app", "provider": "systemd", "masked": "yes"},
+		{"name": "app", "provider": "upstart", "masked": true},
+		{"name": "app", "provider": "systemd", "masked": true, "state": "running"},
+		{"name": "app", "provider": "systemd", "masked": true, "enabled": true},
+	}
+	for _, attrs := range invalid {
+		if err := provider.Validate(ctx, attrs); err == nil {
+			t.Errorf("Expected error for %v, got nil", attrs)
+		}
+	}
+	if err := provider.Validate(ctx, map[string]interface{}{"name": "app", "provider": "systemd", "masked": true, "state": "stopped"}); err != nil {
+		t.Errorf("Expected masked stopped service to be valid, got %v", err)
+	}
+}
+
+func TestServiceProvider_ReloadedOrRestarted(t *testing.T) {
+	ctx := context.Background()
+
+	// systemd reloads when the unit supports it; other init systems restart
+	tests := []struct {
+		provider     string
+		wantCommands [][]string
+	}{
+		{"systemd", [][]string{{"systemctl", "reload-or-restart", "app.service"}}},
+		{"upstart", [][]string{{"restart", "app"}}},
+		{"sysvinit", [][]string{{"service", "app", "restart"}}},
+	}
+
+	for _, tt := range tests {
+		recorder := &commandRecorder{}
+		provider := NewServiceProvider()
+		provider.runCommand = recorder.run
+		provider.serviceState = func(ctx context.Context, provider, name string) (ServiceState, error) {
+			return ServiceState{Running: true, Enabled: true}, nil
+		}
+
+		attrs := map[string]interface{}{"name": "app", "provider": tt.provider, "state": "reloaded_or_restarted", "enabled": true}
+		if err := provider.Validate(ctx, attrs); err != nil {
+			t.Fatalf("%s: Validate failed: %v", tt.provider, err)
+		}
+		plan, err := provider.Plan(ctx, nil, attrs)
+		if err != nil {
+			t.Fatalf("%s: Plan failed: %v", tt.provider, err)
+		}
+		if plan.Status != "planned" {
+			t.Errorf("%s: expected the service to be planned, got %s", tt.provider, plan.Status)
+		}
+
+		result, err := provider.Apply(ctx, plan)
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", tt.provider, err)
+		}
+		checkChanged(t, result)
+		if result.Status != "updated" {
+			t.Errorf("%s: expected status updated, got %s", tt.provider, result.Status)
+		}
+		if !reflect.DeepEqual(recorder.commands, tt.wantCommands) {
+			t.Errorf("%s: expected commands %v, got %v", tt.provider, tt.wantCommands, recorder.commands)
+		}
+
+		// Notification handlers can ask for the same
+		recorder.commands = nil
+		notifyAttrs := map[string]interface{}{"name": "app", "provider": tt.provider, "on_notify": "reload_or_restart"}
+		if err := provider.Validate(ctx, notifyAttrs); err != nil {
+			t.Fatalf("%s: Validate failed: %v", tt.provider, err)
+		}
+		if _, err := provider.Notify(ctx, &ResourceState{Type: "service", Name: "app", Attributes: notifyAttrs}); err != nil {
+			t.Fatalf("%s: Notify failed: %v", tt.provider, err)
+		}
+		if !reflect.DeepEqual(recorder.commands, tt.wantCommands) {
+			t.Errorf("%s: expected notify commands %v, got %v", tt.provider, tt.wantCommands, recorder.commands)
+		}
+	}
+}
+
+func TestServiceProvider_Plan_Diff(t *testing.T) {
+	provider := NewServiceProvider()
+	provider.serviceState = func(ctx context.Context, provider, name string) (ServiceState, error) {
+		return ServiceState{Running: true}, nil
+	}
+
+	plan, err := provider.Plan(context.Background(), nil, map[string]interface{}{
+		"name": "app", "provider": "systemd", "state": "running", "enabled": true,
+	})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	expected := map[string]AttributeDiff{"enabled": {Old: "false", New: "true"}}
+	if plan.Status != "planned" || !reflect.DeepEqual(plan.Diff, expected) {
+		t.Errorf("Expected planned diff %v, got %s %v", expected, plan.Status, plan.Diff)
+	}
+}
+
+func TestServiceProvider_Read(t *testing.T) {
+	provider := NewServiceProvider()
+	queries := 0
+	provider.serviceState = func(ctx context.Context, provider, name string) (ServiceState, error) {
+		queries++
+		return ServiceState{Running: true, Enabled: true, SubState: "running"}, nil
+	}
+
+	desired := map[string]interface{}{"name": "app", "provider": "systemd", "state": "running", "enabled": true}
+	live, err := provider.Read(context.Background(), desired)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if live["running"] != true || live["enabled"] != true || live["masked"] != false {
+		t.Errorf("Unexpected live state %v", live)
+	}
+
+	plan, err := provider.Plan(context.Background(), live, desired)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "unchanged" {
+		t.Errorf("Expected no changes when the live state matches, got %s %v", plan.Status, plan.Diff)
+	}
+
+	live["running"] = false
+	plan, err = provider.Plan(context.Background(), live, desired)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	expected := map[string]AttributeDiff{"state": {Old: "stopped", New: "running"}}
+	if plan.Status != "planned" || !reflect.DeepEqual(plan.Diff, expected) {
+		t.Errorf("Expected planned diff %v, got %s %v", expected, plan.Status, plan.Diff)
+	}
+
+	if queries != 1 {
+		t.Errorf("Expected Plan to use the live state instead of querying, got %d queries", queries)
+	}
+}
+
+func TestRenderSystemdUnit(t *testing.T) {
+	unit, err := renderSystemdUnit("Example application", "/usr/local/bin/app --serve", "multi-user.target", SystemdUnitOptions{
+		Environment:      map[string]string{"PORT": "8080", "GREETING": "hello world"},
+		After:            []string{"network-online.target", "postgresql.service"},
+		Requires:         []string{"postgresql.service"},
+		WorkingDirectory: "/srv/app",
+		Restart:          "always",
+	})
+	if err != nil {
+		t.Fatalf("renderSystemdUnit failed: %v", err)
+	}
+
+	want := `[Unit]
+Description=Example application
+After=network-online.target postgresql.service
+Requires=postgresql.service
+
+[Service]
+ExecStart=/usr/local/bin/app --serve
+WorkingDirectory=/srv/app
+Environment="GREETING=hello world"
+Environment="PORT=8080"
+Restart=always
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`
+	if unit != want {
+		t.Errorf("Unexpected unit file:\n%s\nwant:\n%s", unit, want)
+	}
+
+	// Without options the unit keeps the original layout
+	unit, err = renderSystemdUnit("Example application", "/usr/local/bin/app", "multi-user.target", SystemdUnitOptions{})
+	if err != nil {
+		t.Fatalf("renderSystemdUnit failed: %v", err)
+	}
+
+	want = `[Unit]
+Description=Example application
+
+[Service]
+ExecStart=/usr/local/bin/app
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`
+	if unit != want {
+		t.Errorf("Unexpected default unit file:\n%s\nwant:\n%s", unit, want)
+	}
+}
@@ -0,0 +1,202 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// sysctlKeyPattern matches kernel parameter names such as net.ipv4.ip_forward
+var sysctlKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_\-]+([./][A-Za-z0-9_\-]+)*$`)
+
+// SysctlProvider sets kernel parameters at runtime and optionally persists them
+type SysctlProvider struct {
+	platform   *PlatformChecker
+	runCommand CommandRunner
+	configDir  string
+}
+
+// NewSysctlProvider creates a new sysctl provider
+func NewSysctlProvider() *SysctlProvider {
+	return &SysctlProvider{
+		platform:   &PlatformChecker{},
+		runCommand: runCommand,
+		configDir:  "/etc/sysctl.d",
+	}
+}
+
+// Validate validates sysctl resource attributes
+func (p *SysctlProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("sysctl provider is only supported on Linux")
+	}
+
+	key, ok := attributes["key"]
+	if !ok {
+		return fmt.Errorf("sysctl resource requires 'key' attribute")
+	}
+	keyStr, ok := key.(string)
+	if !ok {
+		return fmt.Errorf("sysctl 'key' must be a string")
+	}
+	if !sysctlKeyPattern.MatchString(keyStr) {
+		return fmt.Errorf("sysctl 'key' is not a valid parameter name: %q", keyStr)
+	}
+
+	if _, ok := attributes["value"]; !ok {
+		return fmt.Errorf("sysctl resource requires 'value' attribute")
+	}
+	if _, err := sysctlValue(attributes); err != nil {
+		return err
+	}
+
+	if persist, ok := attributes["persist"]; ok {
+		if _, ok := persist.(bool); !ok {
+			return fmt.Errorf("sysctl 'persist' must be a boolean")
+		}
+	}
+
+	return nil
+}
+
+// sysctlValue returns the desired value as sysctl prints it, with runs of
+// whitespace in multi-value parameters collapsed to single spaces
+func sysctlValue(attributes map[string]interface{}) (string, error) {
+	switch v := attributes["value"].(type) {
+	case string:
+		if strings.ContainsAny(v, "\r\n") {
+			return "", fmt.Errorf("sysctl 'value' must be a single line")
+		}
+		return strings.Join(strings.Fields(v), " "), nil
+	case int64:
+		return fmt.Sprintf("%d", v), nil
+	default:
+		return "", fmt.Errorf("sysctl 'value' must be a string or number")
+	}
+}
+
+// sysctlConfigPath returns the drop-in file that persists a parameter
+func (p *SysctlProvider) sysctlConfigPath(key string) string {
+	return filepath.Join(p.configDir, "99-zero-"+strings.ReplaceAll(key, "/", ".")+".conf")
+}
+
+// sysctlDrift returns the runtime value and the attributes that differ from
+// the desired state: "value" for the running kernel and "persist" for the
+// drop-in file
+func (p *SysctlProvider) sysctlDrift(ctx context.Context, attributes map[string]interface{}) (string, []string, error) {
+	key := attributes["key"].(string)
+	value, err := sysctlValue(attributes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	output, err := p.runCommand(ctx, "sysctl", "-n", key)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read sysctl %s: %v: %s", key, err, strings.TrimSpace(string(output)))
+	}
+	current := strings.Join(strings.Fields(string(output)), " ")
+
+	var changes []string
+	if current != value {
+		changes = append(changes, "value")
+	}
+
+	if persist, _ := attributes["persist"].(bool); persist {
+		data, err := ioutil.ReadFile(p.sysctlConfigPath(key))
+		if err != nil && !os.IsNotExist(err) {
+			return "", nil, err
+		}
+		if string(data) != sysctlConfigLine(key, value) {
+			changes = append(changes, "persist")
+		}
+	}
+
+	return current, changes, nil
+}
+
+// sysctlConfigLine renders the drop-in file content for a parameter
+func sysctlConfigLine(key, value string) string {
+	return key + " = " + value + "\n"
+}
+
+// Plan determines whether the parameter needs to be set or persisted
+func (p *SysctlProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("sysctl provider is only supported on Linux")
+	}
+
+	key := desired["key"].(string)
+
+	result := &ResourceState{
+		Type:       "sysctl",
+		Name:       key,
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	currentValue, changes, err := p.sysctlDrift(ctx, desired)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		result.Status = "planned"
+		result.Changes = changes
+		value, _ := sysctlValue(desired)
+		result.Details = fmt.Sprintf("%s %s -> %s", key, currentValue, value)
+	}
+
+	return result, nil
+}
+
+// Apply sets the parameter with sysctl -w and writes the drop-in file when persisting
+func (p *SysctlProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("sysctl provider is only supported on Linux")
+	}
+
+	key := state.Attributes["key"].(string)
+
+	result := &ResourceState{
+		Type:       "sysctl",
+		Name:       key,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	_, changes, err := p.sysctlDrift(ctx, state.Attributes)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+	if len(changes) == 0 {
+		return result, nil
+	}
+
+	value, _ := sysctlValue(state.Attributes)
+	for _, change := range changes {
+		switch change {
+		case "value":
+			err = runCommands(ctx, p.runCommand, [][]string{{"sysctl", "-w", key + "=" + value}})
+		case "persist":
+			if err = os.MkdirAll(p.configDir, 0755); err == nil {
+				err = ioutil.WriteFile(p.sysctlConfigPath(key), []byte(sysctlConfigLine(key, value)), 0644)
+			}
+		}
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+	}
+
+	result.Status = "updated"
+	result.Changed = true
+	result.Changes = changes
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package providers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func newTestSysctlProvider(t *testing.T) (*SysctlProvider, *commandRecorder) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sysctl provider is only supported on Linux")
+	}
+
+	tempDir, err := ioutil.TempDir("", "sysctl-provider-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	recorder := &commandRecorder{output: map[string]string{}}
+	provider := NewSysctlProvider()
+	provider.runCommand = recorder.run
+	provider.configDir = filepath.Join(tempDir, "sysctl.d")
+	return provider, recorder
+}
+
+func TestSysctlProvider_Validate(t *testing.T) {
+	provider, _ := newTestSysctlProvider(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"number", map[string]interface{}{"key": "net.ipv4.ip_forward", "value": int64(1), "persist": true}, false},
+		{"multi value", map[string]interface{}{"key": "net.ipv4.tcp_rmem", "value": "4096 87380 6291456"}, false},
+		{"slash separated", map[string]interface{}{"key": "net/ipv4/ip_forward", "value": "1"}, false},
+		{"missing key", map[string]interface{}{"value": "1"}, true},
+		{"invalid key", map[string]interface{}{"key": "net.ipv4 ip_forward", "value": "1"}, true},
+		{"missing value", map[string]interface{}{"key": "net.ipv4.ip_forward"}, true},
+		{"invalid value", map[string]interface{}{"key": "net.ipv4.ip_forward", "value": true}, true},
+		{"invalid persist", map[string]interface{}{"key": "net.ipv4.ip_forward", "value": "1", "persist": "yes"}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestSysctlProvider_Apply(t *testing.T) {
+	provider, recorder := newTestSysctlProvider(t)
+	ctx := context.Background()
+	recorder.output["sysctl -n net.ipv4.ip_forward"] = "0\n"
+
+	attrs := map[string]interface{}{"key": "net.ipv4.ip_forward", "value": int64(1), "persist": true}
+	plan, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Status != "planned" || !reflect.DeepEqual(plan.Changes, []string{"value", "persist"}) {
+		t.Errorf("Expected planned value and persist, got %s %v", plan.Status, plan.Changes)
+	}
+
+	recorder.commands = nil
+	result, err := provider.Apply(ctx, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "updated" {
+		t.Errorf("Expected updated status, got %s", result.Status)
+	}
+
+	wantCommands := [][]string{
+		{"sysctl", "-n", "net.ipv4.ip_forward"},
+		{"sysctl", "-w", "net.ipv4.ip_forward=1"},
+	}
+	if !reflect.DeepEqual(recorder.commands, wantCommands) {
+		t.Errorf("Expected commands %v, got %v", wantCommands, recorder.commands)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(provider.configDir, "99-zero-net.ipv4.ip_forward.conf"))
+	if err != nil || string(content) != "net.ipv4.ip_forward = 1\n" {
+		t.Errorf("Expected drop-in file to be written, got %q (%v)", content, err)
+	}
+
+	// Once the kernel reports the value, nothing is left to do
+	recorder.output["sysctl -n net.ipv4.ip_forward"] = "1\n"
+	plan, err = provider.Plan(ctx, nil, attrs)
+	if err != nil || plan.Status != "unchanged" {
+		t.Errorf("Expected unchanged plan, got %v (%v)", plan, err)
+	}
+
+	// Multi-value parameters compare with whitespace collapsed
+	recorder.output["sysctl -n net.ipv4.tcp_rmem"] = "4096\t87380\t6291456\n"
+	plan, err = provider.Plan(ctx, nil, map[string]interface{}{"key": "net.ipv4.tcp_rmem", "value": "4096 87380  6291456"})
+	if err != nil || plan.Status != "unchanged" {
+		t.Errorf("Expected unchanged plan for multi-value parameter, got %v (%v)", plan, err)
+	}
+}
@@ -0,0 +1,168 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+)
+
+// TemplateFileProvider renders a Go text/template file and manages the
+// result at path the way a file resource manages its content
+type TemplateFileProvider struct {
+	file *FileProvider
+}
+
+// NewTemplateFileProvider creates a new template file provider
+func NewTemplateFileProvider() *TemplateFileProvider {
+	return &TemplateFileProvider{
+		file: NewFileProvider(),
+	}
+}
+
+// Validate validates template_file resource attributes
+func (p *TemplateFileProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	for _, key := range []string{"path", "source"} {
+		value, ok := attributes[key]
+		if !ok {
+			return fmt.Errorf("template_file resource requires '%s' attribute", key)
+		}
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("template_file '%s' must be a string", key)
+		}
+	}
+
+	if vars, ok := attributes["vars"]; ok {
+		if _, ok := vars.(map[string]interface{}); !ok {
+			return fmt.Errorf("template_file 'vars' must be a map")
+		}
+	}
+
+	if _, ok := attributes["content"]; ok {
+		return fmt.Errorf("template_file resource cannot have a 'content' attribute")
+	}
+
+	if state := presenceState(attributes); state != "present" && state != "absent" {
+		return fmt.Errorf("template_file 'state' must be one of: present, absent")
+	}
+
+	// The remaining attributes, such as mode and owner, are those of a file
+	fileAttributes := p.fileAttributes(attributes, "")
+	return p.file.Validate(ctx, fileAttributes)
+}
+
+// renderTemplateFile executes the source template with vars as its data. A
+// template that refers to a missing variable is an error.
+func renderTemplateFile(attributes map[string]interface{}) (string, error) {
+	source := attributes["source"].(string)
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template %s: %v", source, err)
+	}
+
+	tmpl, err := template.New(filepath.Base(source)).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %v", source, err)
+	}
+
+	vars, _ := attributes["vars"].(map[string]interface{})
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %v", source, err)
+	}
+	return out.String(), nil
+}
+
+// fileAttributes returns the attributes of the file resource that manages the
+// rendered content
+func (p *TemplateFileProvider) fileAttributes(attributes map[string]interface{}, content string) map[string]interface{} {
+	result := make(map[string]interface{}, len(attributes))
+	for key, value := range attributes {
+		if key != "source" && key != "vars" {
+			result[key] = value
+		}
+	}
+	if presenceState(attributes) == "present" {
+		result["content"] = content
+	}
+	return result
+}
+
+// renderedAttributes renders the template, unless the file is to be removed,
+// and returns the file attributes holding the result
+func (p *TemplateFileProvider) renderedAttributes(attributes map[string]interface{}) (map[string]interface{}, error) {
+	content := ""
+	if presenceState(attributes) == "present" {
+		rendered, err := renderTemplateFile(attributes)
+		if err != nil {
+			return nil, err
+		}
+		content = rendered
+	}
+	return p.fileAttributes(attributes, content), nil
+}
+
+// Plan renders the template and compares the output with the file at path
+func (p *TemplateFileProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	fileAttributes, err := p.renderedAttributes(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := p.file.Plan(ctx, current, fileAttributes)
+	if err != nil {
+		return nil, err
+	}
+	result.Type = "template_file"
+	result.Attributes = desired
+	return result, nil
+}
+
+// DesiredAttributes translates an intent into template_file attributes
+func (p *TemplateFileProvider) DesiredAttributes(intent Intent, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	return intentAttributes(intent, attributes, map[string]interface{}{"state": "absent"})
+}
+
+// SupportsDryRun reports that Apply honors IsDryRun. Rendering the template
+// changes nothing, and the file is left to the file provider's dry run.
+func (p *TemplateFileProvider) SupportsDryRun() bool {
+	return true
+}
+
+// Apply renders the template and writes the output to path
+func (p *TemplateFileProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	result := &ResourceState{
+		Type:       "template_file",
+		Name:       state.Name,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	fileAttributes, err := p.renderedAttributes(state.Attributes)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	applied, err := p.file.Apply(ctx, &ResourceState{Type: "file", Name: state.Name, Attributes: fileAttributes})
+	if applied != nil {
+		result.Status = applied.Status
+		result.Changed = applied.Changed
+		result.Output = applied.Output
+		result.Outputs = applied.Outputs
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package providers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateFileProvider_Validate(t *testing.T) {
+	provider := NewTemplateFileProvider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"valid", map[string]interface{}{"path": "/etc/app.conf", "source": "app.tmpl", "vars": map[string]interface{}{"port": "80"}, "mode": "0640"}, false},
+		{"absent", map[string]interface{}{"path": "/etc/app.conf", "source": "app.tmpl", "state": "absent"}, false},
+		{"missing source", map[string]interface{}{"path": "/etc/app.conf"}, true},
+		{"missing path", map[string]interface{}{"source": "app.tmpl"}, true},
+		{"vars not a map", map[string]interface{}{"path": "/etc/app.conf", "source": "app.tmpl", "vars": "port=80"}, true},
+		{"content", map[string]interface{}{"path": "/etc/app.conf", "source": "app.tmpl", "content": "x"}, true},
+		{"directory state", map[string]interface{}{"path": "/etc/app.conf", "source": "app.tmpl", "state": "directory"}, true},
+		{"invalid mode", map[string]interface{}{"path": "/etc/app.conf", "source": "app.tmpl", "mode": "rw"}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestTemplateFileProvider_RenderRange(t *testing.T) {
+	tempDir := t.TempDir()
+	source := filepath.Join(tempDir, "upstream.tmpl")
+	template := "upstream {{ .name }} {\n{{- range .servers }}\n  server {{ . }};\n{{- end }}\n}\n"
+	if err := os.WriteFile(source, []byte(template), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	provider := NewTemplateFileProvider()
+	ctx := context.Background()
+	path := filepath.Join(tempDir, "upstream.conf")
+	attrs := map[string]interface{}{
+		"path":   path,
+		"source": source,
+		"vars": map[string]interface{}{
+			"name":    "app",
+			"servers": []interface{}{"10.0.0.1:8080", "10.0.0.2:8080"},
+		},
+	}
+
+	planned, err := provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if planned.Status != "planned" || planned.Type != "template_file" {
+		t.Errorf("Expected a planned template_file, got %s %s", planned.Type, planned.Status)
+	}
+
+	result, err := provider.Apply(ctx, planned)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	checkChanged(t, result)
+	if result.Status != "created" {
+		t.Errorf("Expected status created, got %s", result.Status)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read rendered file: %v", err)
+	}
+	want := "upstream app {\n  server 10.0.0.1:8080;\n  server 10.0.0.2:8080;\n}\n"
+	if string(data) != want {
+		t.Errorf("Expected rendered content %q, got %q", want, string(data))
+	}
+
+	// The rendered file matches, so nothing is planned
+	planned, err = provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if planned.Status != "unchanged" {
+		t.Errorf("Expected unchanged after apply, got %s (%v)", planned.Status, planned.Changes)
+	}
+
+	// A change in vars changes the rendered output
+	attrs["vars"].(map[string]interface{})["name"] = "api"
+	planned, err = provider.Plan(ctx, nil, attrs)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if planned.Status != "planned" || !strings.Contains(planned.Details, "+upstream api {") {
+		t.Errorf("Expected a content change, got %s %q", planned.Status, planned.Details)
+	}
+
+	// Missing variables are an error
+	delete(attrs["vars"].(map[string]interface{}), "servers")
+	if _, err := provider.Plan(ctx, nil, attrs); err == nil {
+		t.Error("Expected error for a missing variable")
+	}
+}
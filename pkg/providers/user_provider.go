@@ -0,0 +1,392 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// userEntry describes an existing local account
+type userEntry struct {
+	UID    string
+	Home   string
+	Shell  string
+	Groups []string // Supplementary groups, excluding the primary group
+}
+
+// UserProvider implements local user account management
+type UserProvider struct {
+	platform   *PlatformChecker
+	runCommand CommandRunner
+	lookupUser func(ctx context.Context, name string) (*userEntry, error)
+}
+
+// NewUserProvider creates a new user provider
+func NewUserProvider() *UserProvider {
+	p := &UserProvider{
+		platform:   &PlatformChecker{},
+		runCommand: runCommand,
+	}
+	p.lookupUser = p.readUser
+	return p
+}
+
+// Validate validates user resource attributes
+func (p *UserProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("user provider is not supported on Windows")
+	}
+
+	name, ok := attributes["name"]
+	if !ok {
+		return fmt.Errorf("user resource requires 'name' attribute")
+	}
+	if _, ok := name.(string); !ok {
+		return fmt.Errorf("user 'name' must be a string")
+	}
+
+	if state, ok := attributes["state"]; ok {
+		stateStr, ok := state.(string)
+		if !ok || (stateStr != "present" && stateStr != "absent") {
+			return fmt.Errorf("user 'state' must be one of: present, absent")
+		}
+	}
+
+	if uid, ok, err := intAttribute(attributes, "uid"); err != nil {
+		return fmt.Errorf("user %v", err)
+	} else if ok && uid < 0 {
+		return fmt.Errorf("user 'uid' must not be negative")
+	}
+
+	if _, _, err := stringSliceAttribute(attributes, "groups"); err != nil {
+		return fmt.Errorf("user %v", err)
+	}
+
+	for _, key := range []string{"shell", "home"} {
+		if value, ok := attributes[key]; ok {
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("user '%s' must be a string", key)
+			}
+		}
+	}
+
+	if system, ok := attributes["system"]; ok {
+		if _, ok := system.(bool); !ok {
+			return fmt.Errorf("user 'system' must be a boolean")
+		}
+	}
+
+	return nil
+}
+
+// Plan determines what changes would be made to a user account
+func (p *UserProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	name := desired["name"].(string)
+
+	result := &ResourceState{
+		Type:       "user",
+		Name:       name,
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	entry, err := p.lookupUser(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if presenceState(desired) == "absent" {
+		if entry != nil {
+			result.Status = "planned"
+			result.Changes = []string{"state"}
+		}
+		return result, nil
+	}
+
+	if entry == nil {
+		result.Status = "planned"
+		result.Changes = []string{"state"}
+		return result, nil
+	}
+
+	if changes := userChanges(entry, desired); len(changes) > 0 {
+		result.Status = "planned"
+		result.Changes = changes
+	}
+
+	return result, nil
+}
+
+// DesiredAttributes translates an intent into user attributes
+func (p *UserProvider) DesiredAttributes(intent Intent, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	return intentAttributes(intent, attributes, map[string]interface{}{"state": "absent"})
+}
+
+// Apply creates, modifies or deletes the user account
+func (p *UserProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	name := state.Attributes["name"].(string)
+
+	result := &ResourceState{
+		Type:       "user",
+		Name:       name,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	if runtime.GOOS == "windows" {
+		err := fmt.Errorf("user provider is not supported on Windows")
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	entry, err := p.lookupUser(ctx, name)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	if presenceState(state.Attributes) == "absent" {
+		if entry == nil {
+			return result, nil
+		}
+		if err := p.deleteUser(ctx, name); err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+		result.Status = "deleted"
+		result.Changed = true
+		return result, nil
+	}
+
+	if entry == nil {
+		if err := p.createUser(ctx, name, state.Attributes); err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+		result.Status = "created"
+		result.Changed = true
+		result.Outputs = p.userOutputs(ctx, name)
+		return result, nil
+	}
+
+	changes := userChanges(entry, state.Attributes)
+	if len(changes) == 0 {
+		result.Outputs = map[string]string{"uid": entry.UID}
+		return result, nil
+	}
+
+	if err := p.modifyUser(ctx, name, entry, changes, state.Attributes); err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+	result.Status = "updated"
+	result.Changed = true
+	result.Changes = changes
+	result.Outputs = p.userOutputs(ctx, name)
+
+	return result, nil
+}
+
+// userOutputs reads back the uid of an applied user so that later resources can
+// refer to it. A user that cannot be read has no outputs.
+func (p *UserProvider) userOutputs(ctx context.Context, name string) map[string]string {
+	entry, err := p.lookupUser(ctx, name)
+	if err != nil || entry == nil || entry.UID == "" {
+		return nil
+	}
+	return map[string]string{"uid": entry.UID}
+}
+
+// userChanges lists the attributes whose desired value differs from the entry
+func userChanges(entry *userEntry, desired map[string]interface{}) []string {
+	var changes []string
+
+	if uid, ok, _ := intAttribute(desired, "uid"); ok && strconv.FormatInt(uid, 10) != entry.UID {
+		changes = append(changes, "uid")
+	}
+	if home, ok := desired["home"].(string); ok && home != entry.Home {
+		changes = append(changes, "home")
+	}
+	if shell, ok := desired["shell"].(string); ok && shell != entry.Shell {
+		changes = append(changes, "shell")
+	}
+	if groups, ok, _ := stringSliceAttribute(desired, "groups"); ok && !sameStringSet(groups, entry.Groups) {
+		changes = append(changes, "groups")
+	}
+
+	return changes
+}
+
+// readUser looks up the current account details, returning nil if the user does not exist
+func (p *UserProvider) readUser(ctx context.Context, name string) (*userEntry, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		if _, ok := err.(user.UnknownUserError); ok {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error looking up user %s: %v", name, err)
+	}
+
+	entry := &userEntry{
+		UID:  u.Uid,
+		Home: u.HomeDir,
+	}
+
+	gids, err := u.GroupIds()
+	if err != nil {
+		return nil, fmt.Errorf("error looking up groups for user %s: %v", name, err)
+	}
+	for _, gid := range gids {
+		if gid == u.Gid {
+			continue
+		}
+		group, err := user.LookupGroupId(gid)
+		if err != nil {
+			continue
+		}
+		entry.Groups = append(entry.Groups, group.Name)
+	}
+
+	entry.Shell = p.readShell(ctx, name)
+
+	return entry, nil
+}
+
+// readShell returns the login shell of a user, or "" if it cannot be determined
+func (p *UserProvider) readShell(ctx context.Context, name string) string {
+	if runtime.GOOS == "darwin" {
+		output, err := p.runCommand(ctx, "dscl", ".", "-read", "/Users/"+name, "UserShell")
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(output)), "UserShell:"))
+	}
+
+	output, err := p.runCommand(ctx, "getent", "passwd", name)
+	if err != nil {
+		return ""
+	}
+	fields := strings.Split(strings.TrimSpace(string(output)), ":")
+	if len(fields) < 7 {
+		return ""
+	}
+	return fields[6]
+}
+
+// createUser adds a new user account
+func (p *UserProvider) createUser(ctx context.Context, name string, attributes map[string]interface{}) error {
+	uid, hasUID, _ := intAttribute(attributes, "uid")
+	groups, _, _ := stringSliceAttribute(attributes, "groups")
+	shell, _ := attributes["shell"].(string)
+	home, _ := attributes["home"].(string)
+	system, _ := attributes["system"].(bool)
+
+	if runtime.GOOS == "darwin" {
+		if !hasUID {
+			return fmt.Errorf("user 'uid' is required to create users on macOS")
+		}
+		path := "/Users/" + name
+		commands := [][]string{
+			{"dscl", ".", "-create", path},
+			{"dscl", ".", "-create", path, "UniqueID", strconv.FormatInt(uid, 10)},
+			{"dscl", ".", "-create", path, "PrimaryGroupID", "20"},
+		}
+		if shell != "" {
+			commands = append(commands, []string{"dscl", ".", "-create", path, "UserShell", shell})
+		}
+		if home != "" {
+			commands = append(commands, []string{"dscl", ".", "-create", path, "NFSHomeDirectory", home})
+		}
+		for _, group := range groups {
+			commands = append(commands, []string{"dseditgroup", "-o", "edit", "-a", name, "-t", "user", group})
+		}
+		return runCommands(ctx, p.runCommand, commands)
+	}
+
+	args := []string{}
+	if hasUID {
+		args = append(args, "-u", strconv.FormatInt(uid, 10))
+	}
+	if len(groups) > 0 {
+		args = append(args, "-G", strings.Join(groups, ","))
+	}
+	if shell != "" {
+		args = append(args, "-s", shell)
+	}
+	if home != "" {
+		args = append(args, "-d", home)
+	}
+	if system {
+		args = append(args, "-r")
+	} else {
+		args = append(args, "-m")
+	}
+	args = append(args, name)
+
+	return runCommands(ctx, p.runCommand, [][]string{append([]string{"useradd"}, args...)})
+}
+
+// modifyUser updates only the drifted attributes of an existing account
+func (p *UserProvider) modifyUser(ctx context.Context, name string, entry *userEntry, changes []string, attributes map[string]interface{}) error {
+	uid, _, _ := intAttribute(attributes, "uid")
+	groups, _, _ := stringSliceAttribute(attributes, "groups")
+	shell, _ := attributes["shell"].(string)
+	home, _ := attributes["home"].(string)
+
+	if runtime.GOOS == "darwin" {
+		path := "/Users/" + name
+		var commands [][]string
+		for _, change := range changes {
+			switch change {
+			case "uid":
+				commands = append(commands, []string{"dscl", ".", "-create", path, "UniqueID", strconv.FormatInt(uid, 10)})
+			case "shell":
+				commands = append(commands, []string{"dscl", ".", "-create", path, "UserShell", shell})
+			case "home":
+				commands = append(commands, []string{"dscl", ".", "-create", path, "NFSHomeDirectory", home})
+			case "groups":
+				add, remove := diffStringSets(entry.Groups, groups)
+				for _, group := range add {
+					commands = append(commands, []string{"dseditgroup", "-o", "edit", "-a", name, "-t", "user", group})
+				}
+				for _, group := range remove {
+					commands = append(commands, []string{"dseditgroup", "-o", "edit", "-d", name, "-t", "user", group})
+				}
+			}
+		}
+		return runCommands(ctx, p.runCommand, commands)
+	}
+
+	args := []string{}
+	for _, change := range changes {
+		switch change {
+		case "uid":
+			args = append(args, "-u", strconv.FormatInt(uid, 10))
+		case "shell":
+			args = append(args, "-s", shell)
+		case "home":
+			args = append(args, "-d", home, "-m")
+		case "groups":
+			args = append(args, "-G", strings.Join(groups, ","))
+		}
+	}
+	args = append(args, name)
+
+	return runCommands(ctx, p.runCommand, [][]string{append([]string{"usermod"}, args...)})
+}
+
+// deleteUser removes a user account
+func (p *UserProvider) deleteUser(ctx context.Context, name string) error {
+	if runtime.GOOS == "darwin" {
+		return runCommands(ctx, p.runCommand, [][]string{{"dscl", ".", "-delete", "/Users/" + name}})
+	}
+	return runCommands(ctx, p.runCommand, [][]string{{"userdel", name}})
+}
@@ -0,0 +1,188 @@
+package providers
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func newTestUserProvider(entry *userEntry) (*UserProvider, *commandRecorder) {
+	recorder := &commandRecorder{}
+	provider := NewUserProvider()
+	provider.runCommand = recorder.run
+	provider.lookupUser = func(ctx context.Context, name string) (*userEntry, error) {
+		return entry, nil
+	}
+	return provider, recorder
+}
+
+func TestUserProvider_Validate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("user provider is not supported on Windows")
+	}
+
+	provider := NewUserProvider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"minimal", map[string]interface{}{"name": "deploy"}, false},
+		{"full", map[string]interface{}{
+			"name":   "deploy",
+			"uid":    int64(1500),
+			"groups": []string{"wheel", "docker"},
+			"shell":  "/bin/bash",
+			"home":   "/home/deploy",
+			"system": false,
+			"state":  "present",
+		}, false},
+		{"missing name", map[string]interface{}{"uid": int64(1500)}, true},
+		{"invalid name", map[string]interface{}{"name": 123}, true},
+		{"invalid state", map[string]interface{}{"name": "deploy", "state": "gone"}, true},
+		{"invalid uid", map[string]interface{}{"name": "deploy", "uid": "abc"}, true},
+		{"negative uid", map[string]interface{}{"name": "deploy", "uid": int64(-1)}, true},
+		{"invalid groups", map[string]interface{}{"name": "deploy", "groups": []interface{}{"wheel", int64(1)}}, true},
+		{"invalid shell", map[string]interface{}{"name": "deploy", "shell": true}, true},
+		{"invalid system", map[string]interface{}{"name": "deploy", "system": "yes"}, true},
+	}
+
+	for _, tt := range tests {
+		err := provider.Validate(ctx, tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestUserProvider_Plan(t *testing.T) {
+	ctx := context.Background()
+	existing := &userEntry{
+		UID:    "1500",
+		Home:   "/home/deploy",
+		Shell:  "/bin/sh",
+		Groups: []string{"docker", "wheel"},
+	}
+
+	tests := []struct {
+		name        string
+		entry       *userEntry
+		desired     map[string]interface{}
+		wantStatus  string
+		wantChanges []string
+	}{
+		{"create", nil, map[string]interface{}{"name": "deploy"}, "planned", []string{"state"}},
+		{"in sync", existing, map[string]interface{}{
+			"name":   "deploy",
+			"uid":    int64(1500),
+			"groups": []string{"wheel", "docker"},
+			"shell":  "/bin/sh",
+		}, "unchanged", nil},
+		{"drift", existing, map[string]interface{}{
+			"name":   "deploy",
+			"uid":    int64(1500),
+			"groups": []string{"wheel"},
+			"shell":  "/bin/bash",
+		}, "planned", []string{"shell", "groups"}},
+		{"remove", existing, map[string]interface{}{"name": "deploy", "state": "absent"}, "planned", []string{"state"}},
+		{"already absent", nil, map[string]interface{}{"name": "deploy", "state": "absent"}, "unchanged", nil},
+	}
+
+	for _, tt := range tests {
+		provider, _ := newTestUserProvider(tt.entry)
+		result, err := provider.Plan(ctx, nil, tt.desired)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, result.Status)
+		}
+		if !reflect.DeepEqual(result.Changes, tt.wantChanges) {
+			t.Errorf("%s: expected changes %v, got %v", tt.name, tt.wantChanges, result.Changes)
+		}
+	}
+}
+
+func TestUserProvider_Apply(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("command expectations are specific to Linux")
+	}
+
+	ctx := context.Background()
+	existing := &userEntry{
+		UID:    "1500",
+		Home:   "/home/deploy",
+		Shell:  "/bin/sh",
+		Groups: []string{"docker"},
+	}
+
+	tests := []struct {
+		name         string
+		entry        *userEntry
+		attrs        map[string]interface{}
+		wantStatus   string
+		wantCommands [][]string
+	}{
+		{"create", nil, map[string]interface{}{
+			"name":   "deploy",
+			"uid":    int64(1500),
+			"groups": []string{"docker", "wheel"},
+			"shell":  "/bin/bash",
+		}, "created", [][]string{{"useradd", "-u", "1500", "-G", "docker,wheel", "-s", "/bin/bash", "-m", "deploy"}}},
+		{"create system", nil, map[string]interface{}{
+			"name":   "svc",
+			"system": true,
+		}, "created", [][]string{{"useradd", "-r", "svc"}}},
+		{"modify drifted only", existing, map[string]interface{}{
+			"name":  "deploy",
+			"uid":   int64(1500),
+			"shell": "/bin/bash",
+		}, "updated", [][]string{{"usermod", "-s", "/bin/bash", "deploy"}}},
+		{"unchanged", existing, map[string]interface{}{
+			"name":  "deploy",
+			"shell": "/bin/sh",
+		}, "unchanged", nil},
+		{"delete", existing, map[string]interface{}{
+			"name":  "deploy",
+			"state": "absent",
+		}, "deleted", [][]string{{"userdel", "deploy"}}},
+	}
+
+	for _, tt := range tests {
+		provider, recorder := newTestUserProvider(tt.entry)
+		result, err := provider.Apply(ctx, &ResourceState{Type: "user", Name: tt.attrs["name"].(string), Attributes: tt.attrs})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, result.Status)
+		}
+		if !reflect.DeepEqual(recorder.commands, tt.wantCommands) {
+			t.Errorf("%s: expected commands %v, got %v", tt.name, tt.wantCommands, recorder.commands)
+		}
+	}
+}
+
+func TestUserProvider_ApplyCommandFailure(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("command expectations are specific to Linux")
+	}
+
+	provider, recorder := newTestUserProvider(nil)
+	recorder.fail = map[string]error{"useradd": context.DeadlineExceeded}
+
+	attrs := map[string]interface{}{"name": "deploy"}
+	result, err := provider.Apply(context.Background(), &ResourceState{Type: "user", Name: "deploy", Attributes: attrs})
+	if err == nil {
+		t.Fatal("Expected error when useradd fails, got nil")
+	}
+	checkChanged(t, result)
+	if result.Status != "failed" {
+		t.Errorf("Expected status failed, got %s", result.Status)
+	}
+}
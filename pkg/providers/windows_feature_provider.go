@@ -10,14 +10,17 @@ import (
 
 // WindowsFeatureProvider implements Windows feature management
 type WindowsFeatureProvider struct {
-	platform *PlatformChecker
+	platform         *PlatformChecker
+	featureInstalled func(name string) (bool, error)
 }
 
 // NewWindowsFeatureProvider creates a new Windows feature provider
 func NewWindowsFeatureProvider() *WindowsFeatureProvider {
-	return &WindowsFeatureProvider{
+	p := &WindowsFeatureProvider{
 		platform: &PlatformChecker{},
 	}
+	p.featureInstalled = p.isFeatureInstalled
+	return p
 }
 
 // Validate validates Windows feature resource attributes
@@ -71,8 +74,28 @@ func (p *WindowsFeatureProvider) isFeatureInstalledDism(name string) (bool, erro
 		return false, fmt.Errorf("error checking feature with DISM: %v", err)
 	}
 
-	outputStr := string(output)
-	return strings.Contains(outputStr, "State : Enabled"), nil
+	return parseDismFeatureState(string(output))
+}
+
+// parseDismFeatureState reads whether a feature is installed from the output
+// of dism /Get-FeatureInfo. Pending states and output without a state are
+// errors, since the feature can't be said to be either installed or removed.
+func parseDismFeatureState(output string) (bool, error) {
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(key) != "State" {
+			continue
+		}
+		switch value = strings.TrimSpace(value); value {
+		case "Enabled":
+			return true, nil
+		case "Disabled", "Disabled with Payload Removed":
+			return false, nil
+		default:
+			return false, fmt.Errorf("feature is in state %q", value)
+		}
+	}
+	return false, fmt.Errorf("DISM did not report the feature's state")
 }
 
 // isFeatureInstalledPowerShell checks if a feature is installed using PowerShell
@@ -83,8 +106,14 @@ func (p *WindowsFeatureProvider) isFeatureInstalledPowerShell(name string) (bool
 		return false, fmt.Errorf("error checking feature with PowerShell: %v", err)
 	}
 
-	outputStr := strings.TrimSpace(string(output))
-	return outputStr == "True", nil
+	switch outputStr := strings.TrimSpace(string(output)); outputStr {
+	case "True":
+		return true, nil
+	case "False":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected output checking feature with PowerShell: %q", outputStr)
+	}
 }
 
 // isDismAvailable checks if DISM is available
@@ -129,26 +158,34 @@ func (p *WindowsFeatureProvider) Plan(ctx context.Context, current, desired map[
 		Type:       "windows_feature",
 		Name:       name,
 		Attributes: desired,
-		Status:     "planned",
+		Status:     "unchanged",
 	}
 
-	// Check if the feature is installed
-	installed, err := p.isFeatureInstalled(name)
+	// Check if the feature is installed. A feature whose state can't be
+	// determined fails the plan rather than being planned blindly.
+	installed, err := p.featureInstalled(name)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error checking windows feature %s: %v", name, err)
 	}
 
-	if state == "installed" && installed {
-		// Feature is already installed
-		result.Status = "unchanged"
-	} else if state == "removed" && !installed {
-		// Feature is already removed
-		result.Status = "unchanged"
+	// Only a feature whose state differs from the desired one is planned
+	currentState := "removed"
+	if installed {
+		currentState = "installed"
+	}
+	if currentState != state {
+		result.Status = "planned"
+		result.addDiff("state", currentState, state)
 	}
 
 	return result, nil
 }
 
+// DesiredAttributes translates an intent into windows_feature attributes
+func (p *WindowsFeatureProvider) DesiredAttributes(intent Intent, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	return intentAttributes(intent, attributes, map[string]interface{}{"state": "removed"})
+}
+
 // Apply installs or removes a Windows feature
 func (p *WindowsFeatureProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
 	// Only valid on Windows
@@ -171,7 +208,7 @@ func (p *WindowsFeatureProvider) Apply(ctx context.Context, state *ResourceState
 	}
 
 	// Check current state
-	installed, err := p.isFeatureInstalled(name)
+	installed, err := p.featureInstalled(name)
 	if err != nil {
 		result.Status = "failed"
 		result.Error = err
@@ -186,6 +223,7 @@ func (p *WindowsFeatureProvider) Apply(ctx context.Context, state *ResourceState
 			return result, err
 		}
 		result.Status = "created"
+		result.Changed = true
 	} else if desiredState == "removed" && installed {
 		// Remove the feature
 		if err := p.removeFeature(name); err != nil {
@@ -194,6 +232,7 @@ func (p *WindowsFeatureProvider) Apply(ctx context.Context, state *ResourceState
 			return result, err
 		}
 		result.Status = "deleted"
+		result.Changed = true
 	} else {
 		// No change needed
 		result.Status = "unchanged"
@@ -2,7 +2,9 @@ package providers
 
 import (
 	"context"
+	"fmt"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -137,6 +139,70 @@ func TestWindowsFeatureProvider_Plan(t *testing.T) {
 	}
 }
 
+func TestWindowsFeatureProvider_Plan_Idempotent(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("Skipping Windows feature plan test on non-Windows platform")
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		installed  bool
+		detectErr  error
+		state      string
+		wantStatus string
+	}{
+		{"installed want installed", true, nil, "installed", "unchanged"},
+		{"removed want installed", false, nil, "installed", "planned"},
+		{"installed want removed", true, nil, "removed", "planned"},
+		{"removed want removed", false, nil, "removed", "unchanged"},
+		{"detection error", false, fmt.Errorf("feature is in state \"Enable Pending\""), "installed", ""},
+	}
+
+	for _, tt := range tests {
+		provider := NewWindowsFeatureProvider()
+		provider.featureInstalled = func(name string) (bool, error) {
+			return tt.installed, tt.detectErr
+		}
+
+		result, err := provider.Plan(ctx, nil, map[string]interface{}{"name": "Web-Server", "state": tt.state})
+		if tt.detectErr != nil {
+			if err == nil || !strings.Contains(err.Error(), "Enable Pending") {
+				t.Errorf("%s: expected the detection error, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Plan failed: %v", tt.name, err)
+		}
+		checkChanged(t, result)
+		if result.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.wantStatus, result.Status)
+		}
+	}
+}
+
+func TestParseDismFeatureState(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    bool
+		wantErr bool
+	}{
+		{"Feature Name : Web-Server\r\nState : Enabled\r\n", true, false},
+		{"Feature Name : Web-Server\r\nState : Disabled\r\n", false, false},
+		{"State : Disabled with Payload Removed\r\n", false, false},
+		{"State : Enable Pending\r\n", false, true},
+		{"Error: 0x800f080c\r\n", false, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDismFeatureState(tt.output)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDismFeatureState(%q) = %v, %v; want %v, error %v", tt.output, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
 func TestWindowsFeatureProvider_Apply(t *testing.T) {
 	if runtime.GOOS != "windows" {
 		t.Skip("Skipping Windows feature apply test on non-Windows platform")
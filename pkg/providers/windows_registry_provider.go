@@ -0,0 +1,366 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// registryTypes maps the type attribute to the reg.exe value type
+var registryTypes = map[string]string{
+	"string":        "REG_SZ",
+	"expand_string": "REG_EXPAND_SZ",
+	"multi_string":  "REG_MULTI_SZ",
+	"dword":         "REG_DWORD",
+	"qword":         "REG_QWORD",
+	"binary":        "REG_BINARY",
+}
+
+// registryRoots are the hive abbreviations and names reg.exe accepts
+var registryRoots = []string{
+	"HKLM", "HKCU", "HKCR", "HKU", "HKCC",
+	"HKEY_LOCAL_MACHINE", "HKEY_CURRENT_USER", "HKEY_CLASSES_ROOT", "HKEY_USERS", "HKEY_CURRENT_CONFIG",
+}
+
+// regQueryValue matches a value line of reg query output: an indented name,
+// type and data separated by runs of four spaces
+var regQueryValue = regexp.MustCompile(`^ {4}(.*?) {4}(REG_[A-Z_]+)(?: {4}(.*))?$`)
+
+// registryValue is a registry value as reported by reg query
+type registryValue struct {
+	Type string
+	Data string
+}
+
+// WindowsRegistryProvider implements Windows registry key and value management
+type WindowsRegistryProvider struct {
+	platform   *PlatformChecker
+	runCommand CommandRunner
+}
+
+// NewWindowsRegistryProvider creates a new Windows registry provider
+func NewWindowsRegistryProvider() *WindowsRegistryProvider {
+	return &WindowsRegistryProvider{
+		platform:   &PlatformChecker{},
+		runCommand: runCommand,
+	}
+}
+
+// Validate validates windows_registry resource attributes
+func (p *WindowsRegistryProvider) Validate(ctx context.Context, attributes map[string]interface{}) error {
+	// Only valid on Windows
+	if runtime.GOOS != "windows" {
+		return fmt.Errorf("windows_registry provider is only valid on Windows")
+	}
+
+	return validateRegistryAttributes(attributes)
+}
+
+// validateRegistryAttributes checks windows_registry attributes independently
+// of the platform
+func validateRegistryAttributes(attributes map[string]interface{}) error {
+	key, ok := attributes["key"]
+	if !ok {
+		return fmt.Errorf("windows_registry resource requires 'key' attribute")
+	}
+	keyStr, ok := key.(string)
+	if !ok {
+		return fmt.Errorf("windows_registry 'key' must be a string")
+	}
+	root, _, _ := strings.Cut(keyStr, `\`)
+	if !containsString(registryRoots, strings.ToUpper(root)) {
+		return fmt.Errorf("windows_registry 'key' must start with one of: %s", strings.Join(registryRoots[:5], ", "))
+	}
+
+	valueName, hasValueName := attributes["value_name"]
+	if hasValueName {
+		if _, ok := valueName.(string); !ok {
+			return fmt.Errorf("windows_registry 'value_name' must be a string")
+		}
+	}
+
+	valueType := "string"
+	if t, hasType := attributes["type"]; hasType {
+		typeStr, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("windows_registry 'type' must be a string")
+		}
+		if _, ok := registryTypes[typeStr]; !ok {
+			return fmt.Errorf("windows_registry 'type' must be one of: string, expand_string, multi_string, dword, qword, binary")
+		}
+		valueType = typeStr
+	}
+
+	state := presenceState(attributes)
+	if state != "present" && state != "absent" {
+		return fmt.Errorf("windows_registry 'state' must be one of: present, absent")
+	}
+
+	data, hasData := attributes["data"]
+	if state == "present" && hasValueName && !hasData {
+		return fmt.Errorf("windows_registry 'value_name' requires 'data'")
+	}
+	if hasData {
+		if _, err := registryData(valueType, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// registryData converts the data attribute to the form reg add takes and reg
+// query reports for a value type
+func registryData(valueType string, data interface{}) (string, error) {
+	switch valueType {
+	case "dword", "qword":
+		n, _, err := intAttribute(map[string]interface{}{"data": data}, "data")
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("windows_registry 'data' must be a non-negative number for type %s", valueType)
+		}
+		if valueType == "dword" && n > 0xFFFFFFFF {
+			return "", fmt.Errorf("windows_registry 'data' must fit in 32 bits for type dword")
+		}
+		return fmt.Sprintf("0x%x", n), nil
+	case "multi_string":
+		items, _, err := stringSliceAttribute(map[string]interface{}{"data": data}, "data")
+		if err != nil {
+			return "", fmt.Errorf("windows_registry %v", err)
+		}
+		return strings.Join(items, `\0`), nil
+	case "binary":
+		str, ok := data.(string)
+		if !ok || len(str)%2 != 0 || strings.Trim(strings.ToUpper(str), "0123456789ABCDEF") != "" {
+			return "", fmt.Errorf("windows_registry 'data' must be a hex string for type binary")
+		}
+		return strings.ToUpper(str), nil
+	default:
+		str, ok := data.(string)
+		if !ok {
+			return "", fmt.Errorf("windows_registry 'data' must be a string for type %s", valueType)
+		}
+		return str, nil
+	}
+}
+
+// registryValueArgs returns the reg arguments that select a named value, or
+// the key's default value when name is empty
+func registryValueArgs(name string) []string {
+	if name == "" {
+		return []string{"/ve"}
+	}
+	return []string{"/v", name}
+}
+
+// registryTarget reads the key, value name and value type from attributes
+func registryTarget(attributes map[string]interface{}) (string, string, string) {
+	key := attributes["key"].(string)
+	valueName, _ := attributes["value_name"].(string)
+	valueType, ok := attributes["type"].(string)
+	if !ok {
+		valueType = "string"
+	}
+	return key, valueName, valueType
+}
+
+// managesValue reports whether a resource manages a value rather than only
+// the key: either a value is named or data is set for the default value
+func managesValue(attributes map[string]interface{}) bool {
+	_, hasValueName := attributes["value_name"]
+	_, hasData := attributes["data"]
+	return hasValueName || hasData
+}
+
+// regQueryCommand builds the command that reads a value, or checks that the
+// key exists when the resource doesn't manage a value
+func regQueryCommand(attributes map[string]interface{}) []string {
+	key, valueName, _ := registryTarget(attributes)
+	command := []string{"reg", "query", key}
+	if managesValue(attributes) {
+		command = append(command, registryValueArgs(valueName)...)
+	}
+	return command
+}
+
+// regAddCommand builds the command that creates the key and sets its value
+func regAddCommand(attributes map[string]interface{}) ([]string, error) {
+	key, valueName, valueType := registryTarget(attributes)
+	command := []string{"reg", "add", key}
+
+	if value, hasData := attributes["data"]; hasData {
+		data, err := registryData(valueType, value)
+		if err != nil {
+			return nil, err
+		}
+		command = append(command, registryValueArgs(valueName)...)
+		command = append(command, "/t", registryTypes[valueType], "/d", data)
+	}
+
+	return append(command, "/f"), nil
+}
+
+// regDeleteCommand builds the command that deletes the value, or the whole
+// key when no value is named
+func regDeleteCommand(attributes map[string]interface{}) []string {
+	key, valueName, _ := registryTarget(attributes)
+	command := []string{"reg", "delete", key}
+	if valueName != "" {
+		command = append(command, "/v", valueName)
+	}
+	return append(command, "/f")
+}
+
+// parseRegQuery reads the values listed in reg query output, keyed by name.
+// The default value is keyed by "".
+func parseRegQuery(output string) map[string]registryValue {
+	values := make(map[string]registryValue)
+	for _, line := range strings.Split(output, "\n") {
+		match := regQueryValue.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			continue
+		}
+		name := match[1]
+		if name == "(Default)" {
+			name = ""
+		}
+		values[name] = registryValue{Type: match[2], Data: match[3]}
+	}
+	return values
+}
+
+// registryDrift returns the attributes that differ between the registry and
+// the desired state. A missing key or value is reported as "state".
+func (p *WindowsRegistryProvider) registryDrift(ctx context.Context, attributes map[string]interface{}) ([]string, error) {
+	_, valueName, valueType := registryTarget(attributes)
+	state := presenceState(attributes)
+
+	// reg query fails when the key or value doesn't exist
+	query := regQueryCommand(attributes)
+	output, err := p.runCommand(ctx, query[0], query[1:]...)
+	exists := err == nil
+
+	var current registryValue
+	if exists && managesValue(attributes) {
+		current, exists = parseRegQuery(string(output))[valueName]
+	}
+
+	if state == "absent" {
+		if exists {
+			return []string{"state"}, nil
+		}
+		return nil, nil
+	}
+	if !exists {
+		return []string{"state"}, nil
+	}
+
+	value, hasData := attributes["data"]
+	if !hasData {
+		return nil, nil
+	}
+
+	var changes []string
+	if current.Type != registryTypes[valueType] {
+		changes = append(changes, "type")
+	}
+	data, err := registryData(valueType, value)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(current.Data, data) {
+		changes = append(changes, "data")
+	}
+	return changes, nil
+}
+
+// Plan determines what changes would be made to a registry key or value
+func (p *WindowsRegistryProvider) Plan(ctx context.Context, current, desired map[string]interface{}) (*ResourceState, error) {
+	// Only valid on Windows
+	if runtime.GOOS != "windows" {
+		return nil, fmt.Errorf("windows_registry provider is only valid on Windows")
+	}
+
+	key, valueName, _ := registryTarget(desired)
+
+	result := &ResourceState{
+		Type:       "windows_registry",
+		Name:       strings.TrimSuffix(key+`\`+valueName, `\`),
+		Attributes: desired,
+		Status:     "unchanged",
+	}
+
+	changes, err := p.registryDrift(ctx, desired)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		result.Status = "planned"
+		result.Changes = changes
+	}
+
+	return result, nil
+}
+
+// Apply sets or deletes a registry key or value
+func (p *WindowsRegistryProvider) Apply(ctx context.Context, state *ResourceState) (*ResourceState, error) {
+	// Only valid on Windows
+	if runtime.GOOS != "windows" {
+		return nil, fmt.Errorf("windows_registry provider is only valid on Windows")
+	}
+
+	result := &ResourceState{
+		Type:       state.Type,
+		Name:       state.Name,
+		Attributes: state.Attributes,
+		Status:     "unchanged",
+	}
+
+	changes, err := p.registryDrift(ctx, state.Attributes)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+	if len(changes) == 0 {
+		return result, nil
+	}
+
+	var command []string
+	if presenceState(state.Attributes) == "absent" {
+		command = regDeleteCommand(state.Attributes)
+		result.Status = "deleted"
+	} else {
+		command, err = regAddCommand(state.Attributes)
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err
+			return result, err
+		}
+		result.Status = "updated"
+		if changes[0] == "state" {
+			result.Status = "created"
+		}
+	}
+
+	if err := runCommands(ctx, p.runCommand, [][]string{command}); err != nil {
+		result.Status = "failed"
+		result.Error = err
+		return result, err
+	}
+
+	result.Changed = true
+	result.Changes = changes
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateRegistryAttributes(t *testing.T) {
+	tests := []struct {
+		name    string
+		attrs   map[string]interface{}
+		wantErr bool
+	}{
+		{"key only", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`}, false},
+		{"string value", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`, "value_name": "Version", "data": "1.2.3"}, false},
+		{"dword value", map[string]interface{}{"key": `HKCU\Software\Example`, "value_name": "Enabled", "type": "dword", "data": int64(1)}, false},
+		{"multi string value", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`, "value_name": "Paths", "type": "multi_string", "data": []string{"a", "b"}}, false},
+		{"binary value", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`, "value_name": "Blob", "type": "binary", "data": "0aff"}, false},
+		{"remove value", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`, "value_name": "Version", "state": "absent"}, false},
+		{"missing key", map[string]interface{}{"value_name": "Version", "data": "1"}, true},
+		{"unknown root", map[string]interface{}{"key": `HKXX\SOFTWARE\Example`}, true},
+		{"value without data", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`, "value_name": "Version"}, true},
+		{"invalid type", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`, "value_name": "V", "type": "float", "data": "1"}, true},
+		{"dword not a number", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`, "value_name": "V", "type": "dword", "data": "yes"}, true},
+		{"dword too large", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`, "value_name": "V", "type": "dword", "data": int64(1) << 33}, true},
+		{"binary not hex", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`, "value_name": "V", "type": "binary", "data": "xyz"}, true},
+		{"invalid state", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`, "state": "gone"}, true},
+	}
+
+	for _, tt := range tests {
+		err := validateRegistryAttributes(tt.attrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestRegistryCommands(t *testing.T) {
+	tests := []struct {
+		name       string
+		attrs      map[string]interface{}
+		wantQuery  []string
+		wantAdd    []string
+		wantDelete []string
+	}{
+		{"string value", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`, "value_name": "Version", "data": "1.2.3"},
+			[]string{"reg", "query", `HKLM\SOFTWARE\Example`, "/v", "Version"},
+			[]string{"reg", "add", `HKLM\SOFTWARE\Example`, "/v", "Version", "/t", "REG_SZ", "/d", "1.2.3", "/f"},
+			[]string{"reg", "delete", `HKLM\SOFTWARE\Example`, "/v", "Version", "/f"}},
+		{"dword value", map[string]interface{}{"key": `HKCU\Software\Example`, "value_name": "Enabled", "type": "dword", "data": int64(255)},
+			[]string{"reg", "query", `HKCU\Software\Example`, "/v", "Enabled"},
+			[]string{"reg", "add", `HKCU\Software\Example`, "/v", "Enabled", "/t", "REG_DWORD", "/d", "0xff", "/f"},
+			[]string{"reg", "delete", `HKCU\Software\Example`, "/v", "Enabled", "/f"}},
+		{"default value", map[string]interface{}{"key": `HKCR\.example`, "type": "multi_string", "data": []string{"a", "b"}},
+			[]string{"reg", "query", `HKCR\.example`, "/ve"},
+			[]string{"reg", "add", `HKCR\.example`, "/ve", "/t", "REG_MULTI_SZ", "/d", `a\0b`, "/f"},
+			[]string{"reg", "delete", `HKCR\.example`, "/f"}},
+		{"key only", map[string]interface{}{"key": `HKLM\SOFTWARE\Example`},
+			[]string{"reg", "query", `HKLM\SOFTWARE\Example`},
+			[]string{"reg", "add", `HKLM\SOFTWARE\Example`, "/f"},
+			[]string{"reg", "delete", `HKLM\SOFTWARE\Example`, "/f"}},
+	}
+
+	for _, tt := range tests {
+		if query := regQueryCommand(tt.attrs); !reflect.DeepEqual(query, tt.wantQuery) {
+			t.Errorf("%s: expected query %v, got %v", tt.name, tt.wantQuery, query)
+		}
+		add, err := regAddCommand(tt.attrs)
+		if err != nil {
+			t.Fatalf("%s: regAddCommand failed: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(add, tt.wantAdd) {
+			t.Errorf("%s: expected add %v, got %v", tt.name, tt.wantAdd, add)
+		}
+		if del := regDeleteCommand(tt.attrs); !reflect.DeepEqual(del, tt.wantDelete) {
+			t.Errorf("%s: expected delete %v, got %v", tt.name, tt.wantDelete, del)
+		}
+	}
+}
+
+func TestParseRegQuery(t *testing.T) {
+	output := "\r\nHKEY_LOCAL_MACHINE\\SOFTWARE\\Example\r\n" +
+		"    (Default)    REG_SZ    \r\n" +
+		"    Version    REG_SZ    1.2.3\r\n" +
+		"    Enabled    REG_DWORD    0x1\r\n" +
+		"    Install Path    REG_EXPAND_SZ    %ProgramFiles%\\Example\r\n\r\n"
+
+	want := map[string]registryValue{
+		"":             {Type: "REG_SZ", Data: ""},
+		"Version":      {Type: "REG_SZ", Data: "1.2.3"},
+		"Enabled":      {Type: "REG_DWORD", Data: "0x1"},
+		"Install Path": {Type: "REG_EXPAND_SZ", Data: `%ProgramFiles%\Example`},
+	}
+	if got := parseRegQuery(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}